@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/abhishek/dmon-cli/internal/auto"
+	"github.com/abhishek/dmon-cli/internal/dock"
+	"github.com/abhishek/dmon-cli/internal/lid"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/power"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var autoCmd = &cobra.Command{
+	Use:   "auto",
+	Short: "Apply the layout that suits the displays, lid, power source and dock",
+	Long: `Pick and apply a layout from what is connected, whether the laptop lid is
+closed, whether it runs on battery and which dock it sits in. A saved
+profile with conditions that hold (see --when-power and --when-dock of
+'dmon profile save') comes first; otherwise:
+
+  internal display only         single
+  external display(s) only      external only, first one primary
+  both, lid closed              external only, internal turned off
+  both, lid open                dual, or dual-profile under [auto]
+
+The lid state comes from /proc/acpi/button/lid/*/state, or logind's
+LidClosed property when there are no such files (lid under [auto] picks
+one: auto, procfs, logind or none). The power source comes from
+/sys/class/power_supply; [power] picks the resolution mode and caps the
+refresh rate of the layouts above on battery and on AC. Docks are the USB
+and Thunderbolt devices 'dmon dock' lists. 'dmon watch' does this whenever
+the situation changes.`,
+	Example: `  dmon auto
+  dmon --i3 auto`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source, err := newLidSource()
+		if err != nil {
+			return err
+		}
+		rules, err := autoRules()
+		if err != nil {
+			return err
+		}
+		situation, err := currentSituation(getContext(), source, rules)
+		if err != nil {
+			return err
+		}
+		return applyAuto(getContext(), situation, rules)
+	},
+}
+
+// newLidSource builds the lid source the config asks for.
+func newLidSource() (lid.Source, error) {
+	source, err := lid.New(cfg.Auto.Lid, cfg.Auto.LidRoot)
+	if err != nil {
+		return nil, fmt.Errorf("config %s: auto: %w", cfg.Path(), err)
+	}
+	return source, nil
+}
+
+// powerSupplies reads the power source from the configured sysfs root.
+func powerSupplies() power.Sysfs {
+	return power.NewSysfs(cfg.Power.Root)
+}
+
+// dockScanner lists USB and Thunderbolt devices under the configured roots.
+func dockScanner() dock.Scanner {
+	return dock.NewScanner(cfg.Dock.USBRoot, cfg.Dock.ThunderboltRoot)
+}
+
+// currentSituation detects the displays, reads the lid and the power
+// source, and looks for the docks the rules' profiles name. An unreadable
+// lid counts as unknown, which decides like an open one; an unreadable
+// power source decides like AC.
+func currentSituation(ctx context.Context, source lid.Source, rules auto.Rules) (auto.Situation, error) {
+	displays, err := svc.DetectDisplays(ctx)
+	if err != nil {
+		return auto.Situation{}, fmt.Errorf("display detection failed: %w", err)
+	}
+
+	state, err := source.State(ctx)
+	if err != nil {
+		log.WithError(err).Warn("Failed to read the lid state")
+	}
+	supply, err := powerSupplies().State()
+	if err != nil {
+		log.WithError(err).Warn("Failed to read the power source")
+	}
+	situation := auto.Situation{Displays: displays, Lid: state, Power: supply}
+
+	if specs := auto.DockSpecs(rules.Profiles); len(specs) > 0 {
+		devices, err := dockScanner().Devices()
+		if err != nil {
+			log.WithError(err).Warn("Failed to look for docks")
+		}
+		situation.Docks = dock.Filter(devices, specs)
+	}
+	return situation, nil
+}
+
+// autoRules collects the decision rules from the config and the saved
+// profiles with conditions. Profiles that fail to load are skipped.
+func autoRules() (auto.Rules, error) {
+	rules := auto.Rules{DualProfile: cfg.Auto.DualProfile}
+
+	var err error
+	if rules.Battery, err = powerSettings(cfg.Power.BatteryMode, cfg.Power.BatteryMaxRate); err != nil {
+		return rules, err
+	}
+	if rules.AC, err = powerSettings(cfg.Power.ACMode, cfg.Power.ACMaxRate); err != nil {
+		return rules, err
+	}
+	if cfg.Power.BatteryMode != "" || cfg.Power.ACMode != "" || cfg.Power.BatteryMaxRate != 0 || cfg.Power.ACMaxRate != 0 {
+		rules.Battery.HighestRate, rules.AC.HighestRate = true, true
+	}
+
+	store := profileStore()
+	names, err := store.List()
+	if err != nil {
+		return rules, err
+	}
+	for _, name := range names {
+		p, err := store.Load(name)
+		if err != nil {
+			log.WithError(err).WithField("profile", name).Warn("Skipping invalid profile")
+			continue
+		}
+		if !p.When.IsZero() {
+			rules.Profiles = append(rules.Profiles, p)
+		}
+	}
+	return rules, nil
+}
+
+func powerSettings(mode string, maxRate float64) (auto.Settings, error) {
+	settings := auto.Settings{MaxRate: maxRate}
+	if maxRate < 0 {
+		return settings, fmt.Errorf("config %s: power: refresh rate cap %g is negative", cfg.Path(), maxRate)
+	}
+	if mode != "" {
+		m, err := layoutPlanner.ParseMode(mode)
+		if err != nil {
+			return settings, fmt.Errorf("config %s: power: %w", cfg.Path(), err)
+		}
+		settings.Mode = m
+	}
+	return settings, nil
+}
+
+// applyAuto applies the layout auto.Decide picks for situation.
+func applyAuto(ctx context.Context, situation auto.Situation, rules auto.Rules) error {
+	decision, err := auto.Decide(situation, rules)
+	if err != nil {
+		return err
+	}
+	log.WithFields(logrus.Fields{
+		"action": decision.Action,
+		"lid":    situation.Lid,
+		"power":  situation.Power,
+	}).Info("Choosing layout")
+
+	var result *models.ConfigResult
+	if decision.Action == auto.Profile {
+		p, loadErr := profileStore().Load(decision.Profile)
+		if loadErr != nil {
+			return loadErr
+		}
+		result, err = svc.ApplyProfile(ctx, p)
+	} else {
+		result, err = svc.ApplyConfig(ctx, decision.Config)
+	}
+	if err != nil {
+		return fmt.Errorf("%s layout failed: %w", decision.Action, err)
+	}
+
+	label := decision.Action.String()
+	if decision.Action == auto.Profile {
+		label = "profile " + decision.Profile
+	}
+	fmt.Printf("✓ Applied %s layout (%s)\n", label, decision.Reason)
+	for _, d := range result.Displays {
+		if d.Active {
+			fmt.Printf("  ▸ %s (%s) → %s\n", d.ID, d.Type, d.Resolution)
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(autoCmd)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/abhishek/dmon-cli/internal/brightness"
+	"github.com/spf13/cobra"
+)
+
+var backlightRoot string
+
+var brightnessCmd = &cobra.Command{
+	Use:   "brightness [output] [level]",
+	Short: "Show or change display brightness",
+	Long: `Show or change display brightness.
+
+Internal panels are driven through the kernel backlight in /sys/class/backlight
+(via logind when the file is not writable). External displays, and panels
+without a backlight, are dimmed in software with xrandr --brightness.
+
+Levels:
+  50%          - Absolute brightness
+  +10%, 10%+   - Brighter by 10 points
+  -10%, 10%-   - Dimmer by 10 points (use "--" before -10%)
+
+Without an output, a level applies to the internal panel, or to every
+active display when the internal panel is off.`,
+	Example: `  dmon brightness
+  dmon brightness 50%
+  dmon brightness 10%-
+  dmon brightness -- -10%
+  dmon brightness HDMI-1 +10%`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var output string
+		var change *brightness.Change
+
+		switch {
+		case len(args) == 2:
+			output = args[0]
+			c, err := brightness.ParseChange(args[1])
+			if err != nil {
+				return err
+			}
+			change = &c
+		case len(args) == 1 && brightness.IsChange(args[0]):
+			c, _ := brightness.ParseChange(args[0])
+			change = &c
+		case len(args) == 1:
+			output = args[0]
+		}
+
+		root := backlightRoot
+		if root == "" {
+			root = cfg.Backlight.Root
+		}
+		svc.WithBacklight(brightness.NewBacklight(root))
+
+		results, err := svc.Brightness(getContext(), output, change)
+		for _, r := range results {
+			via := string(r.Method)
+			if r.Device != "" {
+				via = fmt.Sprintf("%s %s", r.Method, r.Device)
+			}
+			fmt.Printf("▸ %s (%s): %.0f%% [%s]\n", r.ID, r.Type, r.Percent, via)
+		}
+		if err != nil {
+			return fmt.Errorf("brightness failed: %w", err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	brightnessCmd.Flags().StringVar(&backlightRoot, "backlight-root", "", "Backlight sysfs directory (default "+brightness.DefaultRoot+")")
+	rootCmd.AddCommand(brightnessCmd)
+}
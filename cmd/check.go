@@ -11,10 +11,11 @@ var checkCmd = &cobra.Command{
 	Short: "Show current xrandr monitor layout",
 	Long: `Display the current monitor configuration including active displays,
 their resolutions, and which display is set as primary.`,
-	Example: `  dmon check`,
-	Args:    cobra.NoArgs,
+	Example:     `  dmon check`,
+	Annotations: map[string]string{remoteAnnotation: "true"},
+	Args:        cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		layout, err := svc.CheckDisplays(getContext())
+		layout, err := layouts.CheckDisplays(getContext())
 		if err != nil {
 			return fmt.Errorf("failed to check displays: %w", err)
 		}
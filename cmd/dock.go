@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/abhishek/dmon-cli/internal/dock"
+	"github.com/spf13/cobra"
+)
+
+var dockCmd = &cobra.Command{
+	Use:   "dock",
+	Short: "List USB and Thunderbolt devices that can identify a dock",
+	Long: `List the attached Thunderbolt and USB devices (without root hubs) with
+the VENDOR:PRODUCT:SERIAL ID profiles can require:
+
+  dmon profile save desk3 --when-dock 17ef:30b4:1S40AY0090EU
+
+'dmon auto' and 'dmon watch' then pick that profile only while that very
+dock is attached, which tells apart hot-desks whose monitors are the same
+model. Leave the serial out to match any dock of the model. A dock usually
+shows up as several devices; pick the one named after it, or its hub.
+
+Devices are read from /sys/bus/thunderbolt/devices and /sys/bus/usb/devices
+(usb-root and thunderbolt-root under [dock] change that).`,
+	Example:     "  dmon dock",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{noBackendAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		devices, err := dockScanner().Devices()
+		if err != nil {
+			return err
+		}
+		if len(devices) == 0 {
+			fmt.Println("No USB or Thunderbolt devices found")
+			return nil
+		}
+
+		rules, err := autoRules()
+		if err != nil {
+			return err
+		}
+
+		for _, d := range devices {
+			name := d.Name
+			if name == "" {
+				name = "unnamed device"
+			}
+			fmt.Printf("▸ %s %s (%s %s)\n", d.Spec(), name, d.Bus, d.Path)
+			for _, p := range rules.Profiles {
+				spec, err := dock.ParseSpec(p.When.Dock)
+				if err == nil && spec.Matches(d) {
+					fmt.Printf("  ▸ Picks profile %s\n", p.Name)
+				}
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dockCmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/abhishek/dmon-cli/internal/backend"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose which display backends are usable",
+	Long: `Probe every display backend and explain why it was accepted or rejected.
+Also shows which backend dmon would use and where that choice came from.`,
+	Example: `  dmon doctor
+  dmon doctor --backend x11`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{noBackendAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Println("Session:")
+		for _, key := range []string{"XDG_SESSION_TYPE", "DISPLAY", "WAYLAND_DISPLAY", "XAUTHORITY"} {
+			value := os.Getenv(key)
+			if value == "" {
+				value = "(unset)"
+			}
+			fmt.Printf("  %-17s %s\n", key, value)
+		}
+		fmt.Printf("  %-17s %s\n", "config", cfg.Path())
+		fmt.Println()
+
+		selected, source := selectedBackend(), backendSource()
+
+		fmt.Println("Backends:")
+		firstAccepted := ""
+		for _, r := range registry.ProbeAll(getContext()) {
+			marker := "✗"
+			if r.Accepted {
+				marker = "✓"
+				if firstAccepted == "" && !r.Manual {
+					firstAccepted = r.Name
+				}
+			}
+			manual := ""
+			if r.Manual {
+				manual = " (manual)"
+			}
+			fmt.Printf("  %s %s%s: %s\n", marker, r.Name, manual, r.Reason)
+		}
+		fmt.Println()
+
+		if selected == backend.Auto {
+			if firstAccepted == "" {
+				fmt.Println("No usable backend found")
+				return nil
+			}
+			selected = firstAccepted
+		} else if _, err := registry.Lookup(selected); err != nil {
+			return err
+		}
+		fmt.Printf("Selected backend: %s (%s)\n", selected, source)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
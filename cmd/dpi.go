@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/abhishek/dmon-cli/internal/dpi"
+	"github.com/spf13/cobra"
+)
+
+var dpiCmd = &cobra.Command{
+	Use:   "dpi [auto|value]",
+	Short: "Show or set the font DPI (Xft.dpi)",
+	Long: `Show each output's DPI, worked out from its mode and the physical size it
+reports, and the Xft.dpi that follows from the layout: the primary output's
+DPI rounded to a multiple of 24, or 96 when its size is unknown.
+
+'dmon dpi auto' sets that value and 'dmon dpi 144' a fixed one, with xrdb
+-merge, and in xsettingsd's config too when xsettingsd = true under [dpi].
+With enable = true under [dpi] this happens after every layout change; a
+profile's dpi key overrides the computed value. Applications pick up
+Xft.dpi when they start.`,
+	Example: `  dmon dpi
+  dmon dpi auto
+  dmon dpi 144`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		layout, err := svc.CheckDisplays(getContext())
+		if err != nil {
+			return err
+		}
+		effective, from := dpi.Effective(layout)
+
+		if len(args) == 0 {
+			for _, d := range layout.Displays {
+				if !d.Connected || d.CurrentMode == nil {
+					continue
+				}
+				measured, ok := dpi.Of(d)
+				switch {
+				case ok:
+					fmt.Printf("  ▸ %s: %.0f DPI (%dx%d on %dmm x %dmm)\n", d.ID, measured, d.CurrentMode.Width, d.CurrentMode.Height, d.WidthMM, d.HeightMM)
+				default:
+					fmt.Printf("  ▸ %s: unknown (reports %dmm x %dmm)\n", d.ID, d.WidthMM, d.HeightMM)
+				}
+			}
+			fmt.Printf("\nXft.dpi for this layout: %d (from %s)\n", effective, from)
+			return nil
+		}
+
+		value := effective
+		if args[0] != "auto" {
+			if value, err = strconv.Atoi(args[0]); err != nil || value <= 0 {
+				return fmt.Errorf("invalid DPI %q (use auto or a positive number)", args[0])
+			}
+		}
+		if err := newDPISetter().Set(getContext(), value); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Xft.dpi set to %d\n", value)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dpiCmd)
+}
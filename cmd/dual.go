@@ -16,23 +16,25 @@ Modes:
   preset  - Default resolution (1920x1200 internal, 1920x1080 external)
   low     - Reduced resolution (1600x1000 internal, 1280x720 external)
   highest - Highest available resolution for each display
+  <name>  - A preset defined under [presets.<name>] in the config file
 
 If no mode is specified, 'preset' is used.`,
 	Example: `  dmon dual
   dmon dual low
   dmon dual highest`,
-	Args: cobra.MaximumNArgs(1),
+	Annotations: map[string]string{remoteAnnotation: "true"},
+	Args:        cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		mode := models.ModePreset
 		if len(args) > 0 {
 			var err error
-			mode, err = models.ParseResolutionMode(args[0])
+			mode, err = layoutPlanner.ParseMode(args[0])
 			if err != nil {
 				return err
 			}
 		}
 
-		result, err := svc.SetupDual(getContext(), mode)
+		result, err := layouts.SetupDual(getContext(), mode)
 		if err != nil {
 			return fmt.Errorf("dual display setup failed: %w", err)
 		}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/abhishek/dmon-cli/internal/events"
+	"github.com/spf13/cobra"
+)
+
+// defaultPollInterval is how often the layout is read when the backend
+// cannot report changes itself.
+const defaultPollInterval = 2 * time.Second
+
+var (
+	eventsTypes    []string
+	eventsInterval time.Duration
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Stream display changes as newline-delimited JSON",
+	Long: `Print one JSON object per line for every display change, until interrupted:
+output-connected, output-disconnected, mode-changed (mode, rate, position or
+rotation), primary-changed, profile-applied and configure-failed. Each event
+carries "before" and "after" layouts with just the outputs it is about.
+
+Locally, changes are followed through RandR notifications with the x11
+backend and polled otherwise; profile-applied and configure-failed are only
+seen for changes made through a 'dmon serve' daemon, so use --remote to get
+every event. Logs go to stderr.`,
+	Example: `  dmon events
+  dmon --remote events --type output-connected --type output-disconnected
+  dmon events | jq -r 'select(.type == "primary-changed") | .output'`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{remoteAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// stdout carries events only.
+		log.SetOutput(os.Stderr)
+
+		wanted := map[events.Type]bool{}
+		for _, t := range eventsTypes {
+			if !slices.Contains(events.Types, events.Type(t)) {
+				return fmt.Errorf("unknown event type %q (valid: %v)", t, events.Types)
+			}
+			wanted[events.Type(t)] = true
+		}
+
+		ctx, stop := signal.NotifyContext(getContext(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		var stream <-chan events.Event
+		watchErr := make(chan error, 1)
+		if remote != nil {
+			var err error
+			if stream, err = remote.Subscribe(ctx); err != nil {
+				return err
+			}
+		} else {
+			bus := events.NewBus(log)
+			svc.WithEvents(bus)
+			ch, unsubscribe := bus.Subscribe()
+			defer unsubscribe()
+			stream = ch
+			go func() {
+				watchErr <- svc.Watch(ctx, eventsInterval)
+			}()
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		for {
+			select {
+			case e, ok := <-stream:
+				if !ok {
+					if ctx.Err() != nil {
+						return nil
+					}
+					return fmt.Errorf("the dmon server closed the event stream")
+				}
+				if len(wanted) > 0 && !wanted[e.Type] {
+					continue
+				}
+				if err := encoder.Encode(e); err != nil {
+					return err
+				}
+			case err := <-watchErr:
+				return err
+			}
+		}
+	},
+}
+
+func init() {
+	eventsCmd.Flags().StringArrayVar(&eventsTypes, "type", nil, "Only print events of this type (repeatable)")
+	eventsCmd.Flags().DurationVar(&eventsInterval, "interval", defaultPollInterval, "How often to poll when the backend cannot report changes")
+	rootCmd.AddCommand(eventsCmd)
+}
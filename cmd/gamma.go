@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/abhishek/dmon-cli/internal/gamma"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/spf13/cobra"
+)
+
+var gammaCmd = &cobra.Command{
+	Use:   "gamma [output] <value>",
+	Short: "Set the gamma curve of displays",
+	Long: `Set the gamma curve of one display, or of every active display.
+
+The value is one exponent for all channels (0.9) or one per channel
+(red:green:blue, e.g. 1.0:0.9:0.8). Values above 1 brighten the midtones.
+Use 1 to reset. This replaces any 'dmon night' colour temperature.`,
+	Example: `  dmon gamma 0.9
+  dmon gamma HDMI-1 1.0:0.9:0.8
+  dmon gamma 1`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var output string
+		if len(args) == 2 {
+			output = args[0]
+		}
+
+		g, err := gamma.ParseGamma(args[len(args)-1])
+		if err != nil {
+			return err
+		}
+
+		color := models.NeutralColor()
+		color.Gamma = g
+
+		displays, err := svc.SetColor(getContext(), output, color)
+		for _, d := range displays {
+			fmt.Printf("✓ %s gamma set to %.2f:%.2f:%.2f\n", d.ID, g.Red, g.Green, g.Blue)
+		}
+		if err != nil {
+			return fmt.Errorf("gamma failed: %w", err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(gammaCmd)
+}
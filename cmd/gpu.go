@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var gpuCmd = &cobra.Command{
+	Use:   "gpu",
+	Short: "Show GPUs (RandR providers) and link their outputs",
+	Long: `On hybrid laptops some ports, often HDMI, are wired to the discrete GPU.
+Their outputs only appear once that GPU is linked as an output sink of the
+GPU rendering the screen (xrandr --setprovideroutputsource). 'dmon gpu link'
+does that for every GPU that needs it; set auto-link under [gpu] in the
+config to do it before every layout change.`,
+}
+
+var gpuListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List GPUs and their capabilities",
+	Example: "  dmon gpu list",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		providers, err := svc.Providers(getContext())
+		if err != nil {
+			return err
+		}
+
+		if len(providers) == 0 {
+			fmt.Println("No providers found (RandR 1.4 or newer is needed)")
+			return nil
+		}
+
+		fmt.Printf("Found %d provider(s):\n\n", len(providers))
+		for _, p := range providers {
+			fmt.Printf("▸ %d: %s (id %#x)\n", p.Index, p.Name, p.ID)
+			fmt.Printf("  Capabilities: %s\n", p.Capabilities)
+			fmt.Printf("  CRTCs: %d, outputs: %d, linked providers: %d\n", p.CRTCs, p.Outputs, p.Associated)
+			fmt.Println()
+		}
+		return nil
+	},
+}
+
+var gpuLinkCmd = &cobra.Command{
+	Use:   "link",
+	Short: "Link GPUs with their own outputs to the rendering GPU",
+	Example: `  dmon gpu link
+  dmon gpu link && dmon dual`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source, linked, err := svc.LinkProviders(getContext())
+		for _, p := range linked {
+			fmt.Printf("  ▸ %s → outputs driven by %s\n", p.Name, source.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(linked) == 0 {
+			fmt.Println("✓ Nothing to link")
+			return nil
+		}
+		fmt.Printf("✓ Linked %d GPU(s)\n", len(linked))
+
+		displays, err := svc.DetectDisplays(getContext())
+		if err != nil {
+			return fmt.Errorf("display detection failed: %w", err)
+		}
+		fmt.Println("\nConnected displays:")
+		for _, d := range displays {
+			if d.Connected {
+				fmt.Printf("  ▸ %s (%s)\n", d.ID, d.Type)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	gpuCmd.AddCommand(gpuListCmd, gpuLinkCmd)
+	rootCmd.AddCommand(gpuCmd)
+}
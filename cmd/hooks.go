@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/abhishek/dmon-cli/internal/hooks"
+	"github.com/spf13/cobra"
+)
+
+var (
+	hooksProfile string
+	hooksOutput  string
+)
+
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "List and test the scripts run around layout changes",
+	Long: `Executable files in the hook directories run in name order, with the
+layout in DMON_* environment variables:
+
+  pre-apply.d      before dmon changes the layout
+  post-apply.d     after a layout change succeeded
+  on-connect.d     when an output is plugged in (while 'dmon serve' runs)
+  on-disconnect.d  when an output is unplugged (while 'dmon serve' runs)
+
+The directories are under ~/.config/dmon/hooks unless dir is set under
+[hooks] in the config. Each script is killed after the timeout (10s unless
+configured), and its output goes to the dmon log. --no-hooks, or
+disable = true under [hooks], turns hooks off.`,
+}
+
+var hooksListCmd = &cobra.Command{
+	Use:         "list",
+	Short:       "List hook scripts by stage",
+	Example:     "  dmon hooks list",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{noBackendAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		h, err := enabledHooks()
+		if err != nil {
+			return err
+		}
+
+		for _, stage := range hooks.Stages {
+			scripts, err := h.Scripts(stage)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("▸ %s (%s)\n", stage, h.Dir(stage))
+			if len(scripts) == 0 {
+				fmt.Println("  No scripts")
+			}
+			for _, script := range scripts {
+				fmt.Printf("  ▸ %s\n", filepath.Base(script))
+			}
+		}
+		return nil
+	},
+}
+
+var hooksRunCmd = &cobra.Command{
+	Use:   "run <stage>",
+	Short: "Run a stage's hooks with the current layout",
+	Example: `  dmon hooks run post-apply
+  dmon hooks run post-apply --profile desk
+  dmon hooks run on-connect --output HDMI-1`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"pre-apply", "post-apply", "on-connect", "on-disconnect"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stage, err := hooks.ParseStage(args[0])
+		if err != nil {
+			return err
+		}
+		h, err := enabledHooks()
+		if err != nil {
+			return err
+		}
+
+		layout, err := svc.CheckDisplays(getContext())
+		if err != nil {
+			return err
+		}
+		results := h.Run(getContext(), stage, hooks.Env(stage, layout, hooksProfile, hooksOutput))
+		if len(results) == 0 {
+			fmt.Printf("No %s hooks in %s\n", stage, h.Dir(stage))
+			return nil
+		}
+
+		failed := 0
+		for _, r := range results {
+			status := "ok"
+			if r.Err != nil {
+				status = r.Err.Error()
+				failed++
+			}
+			fmt.Printf("  ▸ %s: %s (%s)\n", filepath.Base(r.Script), status, r.Duration.Round(time.Millisecond))
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d %s hook(s) failed", failed, len(results), stage)
+		}
+		fmt.Printf("✓ Ran %d %s hook(s)\n", len(results), stage)
+		return nil
+	},
+}
+
+// enabledHooks returns the configured hooks, or an error when they are off.
+func enabledHooks() (*hooks.Hooks, error) {
+	h, err := newHooks()
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		return nil, fmt.Errorf("hooks are disabled (--no-hooks or disable under [hooks])")
+	}
+	return h, nil
+}
+
+func init() {
+	hooksRunCmd.Flags().StringVar(&hooksProfile, "profile", "", "Profile name to pass as DMON_PROFILE")
+	hooksRunCmd.Flags().StringVar(&hooksOutput, "output", "", "Output to pass as DMON_OUTPUT (for on-connect and on-disconnect)")
+	hooksCmd.AddCommand(hooksListCmd, hooksRunCmd)
+	rootCmd.AddCommand(hooksCmd)
+}
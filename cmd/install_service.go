@@ -0,0 +1,478 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/abhishek/dmon-cli/internal/config"
+	"github.com/abhishek/dmon-cli/internal/systemd"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serviceStatus    bool
+	serviceUninstall bool
+	serviceImportEnv bool
+	servicePrint     bool
+	serviceUdev      bool
+	serviceTarget    string
+	serviceUdevRule  string
+)
+
+var installServiceCmd = &cobra.Command{
+	Use:   "install-service",
+	Short: "Run 'dmon watch' as a systemd user service",
+	Long: `Write a systemd user unit that runs 'dmon watch' for the graphical session,
+enable it and start it. The unit runs this dmon binary with the --config,
+--backend, --i3 and --no-hooks flags given here.
+
+An X client needs DISPLAY and XAUTHORITY, which the systemd user manager
+does not have unless the session imports them. Desktop environments do, and
+start graphical-session.target afterwards; install-service imports them
+from the current shell once. Where nothing imports them (i3 or startx
+without a session target), run 'dmon install-service --import-env' from the
+session, e.g. in the i3 config:
+
+  exec --no-startup-id dmon install-service --import-env
+
+or install with --target default.target; the unit retries until the
+environment is there.
+
+--udev also writes a oneshot unit running 'dmon auto' and a udev rule that
+starts it on DRM change events (hotplug), for when watch is not running.
+The rule goes to /etc/udev/rules.d, which needs root: when that is not
+writable, the commands to install it are printed. The rule reaches the user
+manager with 'systemctl --machine=USER@.host', which needs systemd 248 or
+later. Installing again without --udev removes the unit and the rule.
+
+--status shows the units, the imported environment and the rule;
+--uninstall stops and removes everything install-service wrote.`,
+	Example: `  dmon install-service
+  dmon --i3 install-service --udev
+  dmon install-service --target default.target
+  dmon install-service --import-env
+  dmon install-service --status
+  dmon install-service --uninstall`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{noBackendAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := getContext()
+		manager := systemd.NewManager(log).WithRunner(commands)
+
+		switch {
+		case serviceStatus:
+			return serviceShowStatus(ctx, manager)
+		case serviceUninstall:
+			return serviceRemove(ctx, manager)
+		case serviceImportEnv:
+			return serviceImport(ctx, manager)
+		}
+
+		opts, err := serviceOptions()
+		if err != nil {
+			return err
+		}
+		username, err := currentUser()
+		if err != nil {
+			return err
+		}
+
+		if serviceUdev && !servicePrint {
+			if err := checkUdevSystemd(ctx, manager); err != nil {
+				return err
+			}
+		}
+
+		type file struct{ path, content string }
+		files := []file{{filepath.Join(systemd.UnitDir(), systemd.WatchUnit), systemd.WatchUnitFile(opts)}}
+		if serviceUdev {
+			files = append(files, file{filepath.Join(systemd.UnitDir(), systemd.AutoUnit), systemd.AutoUnitFile(opts)})
+		}
+
+		if servicePrint {
+			for _, f := range files {
+				fmt.Printf("# %s\n%s\n", f.path, f.content)
+			}
+			if serviceUdev {
+				fmt.Printf("# %s\n%s", udevRulePath(username), systemd.UdevRule(opts, username))
+			}
+			return nil
+		}
+
+		for _, f := range files {
+			if err := writeGenerated(f.path, f.content); err != nil {
+				return err
+			}
+		}
+		if err := manager.DaemonReload(ctx); err != nil {
+			return fmt.Errorf("failed to reload the systemd user manager: %w", err)
+		}
+		imported, err := manager.ImportEnvironment(ctx, systemd.SessionVars)
+		if err != nil {
+			return fmt.Errorf("failed to import the session environment: %w", err)
+		}
+		if err := manager.Enable(ctx, systemd.WatchUnit); err != nil {
+			return fmt.Errorf("failed to start %s: %w", systemd.WatchUnit, err)
+		}
+
+		fmt.Printf("✓ Installed and started %s\n", systemd.WatchUnit)
+		fmt.Printf("  ▸ Unit: %s\n", filepath.Join(systemd.UnitDir(), systemd.WatchUnit))
+		fmt.Printf("  ▸ Runs: %s watch\n", strings.Join(opts.Command, " "))
+		if len(imported) > 0 {
+			fmt.Printf("  ▸ Imported %s into the user manager\n", strings.Join(imported, ", "))
+		}
+		if os.Getenv("DISPLAY") == "" {
+			fmt.Println("  ▸ DISPLAY is not set here: run 'dmon install-service --import-env' from the graphical session")
+		}
+
+		if serviceUdev {
+			installUdevRule(ctx, udevRulePath(username), systemd.UdevRule(opts, username))
+		} else if err := removeHotplugStart(ctx, manager, udevRulePath(username)); err != nil {
+			return err
+		}
+
+		target := opts.Target
+		if !manager.IsActive(ctx, target) {
+			fmt.Printf("  ▸ %s is not active in this session, so the service will not start at login by itself.\n", target)
+			fmt.Println("    Add 'exec --no-startup-id dmon install-service --import-env' to your i3 config (or ~/.xprofile),")
+			fmt.Println("    or reinstall with --target default.target")
+		}
+		fmt.Printf("  ▸ Logs: journalctl --user -u %s\n", systemd.WatchUnit)
+		return nil
+	},
+}
+
+// serviceOptions describes the units for this dmon binary and the global
+// flags given on this command line.
+func serviceOptions() (systemd.Options, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return systemd.Options{}, fmt.Errorf("failed to find the dmon executable: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+
+	command := []string{exe}
+	if configPath != "" {
+		path, err := filepath.Abs(configPath)
+		if err != nil {
+			return systemd.Options{}, err
+		}
+		command = append(command, "--config", path)
+	}
+	if backendFlag != "" {
+		command = append(command, "--backend", backendFlag)
+	}
+	if i3Flag {
+		command = append(command, "--i3")
+	}
+	if noHooks {
+		command = append(command, "--no-hooks")
+	}
+
+	systemctl, err := exec.LookPath("systemctl")
+	if err != nil {
+		systemctl = "/usr/bin/systemctl"
+	}
+	return systemd.Options{Command: command, Target: serviceTarget, Systemctl: systemctl}, nil
+}
+
+func currentUser() (string, error) {
+	u, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the current user: %w", err)
+	}
+	return u.Username, nil
+}
+
+func udevRulePath(username string) string {
+	if serviceUdevRule != "" {
+		return serviceUdevRule
+	}
+	return systemd.UdevRulePath(username)
+}
+
+// stagedUdevRule is where the rule waits for root to install it.
+func stagedUdevRule(path string) string {
+	return filepath.Join(config.Dir(), filepath.Base(path))
+}
+
+// writeGenerated writes a generated file, refusing to replace a file of
+// the same name that dmon did not write.
+func writeGenerated(path, content string) error {
+	if _, err := os.Stat(path); err == nil && !systemd.IsGenerated(path) {
+		return fmt.Errorf("%s exists and was not written by dmon; move it away first", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// checkUdevSystemd refuses --udev on a systemd too old for the rule. An
+// unknown version is let through.
+func checkUdevSystemd(ctx context.Context, manager *systemd.Manager) error {
+	version, err := manager.Version(ctx)
+	if err != nil {
+		log.WithError(err).Debug("Failed to read the systemd version")
+		return nil
+	}
+	if version < systemd.MachineVersion {
+		return fmt.Errorf("--udev needs systemd %d or later for 'systemctl --user --machine=USER@.host', this is systemd %d; install without --udev to run only %s",
+			systemd.MachineVersion, version, systemd.WatchUnit)
+	}
+	return nil
+}
+
+// installUdevRule writes the rule and reloads udev, or, without the
+// permission to, stages it and prints the commands to run as root.
+func installUdevRule(ctx context.Context, path, rule string) {
+	err := writeGenerated(path, rule)
+	if errors.Is(err, fs.ErrPermission) {
+		staged := stagedUdevRule(path)
+		if err := writeGenerated(staged, rule); err != nil {
+			fmt.Printf("  ▸ Failed to write the udev rule: %v\n", err)
+			return
+		}
+		fmt.Println("  ▸ Install the udev rule as root:")
+		fmt.Printf("      sudo install -m 644 %s %s\n", staged, path)
+		fmt.Println("      sudo udevadm control --reload")
+		return
+	}
+	if err != nil {
+		fmt.Printf("  ▸ Failed to write the udev rule: %v\n", err)
+		return
+	}
+
+	fmt.Printf("  ▸ udev rule: %s\n", path)
+	if _, err := commands.Run(ctx, "udevadm", "control", "--reload"); err != nil {
+		log.WithError(err).Warn("Failed to reload udev rules")
+	}
+}
+
+func serviceImport(ctx context.Context, manager *systemd.Manager) error {
+	imported, err := manager.ImportEnvironment(ctx, systemd.SessionVars)
+	if err != nil {
+		return fmt.Errorf("failed to import the session environment: %w", err)
+	}
+	if len(imported) == 0 {
+		return fmt.Errorf("none of %s is set; run this from the graphical session", strings.Join(systemd.SessionVars, ", "))
+	}
+	fmt.Printf("✓ Imported %s into the user manager\n", strings.Join(imported, ", "))
+
+	if _, err := os.Stat(filepath.Join(systemd.UnitDir(), systemd.WatchUnit)); err != nil {
+		return nil
+	}
+	if err := manager.Restart(ctx, systemd.WatchUnit); err != nil {
+		return fmt.Errorf("failed to restart %s: %w", systemd.WatchUnit, err)
+	}
+	fmt.Printf("  ▸ Restarted %s\n", systemd.WatchUnit)
+	return nil
+}
+
+func serviceShowStatus(ctx context.Context, manager *systemd.Manager) error {
+	for _, unit := range []string{systemd.WatchUnit, systemd.AutoUnit} {
+		path := filepath.Join(systemd.UnitDir(), unit)
+		if _, err := os.Stat(path); err != nil {
+			if unit == systemd.WatchUnit {
+				fmt.Printf("▸ %s: not installed (run 'dmon install-service')\n", unit)
+			}
+			continue
+		}
+
+		status, err := manager.Status(ctx, unit)
+		if err != nil {
+			return fmt.Errorf("failed to read the state of %s: %w", unit, err)
+		}
+		state := fmt.Sprintf("%s (%s)", status.ActiveState, status.SubState)
+		if status.ActiveState == "active" && status.Since != "" {
+			state += " since " + status.Since
+		}
+		if status.Result != "" && status.Result != "success" {
+			state += ", last result " + status.Result
+		}
+		fmt.Printf("▸ %s: %s, %s\n", unit, state, status.UnitFileState)
+		fmt.Printf("  ▸ Unit: %s\n", path)
+	}
+
+	env, err := manager.Environment(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read the user manager's environment: %w", err)
+	}
+	var session []string
+	for _, v := range systemd.SessionVars {
+		if value := env[v]; value != "" {
+			session = append(session, v+"="+value)
+		}
+	}
+	if len(session) == 0 {
+		fmt.Println("▸ Session environment: none imported (run 'dmon install-service --import-env' from the graphical session)")
+	} else {
+		fmt.Printf("▸ Session environment: %s\n", strings.Join(session, " "))
+	}
+
+	username, err := currentUser()
+	if err != nil {
+		return err
+	}
+	rule := udevRulePath(username)
+	switch {
+	case systemd.IsGenerated(rule):
+		fmt.Printf("▸ udev rule: %s\n", rule)
+	case systemd.IsGenerated(stagedUdevRule(rule)):
+		fmt.Printf("▸ udev rule: waiting in %s to be installed as %s\n", stagedUdevRule(rule), rule)
+	default:
+		fmt.Println("▸ udev rule: not installed")
+	}
+	return nil
+}
+
+func serviceRemove(ctx context.Context, manager *systemd.Manager) error {
+	removed, kept, err := removeUnits(ctx, manager, systemd.WatchUnit, systemd.AutoUnit)
+	if err != nil {
+		return err
+	}
+	for _, path := range kept {
+		fmt.Printf("  ▸ Left %s alone: it was not written by dmon\n", path)
+	}
+
+	username, err := currentUser()
+	if err != nil {
+		return err
+	}
+	rule := udevRulePath(username)
+	removedRules, needsRoot, err := removeUdevRule(ctx, rule)
+	if err != nil {
+		return err
+	}
+	removed = append(removed, removedRules...)
+
+	for _, path := range removed {
+		fmt.Printf("✓ Removed %s\n", path)
+	}
+	if needsRoot {
+		printRootRemoval(rule)
+	}
+	if len(removed) == 0 && len(kept) == 0 && !needsRoot {
+		fmt.Println("Nothing to uninstall")
+	}
+	return nil
+}
+
+// removeHotplugStart removes the auto unit and the udev rule an earlier
+// 'install-service --udev' wrote, when reinstalling without --udev.
+func removeHotplugStart(ctx context.Context, manager *systemd.Manager, rule string) error {
+	removed, kept, err := removeUnits(ctx, manager, systemd.AutoUnit)
+	if err != nil {
+		return err
+	}
+	for _, path := range kept {
+		fmt.Printf("  ▸ Left %s alone: it was not written by dmon\n", path)
+	}
+
+	removedRules, needsRoot, err := removeUdevRule(ctx, rule)
+	if err != nil {
+		return err
+	}
+	for _, path := range append(removed, removedRules...) {
+		fmt.Printf("  ▸ Removed %s (installed without --udev)\n", path)
+	}
+	if needsRoot {
+		printRootRemoval(rule)
+	}
+	return nil
+}
+
+// removeUnits stops those of units that are installed and removes the
+// files dmon wrote, returning the paths it removed and those it kept.
+func removeUnits(ctx context.Context, manager *systemd.Manager, units ...string) (removed, kept []string, err error) {
+	var installed []string
+	for _, unit := range units {
+		if _, err := os.Stat(filepath.Join(systemd.UnitDir(), unit)); err == nil {
+			installed = append(installed, unit)
+		}
+	}
+	if len(installed) == 0 {
+		return nil, nil, nil
+	}
+	if err := manager.Disable(ctx, installed...); err != nil {
+		return nil, nil, fmt.Errorf("failed to stop the service: %w", err)
+	}
+
+	for _, unit := range installed {
+		path := filepath.Join(systemd.UnitDir(), unit)
+		if !systemd.IsGenerated(path) {
+			kept = append(kept, path)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, kept, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		removed = append(removed, path)
+	}
+
+	if err := manager.DaemonReload(ctx); err != nil {
+		return removed, kept, fmt.Errorf("failed to reload the systemd user manager: %w", err)
+	}
+	// Failed runs would keep the units listed until the next login.
+	if err := manager.ResetFailed(ctx, installed...); err != nil {
+		log.WithError(err).Debug("Nothing to reset")
+	}
+	return removed, kept, nil
+}
+
+// removeUdevRule removes the rule and its staged copy if dmon wrote them,
+// returning the paths it removed. needsRoot is set when the installed
+// rule is there but only root may remove it.
+func removeUdevRule(ctx context.Context, rule string) (removed []string, needsRoot bool, err error) {
+	if staged := stagedUdevRule(rule); systemd.IsGenerated(staged) {
+		if err := os.Remove(staged); err != nil {
+			return nil, false, fmt.Errorf("failed to remove %s: %w", staged, err)
+		}
+		removed = append(removed, staged)
+	}
+	if !systemd.IsGenerated(rule) {
+		return removed, false, nil
+	}
+
+	err = os.Remove(rule)
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return removed, true, nil
+	case err != nil:
+		return removed, false, fmt.Errorf("failed to remove %s: %w", rule, err)
+	}
+	if _, err := commands.Run(ctx, "udevadm", "control", "--reload"); err != nil {
+		log.WithError(err).Warn("Failed to reload udev rules")
+	}
+	return append(removed, rule), false, nil
+}
+
+func printRootRemoval(rule string) {
+	fmt.Println("  ▸ Remove the udev rule as root:")
+	fmt.Printf("      sudo rm %s\n", rule)
+	fmt.Println("      sudo udevadm control --reload")
+}
+
+func init() {
+	installServiceCmd.Flags().BoolVar(&serviceStatus, "status", false, "Show the state of the service, the imported environment and the udev rule")
+	installServiceCmd.Flags().BoolVar(&serviceUninstall, "uninstall", false, "Stop and remove the units and the udev rule")
+	installServiceCmd.Flags().BoolVar(&serviceImportEnv, "import-env", false, "Import DISPLAY, XAUTHORITY and I3SOCK into the user manager and restart the service")
+	installServiceCmd.Flags().BoolVar(&servicePrint, "print", false, "Print the files instead of installing them")
+	installServiceCmd.Flags().BoolVar(&serviceUdev, "udev", false, "Also run 'dmon auto' on DRM change events through a udev rule")
+	installServiceCmd.Flags().StringVar(&serviceTarget, "target", systemd.DefaultTarget, "Systemd target the service starts with")
+	installServiceCmd.Flags().StringVar(&serviceUdevRule, "udev-rule", "", "Where the udev rule goes (default /etc/udev/rules.d/90-dmon-USER.rules)")
+	installServiceCmd.MarkFlagsMutuallyExclusive("status", "uninstall", "import-env", "print")
+	rootCmd.AddCommand(installServiceCmd)
+}
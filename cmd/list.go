@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/abhishek/dmon-cli/internal/icc"
 	"github.com/spf13/cobra"
 )
 
@@ -10,11 +11,13 @@ var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "Show all connected displays with available modes",
 	Long: `Display a list of all connected displays along with their supported resolutions.
-Shows which mode is currently active and which is the preferred mode.`,
-	Example: `  dmon list`,
-	Args:    cobra.NoArgs,
+Shows which mode is currently active and which is the preferred mode, and
+which rule classified each display as internal or external.`,
+	Example:     `  dmon list`,
+	Annotations: map[string]string{remoteAnnotation: "true"},
+	Args:        cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		displays, err := svc.ListDisplays(getContext())
+		displays, err := layouts.ListDisplays(getContext())
 		if err != nil {
 			return fmt.Errorf("failed to list displays: %w", err)
 		}
@@ -24,6 +27,11 @@ Shows which mode is currently active and which is the preferred mode.`,
 			return nil
 		}
 
+		var iccProfiles map[string]*icc.Profile
+		if svc != nil {
+			iccProfiles = svc.ICCProfiles(getContext(), displays)
+		}
+
 		fmt.Printf("Found %d display(s):\n\n", len(displays))
 
 		for _, d := range displays {
@@ -32,6 +40,12 @@ Shows which mode is currently active and which is the preferred mode.`,
 			}
 
 			fmt.Printf("▸ %s (%s)\n", d.ID, d.Type)
+			if c := d.Classification; c != nil {
+				fmt.Printf("  Classified by: %s: %s\n", c.Rule, c.Reason)
+			}
+			if p, ok := iccProfiles[d.ID]; ok {
+				fmt.Printf("  Color profile: %s\n", p)
+			}
 
 			if len(d.Modes) > 0 {
 				fmt.Println("  Available modes:")
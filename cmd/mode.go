@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/abhishek/dmon-cli/internal/modeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	modeReduced bool
+	modeNoApply bool
+)
+
+var modeCmd = &cobra.Command{
+	Use:   "mode",
+	Short: "Create custom modes for resolutions a display does not advertise",
+	Long: `Generate VESA CVT timings for resolutions missing from a monitor's EDID
+(common with capture cards, KVMs and cheap adapters) and add them as custom
+modes. Generated modes are named like cvt names them, e.g. 2560x1080_60.00,
+or 2560x1080R_60.00 with reduced blanking.`,
+}
+
+var modeAddCmd = &cobra.Command{
+	Use:   "add <output> <WxH[@R]>",
+	Short: "Add a custom mode to an output and switch to it",
+	Example: `  dmon mode add HDMI-1 2560x1080
+  dmon mode add DP-2 1920x1080@75 --reduced
+  dmon mode add HDMI-1 1600x900@60 --no-apply`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		spec, err := modeline.ParseSpec(args[1])
+		if err != nil {
+			return err
+		}
+		spec.Reduced = modeReduced
+
+		line, result, err := svc.AddMode(getContext(), args[0], spec, !modeNoApply)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("✓ Mode %s added to %s (%.2fHz)\n", line.Name, args[0], line.Rate())
+		fmt.Printf("  %s\n", line)
+		if result != nil {
+			fmt.Printf("\n✓ %s switched to %s\n", args[0], line.Name)
+		}
+		return nil
+	},
+}
+
+var modeCleanCmd = &cobra.Command{
+	Use:   "clean [output]",
+	Short: "Remove generated modes no output is using",
+	Example: `  dmon mode clean
+  dmon mode clean HDMI-1`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output := ""
+		if len(args) > 0 {
+			output = args[0]
+		}
+
+		removed, err := svc.CleanModes(getContext(), output)
+		for _, name := range removed {
+			fmt.Printf("  ▸ removed %s\n", name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(removed) == 0 {
+			fmt.Println("✓ No unused custom modes")
+			return nil
+		}
+		fmt.Printf("✓ Removed %d unused custom mode(s)\n", len(removed))
+		return nil
+	},
+}
+
+var modeLineCmd = &cobra.Command{
+	Use:         "line <WxH[@R]>",
+	Short:       "Print the CVT modeline for a resolution",
+	Example:     "  dmon mode line 2560x1080@60\n  dmon mode line 3440x1440@100 --reduced",
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{noBackendAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		spec, err := modeline.ParseSpec(args[0])
+		if err != nil {
+			return err
+		}
+		spec.Reduced = modeReduced
+
+		line, err := modeline.CVT(spec)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("# %dx%d %.2f Hz (CVT%s) hsync: %.2f kHz; pclk: %.2f MHz\n",
+			line.HDisplay, line.VDisplay, line.Rate(), map[bool]string{true: "-RB"}[spec.Reduced],
+			float64(line.Clock)/float64(line.HTotal), float64(line.Clock)/1000)
+		fmt.Println(line)
+		return nil
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{modeAddCmd, modeLineCmd} {
+		c.Flags().BoolVar(&modeReduced, "reduced", false, "Use CVT reduced blanking (lower pixel clock, for digital inputs)")
+	}
+	modeAddCmd.Flags().BoolVar(&modeNoApply, "no-apply", false, "Only add the mode, do not switch to it")
+
+	modeCmd.AddCommand(modeAddCmd, modeCleanCmd, modeLineCmd)
+	rootCmd.AddCommand(modeCmd)
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/abhishek/dmon-cli/internal/ddc"
+	"github.com/spf13/cobra"
+)
+
+var monitorCmd = &cobra.Command{
+	Use:   "monitor <output> [feature] [value]",
+	Short: "Control an external monitor's settings over DDC/CI",
+	Long: `Read or change an external monitor's own settings over DDC/CI, the
+control channel on the video cable. Needs the i2c-dev kernel module and
+read/write access to /dev/i2c-* (usually membership of the i2c group).
+
+Features:
+  brightness   - Hardware backlight (0 to the monitor's maximum, usually 100)
+  contrast     - Contrast (0 to the monitor's maximum)
+  input        - Active input: vga1, vga2, dvi1, dvi2, dp1, dp2, hdmi1, hdmi2, usbc
+  power        - Power mode: on, standby, suspend, off
+
+Values may also be given as raw VCP numbers (e.g. 0x11).`,
+	Example: `  dmon monitor DP-1
+  dmon monitor DP-1 brightness
+  dmon monitor DP-1 brightness 70
+  dmon monitor HDMI-1 input dp1
+  dmon monitor HDMI-1 power standby`,
+	Args: cobra.RangeArgs(1, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		features := ddc.Features
+		if len(args) > 1 {
+			feature, err := ddc.ParseFeature(args[1])
+			if err != nil {
+				return err
+			}
+			features = []ddc.Feature{feature}
+		}
+
+		var value uint16
+		if len(args) > 2 {
+			var err error
+			value, err = features[0].ParseValue(args[2])
+			if err != nil {
+				return err
+			}
+		}
+
+		monitor, err := svc.Monitor(getContext(), args[0])
+		if err != nil {
+			return err
+		}
+		defer monitor.Close()
+
+		if len(args) > 2 {
+			f := features[0]
+			if err := monitor.SetVCP(f.Code, value); err != nil {
+				return fmt.Errorf("failed to set %s: %w", f.Name, err)
+			}
+			fmt.Printf("✓ %s %s set to %s\n", args[0], f.Name, f.FormatValue(value))
+			return nil
+		}
+
+		fmt.Printf("▸ %s (%s)\n", args[0], monitor.Bus)
+		for _, f := range features {
+			v, err := monitor.GetVCP(f.Code)
+			switch {
+			case err != nil && len(features) == 1:
+				return fmt.Errorf("failed to read %s: %w", f.Name, err)
+			case err != nil:
+				fmt.Printf("  %-11s unsupported\n", f.Name+":")
+			case f.Values == nil:
+				fmt.Printf("  %-11s %d/%d\n", f.Name+":", v.Current, v.Max)
+			default:
+				fmt.Printf("  %-11s %s\n", f.Name+":", f.FormatValue(v.Current))
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(monitorCmd)
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/abhishek/dmon-cli/internal/gamma"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultNightTemperature = 4500
+	scheduleInterval        = time.Minute
+)
+
+var (
+	nightTemp     string
+	nightDayTemp  string
+	nightOff      bool
+	nightSchedule bool
+	nightFollow   bool
+	nightLat      float64
+	nightLon      float64
+)
+
+var nightCmd = &cobra.Command{
+	Use:   "night [output]",
+	Short: "Warm the colour temperature of displays (night mode)",
+	Long: `Shift displays towards a warmer colour temperature to reduce blue light.
+
+With --schedule, the temperature follows the sun: the day temperature between
+sunrise and sunset, the night temperature otherwise, with a gradual change
+around dusk and dawn. Sunrise and sunset are computed offline from --lat/--lon
+or the [night] section of the config file. Add --follow to keep running and
+adjust as the day goes on (the displays are reset when it stops).
+
+The x11 backend loads exact gamma ramps; the xrandr backend approximates the
+white point with xrandr --gamma.`,
+	Example: `  dmon night
+  dmon night --temp 3800K
+  dmon night HDMI-1 --temp 5000
+  dmon night --off
+  dmon night --schedule --lat 52.52 --lon 13.40
+  dmon night --schedule --follow`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var output string
+		if len(args) == 1 {
+			output = args[0]
+		}
+
+		night, day, err := nightTemperatures(cmd)
+		if err != nil {
+			return err
+		}
+
+		if nightOff {
+			return applyTemperature(getContext(), output, gamma.Neutral)
+		}
+		if !nightSchedule {
+			return applyTemperature(getContext(), output, night)
+		}
+
+		schedule, err := nightScheduleFor(cmd, night, day)
+		if err != nil {
+			return err
+		}
+
+		if !nightFollow {
+			return applyTemperature(getContext(), output, schedule.TemperatureAt(time.Now()))
+		}
+		return followSchedule(output, schedule)
+	},
+}
+
+func nightTemperatures(cmd *cobra.Command) (night, day int, err error) {
+	night, err = nightTemperature(cmd, "temp", nightTemp, "temperature", cfg.Night.Temperature, defaultNightTemperature)
+	if err != nil {
+		return 0, 0, err
+	}
+	day, err = nightTemperature(cmd, "day-temp", nightDayTemp, "day-temperature", cfg.Night.DayTemperature, gamma.Neutral)
+	if err != nil {
+		return 0, 0, err
+	}
+	return night, day, nil
+}
+
+// nightTemperature takes a temperature from its flag, else its config key,
+// else the default. Errors name where the bad value came from.
+func nightTemperature(cmd *cobra.Command, flag, value, key string, configured, fallback int) (int, error) {
+	if cmd.Flags().Changed(flag) {
+		t, err := gamma.ParseTemperature(value)
+		if err != nil {
+			return 0, fmt.Errorf("--%s: %w", flag, err)
+		}
+		return t, nil
+	}
+
+	if configured == 0 {
+		return fallback, nil
+	}
+	if configured < gamma.MinTemperature || configured > gamma.MaxTemperature {
+		return 0, fmt.Errorf("config %s: night: invalid %s %dK (must be between %dK and %dK)",
+			cfg.Path(), key, configured, gamma.MinTemperature, gamma.MaxTemperature)
+	}
+	return configured, nil
+}
+
+func nightScheduleFor(cmd *cobra.Command, night, day int) (gamma.Schedule, error) {
+	schedule := gamma.Schedule{
+		Day:        day,
+		Night:      night,
+		Transition: gamma.DefaultTransition,
+	}
+
+	switch {
+	case cmd.Flags().Changed("lat") && cmd.Flags().Changed("lon"):
+		schedule.Latitude, schedule.Longitude = nightLat, nightLon
+	case cfg.Night.Latitude != nil && cfg.Night.Longitude != nil:
+		schedule.Latitude, schedule.Longitude = *cfg.Night.Latitude, *cfg.Night.Longitude
+	default:
+		return schedule, fmt.Errorf("the schedule needs a location: use --lat and --lon, or set latitude and longitude under [night] in %s", cfg.Path())
+	}
+
+	if schedule.Latitude < -90 || schedule.Latitude > 90 || schedule.Longitude < -180 || schedule.Longitude > 180 {
+		return schedule, fmt.Errorf("invalid location: %.4f, %.4f", schedule.Latitude, schedule.Longitude)
+	}
+
+	sunrise, sunset, polarDay, polarNight := gamma.SunTimes(time.Now(), schedule.Latitude, schedule.Longitude)
+	switch {
+	case polarDay:
+		fmt.Println("  The sun does not set today")
+	case polarNight:
+		fmt.Println("  The sun does not rise today")
+	default:
+		fmt.Printf("  Sunrise %s, sunset %s\n", sunrise.Format("15:04"), sunset.Format("15:04"))
+	}
+
+	return schedule, nil
+}
+
+func applyTemperature(ctx context.Context, output string, kelvin int) error {
+	color := models.NeutralColor()
+	color.Whitepoint = gamma.Whitepoint(kelvin)
+
+	displays, err := svc.SetColor(ctx, output, color)
+	for _, d := range displays {
+		fmt.Printf("✓ %s colour temperature set to %dK\n", d.ID, kelvin)
+	}
+	if err != nil {
+		return fmt.Errorf("night mode failed: %w", err)
+	}
+	return nil
+}
+
+// followSchedule re-applies the scheduled temperature whenever it changes
+// until interrupted, then restores neutral colours.
+func followSchedule(output string, schedule gamma.Schedule) error {
+	ctx, stop := signal.NotifyContext(getContext(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+
+	current := 0
+	for {
+		if kelvin := schedule.TemperatureAt(time.Now()); kelvin != current {
+			if err := applyTemperature(ctx, output, kelvin); err != nil {
+				log.WithError(err).Warn("Failed to apply scheduled colour temperature")
+			} else {
+				current = kelvin
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return applyTemperature(getContext(), output, gamma.Neutral)
+		case <-ticker.C:
+		}
+	}
+}
+
+func init() {
+	nightCmd.Flags().StringVar(&nightTemp, "temp", "", fmt.Sprintf("Night colour temperature (default %dK)", defaultNightTemperature))
+	nightCmd.Flags().StringVar(&nightDayTemp, "day-temp", "", fmt.Sprintf("Day colour temperature for --schedule (default %dK)", gamma.Neutral))
+	nightCmd.Flags().BoolVar(&nightOff, "off", false, "Restore neutral colours")
+	nightCmd.Flags().BoolVar(&nightSchedule, "schedule", false, "Pick the temperature from local sunrise and sunset")
+	nightCmd.Flags().BoolVar(&nightFollow, "follow", false, "With --schedule, keep running and adjust through the day")
+	nightCmd.Flags().Float64Var(&nightLat, "lat", 0, "Latitude for --schedule (overrides config)")
+	nightCmd.Flags().Float64Var(&nightLon, "lon", 0, "Longitude for --schedule (overrides config)")
+	rootCmd.AddCommand(nightCmd)
+}
@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/abhishek/dmon-cli/internal/config"
+	"github.com/abhishek/dmon-cli/internal/i3"
+	"github.com/abhishek/dmon-cli/internal/icc"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/profile"
+	"github.com/spf13/cobra"
+)
+
+var (
+	profileICC        []string
+	profileWorkspaces []string
+	profileWhenPower  string
+	profileWhenDock   string
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Save and restore display layouts",
+	Long: `Save the current display layout as a named profile and restore it later.
+
+Profiles are TOML files in ` + config.ProfilesDir() + `. Outputs are matched
+to connectors by monitor EDID first, so a profile still applies when a dock
+renumbers its ports. Each output may name an ICC colour profile to attach
+when the profile is applied.`,
+}
+
+var profileSaveCmd = &cobra.Command{
+	Use:   "save <name>",
+	Short: "Save the current layout as a profile",
+	Example: `  dmon profile save office
+  dmon profile save studio --icc DP-1=~/.local/share/icc/u2720q.icc
+  dmon profile save desk --workspaces external=1-5 --workspaces internal=6-10
+  dmon profile save travel --when-power battery
+  dmon profile save desk3 --when-dock 17ef:30b4:1S40AY0090EU`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := profile.ValidateName(args[0]); err != nil {
+			return err
+		}
+
+		p, err := svc.CaptureProfile(getContext(), args[0])
+		if err != nil {
+			return fmt.Errorf("failed to capture profile: %w", err)
+		}
+
+		for _, assignment := range profileICC {
+			output, file, ok := strings.Cut(assignment, "=")
+			if !ok {
+				return fmt.Errorf("invalid --icc %q (use OUTPUT=FILE)", assignment)
+			}
+			if err := setProfileICC(p, output, file); err != nil {
+				return err
+			}
+		}
+
+		for _, assignment := range profileWorkspaces {
+			target, list, ok := strings.Cut(assignment, "=")
+			if !ok {
+				return fmt.Errorf("invalid --workspaces %q (use TARGET=LIST)", assignment)
+			}
+			if p.Workspaces == nil {
+				p.Workspaces = map[string]string{}
+			}
+			p.Workspaces[target] = list
+		}
+		if _, err := i3.ParseAssignments(p.Workspaces); err != nil {
+			return err
+		}
+
+		p.When.Power = profileWhenPower
+		p.When.Dock = profileWhenDock
+		if err := p.When.Validate(); err != nil {
+			return err
+		}
+
+		if err := profileStore().Save(p); err != nil {
+			return err
+		}
+
+		fmt.Printf("✓ Profile %s saved to %s\n", p.Name, p.Path)
+		printProfile(p)
+		return nil
+	},
+}
+
+var profileApplyCmd = &cobra.Command{
+	Use:         "apply <name|file>",
+	Short:       "Apply a saved profile or layout file",
+	Example:     "  dmon profile apply office\n  dmon profile apply ./desk.toml",
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{remoteAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, result, err := applyProfile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to apply profile %s: %w", name, err)
+		}
+
+		fmt.Printf("✓ Profile %s applied\n\n", name)
+		fmt.Println("Configured displays:")
+		for _, d := range result.Displays {
+			if d.Active {
+				fmt.Printf("  ▸ %s (%s) → %s\n", d.ID, d.Type, d.Resolution)
+			}
+		}
+		return nil
+	},
+}
+
+var profileListCmd = &cobra.Command{
+	Use:         "list",
+	Short:       "List saved profiles",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{noBackendAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store := profileStore()
+		names, err := store.List()
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			fmt.Printf("No profiles saved in %s\n", store.Dir())
+			return nil
+		}
+
+		for _, name := range names {
+			p, err := store.Load(name)
+			if err != nil {
+				fmt.Printf("▸ %s (invalid: %v)\n", name, err)
+				continue
+			}
+			var outputs []string
+			for _, o := range p.Outputs {
+				if o.Enabled {
+					outputs = append(outputs, fmt.Sprintf("%s %s", o.Name, o.Mode))
+				}
+			}
+			fmt.Printf("▸ %s: %s\n", name, strings.Join(outputs, ", "))
+		}
+		return nil
+	},
+}
+
+var profileShowCmd = &cobra.Command{
+	Use:         "show <name|file>",
+	Short:       "Show the outputs of a profile",
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{noBackendAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := profileStore().Load(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Profile %s (%s)\n", p.Name, p.Path)
+		printProfile(p)
+		return nil
+	},
+}
+
+var profileDeleteCmd = &cobra.Command{
+	Use:         "delete <name>",
+	Short:       "Delete a saved profile",
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{noBackendAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := profileStore().Delete(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Profile %s deleted\n", args[0])
+		return nil
+	},
+}
+
+// applyProfile applies a profile locally, or has the daemon load and apply
+// it with --remote.
+func applyProfile(ref string) (string, *models.ConfigResult, error) {
+	if remote != nil {
+		name := ref
+		if profile.IsPath(ref) {
+			// The daemon does not share our working directory.
+			abs, err := filepath.Abs(ref)
+			if err != nil {
+				return ref, nil, err
+			}
+			name, ref = strings.TrimSuffix(filepath.Base(ref), filepath.Ext(ref)), abs
+		}
+		result, err := remote.ApplyProfile(getContext(), ref)
+		return name, result, err
+	}
+
+	p, err := profileStore().Load(ref)
+	if err != nil {
+		return ref, nil, err
+	}
+	result, err := svc.ApplyProfile(getContext(), p)
+	return p.Name, result, err
+}
+
+func profileStore() *profile.Store {
+	return profile.NewStore(config.ProfilesDir())
+}
+
+// setProfileICC attaches an ICC file to the output named output, checking
+// that the file is a valid profile.
+func setProfileICC(p *profile.Profile, output, file string) error {
+	if _, _, err := icc.Load(file); err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+
+	for i := range p.Outputs {
+		if p.Outputs[i].Name == output {
+			p.Outputs[i].ICC = abs
+			return nil
+		}
+	}
+	return fmt.Errorf("output %s is not part of profile %s", output, p.Name)
+}
+
+func printProfile(p *profile.Profile) {
+	fmt.Println()
+	for _, o := range p.Outputs {
+		fmt.Printf("  ▸ %s", o.Name)
+		if o.EDID != "" {
+			fmt.Printf(" [%s]", o.EDID)
+		}
+		if !o.Enabled {
+			fmt.Println(" → off")
+			continue
+		}
+		fmt.Printf(" → %s", o.Mode)
+		if o.Rate > 0 {
+			fmt.Printf("@%.2fHz", o.Rate)
+		}
+		fmt.Printf(" at %d,%d", o.X, o.Y)
+		if o.Primary {
+			fmt.Print(" [PRIMARY]")
+		}
+		fmt.Println()
+		if o.ICC != "" {
+			desc := o.ICC
+			if _, info, err := icc.Load(p.ICCPath(o)); err == nil {
+				desc = fmt.Sprintf("%s (%s)", info, o.ICC)
+			}
+			fmt.Printf("      Color profile: %s\n", desc)
+		}
+	}
+
+	targets := make([]string, 0, len(p.Workspaces))
+	for target := range p.Workspaces {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		fmt.Printf("  ▸ Workspaces %s → %s\n", p.Workspaces[target], target)
+	}
+	if !p.When.IsZero() {
+		fmt.Printf("  ▸ Picked by 'dmon auto' %s\n", p.When)
+	}
+}
+
+func init() {
+	profileSaveCmd.Flags().StringArrayVar(&profileWorkspaces, "workspaces", nil, "Put i3 workspaces on internal, external, primary or an output (TARGET=LIST, e.g. external=1-5, repeatable)")
+	profileSaveCmd.Flags().StringVar(&profileWhenDock, "when-dock", "", "Let 'dmon auto' and 'dmon watch' pick this profile while this dock is attached (VENDOR:PRODUCT[:SERIAL], see 'dmon dock')")
+	profileSaveCmd.Flags().StringVar(&profileWhenPower, "when-power", "", "Let 'dmon auto' and 'dmon watch' pick this profile on this power source (ac or battery)")
+	profileSaveCmd.Flags().StringArrayVar(&profileICC, "icc", nil, "Attach an ICC profile to an output (OUTPUT=FILE, repeatable)")
+	profileCmd.AddCommand(profileSaveCmd, profileApplyCmd, profileListCmd, profileShowCmd, profileDeleteCmd)
+	rootCmd.AddCommand(profileCmd)
+}
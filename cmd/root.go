@@ -1,22 +1,74 @@
 package cmd
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/abhishek/dmon-cli/internal/adapter"
+	"github.com/abhishek/dmon-cli/internal/api"
+	"github.com/abhishek/dmon-cli/internal/backend"
+	"github.com/abhishek/dmon-cli/internal/classify"
+	"github.com/abhishek/dmon-cli/internal/colord"
+	"github.com/abhishek/dmon-cli/internal/config"
+	"github.com/abhishek/dmon-cli/internal/dock"
+	"github.com/abhishek/dmon-cli/internal/dpi"
+	"github.com/abhishek/dmon-cli/internal/hooks"
+	"github.com/abhishek/dmon-cli/internal/i3"
+	"github.com/abhishek/dmon-cli/internal/lid"
 	"github.com/abhishek/dmon-cli/internal/logger"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/planner"
+	"github.com/abhishek/dmon-cli/internal/power"
+	"github.com/abhishek/dmon-cli/internal/record"
+	"github.com/abhishek/dmon-cli/internal/runner"
 	"github.com/abhishek/dmon-cli/internal/service"
 	"github.com/abhishek/dmon-cli/internal/version"
-	"github.com/abhishek/dmon-cli/internal/xrandr"
+	"github.com/abhishek/dmon-cli/internal/x11"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+// noBackendAnnotation marks commands that must run even when no display backend is usable.
+const noBackendAnnotation = "dmon/no-backend"
+
+// remoteAnnotation marks commands that can run against a 'dmon serve' daemon with --remote.
+const remoteAnnotation = "dmon/remote"
+
 var (
-	verbose bool
-	log     *logrus.Logger
-	svc     *service.DisplayService
+	verbose     bool
+	configPath  string
+	backendFlag string
+	fakeState   string
+	recordPath  string
+	replayPath  string
+	remoteMode  bool
+	socketPath  string
+	noHooks     bool
+	i3Flag      bool
+	recorder    *record.Recorder
+	// replayDir holds the files of the --replay bundle.
+	replayDir string
+	// commands runs every external command: executed, recorded with
+	// --record or served from the bundle with --replay.
+	commands    runner.Runner = runner.Exec{}
+	log         *logrus.Logger
+	cfg         *config.Config
+	registry    = backend.Default()
+	backendName string
+	svc         *service.DisplayService
+	// layoutPlanner plans layouts and parses resolution mode names, the
+	// config's presets included.
+	layoutPlanner *planner.Planner
+	// layouts runs list, check, set, dual and single: svc, or a client of
+	// a 'dmon serve' daemon with --remote.
+	layouts api.Layouts
+	remote  *api.Client
 )
 
 var rootCmd = &cobra.Command{
@@ -32,15 +84,105 @@ It provides a simple interface to xrandr for common display management tasks.`,
 			return fmt.Errorf("failed to initialize logger: %w", err)
 		}
 
-		backend := xrandr.NewBackend(log)
-		svc = service.New(backend, log)
+		snapshot, err := newCommandRunner()
+		if err != nil {
+			return err
+		}
+
+		path := configPath
+		if path == "" && snapshot != nil && snapshot.Config != "" {
+			path = snapshot.Config
+		}
+		cfg, err = config.Load(path)
+		if err != nil {
+			return err
+		}
+		if recorder != nil {
+			recorder.WithConfig(cfg.Path()).WithTrees(decisionTrees()...)
+		}
+		if snapshot != nil {
+			useSnapshot(snapshot)
+		}
+
+		var detailed bool
+		if layoutPlanner, detailed, err = newPlanner(); err != nil {
+			return err
+		}
+
+		if cmd.Annotations[noBackendAnnotation] != "" {
+			return nil
+		}
+
+		if remoteMode {
+			return connectRemote(cmd)
+		}
+
+		classifier, err := newClassifier()
+		if err != nil {
+			return err
+		}
+		userHooks, err := newHooks()
+		if err != nil {
+			return err
+		}
+
+		opts := backendOptions()
+		opts.Planner = layoutPlanner
+		b, name, err := registry.Select(getContext(), selectedBackend(), opts, log)
+		if err != nil {
+			return err
+		}
+		backendName = name
+		log.WithField("backend", backendName).Debug("Using display backend")
+
+		svc = service.New(b, log).
+			WithClassifier(classifier).
+			WithICCFiles(cfg.ICC).
+			WithDetailedDetection(detailed).
+			WithProviderLinking(cfg.GPU.AutoLink)
+		if cfg.Colord.Enable {
+			svc.WithICC(colord.NewAssigner(log))
+		} else if _, ok := b.(adapter.ICCAssigner); !ok && backendName == "xrandr" {
+			// xrandr cannot set binary output properties; RandR can, on the same display.
+			svc.WithICC(x11.NewBackend(log))
+		}
+		if userHooks != nil {
+			svc.WithHooks(userHooks)
+		}
+		if cfg.DPI.Enable {
+			svc.WithDPI(newDPISetter())
+		}
+		if i3Flag || cfg.I3.Enable {
+			manager, rules, err := newWorkspaces()
+			if err != nil {
+				return err
+			}
+			svc.WithWorkspaces(manager, rules)
+		}
+		layouts = svc
 
 		return nil
 	},
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+
+	// Write the bundle even when the command failed; failures are what get reported.
+	if recorder != nil {
+		bundle := recorder.Bundle(backendName, err)
+		if writeErr := bundle.Write(recordPath); writeErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", writeErr)
+		} else {
+			fmt.Fprintf(os.Stderr, "Recorded %d command(s) to %s\n", len(bundle.Commands), recordPath)
+		}
+	}
+
+	if replayDir != "" {
+		os.RemoveAll(replayDir)
+	}
+
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -50,6 +192,265 @@ func init() {
 	rootCmd.SetVersionTemplate(fmt.Sprintf("%s\n", version.Info()))
 
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Show detailed output and xrandr commands")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Config file (default "+config.DefaultPath()+")")
+	rootCmd.PersistentFlags().StringVar(&backendFlag, "backend", "", "Display backend: auto, xrandr, x11, fake, replay (overrides config)")
+	rootCmd.PersistentFlags().StringVar(&fakeState, "fake-state", "", "JSON inventory file for the fake backend")
+	rootCmd.PersistentFlags().StringVar(&recordPath, "record", "", "Capture every external command and environment facts into a tar bundle")
+	rootCmd.PersistentFlags().StringVar(&replayPath, "replay", "", "Bundle recorded with --record to serve back (implies --backend replay)")
+	rootCmd.PersistentFlags().BoolVar(&remoteMode, "remote", false, "Run the command on a 'dmon serve' daemon instead of locally")
+	rootCmd.PersistentFlags().BoolVar(&noHooks, "no-hooks", false, "Do not run the hook scripts around layout changes")
+	rootCmd.PersistentFlags().BoolVar(&i3Flag, "i3", false, "Move i3 workspaces to their outputs after layout changes")
+	rootCmd.PersistentFlags().StringVar(&socketPath, "socket", "", "API socket of 'dmon serve' (default "+api.DefaultSocket()+")")
+}
+
+func backendOptions() backend.Options {
+	return backend.Options{
+		FakeState: fakeState,
+		Replay:    replayPath,
+		Runner:    commands,
+	}
+}
+
+// newCommandRunner sets up commands for --record and --replay. A replayed
+// bundle's files are extracted to replayDir.
+func newCommandRunner() (*record.Snapshot, error) {
+	switch {
+	case recordPath != "":
+		recorder = record.NewRecorder(runner.Exec{})
+		commands = recorder
+	case replayPath != "":
+		bundle, err := record.Read(replayPath)
+		if err != nil {
+			return nil, err
+		}
+		commands = record.NewReplayer(bundle)
+		if replayDir, err = os.MkdirTemp("", "dmon-replay-"); err != nil {
+			return nil, fmt.Errorf("failed to extract bundle: %w", err)
+		}
+		return bundle.Extract(replayDir)
+	}
+	return nil, nil
+}
+
+// Names of the directories recorded with --record.
+const (
+	treeDRM         = "drm"
+	treeLid         = "lid"
+	treePower       = "power_supply"
+	treeUSB         = "usb"
+	treeThunderbolt = "thunderbolt"
+)
+
+// decisionTrees are the sysfs and procfs directories whose files classify
+// displays and pick automatic layouts, at the config's roots.
+func decisionTrees() []record.Tree {
+	return []record.Tree{
+		{Name: treeDRM, Root: cmp.Or(cfg.Classify.Root, classify.DefaultRoot)},
+		{Name: treeLid, Root: cmp.Or(cfg.Auto.LidRoot, lid.DefaultRoot)},
+		{Name: treePower, Root: cmp.Or(cfg.Power.Root, power.DefaultRoot)},
+		{Name: treeUSB, Root: cmp.Or(cfg.Dock.USBRoot, dock.DefaultUSBRoot)},
+		{Name: treeThunderbolt, Root: cmp.Or(cfg.Dock.ThunderboltRoot, dock.DefaultThunderboltRoot)},
+	}
+}
+
+// useSnapshot points the config's roots at a replayed bundle's copies, so
+// the replay reads the recorded machine's state rather than this one's.
+func useSnapshot(snapshot *record.Snapshot) {
+	for name, root := range map[string]*string{
+		treeDRM:         &cfg.Classify.Root,
+		treeLid:         &cfg.Auto.LidRoot,
+		treePower:       &cfg.Power.Root,
+		treeUSB:         &cfg.Dock.USBRoot,
+		treeThunderbolt: &cfg.Dock.ThunderboltRoot,
+	} {
+		if recorded, ok := snapshot.Roots[name]; ok {
+			*root = recorded
+		}
+	}
+}
+
+// newPlanner builds the layout planner with the config's resolution presets
+// added as modes. detailed reports whether a preset targets monitors by
+// EDID, which some backends only detect on request.
+func newPlanner() (p *planner.Planner, detailed bool, err error) {
+	p = planner.New(log)
+
+	names := make([]string, 0, len(cfg.Presets))
+	for name := range cfg.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		preset, err := parsePreset(name, cfg.Presets[name])
+		if err != nil {
+			return nil, false, fmt.Errorf("config %s: %w", cfg.Path(), err)
+		}
+		p.WithResolver(models.ResolutionMode(name), preset)
+		detailed = detailed || len(preset.EDID) > 0
+	}
+
+	return p, detailed, nil
+}
+
+// newClassifier builds the internal-display rules, with the config's
+// overrides ahead of the detected ones.
+func newClassifier() (*classify.Classifier, error) {
+	overrides := classify.Overrides{
+		Internal: cfg.Classify.Internal,
+		External: cfg.Classify.External,
+	}
+	if err := overrides.Validate(); err != nil {
+		return nil, fmt.Errorf("config %s: classify: %w", cfg.Path(), err)
+	}
+
+	return classify.New(log, classify.NewSysfs(cfg.Classify.Root), classify.ConnectorType{}).
+		WithOverrides(overrides), nil
+}
+
+// newHooks builds the hook runner from the config, or returns nil when
+// hooks are turned off.
+func newHooks() (*hooks.Hooks, error) {
+	if noHooks || cfg.Hooks.Disable {
+		return nil, nil
+	}
+
+	dir := expandHome(cfg.Hooks.Dir)
+	if dir == "" {
+		dir = config.HooksDir()
+	}
+	h := hooks.New(dir, log).WithRunner(commands)
+	if cfg.Hooks.Timeout != "" {
+		timeout, err := time.ParseDuration(cfg.Hooks.Timeout)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("config %s: hooks: invalid timeout %q", cfg.Path(), cfg.Hooks.Timeout)
+		}
+		h.WithTimeout(timeout)
+	}
+	return h, nil
+}
+
+// newDPISetter builds the Xft.dpi setter from the config.
+func newDPISetter() *dpi.Setter {
+	setter := dpi.NewSetter(log).WithRunner(commands)
+	if cfg.DPI.XSettingsd {
+		path := cfg.DPI.XSettingsdConfig
+		if path == "" {
+			path = "~/.xsettingsd"
+		}
+		setter.WithXSettingsd(expandHome(path))
+	}
+	return setter
+}
+
+// newWorkspaces builds the i3 workspace mover and the config's workspace map.
+func newWorkspaces() (*i3.Manager, i3.Assignments, error) {
+	rules, err := i3.ParseAssignments(cfg.I3.Workspaces)
+	if err != nil {
+		return nil, nil, fmt.Errorf("config %s: i3: %w", cfg.Path(), err)
+	}
+
+	manager := i3.NewManager(log)
+	if cfg.I3.Socket != "" {
+		manager.WithSocket(cfg.I3.Socket)
+	}
+	manager.WithRunner(commands)
+	return manager, rules, nil
+}
+
+func parsePreset(name string, preset config.Preset) (planner.Preset, error) {
+	switch name {
+	case "", "p", "l", "h":
+		return planner.Preset{}, fmt.Errorf("invalid preset name %q", name)
+	}
+
+	var result planner.Preset
+	var err error
+	if result.Internal, err = planner.ParseTarget(preset.Internal); err != nil {
+		return result, fmt.Errorf("preset %s: internal: %w", name, err)
+	}
+	if result.External, err = planner.ParseTarget(preset.External); err != nil {
+		return result, fmt.Errorf("preset %s: external: %w", name, err)
+	}
+
+	if len(preset.EDID) > 0 {
+		result.EDID = make(map[string]planner.Size, len(preset.EDID))
+		for fingerprint, target := range preset.EDID {
+			if result.EDID[fingerprint], err = planner.ParseTarget(target); err != nil {
+				return result, fmt.Errorf("preset %s: edid %s: %w", name, fingerprint, err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// connectRemote makes layouts a client of a running 'dmon serve'.
+func connectRemote(cmd *cobra.Command) error {
+	if cmd.Annotations[remoteAnnotation] == "" {
+		return fmt.Errorf("'%s' cannot run with --remote", cmd.CommandPath())
+	}
+	if backendFlag != "" || fakeState != "" || recordPath != "" || replayPath != "" || noHooks || i3Flag {
+		return fmt.Errorf("--remote uses the daemon's backend, hooks and i3 settings; drop --backend, --fake-state, --record, --replay, --no-hooks and --i3")
+	}
+
+	client, err := api.Dial(getContext(), apiSocket())
+	if err != nil {
+		return err
+	}
+	remote = client
+	layouts = client
+	log.WithField("socket", apiSocket()).Debug("Using remote dmon server")
+	return nil
+}
+
+// apiSocket resolves the API socket: --socket flag, then config, then the default.
+func apiSocket() string {
+	if socketPath != "" {
+		return socketPath
+	}
+	if cfg != nil && cfg.Serve.Socket != "" {
+		return cfg.Serve.Socket
+	}
+	return api.DefaultSocket()
+}
+
+// expandHome expands a leading ~/ in a configured path.
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
+// selectedBackend resolves the backend name: --backend flag, then --replay,
+// then config, then auto.
+func selectedBackend() string {
+	if backendFlag != "" {
+		return backendFlag
+	}
+	if replayPath != "" {
+		return "replay"
+	}
+	if cfg != nil && cfg.Backend != "" {
+		return cfg.Backend
+	}
+	return backend.Auto
+}
+
+// backendSource says where selectedBackend's choice comes from.
+func backendSource() string {
+	switch {
+	case backendFlag != "":
+		return "--backend flag"
+	case replayPath != "":
+		return "--replay flag"
+	case cfg != nil && cfg.Backend != "":
+		return "config key 'backend'"
+	}
+	return "auto-selection"
 }
 
 func getContext() context.Context {
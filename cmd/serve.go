@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/abhishek/dmon-cli/internal/api"
+	"github.com/abhishek/dmon-cli/internal/events"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveDBus     bool
+	serveInterval time.Duration
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve display operations to other programs over a socket",
+	Long: `Run dmon as a daemon that status bars, launchers and scripts can query
+and drive without starting dmon each time. The API is JSON-RPC 2.0 on a Unix
+socket, one request per line; methods are list, check, set, dual, single,
+profile.list and profile.apply, and subscribe streams display events (see
+'dmon events'). With --dbus (or dbus = true under [serve]) the same methods
+are exported on the session bus as ` + api.BusName + `, with events as
+Event signals.
+
+Any dmon command that supports it runs against the daemon with --remote.`,
+	Example: `  dmon serve
+  dmon serve --dbus --socket /run/user/1000/dmon.sock
+  dmon --remote dual
+  echo '{"jsonrpc":"2.0","id":1,"method":"check"}' | socat - UNIX-CONNECT:$XDG_RUNTIME_DIR/dmon.sock`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(getContext(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		path := apiSocket()
+		listener, err := api.Listen(path)
+		if err != nil {
+			return err
+		}
+
+		bus := events.NewBus(log)
+		svc.WithEvents(bus)
+		server := api.NewServer(svc, log).
+			WithProfiles(svc, profileStore()).
+			WithModes(layoutPlanner).
+			WithEvents(bus)
+		if serveDBus || cfg.Serve.DBus {
+			if err := server.ExportDBus(ctx); err != nil {
+				listener.Close()
+				return err
+			}
+			fmt.Printf("✓ Serving on the session bus as %s\n", api.BusName)
+		}
+
+		go func() {
+			if err := svc.Watch(ctx, serveInterval); err != nil {
+				log.WithError(err).Warn("Stopped watching for display changes")
+			}
+		}()
+
+		fmt.Printf("✓ Serving on %s (backend %s)\n", path, backendName)
+		return server.Serve(ctx, listener)
+	},
+}
+
+func init() {
+	serveCmd.Flags().BoolVar(&serveDBus, "dbus", false, "Also serve on the session D-Bus")
+	serveCmd.Flags().DurationVar(&serveInterval, "interval", defaultPollInterval, "How often to poll for display changes when the backend cannot report them")
+	rootCmd.AddCommand(serveCmd)
+}
@@ -23,6 +23,7 @@ Modes:
   preset, p    - Default resolution (1920x1200 internal, 1920x1080 external)
   low, l       - Reduced resolution (1600x1000 internal, 1280x720 external)
   highest, h   - Highest available resolution
+  <name>       - A preset defined under [presets.<name>] in the config file
 
 Positions (optional, for 'both' target):
   left, l      - Internal display to the left of external
@@ -34,14 +35,15 @@ Positions (optional, for 'both' target):
   dmon set both preset left
   dmon set i l
   dmon set e h`,
-	Args: cobra.RangeArgs(2, 3),
+	Annotations: map[string]string{remoteAnnotation: "true"},
+	Args:        cobra.RangeArgs(2, 3),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		target, err := models.ParseTarget(args[0])
 		if err != nil {
 			return err
 		}
 
-		mode, err := models.ParseResolutionMode(args[1])
+		mode, err := layoutPlanner.ParseMode(args[1])
 		if err != nil {
 			return err
 		}
@@ -54,7 +56,7 @@ Positions (optional, for 'both' target):
 			}
 		}
 
-		result, err := svc.SetDisplay(getContext(), target, mode, position, customResolution)
+		result, err := layouts.SetDisplay(getContext(), target, mode, position, customResolution)
 		if err != nil {
 			return fmt.Errorf("display configuration failed: %w", err)
 		}
@@ -63,7 +65,7 @@ Positions (optional, for 'both' target):
 		if target == models.TargetBoth {
 			fmt.Printf(", %s", position)
 		}
-		fmt.Println(")\n")
+		fmt.Print(")\n\n")
 
 		fmt.Println("Configured displays:")
 		for _, d := range result.Displays {
@@ -11,15 +11,16 @@ var singleCmd = &cobra.Command{
 	Short: "Internal display only (disable external)",
 	Long: `Switch to single display mode using only the internal display.
 External displays will be disabled.`,
-	Example: `  dmon single`,
-	Args:    cobra.NoArgs,
+	Example:     `  dmon single`,
+	Annotations: map[string]string{remoteAnnotation: "true"},
+	Args:        cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		result, err := svc.SetSingleDisplay(getContext())
+		result, err := layouts.SetSingleDisplay(getContext())
 		if err != nil {
 			return fmt.Errorf("single display setup failed: %w", err)
 		}
 
-		fmt.Println("✓ Single display mode (internal only)\n")
+		fmt.Print("✓ Single display mode (internal only)\n\n")
 
 		fmt.Println("Configured displays:")
 		for _, d := range result.Displays {
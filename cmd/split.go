@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/split"
+	"github.com/spf13/cobra"
+)
+
+var splitRects []string
+
+var splitCmd = &cobra.Command{
+	Use:   "split [output] [COLUMNSxROWS]",
+	Short: "Split an output into several logical monitors",
+	Long: `Carve one output, typically an ultrawide, into logical monitors with
+xrandr --setmonitor. Window managers then treat each part as a screen of its
+own. Split monitors are named <output>~1, <output>~2 and so on; profiles save
+them with the output they split.
+
+Without arguments, list the logical monitors.`,
+	Example: `  dmon split
+  dmon split DP-1 2x1
+  dmon split DP-1 --rect 1280x1440+0+0 --rect 2560x1440+1280+0 --rect 1280x1440+3840+0`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			if len(splitRects) > 0 {
+				return fmt.Errorf("--rect needs an output to split")
+			}
+			return listMonitors()
+		}
+
+		var monitors []models.Monitor
+		var err error
+		switch {
+		case len(args) == 2 && len(splitRects) > 0:
+			return fmt.Errorf("give either a grid or --rect, not both")
+		case len(args) == 2:
+			columns, rows, gridErr := split.ParseGrid(args[1])
+			if gridErr != nil {
+				return gridErr
+			}
+			monitors, err = svc.SplitGrid(getContext(), args[0], columns, rows)
+		case len(splitRects) > 0:
+			rects := make([]split.Rect, len(splitRects))
+			for i, s := range splitRects {
+				if rects[i], err = split.ParseRect(s); err != nil {
+					return err
+				}
+			}
+			monitors, err = svc.Split(getContext(), args[0], rects)
+		default:
+			return fmt.Errorf("give a grid like 2x1 or one --rect per monitor")
+		}
+
+		for _, m := range monitors {
+			fmt.Printf("  ▸ %s\n", formatMonitor(m))
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✓ %s split into %d monitors\n", args[0], len(monitors))
+		return nil
+	},
+}
+
+var unsplitCmd = &cobra.Command{
+	Use:     "unsplit <output>",
+	Short:   "Remove the logical monitors an output was split into",
+	Example: "  dmon unsplit DP-1",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		removed, err := svc.Unsplit(getContext(), args[0])
+		for _, name := range removed {
+			fmt.Printf("  ▸ removed %s\n", name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(removed) == 0 {
+			fmt.Printf("✓ %s is not split\n", args[0])
+			return nil
+		}
+		fmt.Printf("✓ %s is one monitor again\n", args[0])
+		return nil
+	},
+}
+
+func listMonitors() error {
+	monitors, err := svc.Monitors(getContext())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Found %d monitor(s):\n\n", len(monitors))
+	for _, m := range monitors {
+		fmt.Printf("▸ %s\n", formatMonitor(m))
+	}
+	return nil
+}
+
+func formatMonitor(m models.Monitor) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %dx%d+%d+%d", m.Name, m.Width, m.Height, m.X, m.Y)
+	if m.WidthMM > 0 && m.HeightMM > 0 {
+		fmt.Fprintf(&b, " (%dmm x %dmm)", m.WidthMM, m.HeightMM)
+	}
+	if len(m.Outputs) > 0 {
+		fmt.Fprintf(&b, " on %s", strings.Join(m.Outputs, ", "))
+	}
+	var flags []string
+	if m.Primary {
+		flags = append(flags, "primary")
+	}
+	if m.Automatic {
+		flags = append(flags, "automatic")
+	}
+	if len(flags) > 0 {
+		fmt.Fprintf(&b, " [%s]", strings.Join(flags, ", "))
+	}
+	return b.String()
+}
+
+func init() {
+	splitCmd.Flags().StringArrayVar(&splitRects, "rect", nil, "Monitor as WIDTHxHEIGHT+X+Y relative to the output (repeatable)")
+	rootCmd.AddCommand(splitCmd, unsplitCmd)
+}
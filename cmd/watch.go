@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/abhishek/dmon-cli/internal/auto"
+	"github.com/abhishek/dmon-cli/internal/dock"
+	"github.com/abhishek/dmon-cli/internal/events"
+	"github.com/abhishek/dmon-cli/internal/uevent"
+	"github.com/spf13/cobra"
+)
+
+var watchInterval time.Duration
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Apply 'dmon auto' layouts whenever displays, lid, power source or dock change",
+	Long: `Run until interrupted, applying the layout 'dmon auto' would choose at start
+and whenever a display is connected or disconnected, the lid opens or
+closes, the charger is plugged in or pulled, or a dock a profile names
+comes or goes. Closing the lid with a monitor attached turns the internal
+display off and makes the external one primary; opening it restores dual
+(or dual-profile under [auto]). Going on battery applies the battery
+settings under [power] or a profile saved with --when-power battery, and
+going back on AC restores the AC layout.
+
+Displays are followed like 'dmon events' does, and the power source and
+docks through kernel uevents; the lid (and the power source, where uevents
+are unavailable) is read every --interval. Layout changes that do not alter
+what is connected, including the ones watch makes itself, do not trigger
+anything. Profiles are re-read on every change.`,
+	Example: `  dmon watch
+  dmon --i3 watch --interval 1s`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source, err := newLidSource()
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(getContext(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		bus := events.NewBus(log)
+		svc.WithEvents(bus)
+		changes, unsubscribe := bus.Subscribe()
+		defer unsubscribe()
+		watchErr := make(chan error, 1)
+		go func() {
+			watchErr <- svc.Watch(ctx, watchInterval)
+		}()
+
+		uevents, err := uevent.Listen(ctx, "power_supply", "usb", "thunderbolt")
+		if err != nil {
+			log.WithError(err).Debug("Polling the power source instead")
+		}
+
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+
+		var last auto.Situation
+		var docks []dock.Spec
+		checked := false
+		check := func() {
+			rules, err := autoRules()
+			if err != nil {
+				log.WithError(err).Warn("Failed to read the rules")
+				return
+			}
+			docks = auto.DockSpecs(rules.Profiles)
+			situation, err := currentSituation(ctx, source, rules)
+			if err != nil {
+				log.WithError(err).Warn("Failed to read the situation")
+				return
+			}
+			if checked && situation.Key() == last.Key() {
+				return
+			}
+			// A failed layout is not retried until something changes.
+			last, checked = situation, true
+			if err := applyAuto(ctx, situation, rules); err != nil {
+				log.WithError(err).Warn("Failed to apply the layout")
+			}
+		}
+
+		check()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case err := <-watchErr:
+				return err
+			case e := <-changes:
+				if e.Type == events.Connected || e.Type == events.Disconnected {
+					check()
+				}
+			case e, ok := <-uevents:
+				if !ok {
+					uevents = nil
+					continue
+				}
+				switch {
+				case e.Subsystem == "power_supply":
+					// Battery level updates arrive here too.
+					if supply, err := powerSupplies().State(); err == nil && supply != last.Power {
+						check()
+					}
+				case (e.Action == "add" || e.Action == "remove") && len(docks) > 0:
+					check()
+				}
+			case <-ticker.C:
+				// Only the lid and the power source need polling; displays
+				// are followed above.
+				if state, err := source.State(ctx); err == nil && state != last.Lid {
+					check()
+				} else if supply, err := powerSupplies().State(); err == nil && supply != last.Power {
+					check()
+				}
+			}
+		}
+	},
+}
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", defaultPollInterval, "How often to read the lid and the power source, and the displays when the backend cannot report changes")
+	rootCmd.AddCommand(watchCmd)
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/abhishek/dmon-cli/internal/profile"
+	"github.com/spf13/cobra"
+)
+
+var (
+	workspacesProfile string
+	workspacesDryRun  bool
+)
+
+var workspacesCmd = &cobra.Command{
+	Use:   "workspaces",
+	Short: "Move i3 workspaces to the outputs they belong on",
+	Long: `Move i3 (or sway) workspaces to their outputs on the current layout, as
+happens after every layout change with --i3 or enable = true under [i3].
+
+The map under [i3.workspaces] in the config, or a profile's own
+[workspaces] table, puts workspaces on internal, external, primary or a
+named output:
+
+  [i3.workspaces]
+  external = "1-5"
+  internal = "6-10"
+
+A workspace whose target has no active output goes to the primary output,
+and so does one left on an output that is off.`,
+	Example: `  dmon workspaces
+  dmon workspaces --dry-run
+  dmon workspaces --profile desk`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !i3Flag && !cfg.I3.Enable {
+			manager, rules, err := newWorkspaces()
+			if err != nil {
+				return err
+			}
+			svc.WithWorkspaces(manager, rules)
+		}
+
+		var p *profile.Profile
+		if workspacesProfile != "" {
+			var err error
+			if p, err = profileStore().Load(workspacesProfile); err != nil {
+				return err
+			}
+		}
+
+		moves, err := svc.ArrangeWorkspaces(getContext(), p, workspacesDryRun)
+		for _, move := range moves {
+			fmt.Printf("  ▸ Workspace %s: %s → %s\n", move.Workspace, move.From, move.To)
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case len(moves) == 0:
+			fmt.Println("✓ Every workspace is on its output")
+		case workspacesDryRun:
+			fmt.Printf("✓ Would move %d workspace(s)\n", len(moves))
+		default:
+			fmt.Printf("✓ Moved %d workspace(s)\n", len(moves))
+		}
+		return nil
+	},
+}
+
+func init() {
+	workspacesCmd.Flags().StringVar(&workspacesProfile, "profile", "", "Use this profile's workspace map instead of the config's")
+	workspacesCmd.Flags().BoolVar(&workspacesDryRun, "dry-run", false, "Only show which workspaces would move")
+	rootCmd.AddCommand(workspacesCmd)
+}
@@ -3,7 +3,9 @@ package adapter
 import (
 	"context"
 
+	"github.com/abhishek/dmon-cli/internal/modeline"
 	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/planner"
 )
 
 type DisplayDetector interface {
@@ -24,3 +26,74 @@ type DisplayBackend interface {
 	DisplayConfigurator
 	DisplayQuerier
 }
+
+// BrightnessController is implemented by backends that can dim an output in
+// software (a gamma ramp), for displays without a kernel backlight. Levels
+// are fractions, 1.0 being normal brightness.
+type BrightnessController interface {
+	GetBrightness(ctx context.Context, displayID string) (float64, error)
+	SetBrightness(ctx context.Context, displayID string, level float64) error
+}
+
+// PropertyDetector is implemented by backends whose DetectDisplays leaves out
+// EDID and output properties because they are slower to fetch.
+type PropertyDetector interface {
+	DetectDisplayProperties(ctx context.Context) ([]models.Display, error)
+}
+
+// ColorController is implemented by backends that can load per-output gamma
+// and white point corrections.
+type ColorController interface {
+	SetColor(ctx context.Context, displayID string, color models.Color) error
+}
+
+// PlanApplier is implemented by backends that can apply an explicit
+// per-output plan, such as a saved profile, rather than a DisplayConfig.
+type PlanApplier interface {
+	Apply(ctx context.Context, plan *planner.Plan) (*models.ConfigResult, error)
+}
+
+// ICCAssigner attaches ICC colour profiles to outputs so colour-managed
+// applications can find them.
+type ICCAssigner interface {
+	SetICCProfile(ctx context.Context, displayID string, data []byte) error
+	GetICCProfile(ctx context.Context, displayID string) ([]byte, error)
+}
+
+// ModeManager is implemented by backends that can create custom modes for
+// resolutions missing from a monitor's EDID, and remove them again.
+type ModeManager interface {
+	// AddMode creates the mode if needed and makes it available on the output.
+	AddMode(ctx context.Context, displayID string, mode modeline.Modeline) error
+	// DeleteMode detaches a mode from an output.
+	DeleteMode(ctx context.Context, displayID string, name string) error
+	// DestroyMode removes a mode no output uses any more.
+	DestroyMode(ctx context.Context, name string) error
+}
+
+// ProviderManager is implemented by backends that can list RandR providers
+// (GPUs) and link one as an output sink of another, which hybrid laptops
+// need before outputs wired to the discrete GPU can be used.
+type ProviderManager interface {
+	ListProviders(ctx context.Context) ([]models.Provider, error)
+	// LinkProvider makes sink display images rendered by source.
+	LinkProvider(ctx context.Context, sink, source models.Provider) error
+}
+
+// MonitorManager is implemented by backends that can list and define RandR
+// logical monitors (RandR 1.5), e.g. to split an ultrawide into several.
+type MonitorManager interface {
+	ListMonitors(ctx context.Context) ([]models.Monitor, error)
+	// SetMonitor creates or replaces the monitor of the same name.
+	SetMonitor(ctx context.Context, monitor models.Monitor) error
+	DeleteMonitor(ctx context.Context, name string) error
+}
+
+// ChangeNotifier is implemented by backends that are told when the screen
+// configuration changes (RandR notify events), so watchers need not poll.
+type ChangeNotifier interface {
+	// Changes returns a channel that receives a value after the outputs
+	// may have changed. Bursts may be coalesced into one value. The channel
+	// is closed when ctx is done or the connection is lost.
+	Changes(ctx context.Context) (<-chan struct{}, error)
+}
@@ -0,0 +1,118 @@
+// Package api lets other programs drive dmon: a JSON-RPC 2.0 server on a
+// Unix socket (one request or response per line), a client for it, and an
+// optional export of the same operations on the session D-Bus. A running
+// server keeps one display service, so status bars and launchers can query
+// and switch layouts without starting a process that parses xrandr again.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/profile"
+)
+
+// Methods served on the socket.
+const (
+	MethodList         = "list"
+	MethodCheck        = "check"
+	MethodSet          = "set"
+	MethodDual         = "dual"
+	MethodSingle       = "single"
+	MethodProfiles     = "profile.list"
+	MethodApplyProfile = "profile.apply"
+	// MethodSubscribe turns a connection into an event stream: after the
+	// response, every layout event arrives as a MethodEvent notification.
+	MethodSubscribe = "subscribe"
+	MethodEvent     = "event"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	// CodeFailed reports an operation that was understood but failed, such
+	// as a layout the displays cannot take.
+	CodeFailed = -32000
+)
+
+// Layouts is the part of service.DisplayService the API serves. The Client
+// implements it too, so commands run the same code locally or remotely.
+type Layouts interface {
+	ListDisplays(ctx context.Context) ([]models.Display, error)
+	CheckDisplays(ctx context.Context) (*models.Layout, error)
+	SetDisplay(ctx context.Context, target models.Target, mode models.ResolutionMode, position models.Position, customResolution string) (*models.ConfigResult, error)
+	SetupDual(ctx context.Context, mode models.ResolutionMode) (*models.ConfigResult, error)
+	SetSingleDisplay(ctx context.Context) (*models.ConfigResult, error)
+}
+
+// Modes parses resolution mode names; planner.Planner implements it, with
+// the config's presets.
+type Modes interface {
+	ParseMode(s string) (models.ResolutionMode, error)
+}
+
+// Profiles applies saved profiles; service.DisplayService implements it.
+type Profiles interface {
+	ApplyProfile(ctx context.Context, p *profile.Profile) (*models.ConfigResult, error)
+}
+
+// SetParams are the parameters of "set", spelled as on the command line.
+type SetParams struct {
+	Target     string `json:"target"`
+	Mode       string `json:"mode,omitempty"`
+	Position   string `json:"position,omitempty"`
+	Resolution string `json:"resolution,omitempty"`
+}
+
+// DualParams are the parameters of "dual".
+type DualParams struct {
+	Mode string `json:"mode,omitempty"`
+}
+
+// ProfileParams name a saved profile, or a profile file by path.
+type ProfileParams struct {
+	Name string `json:"name"`
+}
+
+// Request is a JSON-RPC 2.0 request. Requests without an ID are
+// notifications and get no response.
+type Request struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC 2.0 response.
+type Response struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC 2.0 error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// DefaultSocket returns the socket path used when none is configured:
+// dmon.sock in XDG_RUNTIME_DIR, or a per-user file in the temp directory.
+func DefaultSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "dmon.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("dmon-%d.sock", os.Getuid()))
+}
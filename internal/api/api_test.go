@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/abhishek/dmon-cli/internal/classify"
+	"github.com/abhishek/dmon-cli/internal/events"
+	"github.com/abhishek/dmon-cli/internal/fake"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/profile"
+	"github.com/abhishek/dmon-cli/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+type testServer struct {
+	path    string
+	backend *fake.Backend
+	store   *profile.Store
+}
+
+// serve starts a server over the fake backend's default laptop and returns
+// a client connected to it.
+func serve(t *testing.T) (*Client, *testServer) {
+	t.Helper()
+	logger := testLogger()
+	backend := fake.NewBackend(logger, fake.DefaultState())
+	bus := events.NewBus(logger)
+	svc := service.New(backend, logger).
+		WithClassifier(classify.New(logger)).
+		WithEvents(bus)
+	store := profile.NewStore(t.TempDir())
+
+	path := filepath.Join(t.TempDir(), "dmon.sock")
+	listener, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- NewServer(svc, logger).
+			WithProfiles(svc, store).
+			WithEvents(bus).
+			Serve(ctx, listener)
+	}()
+
+	client, err := Dial(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+	return client, &testServer{path: path, backend: backend, store: store}
+}
+
+func TestClientServer(t *testing.T) {
+	client, server := serve(t)
+	store := server.store
+	ctx := context.Background()
+
+	displays, err := client.ListDisplays(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(displays) != 3 || displays[0].ID != "eDP-1" || displays[0].Type != models.Internal {
+		t.Fatalf("ListDisplays: %v", displays)
+	}
+
+	result, err := client.SetupDual(ctx, models.ModeLow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Displays) != 2 || result.Config.Mode != models.ModeLow {
+		t.Errorf("SetupDual: %+v", result)
+	}
+
+	layout, err := client.CheckDisplays(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layout.Primary != "HDMI-1" {
+		t.Errorf("primary after dual = %q, want HDMI-1", layout.Primary)
+	}
+
+	if err := store.Save(profile.Capture("desk", layout.Displays)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SetSingleDisplay(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := client.Profiles(ctx)
+	if err != nil || len(names) != 1 || names[0] != "desk" {
+		t.Errorf("Profiles: %v, %v", names, err)
+	}
+	if _, err := client.ApplyProfile(ctx, "desk"); err != nil {
+		t.Fatal(err)
+	}
+	if layout, err = client.CheckDisplays(ctx); err != nil || layout.Primary != "HDMI-1" {
+		t.Errorf("primary after profile = %q, %v", layout.Primary, err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	client, _ := serve(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		method string
+		params any
+		code   int
+	}{
+		{"resize", nil, CodeMethodNotFound},
+		{MethodSet, SetParams{Target: "sideways"}, CodeInvalidParams},
+		{MethodDual, map[string]int{"mode": 1}, CodeInvalidParams},
+		{MethodApplyProfile, ProfileParams{Name: "missing"}, CodeFailed},
+		{MethodSet, SetParams{Target: "both", Resolution: "banana"}, CodeFailed},
+	}
+	for _, tt := range tests {
+		err := client.Call(ctx, tt.method, tt.params, nil)
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) || rpcErr.Code != tt.code {
+			t.Errorf("%s %v: got %v, want code %d", tt.method, tt.params, err, tt.code)
+		}
+	}
+
+	// The connection survives failed calls.
+	if _, err := client.CheckDisplays(ctx); err != nil {
+		t.Errorf("CheckDisplays after errors: %v", err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	client, server := serve(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subscriber, err := Dial(ctx, server.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscriber.Close()
+	stream, err := subscriber.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := subscriber.CheckDisplays(ctx); err == nil {
+		t.Error("called a method on a subscribed connection")
+	}
+
+	next := func() events.Event {
+		t.Helper()
+		select {
+		case e := <-stream:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+		}
+		return events.Event{}
+	}
+
+	if _, err := client.SetupDual(ctx, models.ModePreset); err != nil {
+		t.Fatal(err)
+	}
+	var got []events.Type
+	for len(got) < 3 {
+		got = append(got, next().Type)
+	}
+	want := []events.Type{events.ModeChanged, events.ModeChanged, events.PrimaryChanged}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dual: %v, want %v", got, want)
+	}
+
+	server.backend.FailNext("configure", "Configure crtc 1 failed")
+	if _, err := client.SetSingleDisplay(ctx); err == nil {
+		t.Fatal("configure did not fail")
+	}
+	if e := next(); e.Type != events.ConfigureFailed || e.Error != "Configure crtc 1 failed" {
+		t.Errorf("failure event: %+v", e)
+	}
+
+	cancel()
+	for range stream {
+	}
+}
+
+func TestListen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dmon.sock")
+
+	// A socket whose server died without removing it.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listener, err := Listen(path)
+	if err != nil {
+		t.Fatalf("stale socket: %v", err)
+	}
+	defer listener.Close()
+
+	if _, err := Listen(path); err == nil {
+		t.Error("listened on a socket with a live server")
+	}
+}
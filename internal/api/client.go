@@ -0,0 +1,244 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/abhishek/dmon-cli/internal/events"
+	"github.com/abhishek/dmon-cli/internal/models"
+)
+
+// Client calls a dmon server over its socket. It implements Layouts.
+type Client struct {
+	path    string
+	conn    net.Conn
+	scanner *bufio.Scanner
+
+	mu         sync.Mutex
+	nextID     int
+	subscribed bool
+}
+
+// message is any line the server sends: a response, or an event
+// notification on a subscribed connection.
+type message struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+var _ Layouts = (*Client)(nil)
+
+// Dial connects to the server listening on the socket at path.
+func Dial(ctx context.Context, path string) (*Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("no dmon server on %s (start one with 'dmon serve'): %w", path, err)
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), maxResponse)
+	return &Client{path: path, conn: conn, scanner: scanner}, nil
+}
+
+// maxResponse bounds one response line; display lists with EDIDs are large.
+const maxResponse = 16 << 20
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Call sends a request and decodes its result into result, which may be
+// nil. Server-side failures are returned as *Error.
+func (c *Client) Call(ctx context.Context, method string, params, result any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.subscribed {
+		return fmt.Errorf("the connection is subscribed to events")
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+		defer c.conn.SetDeadline(time.Time{})
+	}
+
+	resp, err := c.roundTrip(method, params, nil)
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("invalid %s result: %w", method, err)
+	}
+	return nil
+}
+
+// roundTrip sends a request and reads lines until its response. Event
+// notifications read on the way are passed to onEvent, or dropped.
+func (c *Client) roundTrip(method string, params any, onEvent func(json.RawMessage)) (message, error) {
+	c.nextID++
+	req := Request{Version: "2.0", ID: json.RawMessage(strconv.Itoa(c.nextID)), Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return message{}, fmt.Errorf("failed to encode %s parameters: %w", method, err)
+		}
+		req.Params = data
+	}
+
+	if err := json.NewEncoder(c.conn).Encode(req); err != nil {
+		return message{}, fmt.Errorf("failed to send %s to %s: %w", method, c.path, err)
+	}
+
+	for {
+		msg, err := c.read()
+		if err != nil {
+			return msg, fmt.Errorf("failed to read %s response: %w", method, err)
+		}
+		if msg.Method != "" {
+			if msg.Method == MethodEvent && onEvent != nil {
+				onEvent(msg.Params)
+			}
+			continue
+		}
+		if msg.Error != nil {
+			return msg, msg.Error
+		}
+		return msg, nil
+	}
+}
+
+func (c *Client) read() (message, error) {
+	if !c.scanner.Scan() {
+		if err := c.scanner.Err(); err != nil {
+			return message{}, err
+		}
+		return message{}, fmt.Errorf("dmon server on %s closed the connection", c.path)
+	}
+	var msg message
+	if err := json.Unmarshal(c.scanner.Bytes(), &msg); err != nil {
+		return msg, fmt.Errorf("invalid message: %w", err)
+	}
+	return msg, nil
+}
+
+// Subscribe asks the server for layout events. From then on the connection
+// only carries events: calls fail, and the channel is closed when ctx is
+// done or the server goes away.
+func (c *Client) Subscribe(ctx context.Context) (<-chan events.Event, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.subscribed {
+		return nil, fmt.Errorf("the connection is already subscribed to events")
+	}
+
+	var early []json.RawMessage
+	if _, err := c.roundTrip(MethodSubscribe, nil, func(params json.RawMessage) {
+		early = append(early, params)
+	}); err != nil {
+		return nil, err
+	}
+	c.subscribed = true
+
+	ch := make(chan events.Event)
+	go func() {
+		<-ctx.Done()
+		c.conn.Close()
+	}()
+	go func() {
+		defer close(ch)
+		send := func(params json.RawMessage) bool {
+			var e events.Event
+			if err := json.Unmarshal(params, &e); err != nil {
+				return true
+			}
+			select {
+			case ch <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for _, params := range early {
+			if !send(params) {
+				return
+			}
+		}
+		for {
+			msg, err := c.read()
+			if err != nil {
+				return
+			}
+			if msg.Method == MethodEvent && !send(msg.Params) {
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (c *Client) ListDisplays(ctx context.Context) ([]models.Display, error) {
+	var displays []models.Display
+	err := c.Call(ctx, MethodList, nil, &displays)
+	return displays, err
+}
+
+func (c *Client) CheckDisplays(ctx context.Context) (*models.Layout, error) {
+	var layout models.Layout
+	if err := c.Call(ctx, MethodCheck, nil, &layout); err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+func (c *Client) SetDisplay(ctx context.Context, target models.Target, mode models.ResolutionMode, position models.Position, customResolution string) (*models.ConfigResult, error) {
+	return c.configure(ctx, MethodSet, SetParams{
+		Target:     target.String(),
+		Mode:       mode.String(),
+		Position:   position.String(),
+		Resolution: customResolution,
+	})
+}
+
+func (c *Client) SetupDual(ctx context.Context, mode models.ResolutionMode) (*models.ConfigResult, error) {
+	return c.configure(ctx, MethodDual, DualParams{Mode: mode.String()})
+}
+
+func (c *Client) SetSingleDisplay(ctx context.Context) (*models.ConfigResult, error) {
+	return c.configure(ctx, MethodSingle, nil)
+}
+
+// Profiles lists the server's saved profiles.
+func (c *Client) Profiles(ctx context.Context) ([]string, error) {
+	var names []string
+	err := c.Call(ctx, MethodProfiles, nil, &names)
+	return names, err
+}
+
+// ApplyProfile applies a profile the server loads by name or path.
+func (c *Client) ApplyProfile(ctx context.Context, name string) (*models.ConfigResult, error) {
+	return c.configure(ctx, MethodApplyProfile, ProfileParams{Name: name})
+}
+
+func (c *Client) configure(ctx context.Context, method string, params any) (*models.ConfigResult, error) {
+	var result models.ConfigResult
+	if err := c.Call(ctx, method, params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+// Names of the D-Bus service. Methods return their results, and the Event
+// signal carries its event, as the same JSON the socket API sends.
+const (
+	BusName       = "io.github.abhishek.Dmon"
+	BusInterface  = BusName
+	BusObjectPath = dbus.ObjectPath("/io/github/abhishek/Dmon")
+	BusSignal     = BusInterface + ".Event"
+	busError      = BusName + ".Error"
+)
+
+// busObject is exported on the bus; its method names are the D-Bus method
+// names.
+type busObject struct {
+	ctx    context.Context
+	server *Server
+}
+
+func (o busObject) List() (string, *dbus.Error) {
+	return o.call(MethodList, nil)
+}
+
+func (o busObject) Check() (string, *dbus.Error) {
+	return o.call(MethodCheck, nil)
+}
+
+func (o busObject) Set(target, mode, position, resolution string) (string, *dbus.Error) {
+	return o.call(MethodSet, SetParams{Target: target, Mode: mode, Position: position, Resolution: resolution})
+}
+
+func (o busObject) Dual(mode string) (string, *dbus.Error) {
+	return o.call(MethodDual, DualParams{Mode: mode})
+}
+
+func (o busObject) Single() (string, *dbus.Error) {
+	return o.call(MethodSingle, nil)
+}
+
+func (o busObject) Profiles() (string, *dbus.Error) {
+	return o.call(MethodProfiles, nil)
+}
+
+func (o busObject) ApplyProfile(name string) (string, *dbus.Error) {
+	return o.call(MethodApplyProfile, ProfileParams{Name: name})
+}
+
+func (o busObject) call(method string, params any) (string, *dbus.Error) {
+	o.server.logger.WithField("method", method).Info("D-Bus request")
+
+	var raw json.RawMessage
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return "", dbus.MakeFailedError(err)
+		}
+		raw = data
+	}
+
+	result, err := o.server.call(o.ctx, method, raw)
+	if err != nil {
+		name := busError + ".Failed"
+		if rpcErr := toError(err); rpcErr.Code == CodeInvalidParams {
+			name = busError + ".InvalidArgs"
+		}
+		return "", dbus.NewError(name, []any{err.Error()})
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return string(data), nil
+}
+
+// ExportDBus serves the server's methods on the session bus under BusName
+// until ctx is done, and emits its events as Event signals. Calls share the
+// server's lock with socket requests.
+func (s *Server) ExportDBus(ctx context.Context) error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the session bus: %w", err)
+	}
+
+	obj := busObject{ctx: ctx, server: s}
+	if err := conn.Export(obj, BusObjectPath, BusInterface); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to export %s: %w", BusObjectPath, err)
+	}
+	node := &introspect.Node{
+		Name: string(BusObjectPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			{
+				Name:    BusInterface,
+				Methods: introspect.Methods(obj),
+				Signals: []introspect.Signal{{
+					Name: "Event",
+					Args: []introspect.Arg{{Name: "event", Type: "s"}},
+				}},
+			},
+		},
+	}
+	if err := conn.Export(introspect.NewIntrospectable(node), BusObjectPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to export introspection data: %w", err)
+	}
+
+	reply, err := conn.RequestName(BusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to request %s: %w", BusName, err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return fmt.Errorf("%s is already owned on the session bus", BusName)
+	}
+
+	s.logger.WithField("name", BusName).Info("Serving on the session bus")
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	if s.events != nil {
+		ch, unsubscribe := s.events.Subscribe()
+		go func() {
+			<-ctx.Done()
+			unsubscribe()
+		}()
+		go func() {
+			for e := range ch {
+				data, err := json.Marshal(e)
+				if err != nil {
+					continue
+				}
+				if err := conn.Emit(BusObjectPath, BusSignal, string(data)); err != nil {
+					s.logger.WithError(err).Debug("Failed to emit D-Bus event")
+				}
+			}
+		}()
+	}
+	return nil
+}
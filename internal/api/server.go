@@ -0,0 +1,349 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/abhishek/dmon-cli/internal/events"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/profile"
+	"github.com/sirupsen/logrus"
+)
+
+// maxRequest bounds one request line.
+const maxRequest = 1 << 20
+
+// Server answers JSON-RPC requests with a display service. Calls are run
+// one at a time, whichever connection they come from, since layout changes
+// must not interleave.
+type Server struct {
+	layouts  Layouts
+	profiles Profiles
+	modes    Modes
+	store    *profile.Store
+	events   *events.Bus
+	logger   *logrus.Logger
+
+	mu sync.Mutex
+}
+
+// NewServer creates a server for layouts. Profile methods fail until
+// WithProfiles is called.
+func NewServer(layouts Layouts, logger *logrus.Logger) *Server {
+	return &Server{
+		layouts: layouts,
+		logger:  logger,
+	}
+}
+
+// WithProfiles serves the profiles saved in store, applied with profiles.
+func (s *Server) WithProfiles(profiles Profiles, store *profile.Store) *Server {
+	s.profiles = profiles
+	s.store = store
+	return s
+}
+
+// WithModes parses mode parameters with modes, so the config's presets are
+// accepted. Without it only the built-in modes are.
+func (s *Server) WithModes(modes Modes) *Server {
+	s.modes = modes
+	return s
+}
+
+// WithEvents lets clients subscribe to the events published on bus.
+func (s *Server) WithEvents(bus *events.Bus) *Server {
+	s.events = bus
+	return s
+}
+
+// Listen creates the Unix socket at path, readable by the current user
+// only. A socket left behind by a server that is gone is replaced; one
+// with a live server behind it is an error.
+func Listen(path string) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("a dmon server is already listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict socket %s: %w", path, err)
+	}
+	return listener, nil
+}
+
+// Serve accepts connections until ctx is done, then closes the listener
+// (which removes the socket file).
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// connection is a client connection. Responses and event notifications
+// are written from different goroutines, so writes take a lock.
+type connection struct {
+	net.Conn
+	mu          sync.Mutex
+	encoder     *json.Encoder
+	unsubscribe func()
+}
+
+func (c *connection) write(v any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.encoder.Encode(v)
+}
+
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	c := &connection{Conn: conn, encoder: json.NewEncoder(conn)}
+	defer func() {
+		if c.unsubscribe != nil {
+			c.unsubscribe()
+		}
+		conn.Close()
+	}()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	s.logger.Debug("API client connected")
+	defer s.logger.Debug("API client disconnected")
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), maxRequest)
+
+	for scanner.Scan() {
+		response := s.handle(ctx, c, scanner.Bytes())
+		if response == nil {
+			continue
+		}
+		if err := c.write(response); err != nil {
+			s.logger.WithError(err).Debug("Failed to write API response")
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		s.logger.WithError(err).Debug("Failed to read API request")
+	}
+}
+
+// handle runs one request line. It returns nil for notifications.
+func (s *Server) handle(ctx context.Context, c *connection, line []byte) *Response {
+	var req Request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return errorResponse(nil, &Error{Code: CodeParseError, Message: err.Error()})
+	}
+	if req.Version != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, &Error{Code: CodeInvalidRequest, Message: "not a JSON-RPC 2.0 request"})
+	}
+
+	s.logger.WithField("method", req.Method).Info("API request")
+
+	var result any
+	var err error
+	if req.Method == MethodSubscribe {
+		result, err = s.subscribe(c)
+	} else {
+		result, err = s.call(ctx, req.Method, req.Params)
+	}
+	if len(req.ID) == 0 {
+		if err != nil {
+			s.logger.WithError(err).WithField("method", req.Method).Warn("API notification failed")
+		}
+		return nil
+	}
+	if err != nil {
+		return errorResponse(req.ID, toError(err))
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, &Error{Code: CodeFailed, Message: err.Error()})
+	}
+	return &Response{Version: "2.0", ID: req.ID, Result: data}
+}
+
+// subscribe forwards every event to the connection until it closes.
+func (s *Server) subscribe(c *connection) (any, error) {
+	if s.events == nil {
+		return nil, errors.New("this server does not publish events")
+	}
+	if c.unsubscribe != nil {
+		return true, nil
+	}
+
+	ch, unsubscribe := s.events.Subscribe()
+	c.unsubscribe = unsubscribe
+	go func() {
+		for e := range ch {
+			params, err := json.Marshal(e)
+			if err != nil {
+				s.logger.WithError(err).Warn("Failed to encode event")
+				continue
+			}
+			if err := c.write(Request{Version: "2.0", Method: MethodEvent, Params: params}); err != nil {
+				s.logger.WithError(err).Debug("Failed to send event")
+				c.Close()
+				return
+			}
+		}
+	}()
+	return true, nil
+}
+
+func (s *Server) call(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch method {
+	case MethodList:
+		return s.layouts.ListDisplays(ctx)
+
+	case MethodCheck:
+		return s.layouts.CheckDisplays(ctx)
+
+	case MethodSet:
+		var p SetParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		target, err := models.ParseTarget(p.Target)
+		if err != nil {
+			return nil, invalidParams(err)
+		}
+		mode, err := s.parseMode(p.Mode)
+		if err != nil {
+			return nil, err
+		}
+		position := models.PositionRight
+		if p.Position != "" {
+			if position, err = models.ParsePosition(p.Position); err != nil {
+				return nil, invalidParams(err)
+			}
+		}
+		return s.layouts.SetDisplay(ctx, target, mode, position, p.Resolution)
+
+	case MethodDual:
+		var p DualParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		mode, err := s.parseMode(p.Mode)
+		if err != nil {
+			return nil, err
+		}
+		return s.layouts.SetupDual(ctx, mode)
+
+	case MethodSingle:
+		return s.layouts.SetSingleDisplay(ctx)
+
+	case MethodProfiles:
+		if s.store == nil {
+			return nil, errNoProfiles
+		}
+		names, err := s.store.List()
+		if names == nil {
+			names = []string{}
+		}
+		return names, err
+
+	case MethodApplyProfile:
+		if s.store == nil {
+			return nil, errNoProfiles
+		}
+		var p ProfileParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		saved, err := s.store.Load(p.Name)
+		if err != nil {
+			return nil, err
+		}
+		return s.profiles.ApplyProfile(ctx, saved)
+	}
+
+	return nil, &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("unknown method %q", method)}
+}
+
+var errNoProfiles = errors.New("this server does not serve profiles")
+
+func (s *Server) parseMode(name string) (models.ResolutionMode, error) {
+	if name == "" {
+		return models.ModePreset, nil
+	}
+	parse := models.ParseResolutionMode
+	if s.modes != nil {
+		parse = s.modes.ParseMode
+	}
+	mode, err := parse(name)
+	if err != nil {
+		return mode, invalidParams(err)
+	}
+	return mode, nil
+}
+
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return invalidParams(err)
+	}
+	return nil
+}
+
+func invalidParams(err error) error {
+	return &Error{Code: CodeInvalidParams, Message: err.Error()}
+}
+
+func toError(err error) *Error {
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	return &Error{Code: CodeFailed, Message: err.Error()}
+}
+
+func errorResponse(id json.RawMessage, err *Error) *Response {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &Response{Version: "2.0", ID: id, Error: err}
+}
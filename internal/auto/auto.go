@@ -0,0 +1,223 @@
+// Package auto decides which layout suits the machine's situation: the
+// displays that are connected, whether the lid is closed, whether the
+// machine runs on battery and which dock it sits in. 'dmon auto'
+// applies the decision once and 'dmon watch' whenever the situation
+// changes.
+package auto
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/abhishek/dmon-cli/internal/dock"
+	"github.com/abhishek/dmon-cli/internal/lid"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/power"
+	"github.com/abhishek/dmon-cli/internal/profile"
+)
+
+// Action is a kind of layout change.
+type Action int
+
+const (
+	// Single turns on the internal display only.
+	Single Action = iota
+	// External turns on the external displays only, the first one primary.
+	External
+	// Dual turns on both, as 'dmon dual' does.
+	Dual
+	// Profile applies a saved profile.
+	Profile
+)
+
+func (a Action) String() string {
+	switch a {
+	case Single:
+		return "single"
+	case External:
+		return "external"
+	case Dual:
+		return "dual"
+	case Profile:
+		return "profile"
+	}
+	return "unknown"
+}
+
+// Situation is what the decision depends on.
+type Situation struct {
+	Displays []models.Display
+	Lid      lid.State
+	Power    power.State
+	// Docks are the attached devices that profile conditions name; other
+	// USB devices coming and going do not matter.
+	Docks []dock.Device
+}
+
+// Key identifies a situation for noticing that it changed: the lid state,
+// the power source, the docks and the connected outputs. Layout changes
+// that follow from a decision leave it alone.
+func (s Situation) Key() string {
+	var connected []string
+	for _, d := range s.Displays {
+		if d.Connected {
+			connected = append(connected, d.ID)
+		}
+	}
+	sort.Strings(connected)
+	docks := make([]string, 0, len(s.Docks))
+	for _, d := range s.Docks {
+		docks = append(docks, d.Spec().String())
+	}
+	sort.Strings(docks)
+	return fmt.Sprintf("lid=%s power=%s docks=%s outputs=%s", s.Lid, s.Power, strings.Join(docks, ","), strings.Join(connected, ","))
+}
+
+// Rules tune the decision.
+type Rules struct {
+	// DualProfile is applied instead of 'dmon dual' when an external
+	// display is connected and the lid is open.
+	DualProfile string
+	// Profiles are saved profiles with conditions. The most specific one
+	// that suits the situation wins over the built-in layouts.
+	Profiles []*profile.Profile
+	// Battery and AC pick resolutions and refresh rates for the built-in
+	// layouts by power source. An unknown source counts as AC.
+	Battery, AC Settings
+}
+
+// Settings are how the built-in layouts pick modes.
+type Settings struct {
+	Mode models.ResolutionMode
+	// MaxRate and HighestRate are as in models.DisplayConfig.
+	MaxRate     float64
+	HighestRate bool
+}
+
+// Decision is the layout change for a situation.
+type Decision struct {
+	Action  Action
+	Profile string
+	// Config is the layout of the Single, External and Dual actions.
+	Config models.DisplayConfig
+	// Reason says why, for the user.
+	Reason string
+}
+
+// Decide picks the layout for a situation. A profile whose conditions
+// hold is used first. Otherwise, with only one kind of display connected,
+// that kind is used whatever the lid says; with both, a closed lid turns
+// the internal display off and an open one uses both.
+func Decide(s Situation, r Rules) (Decision, error) {
+	internal, external := 0, 0
+	for _, d := range s.Displays {
+		if !d.Connected {
+			continue
+		}
+		if d.Type == models.Internal {
+			internal++
+		} else {
+			external++
+		}
+	}
+
+	if internal == 0 && external == 0 {
+		return Decision{}, errors.New("no displays are connected")
+	}
+
+	if p := pickProfile(s, r.Profiles); p != nil {
+		return Decision{Action: Profile, Profile: p.Name, Reason: "its conditions hold: " + p.When.String()}, nil
+	}
+
+	settings := r.AC
+	if s.Power == power.Battery {
+		settings = r.Battery
+	}
+	config := func(target models.Target, position models.Position) models.DisplayConfig {
+		return models.DisplayConfig{
+			Target:      target,
+			Mode:        settings.Mode,
+			Position:    position,
+			MaxRate:     settings.MaxRate,
+			HighestRate: settings.HighestRate,
+		}
+	}
+
+	switch {
+	case external == 0:
+		return Decision{Action: Single, Config: config(models.TargetInternal, models.PositionNone), Reason: "only the internal display is connected"}, nil
+	case internal == 0:
+		return Decision{Action: External, Config: config(models.TargetExternal, models.PositionNone), Reason: "no internal display is connected"}, nil
+	case s.Lid == lid.Closed:
+		return Decision{Action: External, Config: config(models.TargetExternal, models.PositionNone), Reason: "the lid is closed"}, nil
+	case r.DualProfile != "":
+		return Decision{Action: Profile, Profile: r.DualProfile, Reason: "an external display is connected and the lid is " + lidWord(s.Lid)}, nil
+	}
+	return Decision{Action: Dual, Config: config(models.TargetBoth, models.PositionRight), Reason: "an external display is connected and the lid is " + lidWord(s.Lid)}, nil
+}
+
+// pickProfile returns the profile with the most conditions that suits s:
+// its conditions hold and every output it enables is connected, and it
+// does not turn on the internal display under a closed lid. Ties go to
+// the first profile.
+func pickProfile(s Situation, profiles []*profile.Profile) *profile.Profile {
+	var best *profile.Profile
+	for _, p := range profiles {
+		if p.When.IsZero() || !holds(p.When, s) || !fits(p, s) {
+			continue
+		}
+		if best == nil || p.When.Count() > best.When.Count() {
+			best = p
+		}
+	}
+	return best
+}
+
+func holds(c profile.Conditions, s Situation) bool {
+	if c.Power != "" {
+		state, err := power.ParseState(c.Power)
+		if err != nil || state != s.Power {
+			return false
+		}
+	}
+	if c.Dock != "" {
+		spec, err := dock.ParseSpec(c.Dock)
+		if err != nil || len(dock.Filter(s.Docks, []dock.Spec{spec})) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// DockSpecs returns the docks the profiles' conditions name, for picking
+// the Situation's docks out of all attached devices.
+func DockSpecs(profiles []*profile.Profile) []dock.Spec {
+	var specs []dock.Spec
+	for _, p := range profiles {
+		if spec, err := dock.ParseSpec(p.When.Dock); err == nil {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+func fits(p *profile.Profile, s Situation) bool {
+	for i, d := range p.Match(s.Displays) {
+		if !p.Outputs[i].Enabled {
+			continue
+		}
+		if d == nil || (d.Type == models.Internal && s.Lid == lid.Closed) {
+			return false
+		}
+	}
+	return true
+}
+
+func lidWord(s lid.State) string {
+	if s == lid.Unknown {
+		return "not closed"
+	}
+	return s.String()
+}
@@ -0,0 +1,175 @@
+package auto
+
+import (
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/dock"
+	"github.com/abhishek/dmon-cli/internal/lid"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/power"
+	"github.com/abhishek/dmon-cli/internal/profile"
+)
+
+var (
+	laptop  = models.Display{ID: "eDP-1", Type: models.Internal, Connected: true}
+	monitor = models.Display{ID: "HDMI-1", Type: models.External, Connected: true}
+	unused  = models.Display{ID: "DP-1", Type: models.External}
+)
+
+func TestDecide(t *testing.T) {
+	tests := []struct {
+		name     string
+		displays []models.Display
+		lid      lid.State
+		rules    Rules
+		want     Action
+		profile  string
+	}{
+		{"laptop alone", []models.Display{laptop, unused}, lid.Open, Rules{}, Single, ""},
+		{"laptop alone, lid closed", []models.Display{laptop, unused}, lid.Closed, Rules{}, Single, ""},
+		{"docked, lid closed", []models.Display{laptop, monitor}, lid.Closed, Rules{DualProfile: "desk"}, External, ""},
+		{"docked, lid open", []models.Display{laptop, monitor}, lid.Open, Rules{}, Dual, ""},
+		{"docked, no lid", []models.Display{laptop, monitor}, lid.Unknown, Rules{}, Dual, ""},
+		{"docked, dual profile", []models.Display{laptop, monitor}, lid.Open, Rules{DualProfile: "desk"}, Profile, "desk"},
+		{"desktop", []models.Display{monitor, unused}, lid.Unknown, Rules{}, External, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decide(Situation{Displays: tt.displays, Lid: tt.lid}, tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Action != tt.want || got.Profile != tt.profile || got.Reason == "" {
+				t.Errorf("Decide = %+v, want %s %q", got, tt.want, tt.profile)
+			}
+		})
+	}
+
+	if _, err := Decide(Situation{Displays: []models.Display{unused}}, Rules{}); err == nil {
+		t.Error("Decide with nothing connected succeeded")
+	}
+}
+
+func TestKey(t *testing.T) {
+	a := Situation{Displays: []models.Display{monitor, laptop, unused}, Lid: lid.Open}
+	b := Situation{Displays: []models.Display{laptop, monitor}, Lid: lid.Open}
+	if a.Key() != b.Key() {
+		t.Errorf("same situation, different keys: %q, %q", a.Key(), b.Key())
+	}
+	b.Lid = lid.Closed
+	if a.Key() == b.Key() {
+		t.Errorf("lid change kept the key %q", a.Key())
+	}
+	b.Lid, b.Power = lid.Open, power.Battery
+	if a.Key() == b.Key() {
+		t.Errorf("power change kept the key %q", a.Key())
+	}
+}
+
+func TestDecidePower(t *testing.T) {
+	rules := Rules{
+		Battery: Settings{Mode: models.ModeLow, MaxRate: 60, HighestRate: true},
+		AC:      Settings{Mode: models.ModePreset, HighestRate: true},
+	}
+	docked := []models.Display{laptop, monitor}
+
+	got, err := Decide(Situation{Displays: docked, Lid: lid.Open, Power: power.Battery}, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.DisplayConfig{Target: models.TargetBoth, Mode: models.ModeLow, Position: models.PositionRight, MaxRate: 60, HighestRate: true}
+	if got.Action != Dual || got.Config != want {
+		t.Errorf("on battery: %+v", got)
+	}
+
+	got, _ = Decide(Situation{Displays: docked, Lid: lid.Closed, Power: power.Unknown}, rules)
+	want = models.DisplayConfig{Target: models.TargetExternal, Mode: models.ModePreset, HighestRate: true}
+	if got.Action != External || got.Config != want {
+		t.Errorf("unknown power counts as AC: %+v", got)
+	}
+}
+
+func TestDecideProfiles(t *testing.T) {
+	travel := &profile.Profile{Name: "travel", When: profile.Conditions{Power: "battery"}, Outputs: []profile.Output{
+		{Name: "eDP-1", Enabled: true, Mode: "1920x1200", Rate: 60},
+	}}
+	desk := &profile.Profile{Name: "desk", When: profile.Conditions{Power: "ac"}, Outputs: []profile.Output{
+		{Name: "eDP-1", Enabled: true, Mode: "1920x1200"},
+		{Name: "HDMI-1", Enabled: true, Mode: "3840x2160", X: 1920},
+	}}
+	plain := &profile.Profile{Name: "plain", Outputs: []profile.Output{{Name: "eDP-1", Enabled: true, Mode: "1920x1200"}}}
+	rules := Rules{Profiles: []*profile.Profile{plain, travel, desk}}
+	docked := []models.Display{laptop, monitor}
+
+	tests := []struct {
+		name    string
+		s       Situation
+		want    Action
+		profile string
+	}{
+		{"battery", Situation{Displays: []models.Display{laptop}, Power: power.Battery}, Profile, "travel"},
+		{"AC, docked", Situation{Displays: docked, Lid: lid.Open, Power: power.AC}, Profile, "desk"},
+		{"AC, monitor missing", Situation{Displays: []models.Display{laptop, unused}, Power: power.AC}, Single, ""},
+		{"lid closed", Situation{Displays: docked, Lid: lid.Closed, Power: power.AC}, External, ""},
+		{"no power information", Situation{Displays: []models.Display{laptop}}, Single, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decide(tt.s, rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Action != tt.want || got.Profile != tt.profile {
+				t.Errorf("Decide = %+v, want %s %q", got, tt.want, tt.profile)
+			}
+		})
+	}
+}
+
+func TestDecideDocks(t *testing.T) {
+	outputs := []profile.Output{
+		{Name: "eDP-1"},
+		{Name: "HDMI-1", Enabled: true, Mode: "3840x2160"},
+	}
+	desk3 := &profile.Profile{Name: "desk3", When: profile.Conditions{Dock: "17ef:30b4:DESK3"}, Outputs: outputs}
+	desk7 := &profile.Profile{Name: "desk7", When: profile.Conditions{Dock: "17ef:30b4:DESK7"}, Outputs: outputs}
+	anyDock := &profile.Profile{Name: "docked", When: profile.Conditions{Dock: "17ef:30b4"}, Outputs: outputs}
+	rules := Rules{Profiles: []*profile.Profile{anyDock, desk3, desk7}}
+
+	dockAt := func(serial string) []dock.Device {
+		return []dock.Device{{Bus: dock.USB, Vendor: "17ef", Product: "30b4", Serial: serial}}
+	}
+	docked := []models.Display{laptop, monitor}
+
+	tests := []struct {
+		name    string
+		docks   []dock.Device
+		want    Action
+		profile string
+	}{
+		{"desk 3", dockAt("DESK3"), Profile, "desk3"},
+		{"desk 7", dockAt("DESK7"), Profile, "desk7"},
+		{"another desk", dockAt("DESK9"), Profile, "docked"},
+		{"no dock", nil, Dual, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decide(Situation{Displays: docked, Lid: lid.Open, Docks: tt.docks}, rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Action != tt.want || got.Profile != tt.profile {
+				t.Errorf("Decide = %+v, want %s %q", got, tt.want, tt.profile)
+			}
+		})
+	}
+
+	if specs := DockSpecs(rules.Profiles); len(specs) != 3 || specs[1].Serial != "DESK3" {
+		t.Errorf("DockSpecs = %+v", specs)
+	}
+	a := Situation{Displays: docked, Docks: dockAt("DESK3")}
+	b := Situation{Displays: docked, Docks: dockAt("DESK7")}
+	if a.Key() == b.Key() {
+		t.Errorf("dock change kept the key %q", a.Key())
+	}
+}
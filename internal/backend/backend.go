@@ -0,0 +1,293 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/abhishek/dmon-cli/internal/adapter"
+	"github.com/abhishek/dmon-cli/internal/fake"
+	"github.com/abhishek/dmon-cli/internal/planner"
+	"github.com/abhishek/dmon-cli/internal/record"
+	"github.com/abhishek/dmon-cli/internal/runner"
+	"github.com/abhishek/dmon-cli/internal/x11"
+	"github.com/abhishek/dmon-cli/internal/xrandr"
+	"github.com/sirupsen/logrus"
+)
+
+// Auto asks the registry to probe for the first usable backend.
+const Auto = "auto"
+
+// Options carries CLI settings that only some backends use.
+type Options struct {
+	// FakeState is the JSON inventory file for the fake backend.
+	FakeState string
+	// Replay is the --record bundle served back by the replay backend.
+	Replay string
+	// Runner, when set, executes external commands: a recorder, or the
+	// replayer the replay backend shares with hooks and the like.
+	Runner runner.Runner
+	// Planner, when set, replaces the backend's default layout planner.
+	Planner *planner.Planner
+}
+
+// Entry describes a backend the registry can probe and construct.
+type Entry struct {
+	Name        string
+	Description string
+	// Probe reports whether the backend can work in this session and why.
+	Probe func(ctx context.Context) (bool, string)
+	New   func(logger *logrus.Logger, opts Options) (adapter.DisplayBackend, error)
+	// Manual backends are never picked by auto-selection.
+	Manual bool
+}
+
+// ProbeResult is the outcome of probing one backend.
+type ProbeResult struct {
+	Name     string
+	Accepted bool
+	Reason   string
+	Manual   bool
+}
+
+type Registry struct {
+	entries []Entry
+}
+
+func NewRegistry(entries ...Entry) *Registry {
+	return &Registry{
+		entries: entries,
+	}
+}
+
+// Default returns the registry of built-in backends in auto-selection order.
+func Default() *Registry {
+	return NewRegistry(
+		Entry{
+			Name:        "xrandr",
+			Description: "Runs the xrandr binary and parses its output",
+			Probe:       probeXrandr,
+			New: func(logger *logrus.Logger, opts Options) (adapter.DisplayBackend, error) {
+				b := xrandr.NewBackend(logger)
+				if opts.Runner != nil {
+					b.WithRunner(opts.Runner)
+				}
+				if opts.Planner != nil {
+					b.WithPlanner(opts.Planner)
+				}
+				return b, nil
+			},
+		},
+		Entry{
+			Name:        "x11",
+			Description: "Speaks RandR directly over the X socket",
+			Probe:       probeX11,
+			New: func(logger *logrus.Logger, opts Options) (adapter.DisplayBackend, error) {
+				b := x11.NewBackend(logger)
+				if opts.Planner != nil {
+					b.WithPlanner(opts.Planner)
+				}
+				return b, nil
+			},
+		},
+		Entry{
+			Name:        "fake",
+			Description: "Scriptable in-memory inventory for tests and demos",
+			Manual:      true,
+			New: func(logger *logrus.Logger, opts Options) (adapter.DisplayBackend, error) {
+				b := fake.NewBackend(logger, fake.DefaultState())
+				if opts.FakeState != "" {
+					var err error
+					if b, err = fake.Load(logger, opts.FakeState); err != nil {
+						return nil, err
+					}
+				}
+				if opts.Planner != nil {
+					b.WithPlanner(opts.Planner)
+				}
+				return b, nil
+			},
+		},
+		Entry{
+			Name:        "replay",
+			Description: "Serves xrandr output captured with --record",
+			Manual:      true,
+			New:         newReplay,
+		},
+	)
+}
+
+// Register appends a backend after the built-in ones.
+func (r *Registry) Register(entry Entry) {
+	r.entries = append(r.entries, entry)
+}
+
+// Names lists every registered backend.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.entries))
+	for _, e := range r.entries {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+// ProbeAll probes every backend, for diagnostics.
+func (r *Registry) ProbeAll(ctx context.Context) []ProbeResult {
+	results := make([]ProbeResult, 0, len(r.entries))
+	for _, e := range r.entries {
+		results = append(results, probe(ctx, e))
+	}
+	return results
+}
+
+func probe(ctx context.Context, e Entry) ProbeResult {
+	if e.Manual {
+		ok, reason := true, "only used when selected explicitly"
+		if e.Probe != nil {
+			ok, reason = e.Probe(ctx)
+		}
+		return ProbeResult{Name: e.Name, Accepted: ok, Reason: reason, Manual: true}
+	}
+
+	ok, reason := e.Probe(ctx)
+	return ProbeResult{Name: e.Name, Accepted: ok, Reason: reason}
+}
+
+// Select constructs the named backend, or the first accepted one for "auto".
+// A forced backend is constructed even when its probe rejects it.
+func (r *Registry) Select(ctx context.Context, name string, opts Options, logger *logrus.Logger) (adapter.DisplayBackend, string, error) {
+	if name == "" || name == Auto {
+		var reasons []string
+		for _, e := range r.entries {
+			if e.Manual {
+				continue
+			}
+			result := probe(ctx, e)
+			logger.WithFields(logrus.Fields{
+				"backend":  e.Name,
+				"accepted": result.Accepted,
+				"reason":   result.Reason,
+			}).Debug("Probed display backend")
+			if result.Accepted {
+				b, err := e.New(logger, opts)
+				return b, e.Name, err
+			}
+			reasons = append(reasons, fmt.Sprintf("%s: %s", e.Name, result.Reason))
+		}
+		return nil, "", fmt.Errorf("no usable display backend (%s). Run 'dmon doctor' for details", strings.Join(reasons, "; "))
+	}
+
+	e, err := r.Lookup(name)
+	if err != nil {
+		return nil, "", err
+	}
+	if e.Probe != nil {
+		if ok, reason := e.Probe(ctx); !ok {
+			logger.WithFields(logrus.Fields{
+				"backend": e.Name,
+				"reason":  reason,
+			}).Warn("Forced backend failed its probe")
+		}
+	}
+	b, err := e.New(logger, opts)
+	return b, e.Name, err
+}
+
+// Lookup returns the entry registered under name.
+func (r *Registry) Lookup(name string) (Entry, error) {
+	for _, e := range r.entries {
+		if e.Name == name {
+			return e, nil
+		}
+	}
+	return Entry{}, fmt.Errorf("unknown backend: %s (valid: %s, %s)", name, Auto, strings.Join(r.Names(), ", "))
+}
+
+func newReplay(logger *logrus.Logger, opts Options) (adapter.DisplayBackend, error) {
+	if opts.Replay == "" {
+		return nil, fmt.Errorf("the replay backend needs --replay <bundle.tar>")
+	}
+
+	bundle, err := record.Read(opts.Replay)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.WithFields(logrus.Fields{
+		"bundle":   opts.Replay,
+		"recorded": bundle.Manifest.RecordedAt,
+		"args":     strings.Join(bundle.Manifest.Args, " "),
+		"commands": len(bundle.Commands),
+	}).Info("Replaying recorded session")
+	if bundle.Manifest.Backend != "" && bundle.Manifest.Backend != "xrandr" {
+		logger.WithField("backend", bundle.Manifest.Backend).Warn("Bundle was recorded with a backend that does not run external commands")
+	}
+
+	replayer := opts.Runner
+	if replayer == nil {
+		replayer = record.NewReplayer(bundle)
+	}
+	b := xrandr.NewBackend(logger).WithRunner(replayer)
+	if opts.Planner != nil {
+		b.WithPlanner(opts.Planner)
+	}
+	return b, nil
+}
+
+// checkX verifies that the session looks like an X session with a reachable display.
+func checkX() (bool, string) {
+	display := os.Getenv("DISPLAY")
+	if display == "" {
+		return false, "DISPLAY is not set"
+	}
+
+	if os.Getenv("XDG_SESSION_TYPE") == "wayland" || os.Getenv("WAYLAND_DISPLAY") != "" {
+		return false, fmt.Sprintf("Wayland session detected (DISPLAY=%s is XWayland, which cannot change outputs)", display)
+	}
+
+	// ":N" or ":N.S" is a local display served from /tmp/.X11-unix/XN.
+	if strings.HasPrefix(display, ":") {
+		number := strings.TrimPrefix(display, ":")
+		if i := strings.Index(number, "."); i >= 0 {
+			number = number[:i]
+		}
+		socket := filepath.Join("/tmp/.X11-unix", "X"+number)
+		if _, err := os.Stat(socket); err != nil {
+			return false, fmt.Sprintf("X socket %s not found for DISPLAY=%s", socket, display)
+		}
+		return true, fmt.Sprintf("local display %s (%s)", display, socket)
+	}
+
+	return true, fmt.Sprintf("remote display %s", display)
+}
+
+func probeXrandr(ctx context.Context) (bool, string) {
+	path, err := exec.LookPath("xrandr")
+	if err != nil {
+		return false, "xrandr binary not found in PATH"
+	}
+
+	ok, reason := checkX()
+	if !ok {
+		return false, reason
+	}
+
+	return true, fmt.Sprintf("%s, %s", path, reason)
+}
+
+func probeX11(ctx context.Context) (bool, string) {
+	ok, reason := checkX()
+	if !ok {
+		return false, reason
+	}
+
+	version, err := x11.Probe("")
+	if err != nil {
+		return false, err.Error()
+	}
+
+	return true, fmt.Sprintf("%s, %s", version, reason)
+}
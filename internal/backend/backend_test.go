@@ -0,0 +1,238 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/adapter"
+	"github.com/abhishek/dmon-cli/internal/fake"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/record"
+	"github.com/abhishek/dmon-cli/internal/runner"
+	"github.com/sirupsen/logrus"
+)
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// testEntry registers a fake backend whose probe answers ok; built records
+// which entries were constructed.
+func testEntry(name string, ok, manual bool, built *[]string) Entry {
+	return Entry{
+		Name: name,
+		Probe: func(ctx context.Context) (bool, string) {
+			if ok {
+				return true, "fine"
+			}
+			return false, name + " is missing"
+		},
+		New: func(logger *logrus.Logger, opts Options) (adapter.DisplayBackend, error) {
+			*built = append(*built, name)
+			return fake.NewBackend(logger, fake.DefaultState()), nil
+		},
+		Manual: manual,
+	}
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries func(built *[]string) []Entry
+		backend string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "auto picks the first accepted backend",
+			entries: func(built *[]string) []Entry {
+				return []Entry{testEntry("a", false, false, built), testEntry("b", true, false, built), testEntry("c", true, false, built)}
+			},
+			backend: Auto,
+			want:    "b",
+		},
+		{
+			name: "empty name means auto",
+			entries: func(built *[]string) []Entry {
+				return []Entry{testEntry("a", true, false, built)}
+			},
+			want: "a",
+		},
+		{
+			name: "auto skips manual backends",
+			entries: func(built *[]string) []Entry {
+				return []Entry{testEntry("m", true, true, built), testEntry("a", true, false, built)}
+			},
+			backend: Auto,
+			want:    "a",
+		},
+		{
+			name: "auto fails with every reason",
+			entries: func(built *[]string) []Entry {
+				return []Entry{testEntry("a", false, false, built), testEntry("b", false, false, built)}
+			},
+			backend: Auto,
+			wantErr: "no usable display backend (a: a is missing; b: b is missing)",
+		},
+		{
+			name: "forced backend is built despite its probe",
+			entries: func(built *[]string) []Entry {
+				return []Entry{testEntry("a", true, false, built), testEntry("b", false, false, built)}
+			},
+			backend: "b",
+			want:    "b",
+		},
+		{
+			name: "forced manual backend",
+			entries: func(built *[]string) []Entry {
+				return []Entry{testEntry("a", true, false, built), testEntry("m", true, true, built)}
+			},
+			backend: "m",
+			want:    "m",
+		},
+		{
+			name: "unknown backend",
+			entries: func(built *[]string) []Entry {
+				return []Entry{testEntry("a", true, false, built), testEntry("b", true, false, built)}
+			},
+			backend: "wayland",
+			wantErr: "unknown backend: wayland (valid: auto, a, b)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var built []string
+			r := NewRegistry(tt.entries(&built)...)
+
+			b, name, err := r.Select(context.Background(), tt.backend, Options{}, testLogger())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if len(built) != 0 {
+					t.Errorf("built %v after an error", built)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != tt.want || b == nil {
+				t.Errorf("selected %q (%v), want %q", name, b, tt.want)
+			}
+			if len(built) != 1 || built[0] != tt.want {
+				t.Errorf("built %v, want only %s", built, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	r := Default()
+	for _, name := range []string{"xrandr", "x11", "fake", "replay"} {
+		if _, err := r.Lookup(name); err != nil {
+			t.Errorf("Lookup(%s): %v", name, err)
+		}
+	}
+	if _, err := r.Lookup("xrandr2"); err == nil || !strings.Contains(err.Error(), "unknown backend: xrandr2") {
+		t.Errorf("Lookup(xrandr2) = %v", err)
+	}
+}
+
+func TestDefaultManualBackends(t *testing.T) {
+	ctx := context.Background()
+
+	if _, name, err := Default().Select(ctx, "fake", Options{}, testLogger()); err != nil || name != "fake" {
+		t.Errorf("fake: name %q, err %v", name, err)
+	}
+	if _, _, err := Default().Select(ctx, "replay", Options{}, testLogger()); err == nil || !strings.Contains(err.Error(), "--replay") {
+		t.Errorf("replay without a bundle: err = %v", err)
+	}
+}
+
+// TestRecordReplay records a session of the xrandr backend and serves it
+// back through the replay backend.
+func TestRecordReplay(t *testing.T) {
+	ctx := context.Background()
+	query, err := os.ReadFile(filepath.Join("..", "xrandr", "testdata", "dock-mst.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xrandr := runner.Func(func(ctx context.Context, name string, args ...string) (runner.Result, error) {
+		if name != "xrandr" {
+			return runner.Result{ExitCode: 1}, &runner.ExitError{Name: name, ExitCode: 1}
+		}
+		if len(args) == 1 && args[0] == "--query" {
+			return runner.Result{Stdout: query}, nil
+		}
+		return runner.Result{}, nil
+	})
+	recorder := record.NewRecorder(xrandr)
+
+	config := models.DisplayConfig{Target: models.TargetBoth, Mode: models.ModePreset, Position: models.PositionRight}
+	recorded, _, err := Default().Select(ctx, "xrandr", Options{Runner: recorder}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	displays, err := recorded.DetectDisplays(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recorded.Configure(ctx, config, displays); err != nil {
+		t.Fatal(err)
+	}
+	// Hooks and helpers run through the same runner.
+	if _, err := recorder.Run(ctx, "xrdb", "-merge"); err == nil {
+		t.Fatal("xrdb succeeded")
+	}
+
+	path := filepath.Join(t.TempDir(), "bundle.tar")
+	if err := recorder.Bundle("xrandr", nil).Write(path); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, name, err := Default().Select(ctx, "replay", Options{Replay: path}, testLogger())
+	if err != nil || name != "replay" {
+		t.Fatalf("select replay: %q, %v", name, err)
+	}
+	again, err := replayed.DetectDisplays(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, displays) {
+		t.Errorf("replayed displays differ:\n got %+v\nwant %+v", again, displays)
+	}
+	if _, err := replayed.Configure(ctx, config, again); err != nil {
+		t.Errorf("replayed configure: %v", err)
+	}
+
+	// A different decision needs a command the bundle does not have.
+	config.Position = models.PositionLeft
+	if _, err := replayed.Configure(ctx, config, again); err == nil || !strings.Contains(err.Error(), "command not in bundle") {
+		t.Errorf("diverging configure: %v", err)
+	}
+
+	// The root command shares its replayer with hooks and helpers.
+	bundle, err := record.Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer := record.NewReplayer(bundle)
+	if _, _, err := Default().Select(ctx, "replay", Options{Replay: path, Runner: replayer}, testLogger()); err != nil {
+		t.Fatal(err)
+	}
+	var exitErr *runner.ExitError
+	if _, err := replayer.Run(ctx, "xrdb", "-merge"); !errors.As(err, &exitErr) || exitErr.ExitCode != 1 {
+		t.Errorf("replayed xrdb: %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+package brightness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// DefaultRoot is where the kernel exposes backlight devices.
+const DefaultRoot = "/sys/class/backlight"
+
+// Change is a parsed brightness argument: "50%", "+10%", "-10%" or "50".
+// The brightnessctl-style "10%+" and "10%-" are accepted too, since a
+// leading "-" on the command line is otherwise taken for a flag.
+type Change struct {
+	Percent  float64
+	Relative bool
+}
+
+func ParseChange(s string) (Change, error) {
+	value := strings.TrimSpace(s)
+	if strings.HasSuffix(value, "%+") || strings.HasSuffix(value, "%-") {
+		value = value[len(value)-1:] + value[:len(value)-1]
+	}
+	value = strings.TrimSuffix(value, "%")
+	relative := strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-")
+
+	percent, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(percent) || math.IsInf(percent, 0) {
+		return Change{}, fmt.Errorf("invalid brightness: %s (valid: 50%%, +10%%, -10%%, 10%%-)", s)
+	}
+	if !relative && (percent < 0 || percent > 100) {
+		return Change{}, fmt.Errorf("invalid brightness: %s (must be between 0%% and 100%%)", s)
+	}
+
+	return Change{Percent: percent, Relative: relative}, nil
+}
+
+// IsChange reports whether s looks like a brightness argument rather than an output name.
+func IsChange(s string) bool {
+	_, err := ParseChange(s)
+	return err == nil
+}
+
+// Apply returns the new percentage, clamped to 0-100.
+func (c Change) Apply(current float64) float64 {
+	target := c.Percent
+	if c.Relative {
+		target = current + c.Percent
+	}
+	return math.Max(0, math.Min(100, target))
+}
+
+func (c Change) String() string {
+	if c.Relative {
+		return fmt.Sprintf("%+g%%", c.Percent)
+	}
+	return fmt.Sprintf("%g%%", c.Percent)
+}
+
+// Device is one entry under /sys/class/backlight.
+type Device struct {
+	Name    string
+	Type    string
+	Current int
+	Max     int
+}
+
+// Percent returns the device brightness as a percentage of its maximum.
+func (d Device) Percent() float64 {
+	if d.Max == 0 {
+		return 0
+	}
+	return float64(d.Current) * 100 / float64(d.Max)
+}
+
+// Setter changes a backlight on behalf of an unprivileged user.
+type Setter interface {
+	SetBrightness(ctx context.Context, subsystem, name string, value uint32) error
+}
+
+// Backlight reads and writes kernel backlight devices. Root is configurable
+// so it can point at a fake sysfs tree.
+type Backlight struct {
+	Root string
+	// Fallback is used when the brightness file is not writable.
+	Fallback Setter
+}
+
+func NewBacklight(root string) *Backlight {
+	if root == "" {
+		root = DefaultRoot
+	}
+	return &Backlight{
+		Root:     root,
+		Fallback: Logind{},
+	}
+}
+
+// typeRank orders interfaces like systemd-backlight: firmware, then platform, then raw.
+var typeRank = map[string]int{"firmware": 0, "platform": 1, "raw": 2}
+
+// Devices lists backlight devices, best candidate first.
+func (b *Backlight) Devices() ([]Device, error) {
+	entries, err := os.ReadDir(b.Root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", b.Root, err)
+	}
+
+	var devices []Device
+	for _, e := range entries {
+		device, err := b.Device(e.Name())
+		if err != nil {
+			continue
+		}
+		devices = append(devices, device)
+	}
+
+	sort.SliceStable(devices, func(i, j int) bool {
+		ri, ok := typeRank[devices[i].Type]
+		if !ok {
+			ri = len(typeRank)
+		}
+		rj, ok := typeRank[devices[j].Type]
+		if !ok {
+			rj = len(typeRank)
+		}
+		return ri < rj
+	})
+
+	return devices, nil
+}
+
+// Device reads a single backlight device.
+func (b *Backlight) Device(name string) (Device, error) {
+	dir := filepath.Join(b.Root, name)
+
+	current, err := readInt(filepath.Join(dir, "brightness"))
+	if err != nil {
+		return Device{}, err
+	}
+	maximum, err := readInt(filepath.Join(dir, "max_brightness"))
+	if err != nil {
+		return Device{}, err
+	}
+	kind, _ := os.ReadFile(filepath.Join(dir, "type"))
+
+	return Device{
+		Name:    name,
+		Type:    strings.TrimSpace(string(kind)),
+		Current: current,
+		Max:     maximum,
+	}, nil
+}
+
+// Set applies a change to a device and returns its new state. When the
+// sysfs file is not writable, the Fallback setter (logind) is used.
+func (b *Backlight) Set(ctx context.Context, name string, change Change) (Device, error) {
+	device, err := b.Device(name)
+	if err != nil {
+		return Device{}, err
+	}
+
+	percent := change.Apply(device.Percent())
+	value := int(math.Round(percent * float64(device.Max) / 100))
+	// Never switch the panel fully off unless explicitly asked for 0%.
+	if value == 0 && percent > 0 {
+		value = 1
+	}
+
+	path := filepath.Join(b.Root, name, "brightness")
+	err = os.WriteFile(path, []byte(strconv.Itoa(value)), 0644)
+	if errors.Is(err, fs.ErrPermission) && b.Fallback != nil {
+		err = b.Fallback.SetBrightness(ctx, "backlight", name, uint32(value))
+	}
+	if err != nil {
+		return Device{}, fmt.Errorf("failed to set backlight %s: %w", name, err)
+	}
+
+	device.Current = value
+	return device, nil
+}
+
+func readInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// Logind sets brightness through the session's SetBrightness D-Bus method,
+// which logind allows for the user of an active local session.
+type Logind struct{}
+
+func (Logind) SetBrightness(ctx context.Context, subsystem, name string, value uint32) error {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	defer conn.Close()
+
+	session := conn.Object("org.freedesktop.login1", "/org/freedesktop/login1/session/auto")
+	call := session.CallWithContext(ctx, "org.freedesktop.login1.Session.SetBrightness", 0, subsystem, name, value)
+	if call.Err != nil {
+		return fmt.Errorf("logind SetBrightness failed: %w", call.Err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package brightness
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseChange(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want Change
+		err  bool
+	}{
+		{arg: "50%", want: Change{Percent: 50}},
+		{arg: "50", want: Change{Percent: 50}},
+		{arg: "+10%", want: Change{Percent: 10, Relative: true}},
+		{arg: "-10%", want: Change{Percent: -10, Relative: true}},
+		{arg: "10%+", want: Change{Percent: 10, Relative: true}},
+		{arg: "10%-", want: Change{Percent: -10, Relative: true}},
+		{arg: "150%", err: true},
+		{arg: "HDMI-1", err: true},
+		{arg: "inf", err: true},
+		{arg: "", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseChange(tt.arg)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseChange(%q) = %+v, want error", tt.arg, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseChange(%q) = %+v, %v, want %+v", tt.arg, got, err, tt.want)
+		}
+	}
+}
+
+type setterFunc func(ctx context.Context, subsystem, name string, value uint32) error
+
+func (f setterFunc) SetBrightness(ctx context.Context, subsystem, name string, value uint32) error {
+	return f(ctx, subsystem, name, value)
+}
+
+// fakeDevice creates a backlight device under root the way sysfs lays it out.
+func fakeDevice(t *testing.T, root, name, kind string, current, max int) {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"brightness":     strconv.Itoa(current),
+		"max_brightness": strconv.Itoa(max),
+		"type":           kind,
+	}
+	for file, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDevicesOrder(t *testing.T) {
+	root := t.TempDir()
+	fakeDevice(t, root, "acpi_video0", "firmware", 5, 10)
+	fakeDevice(t, root, "intel_backlight", "raw", 19200, 19200)
+	fakeDevice(t, root, "nvidia_0", "platform", 50, 100)
+
+	devices, err := NewBacklight(root).Devices()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, d := range devices {
+		names = append(names, d.Name)
+	}
+	if got := strings.Join(names, ","); got != "acpi_video0,nvidia_0,intel_backlight" {
+		t.Errorf("unexpected device order %s", got)
+	}
+}
+
+func TestDevicesMissingRoot(t *testing.T) {
+	devices, err := NewBacklight(filepath.Join(t.TempDir(), "missing")).Devices()
+	if err != nil || len(devices) != 0 {
+		t.Errorf("missing root should yield no devices, got %v, %v", devices, err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		change string
+		want   int
+	}{
+		{change: "50%", want: 500},
+		{change: "+10%", want: 500},
+		{change: "-10%", want: 300},
+		{change: "-90%", want: 0},
+		{change: "+90%", want: 1000},
+		{change: "0.01%", want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.change, func(t *testing.T) {
+			root := t.TempDir()
+			fakeDevice(t, root, "intel_backlight", "raw", 400, 1000)
+
+			change, err := ParseChange(tt.change)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			device, err := NewBacklight(root).Set(context.Background(), "intel_backlight", change)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if device.Current != tt.want {
+				t.Errorf("device reports %d, want %d", device.Current, tt.want)
+			}
+
+			written, err := os.ReadFile(filepath.Join(root, "intel_backlight", "brightness"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(written) != strconv.Itoa(tt.want) {
+				t.Errorf("wrote %q, want %d", written, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetFallsBackWhenNotWritable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write read-only files")
+	}
+
+	root := t.TempDir()
+	fakeDevice(t, root, "intel_backlight", "raw", 400, 1000)
+	if err := os.Chmod(filepath.Join(root, "intel_backlight", "brightness"), 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	var got uint32
+	b := NewBacklight(root)
+	b.Fallback = setterFunc(func(ctx context.Context, subsystem, name string, value uint32) error {
+		if subsystem != "backlight" || name != "intel_backlight" {
+			t.Errorf("unexpected fallback call for %s/%s", subsystem, name)
+		}
+		got = value
+		return nil
+	})
+
+	change, _ := ParseChange("75%")
+	if _, err := b.Set(context.Background(), "intel_backlight", change); err != nil {
+		t.Fatal(err)
+	}
+	if got != 750 {
+		t.Errorf("fallback got %d, want 750", got)
+	}
+}
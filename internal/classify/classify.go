@@ -0,0 +1,248 @@
+// Package classify decides whether a display is the built-in panel or an
+// external monitor. Connector names alone are not enough: DSI panels on
+// tablets and ARM laptops, and eDP panels that a hybrid-GPU driver exposes
+// as DP-*, are internal too. Rules are asked in order and the first one with
+// an answer wins.
+package classify
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/abhishek/dmon-cli/internal/edid"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Rule names, as recorded in models.Classification.
+const (
+	RuleConfig        = "config"
+	RuleSysfs         = "sysfs"
+	RuleConnectorType = "connector-type"
+	RuleName          = "name"
+)
+
+// Verdict is a rule's answer for one display.
+type Verdict struct {
+	Type   models.DisplayType
+	Reason string
+}
+
+// Rule classifies a display, or reports ok=false when it cannot tell.
+type Rule interface {
+	Name() string
+	Classify(display *models.Display) (v Verdict, ok bool)
+}
+
+// Classifier runs rules in order over detected displays.
+type Classifier struct {
+	logger *logrus.Logger
+	rules  []Rule
+}
+
+// New returns a classifier asking rules in the given order. The connector
+// name rule is always asked last, so every display gets a type.
+func New(logger *logrus.Logger, rules ...Rule) *Classifier {
+	return &Classifier{
+		logger: logger,
+		rules:  append(rules, Name{}),
+	}
+}
+
+// Default returns the standard rules: the kernel's DRM connector type, the
+// connector type the X driver reports, then the connector name.
+func Default(logger *logrus.Logger) *Classifier {
+	return New(logger, NewSysfs(DefaultRoot), ConnectorType{})
+}
+
+// WithOverrides makes the user's config win over every other rule.
+func (c *Classifier) WithOverrides(overrides Overrides) *Classifier {
+	if !overrides.Empty() {
+		c.rules = append([]Rule{overrides}, c.rules...)
+	}
+	return c
+}
+
+// propertyRule is a rule that reads EDIDs or output properties, which some
+// backends only detect on request.
+type propertyRule interface {
+	needsProperties() bool
+}
+
+// NeedsProperties reports whether displays, detected without EDIDs and
+// output properties, must be detected again with them: when a rule that
+// reads them comes before the first rule that can answer for a connected
+// display. Reading them costs a slower query (xrandr --verbose), so it is
+// only done when the names and sysfs leave a display undecided.
+func (c *Classifier) NeedsProperties(displays []models.Display) bool {
+	for i := range displays {
+		d := &displays[i]
+		if !d.Connected {
+			continue
+		}
+		for _, r := range c.rules {
+			if _, ok := r.Classify(d); ok {
+				break
+			}
+			if p, ok := r.(propertyRule); ok && p.needsProperties() {
+				c.logger.WithFields(logrus.Fields{
+					"display": d.ID,
+					"rule":    r.Name(),
+				}).Debug("Display needs output properties to classify")
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Classify sets Type and Classification on every connected display.
+func (c *Classifier) Classify(displays []models.Display) {
+	for i := range displays {
+		d := &displays[i]
+		if !d.Connected {
+			continue
+		}
+		for _, r := range c.rules {
+			v, ok := r.Classify(d)
+			if !ok {
+				continue
+			}
+			if v.Type != d.Type {
+				c.logger.WithFields(logrus.Fields{
+					"display": d.ID,
+					"was":     d.Type,
+					"type":    v.Type,
+					"rule":    r.Name(),
+				}).Debug("Display reclassified")
+			}
+			d.Type = v.Type
+			d.Classification = &models.Classification{Rule: r.Name(), Reason: v.Reason}
+			c.logger.WithFields(logrus.Fields{
+				"display": d.ID,
+				"type":    v.Type,
+				"rule":    r.Name(),
+				"reason":  v.Reason,
+			}).Debug("Classified display")
+			break
+		}
+	}
+}
+
+// Name classifies by connector name prefix (eDP, LVDS, DSI).
+type Name struct{}
+
+func (Name) Name() string { return RuleName }
+
+func (Name) Classify(display *models.Display) (Verdict, bool) {
+	if prefix := models.InternalPrefix(display.ID); prefix != "" {
+		return Verdict{Type: models.Internal, Reason: fmt.Sprintf("connector name starts with %s", prefix)}, true
+	}
+	return Verdict{Type: models.External, Reason: "not a panel connector name"}, true
+}
+
+// ConnectorType classifies by the ConnectorType output property some X
+// drivers report (seen with xrandr --props): "Panel" for built-in panels.
+// On the xrandr backend the property costs an xrandr --verbose run, which
+// the service only makes for displays the earlier rules cannot place.
+type ConnectorType struct{}
+
+// ConnectorTypeProperty is the RandR output property the rule reads.
+const ConnectorTypeProperty = "ConnectorType"
+
+var panelConnectorTypes = map[string]bool{"Panel": true, "LVDS": true, "eDP": true, "DSI": true}
+
+var externalConnectorTypes = map[string]bool{
+	"VGA": true, "DVI": true, "DVI-I": true, "DVI-D": true, "DVI-A": true,
+	"HDMI": true, "DisplayPort": true, "TV": true, "TV-Composite": true,
+	"TV-SVideo": true, "TV-Component": true,
+}
+
+func (ConnectorType) Name() string { return RuleConnectorType }
+
+func (ConnectorType) needsProperties() bool { return true }
+
+func (ConnectorType) Classify(display *models.Display) (Verdict, bool) {
+	value := display.Properties[ConnectorTypeProperty]
+	switch {
+	case panelConnectorTypes[value]:
+		return Verdict{Type: models.Internal, Reason: fmt.Sprintf("ConnectorType is %s", value)}, true
+	case externalConnectorTypes[value]:
+		return Verdict{Type: models.External, Reason: fmt.Sprintf("ConnectorType is %s", value)}, true
+	}
+	return Verdict{}, false
+}
+
+// Overrides are the user's own lists from the config. Entries are connector
+// names, which may be shell patterns ("DSI-*"), or EDID fingerprints as
+// 'dmon profile show' prints them. Internal entries are checked first.
+type Overrides struct {
+	Internal []string
+	External []string
+}
+
+// fingerprintRegex recognises EDID fingerprints such as "DEL-A0EA-7RCRW83".
+var fingerprintRegex = regexp.MustCompile(`^[A-Z]{3}-[0-9A-F]{4}(-|$)`)
+
+func (Overrides) Name() string { return RuleConfig }
+
+// Empty reports whether there is nothing to override.
+func (o Overrides) Empty() bool {
+	return len(o.Internal) == 0 && len(o.External) == 0
+}
+
+// Validate rejects malformed patterns.
+func (o Overrides) Validate() error {
+	for _, list := range [][]string{o.Internal, o.External} {
+		for _, pattern := range list {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid display pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (o Overrides) Classify(display *models.Display) (Verdict, bool) {
+	fingerprint := ""
+	if o.usesFingerprints() {
+		fingerprint = edid.Fingerprint(display.EDID)
+	}
+
+	lists := []struct {
+		kind     models.DisplayType
+		patterns []string
+	}{{models.Internal, o.Internal}, {models.External, o.External}}
+
+	for _, list := range lists {
+		for _, pattern := range list.patterns {
+			if matches(pattern, display.ID) || (fingerprint != "" && matches(pattern, fingerprint)) {
+				return Verdict{
+					Type:   list.kind,
+					Reason: fmt.Sprintf("listed as %s in config (%s)", strings.ToLower(list.kind.String()), pattern),
+				}, true
+			}
+		}
+	}
+	return Verdict{}, false
+}
+
+func (o Overrides) needsProperties() bool { return o.usesFingerprints() }
+
+func (o Overrides) usesFingerprints() bool {
+	for _, list := range [][]string{o.Internal, o.External} {
+		for _, pattern := range list {
+			if fingerprintRegex.MatchString(pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matches(pattern, name string) bool {
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
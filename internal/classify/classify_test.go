@@ -0,0 +1,270 @@
+package classify
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// testEDID builds a minimal EDID whose fingerprint is "DEL-A0EA-<serial>".
+func testEDID(serial byte) []byte {
+	e := make([]byte, 128)
+	copy(e, []byte{0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00})
+	e[8], e[9] = 0x10, 0xac
+	e[10], e[11] = 0xea, 0xa0
+	e[12] = serial
+	var sum byte
+	for _, b := range e[:127] {
+		sum += b
+	}
+	e[127] = -sum
+	return e
+}
+
+// connector is a fake /sys/class/drm entry.
+type connector struct {
+	name   string
+	id     string
+	status string
+	edid   []byte
+}
+
+func sysfsTree(t *testing.T, connectors ...connector) string {
+	t.Helper()
+	root := t.TempDir()
+	write := func(dir, name string, data []byte) {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range connectors {
+		dir := filepath.Join(root, c.name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if c.status == "" {
+			c.status = "connected"
+		}
+		write(dir, "status", []byte(c.status+"\n"))
+		if c.id != "" {
+			write(dir, "connector_id", []byte(c.id+"\n"))
+		}
+		write(dir, "edid", c.edid)
+	}
+	// Entries that are not connectors are ignored.
+	for _, dir := range []string{"card0", "renderD128"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(root, "version", []byte("drm 1.1.0 20060810\n"))
+	return root
+}
+
+func display(id string) models.Display {
+	return models.Display{ID: id, Type: models.IdentifyDisplayType(id), Connected: true}
+}
+
+func TestSysfs(t *testing.T) {
+	root := sysfsTree(t,
+		connector{name: "card0-eDP-1", id: "95", edid: testEDID(1)},
+		connector{name: "card0-HDMI-A-1", id: "103"},
+		connector{name: "card0-DP-1", id: "110", status: "disconnected"},
+		connector{name: "card1-DP-1", id: "120"},
+		connector{name: "card1-DSI-1", id: "130"},
+		connector{name: "card2-DP-2", id: "140"},
+		connector{name: "card3-DP-2", id: "150"},
+		connector{name: "card3-Virtual-1", id: "160"},
+	)
+	rule := NewSysfs(root)
+
+	withID := display("DP-4")
+	withID.Properties = map[string]string{"CONNECTOR_ID": "95"}
+	withEDID := display("DP-5")
+	withEDID.EDID = testEDID(1)
+
+	tests := []struct {
+		name    string
+		display models.Display
+		want    models.DisplayType
+		reason  string
+		ok      bool
+	}{
+		{"connector id links a DP-named panel", withID, models.Internal, "card0-eDP-1 has connector type eDP (matched by connector id 95)", true},
+		{"EDID links a DP-named panel", withEDID, models.Internal, "card0-eDP-1 has connector type eDP (matched by EDID)", true},
+		{"DSI by name", display("DSI-1"), models.Internal, "card1-DSI-1 has connector type DSI (matched by name)", true},
+		{"HDMI-A is HDMI", display("HDMI-1"), models.External, "card0-HDMI-A-1 has connector type HDMI-A (matched by name)", true},
+		{"dashless names", display("eDP1"), models.Internal, "card0-eDP-1 has connector type eDP (matched by name)", true},
+		{"disconnected namesake skipped", display("DP-1"), models.External, "card1-DP-1 has connector type DP (matched by name)", true},
+		{"ambiguous name", display("DP-2"), 0, "", false},
+		{"virtual says nothing", display("Virtual-1"), 0, "", false},
+		{"no connector", display("DP-9"), 0, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.display
+			v, ok := rule.Classify(&d)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v (%+v)", ok, tt.ok, v)
+			}
+			if !ok {
+				return
+			}
+			if v.Type != tt.want || v.Reason != tt.reason {
+				t.Errorf("got %s %q, want %s %q", v.Type, v.Reason, tt.want, tt.reason)
+			}
+		})
+	}
+
+	missing := NewSysfs(filepath.Join(root, "missing"))
+	d := display("eDP-1")
+	if _, ok := missing.Classify(&d); ok {
+		t.Error("missing sysfs root classified a display")
+	}
+}
+
+func TestConnectorType(t *testing.T) {
+	tests := []struct {
+		value string
+		want  models.DisplayType
+		ok    bool
+	}{
+		{"Panel", models.Internal, true},
+		{"DSI", models.Internal, true},
+		{"DisplayPort", models.External, true},
+		{"HDMI", models.External, true},
+		{"unknown", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		d := display("DP-3")
+		if tt.value != "" {
+			d.Properties = map[string]string{ConnectorTypeProperty: tt.value}
+		}
+		v, ok := ConnectorType{}.Classify(&d)
+		if ok != tt.ok || (ok && v.Type != tt.want) {
+			t.Errorf("ConnectorType %q: got %s, %v; want %s, %v", tt.value, v.Type, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	overrides := Overrides{
+		Internal: []string{"DSI-*", "DEL-A0EA-2"},
+		External: []string{"eDP-2", "DP-*"},
+	}
+	if err := overrides.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !overrides.usesFingerprints() {
+		t.Error("fingerprint entry not recognised")
+	}
+	if (Overrides{Internal: []string{"DP-1-1", "DSI-*"}}).usesFingerprints() {
+		t.Error("connector names taken for fingerprints")
+	}
+
+	monitor := display("DP-3")
+	monitor.EDID = testEDID(2)
+
+	tests := []struct {
+		display models.Display
+		want    models.DisplayType
+		reason  string
+		ok      bool
+	}{
+		{display("DSI-2"), models.Internal, "listed as internal in config (DSI-*)", true},
+		{monitor, models.Internal, "listed as internal in config (DEL-A0EA-2)", true},
+		{display("eDP-2"), models.External, "listed as external in config (eDP-2)", true},
+		{display("DP-1"), models.External, "listed as external in config (DP-*)", true},
+		{display("eDP-1"), 0, "", false},
+	}
+
+	for _, tt := range tests {
+		v, ok := overrides.Classify(&tt.display)
+		if ok != tt.ok || (ok && (v.Type != tt.want || v.Reason != tt.reason)) {
+			t.Errorf("%s: got %s %q, %v; want %s %q, %v", tt.display.ID, v.Type, v.Reason, ok, tt.want, tt.reason, tt.ok)
+		}
+	}
+
+	if err := (Overrides{External: []string{"DP-["}}).Validate(); err == nil {
+		t.Error("malformed pattern accepted")
+	}
+}
+
+func TestClassifier(t *testing.T) {
+	root := sysfsTree(t,
+		connector{name: "card1-eDP-1", id: "77"},
+		connector{name: "card0-DP-1"},
+	)
+
+	panel := display("DP-4")
+	panel.Properties = map[string]string{"CONNECTOR_ID": "77", ConnectorTypeProperty: "DisplayPort"}
+	legacy := display("LVDS-1")
+	legacy.Properties = map[string]string{ConnectorTypeProperty: "Panel"}
+	driverPanel := display("DP-6")
+	driverPanel.Properties = map[string]string{ConnectorTypeProperty: "Panel"}
+	disconnected := display("DSI-1")
+	disconnected.Connected = false
+	disconnected.Type = models.External
+
+	displays := []models.Display{panel, display("DP-1"), display("HDMI-2"), legacy, driverPanel, display("DP-7"), disconnected}
+
+	classifier := New(testLogger(), NewSysfs(root), ConnectorType{}).
+		WithOverrides(Overrides{External: []string{"DP-1"}})
+	classifier.Classify(displays)
+
+	want := []struct {
+		typ  models.DisplayType
+		rule string
+	}{
+		{models.Internal, RuleSysfs},         // the kernel beats the driver's ConnectorType
+		{models.External, RuleConfig},        // config beats sysfs
+		{models.External, RuleName},          // nothing else knows HDMI-2
+		{models.Internal, RuleConnectorType}, // no connector for LVDS-1
+		{models.Internal, RuleConnectorType},
+		{models.External, RuleName},
+	}
+	for i, w := range want {
+		d := displays[i]
+		if d.Type != w.typ || d.Classification == nil || d.Classification.Rule != w.rule {
+			t.Errorf("%s: got %s by %+v, want %s by %s", d.ID, d.Type, d.Classification, w.typ, w.rule)
+		}
+	}
+
+	if d := displays[len(displays)-1]; d.Classification != nil || d.Type != models.External {
+		t.Errorf("disconnected display classified: %s by %+v", d.Type, d.Classification)
+	}
+
+	// Displays as detected without properties: only a property rule asked
+	// before any rule answers needs them.
+	for _, tt := range []struct {
+		name       string
+		classifier *Classifier
+		display    string
+		want       bool
+	}{
+		{"names only", New(testLogger()).WithOverrides(Overrides{Internal: []string{"DSI-*"}}), "HDMI-1", false},
+		{"fingerprint override", New(testLogger()).WithOverrides(Overrides{Internal: []string{"DEL-A0EA"}}), "HDMI-1", true},
+		{"connector type", New(testLogger(), ConnectorType{}), "DP-4", true},
+		{"sysfs without connectors", New(testLogger(), NewSysfs(t.TempDir())), "DP-4", false},
+		{"sysfs linked by name", New(testLogger(), NewSysfs(root), ConnectorType{}), "DP-1", false},
+		{"sysfs without a link", New(testLogger(), NewSysfs(root), ConnectorType{}), "DP-4", true},
+		{"config before sysfs", New(testLogger(), NewSysfs(root)).WithOverrides(Overrides{Internal: []string{"DP-4"}}), "DP-4", false},
+	} {
+		if got := tt.classifier.NeedsProperties([]models.Display{display(tt.display)}); got != tt.want {
+			t.Errorf("%s: NeedsProperties(%s) = %v, want %v", tt.name, tt.display, got, tt.want)
+		}
+	}
+}
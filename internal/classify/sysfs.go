@@ -0,0 +1,170 @@
+package classify
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+)
+
+// DefaultRoot is where the kernel exposes DRM connectors.
+const DefaultRoot = "/sys/class/drm"
+
+// connectorIDProperty is the RandR output property the modesetting driver
+// sets to the kernel's connector id.
+const connectorIDProperty = "CONNECTOR_ID"
+
+// connectorRegex splits card0-HDMI-A-1 into card, kernel type and index.
+var connectorRegex = regexp.MustCompile(`^(card\d+)-(.+)-(\d+)$`)
+
+// panelKinds are kernel connector types that are always built in.
+var panelKinds = map[string]bool{"eDP": true, "LVDS": true, "DSI": true, "DPI": true}
+
+// unclassifiedKinds say nothing about where the display is.
+var unclassifiedKinds = map[string]bool{"Unknown": true, "Virtual": true, "Writeback": true}
+
+// Connector is one DRM connector under /sys/class/drm.
+type Connector struct {
+	// Name is the sysfs entry, e.g. card1-eDP-1.
+	Name string
+	// Kind is the kernel connector type, e.g. eDP or HDMI-A.
+	Kind  string
+	Index string
+	// ID is the kernel connector id, when the kernel exposes it.
+	ID     string
+	Status string
+	EDID   []byte
+}
+
+// Sysfs links displays to kernel DRM connectors and classifies them by the
+// kernel's connector type. A display is linked by the CONNECTOR_ID output
+// property, then by identical EDID, then by a connector name that matches
+// exactly one connected connector across all cards. Root is configurable so
+// it can point at a fake tree.
+type Sysfs struct {
+	Root string
+}
+
+func NewSysfs(root string) Sysfs {
+	if root == "" {
+		root = DefaultRoot
+	}
+	return Sysfs{Root: root}
+}
+
+func (Sysfs) Name() string { return RuleSysfs }
+
+// needsProperties is true when there are connectors to link: for a display
+// its name does not link, only the CONNECTOR_ID property and EDID can.
+func (s Sysfs) needsProperties() bool {
+	connectors, err := s.Connectors()
+	return err == nil && len(connectors) > 0
+}
+
+func (s Sysfs) Classify(display *models.Display) (Verdict, bool) {
+	connectors, err := s.Connectors()
+	if err != nil || len(connectors) == 0 {
+		return Verdict{}, false
+	}
+
+	c, how := link(display, connectors)
+	if c == nil || unclassifiedKinds[c.Kind] {
+		return Verdict{}, false
+	}
+
+	v := Verdict{Type: models.External}
+	if panelKinds[c.Kind] {
+		v.Type = models.Internal
+	}
+	v.Reason = fmt.Sprintf("%s has connector type %s (matched by %s)", c.Name, c.Kind, how)
+	return v, true
+}
+
+// Connectors lists the DRM connectors of every card.
+func (s Sysfs) Connectors() ([]Connector, error) {
+	entries, err := os.ReadDir(s.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	var connectors []Connector
+	for _, e := range entries {
+		m := connectorRegex.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		dir := filepath.Join(s.Root, e.Name())
+		c := Connector{
+			Name:   e.Name(),
+			Kind:   m[2],
+			Index:  m[3],
+			ID:     readString(filepath.Join(dir, "connector_id")),
+			Status: readString(filepath.Join(dir, "status")),
+		}
+		c.EDID, _ = os.ReadFile(filepath.Join(dir, "edid"))
+		connectors = append(connectors, c)
+	}
+	return connectors, nil
+}
+
+// link finds the connector behind a display and says how it was matched.
+func link(display *models.Display, connectors []Connector) (*Connector, string) {
+	if id := display.Properties[connectorIDProperty]; id != "" {
+		for i := range connectors {
+			if connectors[i].ID == id {
+				return &connectors[i], "connector id " + id
+			}
+		}
+	}
+
+	if len(display.EDID) > 0 {
+		for i := range connectors {
+			if bytes.Equal(connectors[i].EDID, display.EDID) {
+				return &connectors[i], "EDID"
+			}
+		}
+	}
+
+	var found *Connector
+	for i := range connectors {
+		if !sameName(display.ID, connectors[i]) || connectors[i].Status == "disconnected" {
+			continue
+		}
+		if found != nil {
+			// The same name on two cards; only an id or EDID can tell them apart.
+			return nil, ""
+		}
+		found = &connectors[i]
+	}
+	if found == nil {
+		return nil, ""
+	}
+	return found, "name"
+}
+
+// sameName compares an X output name with a kernel connector name. Drivers
+// shorten HDMI-A to HDMI, and some drop the dashes (eDP1).
+func sameName(output string, c Connector) bool {
+	kind := c.Kind
+	if kind == "HDMI-A" {
+		kind = "HDMI"
+	}
+	for _, name := range []string{c.Kind + "-" + c.Index, kind + "-" + c.Index} {
+		if output == name || output == strings.ReplaceAll(name, "-", "") {
+			return true
+		}
+	}
+	return false
+}
+
+func readString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,200 @@
+// Package colord attaches ICC profiles through colord, the colour daemon
+// GNOME and KDE use to manage display profiles.
+package colord
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	busName          = "org.freedesktop.ColorManager"
+	managerPath      = dbus.ObjectPath("/org/freedesktop/ColorManager")
+	deviceInterface  = busName + ".Device"
+	profileInterface = busName + ".Profile"
+	errNotFound      = busName + ".NotFound"
+	errExists        = busName + ".AlreadyExists"
+
+	// xrandrName is the device metadata key colord's session plugins set to
+	// the RandR output name.
+	xrandrName = "XRANDR_name"
+)
+
+// Assigner imports profiles into colord and makes them the default of the
+// output's colord device. The desktop's colour plugin then loads them into
+// the X server, so the _ICC_PROFILE atoms it owns are not overwritten.
+type Assigner struct {
+	logger *logrus.Logger
+	dir    string
+}
+
+func NewAssigner(logger *logrus.Logger) *Assigner {
+	return &Assigner{logger: logger, dir: Dir()}
+}
+
+// WithDir sets where imported profiles are stored.
+func (a *Assigner) WithDir(dir string) *Assigner {
+	a.dir = dir
+	return a
+}
+
+// Dir returns the per-user ICC directory colord watches, honouring
+// XDG_DATA_HOME.
+func Dir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "icc")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "share", "icc")
+	}
+	return "icc"
+}
+
+// SetICCProfile stores the profile in the ICC directory, registers it with
+// colord unless it already knows it, and makes it the default profile of
+// the output's device.
+func (a *Assigner) SetICCProfile(ctx context.Context, displayID string, data []byte) error {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	defer conn.Close()
+
+	device, err := findDevice(ctx, conn, displayID)
+	if err != nil {
+		return err
+	}
+
+	sum := md5.Sum(data)
+	id := "icc-" + hex.EncodeToString(sum[:])
+	path := filepath.Join(a.dir, "dmon-"+hex.EncodeToString(sum[:])+".icc")
+
+	profile, err := a.createProfile(ctx, conn, id, path, data)
+	if err != nil {
+		return err
+	}
+
+	dev := conn.Object(busName, device)
+	if err := dev.CallWithContext(ctx, deviceInterface+".AddProfile", 0, "hard", profile).Err; err != nil && !isError(err, errExists) {
+		return fmt.Errorf("colord AddProfile failed for %s: %w", displayID, err)
+	}
+	if err := dev.CallWithContext(ctx, deviceInterface+".MakeProfileDefault", 0, profile).Err; err != nil {
+		return fmt.Errorf("colord MakeProfileDefault failed for %s: %w", displayID, err)
+	}
+
+	a.logger.WithFields(logrus.Fields{
+		"display": displayID,
+		"device":  device,
+		"profile": id,
+		"file":    path,
+	}).Debug("Set colord default profile")
+
+	return nil
+}
+
+// createProfile returns the colord profile with id, importing the file at
+// path (written from data first) when colord does not have it yet. The
+// profile stays registered until colord restarts; colord picks the file up
+// from the ICC directory again after that.
+func (a *Assigner) createProfile(ctx context.Context, conn *dbus.Conn, id, path string, data []byte) (dbus.ObjectPath, error) {
+	manager := conn.Object(busName, managerPath)
+
+	var profile dbus.ObjectPath
+	err := manager.CallWithContext(ctx, busName+".FindProfileById", 0, id).Store(&profile)
+	if err == nil {
+		return profile, nil
+	}
+	if !isError(err, errNotFound) {
+		return "", fmt.Errorf("colord FindProfileById failed: %w", err)
+	}
+
+	if err := os.MkdirAll(a.dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create ICC directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write ICC profile: %w", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open ICC profile: %w", err)
+	}
+	defer f.Close()
+
+	// colord runs as its own user and may not read the home directory, so
+	// the file is passed as a descriptor.
+	properties := map[string]string{"Filename": path}
+	err = manager.CallWithContext(ctx, busName+".CreateProfileWithFd", 0, id, "normal", dbus.UnixFD(f.Fd()), properties).Store(&profile)
+	if err != nil {
+		return "", fmt.Errorf("colord CreateProfileWithFd failed: %w", err)
+	}
+
+	return profile, nil
+}
+
+// GetICCProfile reads the file of the device's default profile, or returns
+// nil when the device has none.
+func (a *Assigner) GetICCProfile(ctx context.Context, displayID string) ([]byte, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	defer conn.Close()
+
+	device, err := findDevice(ctx, conn, displayID)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []dbus.ObjectPath
+	if err := property(ctx, conn.Object(busName, device), deviceInterface, "Profiles", &profiles); err != nil {
+		return nil, fmt.Errorf("failed to read colord profiles of %s: %w", displayID, err)
+	}
+	if len(profiles) == 0 {
+		return nil, nil
+	}
+
+	var filename string
+	if err := property(ctx, conn.Object(busName, profiles[0]), profileInterface, "Filename", &filename); err != nil {
+		return nil, fmt.Errorf("failed to read colord profile of %s: %w", displayID, err)
+	}
+	if filename == "" {
+		return nil, nil
+	}
+
+	return os.ReadFile(filename)
+}
+
+// findDevice returns the colord device of a RandR output.
+func findDevice(ctx context.Context, conn *dbus.Conn, displayID string) (dbus.ObjectPath, error) {
+	var device dbus.ObjectPath
+	err := conn.Object(busName, managerPath).CallWithContext(ctx, busName+".FindDeviceByProperty", 0, xrandrName, displayID).Store(&device)
+	if isError(err, errNotFound) {
+		return "", fmt.Errorf("colord has no device for %s (is a colour session plugin running?)", displayID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("colord FindDeviceByProperty failed: %w", err)
+	}
+	return device, nil
+}
+
+func property(ctx context.Context, obj dbus.BusObject, iface, name string, value any) error {
+	var variant dbus.Variant
+	if err := obj.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, iface, name).Store(&variant); err != nil {
+		return err
+	}
+	return variant.Store(value)
+}
+
+// isError reports whether err is the D-Bus error reply called name.
+func isError(err error, name string) bool {
+	var dbusErr dbus.Error
+	return errors.As(err, &dbusErr) && dbusErr.Name == name
+}
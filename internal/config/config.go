@@ -0,0 +1,239 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config mirrors ~/.config/dmon/config.toml. Every key is optional.
+type Config struct {
+	// Backend forces a display backend by name ("auto" or empty probes).
+	Backend string `toml:"backend"`
+
+	Auto Auto `toml:"auto"`
+
+	Backlight Backlight `toml:"backlight"`
+
+	Classify Classify `toml:"classify"`
+
+	Colord Colord `toml:"colord"`
+
+	Dock Dock `toml:"dock"`
+
+	DPI DPI `toml:"dpi"`
+
+	GPU GPU `toml:"gpu"`
+
+	Hooks Hooks `toml:"hooks"`
+
+	I3 I3 `toml:"i3"`
+
+	Night Night `toml:"night"`
+
+	Power Power `toml:"power"`
+
+	Serve Serve `toml:"serve"`
+
+	// ICC maps monitor EDID fingerprints (see 'dmon profile show') to ICC
+	// profile files attached after every configuration change.
+	ICC map[string]string `toml:"icc"`
+
+	// Presets define named resolution modes for 'dmon dual' and 'dmon set',
+	// or redefine the built-in preset and low.
+	Presets map[string]Preset `toml:"presets"`
+
+	path string
+}
+
+// Preset is a resolution target per display type, and optionally per
+// monitor. Targets are WIDTHxHEIGHT or "highest"; internal and external
+// are required.
+type Preset struct {
+	Internal string `toml:"internal"`
+	External string `toml:"external"`
+	// EDID maps monitor fingerprints (see 'dmon profile show') to targets
+	// that take precedence over the type targets.
+	EDID map[string]string `toml:"edid"`
+}
+
+// Auto configures 'dmon auto' and 'dmon watch'.
+type Auto struct {
+	// DualProfile is applied instead of 'dmon dual' when an external
+	// display is connected and the lid is open.
+	DualProfile string `toml:"dual-profile"`
+	// Lid is where the lid state comes from: auto, procfs, logind or none.
+	Lid string `toml:"lid"`
+	// LidRoot is the ACPI lid directory (default /proc/acpi/button/lid).
+	LidRoot string `toml:"lid-root"`
+}
+
+// Backlight configures kernel backlight access for internal panels.
+type Backlight struct {
+	// Root is the sysfs backlight class directory (default /sys/class/backlight).
+	Root string `toml:"root"`
+}
+
+// Classify overrides which displays count as internal panels.
+type Classify struct {
+	// Internal and External list connector names, which may be shell
+	// patterns ("DSI-*"), or EDID fingerprints (see 'dmon profile show').
+	Internal []string `toml:"internal"`
+	External []string `toml:"external"`
+	// Root is the sysfs DRM class directory (default /sys/class/drm).
+	Root string `toml:"root"`
+}
+
+// Dock configures where docking stations are looked for.
+type Dock struct {
+	// USBRoot and ThunderboltRoot are the sysfs device directories
+	// (default /sys/bus/usb/devices and /sys/bus/thunderbolt/devices).
+	USBRoot         string `toml:"usb-root"`
+	ThunderboltRoot string `toml:"thunderbolt-root"`
+}
+
+// Colord configures attaching ICC profiles through colord.
+type Colord struct {
+	// Enable makes ICC profiles the default of the output's colord device
+	// instead of setting the _ICC_PROFILE atoms directly.
+	Enable bool `toml:"enable"`
+}
+
+// DPI configures updating the font DPI after layout changes.
+type DPI struct {
+	// Enable sets Xft.dpi after every layout change, from the primary
+	// output's physical size or the profile's dpi.
+	Enable bool `toml:"enable"`
+	// XSettingsd also updates Xft/DPI in the xsettingsd config and makes
+	// xsettingsd reload it.
+	XSettingsd bool `toml:"xsettingsd"`
+	// XSettingsdConfig is the xsettingsd config file (default ~/.xsettingsd).
+	XSettingsdConfig string `toml:"xsettingsd-config"`
+}
+
+// GPU configures hybrid graphics (RandR providers).
+type GPU struct {
+	// AutoLink links unlinked GPUs as output sinks before every layout
+	// change, as 'dmon gpu link' does.
+	AutoLink bool `toml:"auto-link"`
+}
+
+// Hooks configures the scripts run around layout changes.
+type Hooks struct {
+	// Dir holds the pre-apply.d, post-apply.d, on-connect.d and
+	// on-disconnect.d directories (default ~/.config/dmon/hooks).
+	Dir string `toml:"dir"`
+	// Timeout limits each script, as a duration such as "10s".
+	Timeout string `toml:"timeout"`
+	// Disable turns hooks off, as --no-hooks does.
+	Disable bool `toml:"disable"`
+}
+
+// I3 configures moving i3 (or sway) workspaces after layout changes.
+type I3 struct {
+	// Enable moves workspaces after every layout change, as --i3 does.
+	Enable bool `toml:"enable"`
+	// Socket is the IPC socket (default $I3SOCK, $SWAYSOCK or what
+	// 'i3 --get-socketpath' prints).
+	Socket string `toml:"socket"`
+	// Workspaces maps internal, external, primary or an output name to
+	// workspace lists such as "1-5" or "6-10,chat". Profiles can have
+	// their own.
+	Workspaces map[string]string `toml:"workspaces"`
+}
+
+// Power tunes the layouts 'dmon auto' and 'dmon watch' choose by power
+// source. With any mode or rate set, dmon picks refresh rates itself: the
+// highest each output offers under the cap.
+type Power struct {
+	// BatteryMode and ACMode are resolution modes (preset, low, highest
+	// or a preset name); empty means preset.
+	BatteryMode string `toml:"battery-mode"`
+	ACMode      string `toml:"ac-mode"`
+	// BatteryMaxRate and ACMaxRate cap refresh rates in Hz; zero means
+	// no cap.
+	BatteryMaxRate float64 `toml:"battery-max-rate"`
+	ACMaxRate      float64 `toml:"ac-max-rate"`
+	// Root is the sysfs power supply class directory (default
+	// /sys/class/power_supply).
+	Root string `toml:"root"`
+}
+
+// Serve configures 'dmon serve' and the clients that connect to it.
+type Serve struct {
+	// Socket is the Unix socket path (default $XDG_RUNTIME_DIR/dmon.sock).
+	Socket string `toml:"socket"`
+	// DBus also serves the API on the session bus.
+	DBus bool `toml:"dbus"`
+}
+
+// Night configures `dmon night` and its sunrise/sunset schedule.
+type Night struct {
+	// Temperature is the night colour temperature in kelvin.
+	Temperature int `toml:"temperature"`
+	// DayTemperature is used between sunrise and sunset.
+	DayTemperature int `toml:"day-temperature"`
+	// Latitude and Longitude locate the sun; unset means no schedule.
+	Latitude  *float64 `toml:"latitude"`
+	Longitude *float64 `toml:"longitude"`
+}
+
+// Dir returns the dmon configuration directory, honouring XDG_CONFIG_HOME.
+func Dir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "dmon")
+	}
+	return filepath.Join(os.Getenv("HOME"), ".config", "dmon")
+}
+
+// ProfilesDir returns where saved display profiles live.
+func ProfilesDir() string {
+	return filepath.Join(Dir(), "profiles")
+}
+
+// HooksDir returns where hook scripts live by default.
+func HooksDir() string {
+	return filepath.Join(Dir(), "hooks")
+}
+
+// DefaultPath returns the config file location used when --config is not given.
+func DefaultPath() string {
+	return filepath.Join(Dir(), "config.toml")
+}
+
+// Load reads the config file at path. A missing file yields an empty config.
+func Load(path string) (*Config, error) {
+	if path == "" {
+		path = DefaultPath()
+	}
+
+	cfg := &Config{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	meta, err := toml.Decode(string(data), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("unknown key %q in config %s", undecoded[0].String(), path)
+	}
+
+	return cfg, nil
+}
+
+// Path returns the file this config was loaded from (it may not exist).
+func (c *Config) Path() string {
+	return c.path
+}
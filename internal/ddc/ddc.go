@@ -0,0 +1,291 @@
+// Package ddc speaks DDC/CI (the VESA MCCS command set) to external monitors
+// over I2C, to read and change settings such as hardware brightness and the
+// active input.
+package ddc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// edidAddr is the I2C address monitors expose their EDID on.
+	edidAddr = 0x50
+	// ddcAddr is the I2C address of the monitor's DDC/CI interface.
+	ddcAddr = 0x37
+	// hostAddr is the virtual source address the host uses in DDC/CI packets.
+	hostAddr = 0x51
+
+	opGetVCP      = 0x01
+	opGetVCPReply = 0x02
+	opSetVCP      = 0x03
+
+	// DefaultDelay is how long a monitor needs between a request and its reply.
+	DefaultDelay = 50 * time.Millisecond
+)
+
+// Bus is an opened I2C adapter.
+type Bus interface {
+	Read(addr uint16, p []byte) error
+	Write(addr uint16, p []byte) error
+	Close() error
+}
+
+// Opener lists and opens I2C adapters, so tests can provide fake devices.
+type Opener interface {
+	List() ([]string, error)
+	Open(name string) (Bus, error)
+}
+
+// Code is a VCP feature code.
+type Code byte
+
+const (
+	Brightness  Code = 0x10
+	Contrast    Code = 0x12
+	InputSource Code = 0x60
+	PowerMode   Code = 0xD6
+)
+
+// Feature names a VCP code and, for non-continuous features, its values.
+type Feature struct {
+	Name   string
+	Code   Code
+	Values map[string]uint16
+}
+
+// Features are the VCP codes dmon exposes, in display order.
+var Features = []Feature{
+	{Name: "brightness", Code: Brightness},
+	{Name: "contrast", Code: Contrast},
+	{Name: "input", Code: InputSource, Values: map[string]uint16{
+		"vga1":  0x01,
+		"vga2":  0x02,
+		"dvi1":  0x03,
+		"dvi2":  0x04,
+		"dp1":   0x0f,
+		"dp2":   0x10,
+		"hdmi1": 0x11,
+		"hdmi2": 0x12,
+		"usbc":  0x1b,
+	}},
+	{Name: "power", Code: PowerMode, Values: map[string]uint16{
+		"on":      0x01,
+		"standby": 0x02,
+		"suspend": 0x03,
+		"off":     0x04,
+	}},
+}
+
+// ParseFeature looks a feature up by name.
+func ParseFeature(name string) (Feature, error) {
+	for _, f := range Features {
+		if f.Name == strings.ToLower(name) {
+			return f, nil
+		}
+	}
+
+	names := make([]string, 0, len(Features))
+	for _, f := range Features {
+		names = append(names, f.Name)
+	}
+	return Feature{}, fmt.Errorf("invalid feature: %s (valid: %s)", name, strings.Join(names, ", "))
+}
+
+// ParseValue accepts a named value ("hdmi1"), a number, or a hex number ("0x11").
+func (f Feature) ParseValue(s string) (uint16, error) {
+	if v, ok := f.Values[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		if len(f.Values) > 0 {
+			return 0, fmt.Errorf("invalid %s: %s (valid: %s, or a VCP value)", f.Name, s, strings.Join(f.valueNames(), ", "))
+		}
+		return 0, fmt.Errorf("invalid %s: %s", f.Name, s)
+	}
+	return uint16(v), nil
+}
+
+// FormatValue names a value when the feature knows it. Non-continuous
+// values live in the low byte; some monitors put vendor data in the high one.
+func (f Feature) FormatValue(v uint16) string {
+	if len(f.Values) > 0 {
+		v &= 0xff
+	}
+	for name, value := range f.Values {
+		if value == v {
+			return name
+		}
+	}
+	if len(f.Values) > 0 {
+		return fmt.Sprintf("0x%02x", v)
+	}
+	return strconv.Itoa(int(v))
+}
+
+func (f Feature) valueNames() []string {
+	names := make([]string, 0, len(f.Values))
+	for name := range f.Values {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return f.Values[names[i]] < f.Values[names[j]] })
+	return names
+}
+
+// Value is the result of a VCP read.
+type Value struct {
+	Current uint16
+	Max     uint16
+}
+
+// Client finds monitors on the I2C adapters of an Opener.
+type Client struct {
+	opener Opener
+	// Delay is waited after each DDC/CI request before reading or sending more.
+	Delay time.Duration
+}
+
+func NewClient(opener Opener) *Client {
+	return &Client{
+		opener: opener,
+		Delay:  DefaultDelay,
+	}
+}
+
+// Monitor is a display reachable over DDC/CI. Close it when done.
+type Monitor struct {
+	Bus  string
+	EDID []byte
+
+	bus   Bus
+	delay time.Duration
+}
+
+// Monitors opens every adapter that answers with an EDID.
+func (c *Client) Monitors() ([]*Monitor, error) {
+	names, err := c.opener.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var monitors []*Monitor
+	for _, name := range names {
+		bus, err := c.opener.Open(name)
+		if err != nil {
+			continue
+		}
+		edid, err := readEDID(bus)
+		if err != nil {
+			bus.Close()
+			continue
+		}
+		monitors = append(monitors, &Monitor{Bus: name, EDID: edid, bus: bus, delay: c.Delay})
+	}
+
+	return monitors, nil
+}
+
+// Find returns the monitor whose EDID matches edid. Only the 128-byte base
+// block is compared, since extension blocks are not always readable over I2C.
+func (c *Client) Find(edid []byte) (*Monitor, error) {
+	if len(edid) < 128 {
+		return nil, errors.New("display has no EDID to match against an I2C bus")
+	}
+
+	monitors, err := c.Monitors()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *Monitor
+	for _, m := range monitors {
+		if found == nil && bytes.Equal(m.EDID[:128], edid[:128]) {
+			found = m
+			continue
+		}
+		m.Close()
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("no I2C bus returned a matching EDID (is the i2c-dev module loaded?)")
+	}
+	return found, nil
+}
+
+func (m *Monitor) Close() error {
+	return m.bus.Close()
+}
+
+func readEDID(bus Bus) ([]byte, error) {
+	if err := bus.Write(edidAddr, []byte{0}); err != nil {
+		return nil, err
+	}
+	edid := make([]byte, 128)
+	if err := bus.Read(edidAddr, edid); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(edid[:8], []byte{0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00}) {
+		return nil, errors.New("not an EDID")
+	}
+	return edid, nil
+}
+
+// GetVCP reads a feature's current and maximum value.
+func (m *Monitor) GetVCP(code Code) (Value, error) {
+	if err := m.send([]byte{opGetVCP, byte(code)}); err != nil {
+		return Value{}, fmt.Errorf("failed to request VCP 0x%02x: %w", byte(code), err)
+	}
+
+	reply := make([]byte, 11)
+	if err := m.bus.Read(ddcAddr, reply); err != nil {
+		return Value{}, fmt.Errorf("failed to read VCP 0x%02x: %w", byte(code), err)
+	}
+
+	// Reply: source, length|0x80, opcode, result, code, type, max hi/lo, current hi/lo, checksum.
+	if checksum(0x50, reply[:10]) != reply[10] {
+		return Value{}, fmt.Errorf("VCP 0x%02x reply has a bad checksum", byte(code))
+	}
+	if reply[1]&0x7f != 8 || reply[2] != opGetVCPReply || reply[4] != byte(code) {
+		return Value{}, fmt.Errorf("unexpected reply to VCP 0x%02x: % x", byte(code), reply)
+	}
+	if reply[3] != 0 {
+		return Value{}, fmt.Errorf("monitor does not support VCP 0x%02x", byte(code))
+	}
+
+	return Value{
+		Max:     uint16(reply[6])<<8 | uint16(reply[7]),
+		Current: uint16(reply[8])<<8 | uint16(reply[9]),
+	}, nil
+}
+
+// SetVCP writes a feature value. Monitors do not acknowledge writes.
+func (m *Monitor) SetVCP(code Code, value uint16) error {
+	if err := m.send([]byte{opSetVCP, byte(code), byte(value >> 8), byte(value)}); err != nil {
+		return fmt.Errorf("failed to set VCP 0x%02x: %w", byte(code), err)
+	}
+	return nil
+}
+
+// send wraps a DDC/CI payload in the host address, length and checksum.
+func (m *Monitor) send(payload []byte) error {
+	packet := append([]byte{hostAddr, 0x80 | byte(len(payload))}, payload...)
+	packet = append(packet, checksum(ddcAddr<<1, packet))
+
+	err := m.bus.Write(ddcAddr, packet)
+	time.Sleep(m.delay)
+	return err
+}
+
+func checksum(seed byte, data []byte) byte {
+	for _, b := range data {
+		seed ^= b
+	}
+	return seed
+}
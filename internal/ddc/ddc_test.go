@@ -0,0 +1,189 @@
+package ddc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// fakeMonitor answers EDID reads and DDC/CI requests like a real monitor.
+type fakeMonitor struct {
+	edid   []byte
+	vcp    map[Code]Value
+	reply  []byte
+	closed bool
+}
+
+func (m *fakeMonitor) Write(addr uint16, p []byte) error {
+	switch addr {
+	case edidAddr:
+		return nil
+	case ddcAddr:
+	default:
+		return fmt.Errorf("no device at 0x%02x", addr)
+	}
+
+	if checksum(ddcAddr<<1, p[:len(p)-1]) != p[len(p)-1] {
+		return errors.New("bad checksum")
+	}
+	if p[0] != hostAddr || int(p[1]&0x7f) != len(p)-3 {
+		return errors.New("bad header")
+	}
+
+	switch op, code := p[2], Code(p[3]); op {
+	case opGetVCP:
+		v, ok := m.vcp[code]
+		result := byte(0)
+		if !ok {
+			result = 1
+		}
+		m.reply = []byte{0x6e, 0x88, opGetVCPReply, result, byte(code), 0, byte(v.Max >> 8), byte(v.Max), byte(v.Current >> 8), byte(v.Current)}
+		m.reply = append(m.reply, checksum(0x50, m.reply))
+	case opSetVCP:
+		v := m.vcp[code]
+		v.Current = uint16(p[4])<<8 | uint16(p[5])
+		m.vcp[code] = v
+	}
+	return nil
+}
+
+func (m *fakeMonitor) Read(addr uint16, p []byte) error {
+	switch addr {
+	case edidAddr:
+		copy(p, m.edid)
+	case ddcAddr:
+		copy(p, m.reply)
+	default:
+		return fmt.Errorf("no device at 0x%02x", addr)
+	}
+	return nil
+}
+
+func (m *fakeMonitor) Close() error {
+	m.closed = true
+	return nil
+}
+
+type fakeOpener map[string]*fakeMonitor
+
+func (o fakeOpener) List() ([]string, error) {
+	return []string{"i2c-0", "i2c-1", "i2c-2"}, nil
+}
+
+func (o fakeOpener) Open(name string) (Bus, error) {
+	m, ok := o[name]
+	if !ok {
+		return nil, errors.New("no such bus")
+	}
+	return m, nil
+}
+
+func testEDID(serial byte) []byte {
+	edid := make([]byte, 128)
+	copy(edid, []byte{0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00})
+	edid[12] = serial
+	return edid
+}
+
+func newTestClient() (*Client, fakeOpener) {
+	opener := fakeOpener{
+		// i2c-1 is a bus without a monitor, like an SMBus adapter.
+		"i2c-0": {edid: testEDID(1), vcp: map[Code]Value{Brightness: {Current: 70, Max: 100}}},
+		"i2c-1": {},
+		"i2c-2": {edid: testEDID(2), vcp: map[Code]Value{
+			Brightness:  {Current: 30, Max: 100},
+			InputSource: {Current: 0x0f, Max: 0x12},
+		}},
+	}
+	client := NewClient(opener)
+	client.Delay = 0
+	return client, opener
+}
+
+func TestFindMatchesEDID(t *testing.T) {
+	client, opener := newTestClient()
+
+	m, err := client.Find(testEDID(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Bus != "i2c-2" {
+		t.Errorf("matched %s, want i2c-2", m.Bus)
+	}
+	if !opener["i2c-0"].closed || opener["i2c-2"].closed {
+		t.Error("only unmatched buses should be closed")
+	}
+
+	// Extension blocks are ignored when matching.
+	if _, err := client.Find(append(testEDID(1), bytes.Repeat([]byte{0xaa}, 128)...)); err != nil {
+		t.Errorf("EDID with extension block should match: %v", err)
+	}
+
+	if _, err := client.Find(testEDID(3)); err == nil {
+		t.Error("unknown EDID should not match")
+	}
+	if _, err := client.Find(nil); err == nil {
+		t.Error("missing EDID should fail")
+	}
+}
+
+func TestVCPRoundTrip(t *testing.T) {
+	client, _ := newTestClient()
+
+	m, err := client.Find(testEDID(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := m.GetVCP(Brightness)
+	if err != nil || v != (Value{Current: 30, Max: 100}) {
+		t.Fatalf("GetVCP(Brightness) = %+v, %v", v, err)
+	}
+
+	input, _ := ParseFeature("input")
+	hdmi1, err := input.ParseValue("HDMI1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetVCP(InputSource, hdmi1); err != nil {
+		t.Fatal(err)
+	}
+	v, err = m.GetVCP(InputSource)
+	if err != nil || input.FormatValue(v.Current) != "hdmi1" {
+		t.Errorf("input is %+v after switching to hdmi1, err %v", v, err)
+	}
+
+	if _, err := m.GetVCP(PowerMode); err == nil {
+		t.Error("unsupported VCP code should fail")
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	power, _ := ParseFeature("power")
+	brightness, _ := ParseFeature("brightness")
+
+	tests := []struct {
+		feature Feature
+		arg     string
+		want    uint16
+		err     bool
+	}{
+		{feature: power, arg: "standby", want: 0x02},
+		{feature: power, arg: "0x05", want: 0x05},
+		{feature: power, arg: "sleep", err: true},
+		{feature: brightness, arg: "75", want: 75},
+		{feature: brightness, arg: "bright", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.feature.ParseValue(tt.arg)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("%s.ParseValue(%q) = %d, %v", tt.feature.Name, tt.arg, got, err)
+		}
+	}
+
+	if _, err := ParseFeature("volume"); err == nil {
+		t.Error("unknown feature should fail")
+	}
+}
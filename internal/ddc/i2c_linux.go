@@ -0,0 +1,108 @@
+package ddc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// i2cSlave is the I2C_SLAVE ioctl from <linux/i2c-dev.h>.
+const i2cSlave = 0x0703
+
+// DevDir is where i2c-dev exposes the I2C adapters.
+const DevDir = "/dev"
+
+// SysDir is where the kernel names the I2C adapters.
+const SysDir = "/sys/bus/i2c/devices"
+
+// DevOpener opens /dev/i2c-* character devices (requires the i2c-dev module
+// and read/write access, usually through the i2c group). SysDir is read to
+// skip adapters that cannot lead to a monitor.
+type DevOpener struct {
+	Dir    string
+	SysDir string
+}
+
+func (o DevOpener) dir() string {
+	if o.Dir == "" {
+		return DevDir
+	}
+	return o.Dir
+}
+
+func (o DevOpener) sysDir() string {
+	if o.SysDir == "" {
+		return SysDir
+	}
+	return o.SysDir
+}
+
+// displayAdapter reports whether an adapter may lead to a monitor. SMBus
+// controllers (i801, piix4) are skipped as ddcutil does: on them 0x50 is a
+// memory module's SPD EEPROM, which the EDID probe would write to. An
+// adapter without a name in sysfs is kept.
+func (o DevOpener) displayAdapter(name string) bool {
+	data, err := os.ReadFile(filepath.Join(o.sysDir(), name, "name"))
+	return err != nil || !strings.HasPrefix(strings.TrimSpace(string(data)), "SMBus")
+}
+
+func (o DevOpener) List() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(o.dir(), "i2c-*"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if name := filepath.Base(p); o.displayAdapter(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, _ := strconv.Atoi(strings.TrimPrefix(names[i], "i2c-"))
+		b, _ := strconv.Atoi(strings.TrimPrefix(names[j], "i2c-"))
+		return a < b
+	})
+	return names, nil
+}
+
+func (o DevOpener) Open(name string) (Bus, error) {
+	f, err := os.OpenFile(filepath.Join(o.dir(), name), os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	return &devBus{f: f}, nil
+}
+
+type devBus struct {
+	f *os.File
+}
+
+func (b *devBus) target(addr uint16) error {
+	return unix.IoctlSetInt(int(b.f.Fd()), i2cSlave, int(addr))
+}
+
+func (b *devBus) Read(addr uint16, p []byte) error {
+	if err := b.target(addr); err != nil {
+		return err
+	}
+	_, err := b.f.Read(p)
+	return err
+}
+
+func (b *devBus) Write(addr uint16, p []byte) error {
+	if err := b.target(addr); err != nil {
+		return err
+	}
+	_, err := b.f.Write(p)
+	return err
+}
+
+func (b *devBus) Close() error {
+	return b.f.Close()
+}
@@ -0,0 +1,42 @@
+package ddc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDevOpenerList(t *testing.T) {
+	dev, sys := t.TempDir(), t.TempDir()
+	adapters := map[string]string{
+		"i2c-0":  "SMBus I801 adapter at efa0",
+		"i2c-1":  "i915 gmbus dpb",
+		"i2c-2":  "AMDGPU DM i2c hw bus 0",
+		"i2c-3":  "",
+		"i2c-10": "SMBus PIIX4 adapter port 0 at 0b00",
+		"i2c-12": "NVIDIA i2c adapter 4 at 1:00.0",
+	}
+	for name, adapter := range adapters {
+		if err := os.WriteFile(filepath.Join(dev, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if adapter == "" {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Join(sys, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(sys, name, "name"), []byte(adapter+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names, err := DevOpener{Dir: dev, SysDir: sys}.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"i2c-1", "i2c-2", "i2c-3", "i2c-12"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("List = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package ddc
+
+import "errors"
+
+// DevDir is where i2c-dev exposes the I2C adapters.
+const DevDir = "/dev"
+
+// SysDir is where the kernel names the I2C adapters.
+const SysDir = "/sys/bus/i2c/devices"
+
+// DevOpener is only implemented on Linux.
+type DevOpener struct {
+	Dir    string
+	SysDir string
+}
+
+func (o DevOpener) List() ([]string, error) {
+	return nil, errors.New("DDC/CI is only supported on Linux")
+}
+
+func (o DevOpener) Open(name string) (Bus, error) {
+	return nil, errors.New("DDC/CI is only supported on Linux")
+}
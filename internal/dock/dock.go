@@ -0,0 +1,225 @@
+// Package dock identifies docking stations by the USB and Thunderbolt
+// devices they bring along. Hot-desks often share identical monitors, so
+// the monitors' EDIDs cannot tell one desk from another, but the dock's
+// vendor and product IDs, and its serial number, can.
+package dock
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Buses.
+const (
+	USB         = "usb"
+	Thunderbolt = "thunderbolt"
+)
+
+// Default sysfs roots.
+const (
+	DefaultUSBRoot         = "/sys/bus/usb/devices"
+	DefaultThunderboltRoot = "/sys/bus/thunderbolt/devices"
+)
+
+// linuxFoundation is the USB vendor of the kernel's root hubs.
+const linuxFoundation = "1d6b"
+
+// Device is a USB or Thunderbolt device.
+type Device struct {
+	Bus string
+	// Vendor and Product are four lower-case hex digits.
+	Vendor  string
+	Product string
+	// Serial is the USB serial number or the Thunderbolt unique ID, if any.
+	Serial string
+	// Name is the vendor and product names the device reports.
+	Name string
+	// Path is the sysfs device name, such as 3-1.2 or 0-1.
+	Path string
+}
+
+// ID returns VENDOR:PRODUCT.
+func (d Device) ID() string {
+	return d.Vendor + ":" + d.Product
+}
+
+// Spec returns the most specific spec matching the device.
+func (d Device) Spec() Spec {
+	return Spec{Vendor: d.Vendor, Product: d.Product, Serial: d.Serial}
+}
+
+// Scanner lists the devices under the sysfs bus directories.
+type Scanner struct {
+	USBRoot         string
+	ThunderboltRoot string
+}
+
+func NewScanner(usbRoot, thunderboltRoot string) Scanner {
+	if usbRoot == "" {
+		usbRoot = DefaultUSBRoot
+	}
+	if thunderboltRoot == "" {
+		thunderboltRoot = DefaultThunderboltRoot
+	}
+	return Scanner{USBRoot: usbRoot, ThunderboltRoot: thunderboltRoot}
+}
+
+// Devices returns the connected USB devices, except root hubs and
+// interfaces, and the Thunderbolt devices other than the host's own
+// routers, sorted by bus and path. A missing bus directory yields no
+// devices.
+func (s Scanner) Devices() ([]Device, error) {
+	usb, err := scan(s.USBRoot, usbDevice)
+	if err != nil {
+		return nil, err
+	}
+	thunderbolt, err := scan(s.ThunderboltRoot, thunderboltDevice)
+	if err != nil {
+		return nil, err
+	}
+	return append(thunderbolt, usb...), nil
+}
+
+func scan(root string, read func(dir, name string) (Device, bool)) ([]Device, error) {
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", root, err)
+	}
+
+	var devices []Device
+	for _, e := range entries {
+		// Interfaces (3-1:1.0) and Thunderbolt retimers (0-0:1.1) are
+		// parts of a device, not devices.
+		if strings.Contains(e.Name(), ":") {
+			continue
+		}
+		if d, ok := read(filepath.Join(root, e.Name()), e.Name()); ok {
+			devices = append(devices, d)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Path < devices[j].Path })
+	return devices, nil
+}
+
+func usbDevice(dir, name string) (Device, bool) {
+	d := Device{
+		Bus:     USB,
+		Vendor:  strings.ToLower(read(dir, "idVendor")),
+		Product: strings.ToLower(read(dir, "idProduct")),
+		Serial:  read(dir, "serial"),
+		Name:    join(read(dir, "manufacturer"), read(dir, "product")),
+		Path:    name,
+	}
+	if d.Vendor == "" || d.Product == "" || d.Vendor == linuxFoundation {
+		return Device{}, false
+	}
+	return d, true
+}
+
+func thunderboltDevice(dir, name string) (Device, bool) {
+	// N-0 is the host router of domain N.
+	if strings.HasSuffix(name, "-0") {
+		return Device{}, false
+	}
+	vendor, okVendor := hex16(read(dir, "vendor"))
+	product, okProduct := hex16(read(dir, "device"))
+	if !okVendor || !okProduct {
+		return Device{}, false
+	}
+	return Device{
+		Bus:     Thunderbolt,
+		Vendor:  vendor,
+		Product: product,
+		Serial:  read(dir, "unique_id"),
+		Name:    join(read(dir, "vendor_name"), read(dir, "device_name")),
+		Path:    name,
+	}, true
+}
+
+// hex16 formats a sysfs ID such as "0x108" as four hex digits.
+func hex16(s string) (string, bool) {
+	n, err := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 16)
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("%04x", n), true
+}
+
+func read(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func join(vendor, product string) string {
+	return strings.TrimSpace(vendor + " " + product)
+}
+
+// Spec identifies a dock: VENDOR:PRODUCT, or VENDOR:PRODUCT:SERIAL to
+// tell identical docks apart.
+type Spec struct {
+	Vendor  string
+	Product string
+	Serial  string
+}
+
+// ParseSpec parses VENDOR:PRODUCT[:SERIAL] with hex IDs, as 'dmon dock'
+// prints them.
+func ParseSpec(s string) (Spec, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) < 2 {
+		return Spec{}, fmt.Errorf("invalid dock %q (use VENDOR:PRODUCT or VENDOR:PRODUCT:SERIAL, see 'dmon dock')", s)
+	}
+	var spec Spec
+	var ok bool
+	if spec.Vendor, ok = hex16(parts[0]); !ok {
+		return Spec{}, fmt.Errorf("invalid dock %q: vendor %q is not a hex ID", s, parts[0])
+	}
+	if spec.Product, ok = hex16(parts[1]); !ok {
+		return Spec{}, fmt.Errorf("invalid dock %q: product %q is not a hex ID", s, parts[1])
+	}
+	if len(parts) == 3 {
+		if parts[2] == "" {
+			return Spec{}, fmt.Errorf("invalid dock %q: empty serial", s)
+		}
+		spec.Serial = parts[2]
+	}
+	return spec, nil
+}
+
+func (s Spec) String() string {
+	if s.Serial == "" {
+		return s.Vendor + ":" + s.Product
+	}
+	return s.Vendor + ":" + s.Product + ":" + s.Serial
+}
+
+// Matches reports whether d is the dock s describes.
+func (s Spec) Matches(d Device) bool {
+	return d.Vendor == s.Vendor && d.Product == s.Product && (s.Serial == "" || d.Serial == s.Serial)
+}
+
+// Filter returns the devices at least one of specs matches.
+func Filter(devices []Device, specs []Spec) []Device {
+	var matched []Device
+	for _, d := range devices {
+		for _, s := range specs {
+			if s.Matches(d) {
+				matched = append(matched, d)
+				break
+			}
+		}
+	}
+	return matched
+}
@@ -0,0 +1,91 @@
+package dock
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeDevice(t *testing.T, root, name string, files map[string]string) {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for file, value := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(value+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDevices(t *testing.T) {
+	usb := filepath.Join(t.TempDir(), "usb")
+	writeDevice(t, usb, "usb3", map[string]string{"idVendor": "1d6b", "idProduct": "0003", "product": "xHCI Host Controller"})
+	writeDevice(t, usb, "3-1", map[string]string{"idVendor": "17EF", "idProduct": "30b4", "serial": "1S40AY0090EU", "manufacturer": "Lenovo", "product": "ThinkPad USB-C Dock Gen2"})
+	writeDevice(t, usb, "3-1:1.0", map[string]string{"bInterfaceClass": "09"})
+	writeDevice(t, usb, "1-2", map[string]string{"idVendor": "046d", "idProduct": "c52b"})
+
+	thunderbolt := filepath.Join(t.TempDir(), "thunderbolt")
+	writeDevice(t, thunderbolt, "domain0", nil)
+	writeDevice(t, thunderbolt, "0-0", map[string]string{"vendor": "0x8086", "device": "0x15ef"})
+	writeDevice(t, thunderbolt, "0-1", map[string]string{"vendor": "0xd4", "device": "0xb070", "vendor_name": "Dell", "device_name": "WD19TB Thunderbolt Dock", "unique_id": "c4010000-0070-740e-034f-9e0830b5ce24"})
+	writeDevice(t, thunderbolt, "0-1.1", map[string]string{"key": "network"})
+
+	devices, err := NewScanner(usb, thunderbolt).Devices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Device{
+		{Bus: Thunderbolt, Vendor: "00d4", Product: "b070", Serial: "c4010000-0070-740e-034f-9e0830b5ce24", Name: "Dell WD19TB Thunderbolt Dock", Path: "0-1"},
+		{Bus: USB, Vendor: "046d", Product: "c52b", Path: "1-2"},
+		{Bus: USB, Vendor: "17ef", Product: "30b4", Serial: "1S40AY0090EU", Name: "Lenovo ThinkPad USB-C Dock Gen2", Path: "3-1"},
+	}
+	if !reflect.DeepEqual(devices, want) {
+		t.Errorf("Devices =\n%+v\nwant\n%+v", devices, want)
+	}
+
+	if devices, err := NewScanner(filepath.Join(usb, "missing"), filepath.Join(usb, "missing")).Devices(); err != nil || len(devices) != 0 {
+		t.Errorf("missing roots: %v, %v", devices, err)
+	}
+}
+
+func TestSpec(t *testing.T) {
+	lenovo := Device{Bus: USB, Vendor: "17ef", Product: "30b4", Serial: "1S40AY0090EU"}
+	other := Device{Bus: USB, Vendor: "17ef", Product: "30b4", Serial: "1S40AY0123EU"}
+
+	tests := []struct {
+		spec    string
+		want    string
+		matches []bool
+	}{
+		{"17ef:30b4", "17ef:30b4", []bool{true, true}},
+		{"17EF:30B4:1S40AY0090EU", "17ef:30b4:1S40AY0090EU", []bool{true, false}},
+		{"0x17ef:30b4", "17ef:30b4", []bool{true, true}},
+	}
+	for _, tt := range tests {
+		spec, err := ParseSpec(tt.spec)
+		if err != nil {
+			t.Errorf("ParseSpec(%q): %v", tt.spec, err)
+			continue
+		}
+		if spec.String() != tt.want {
+			t.Errorf("ParseSpec(%q) = %s, want %s", tt.spec, spec, tt.want)
+		}
+		if got := []bool{spec.Matches(lenovo), spec.Matches(other)}; !reflect.DeepEqual(got, tt.matches) {
+			t.Errorf("%s matches %v, want %v", spec, got, tt.matches)
+		}
+	}
+
+	for _, bad := range []string{"17ef", "17ef:zz", "12345:30b4", "17ef:30b4:"} {
+		if _, err := ParseSpec(bad); err == nil {
+			t.Errorf("ParseSpec(%q) succeeded", bad)
+		}
+	}
+
+	specs := []Spec{lenovo.Spec()}
+	if got := Filter([]Device{other, lenovo}, specs); !reflect.DeepEqual(got, []Device{lenovo}) {
+		t.Errorf("Filter = %+v", got)
+	}
+}
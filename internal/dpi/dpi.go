@@ -0,0 +1,200 @@
+// Package dpi works out the font DPI a layout wants from the physical size
+// monitors report, and tells X clients about it: Xft.dpi in the X resource
+// database, and optionally xsettingsd's Xft/DPI for GTK applications.
+package dpi
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/runner"
+	"github.com/sirupsen/logrus"
+)
+
+// Default is the DPI X assumes, used when no monitor reports a usable size.
+const Default = 96
+
+// Step is what computed DPIs are rounded to: 96 times a quarter scale
+// step, the sizes toolkits and fonts are tuned for.
+const Step = 24
+
+// Physical sizes that give a DPI outside this range are taken to be made
+// up (projectors and TVs report 0, their aspect ratio, or centimetres).
+const (
+	minPlausible = 50
+	maxPlausible = 600
+)
+
+// Of returns the DPI of an active display from its mode and physical
+// size. ok is false when the size is missing or implausible.
+func Of(d models.Display) (dpi float64, ok bool) {
+	if d.CurrentMode == nil || d.WidthMM <= 0 || d.HeightMM <= 0 {
+		return 0, false
+	}
+	// Modes and physical sizes are both unrotated, so rotation cancels out.
+	horizontal := float64(d.CurrentMode.Width) * 25.4 / float64(d.WidthMM)
+	vertical := float64(d.CurrentMode.Height) * 25.4 / float64(d.HeightMM)
+	dpi = (horizontal + vertical) / 2
+	if dpi < minPlausible || dpi > maxPlausible {
+		return dpi, false
+	}
+	return dpi, true
+}
+
+// Round rounds a DPI to the nearest Step, and to no less than Default.
+func Round(dpi float64) int {
+	rounded := int(math.Round(dpi/Step)) * Step
+	if rounded < Default {
+		return Default
+	}
+	return rounded
+}
+
+// Effective returns the Xft.dpi for a layout and the output it was taken
+// from. Xft.dpi is one value for the whole screen, so it follows the
+// primary output, or the leftmost active one without a primary; it is
+// Default when that output's size is unknown.
+func Effective(layout *models.Layout) (dpi int, output string) {
+	var active []models.Display
+	for _, d := range layout.Displays {
+		if d.Connected && d.CurrentMode != nil {
+			active = append(active, d)
+		}
+	}
+	if len(active) == 0 {
+		return Default, ""
+	}
+	sort.SliceStable(active, func(i, j int) bool {
+		if active[i].X != active[j].X {
+			return active[i].X < active[j].X
+		}
+		return active[i].Y < active[j].Y
+	})
+
+	chosen := active[0]
+	for _, d := range active {
+		if d.ID == layout.Primary {
+			chosen = d
+		}
+	}
+	if measured, ok := Of(chosen); ok {
+		return Round(measured), chosen.ID
+	}
+	return Default, chosen.ID
+}
+
+// Setter publishes a DPI to X clients. Applications read it when they
+// start; running GTK applications follow xsettingsd.
+type Setter struct {
+	runner     runner.Runner
+	xsettingsd string
+	logger     *logrus.Logger
+}
+
+// NewSetter sets Xft.dpi with xrdb.
+func NewSetter(logger *logrus.Logger) *Setter {
+	return &Setter{
+		runner: runner.Exec{},
+		logger: logger,
+	}
+}
+
+// WithRunner sets how xrdb and pkill are run.
+func (s *Setter) WithRunner(r runner.Runner) *Setter {
+	s.runner = r
+	return s
+}
+
+// WithXSettingsd also writes Xft/DPI to the xsettingsd config at path and
+// makes a running xsettingsd reload it.
+func (s *Setter) WithXSettingsd(path string) *Setter {
+	s.xsettingsd = path
+	return s
+}
+
+// Set merges Xft.dpi into the X resource database and updates xsettingsd
+// when configured.
+func (s *Setter) Set(ctx context.Context, dpi int) error {
+	if dpi <= 0 {
+		return fmt.Errorf("invalid DPI %d", dpi)
+	}
+
+	// xrdb reads resources from a file; the runner has no stdin.
+	file, err := os.CreateTemp("", "dmon-xresources-*")
+	if err != nil {
+		return fmt.Errorf("failed to write X resources: %w", err)
+	}
+	defer os.Remove(file.Name())
+	_, err = fmt.Fprintf(file, "Xft.dpi: %d\n", dpi)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write X resources: %w", err)
+	}
+
+	s.logger.WithField("dpi", dpi).Debug("Setting Xft.dpi")
+	if _, err := s.runner.Run(ctx, "xrdb", "-merge", file.Name()); err != nil {
+		return fmt.Errorf("failed to set Xft.dpi: %w", err)
+	}
+
+	if s.xsettingsd != "" {
+		return s.setXSettingsd(ctx, dpi)
+	}
+	return nil
+}
+
+// setXSettingsd replaces (or adds) the Xft/DPI line of the xsettingsd
+// config, in 1024ths of a DPI, and sends xsettingsd SIGHUP.
+func (s *Setter) setXSettingsd(ctx context.Context, dpi int) error {
+	data, err := os.ReadFile(s.xsettingsd)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read xsettingsd config: %w", err)
+	}
+
+	setting := "Xft/DPI " + strconv.Itoa(dpi*1024)
+	var lines []string
+	replaced := false
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == "Xft/DPI" {
+			if replaced {
+				continue
+			}
+			line, replaced = setting, true
+		}
+		lines = append(lines, line)
+	}
+	if !replaced {
+		lines = append(lines, setting)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.xsettingsd), 0o755); err != nil {
+		return fmt.Errorf("failed to write xsettingsd config: %w", err)
+	}
+	if err := os.WriteFile(s.xsettingsd, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write xsettingsd config: %w", err)
+	}
+
+	_, err = s.runner.Run(ctx, "pkill", "-HUP", "-x", "xsettingsd")
+	var exitErr *runner.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode == 1 {
+		s.logger.Debug("xsettingsd is not running; it reads the new DPI when it starts")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reload xsettingsd: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package dpi
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/runner"
+	"github.com/sirupsen/logrus"
+)
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func display(id string, width, height, widthMM, heightMM, x int) models.Display {
+	return models.Display{
+		ID:          id,
+		Connected:   true,
+		CurrentMode: &models.Mode{Width: width, Height: height},
+		WidthMM:     widthMM,
+		HeightMM:    heightMM,
+		X:           x,
+	}
+}
+
+func TestEffective(t *testing.T) {
+	laptop := display("eDP-1", 2880, 1800, 302, 189, 0)
+	uhd := display("DP-1", 3840, 2160, 597, 336, 2880)
+	fhd := display("HDMI-1", 1920, 1080, 527, 296, 2880)
+	projector := display("HDMI-2", 1280, 720, 16, 9, 2880)
+
+	tests := []struct {
+		name       string
+		layout     *models.Layout
+		dpi        int
+		fromOutput string
+	}{
+		{"laptop alone", &models.Layout{Primary: "eDP-1", Displays: []models.Display{laptop}}, 240, "eDP-1"},
+		{"primary 4K", &models.Layout{Primary: "DP-1", Displays: []models.Display{laptop, uhd}}, 168, "DP-1"},
+		{"primary 1080p", &models.Layout{Primary: "HDMI-1", Displays: []models.Display{laptop, fhd}}, 96, "HDMI-1"},
+		{"no primary", &models.Layout{Displays: []models.Display{uhd, laptop}}, 240, "eDP-1"},
+		{"made-up size", &models.Layout{Primary: "HDMI-2", Displays: []models.Display{laptop, projector}}, Default, "HDMI-2"},
+		{"nothing on", &models.Layout{}, Default, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dpi, from := Effective(tt.layout)
+			if dpi != tt.dpi || from != tt.fromOutput {
+				t.Errorf("Effective = %d from %q, want %d from %q", dpi, from, tt.dpi, tt.fromOutput)
+			}
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	xsettingsd := filepath.Join(t.TempDir(), "xsettingsd.conf")
+	if err := os.WriteFile(xsettingsd, []byte("Net/ThemeName \"Adwaita\"\nXft/DPI 98304\nXft/Antialias 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls [][]string
+	var resources string
+	r := runner.Func(func(ctx context.Context, name string, args ...string) (runner.Result, error) {
+		calls = append(calls, append([]string{name}, args...))
+		if name == "xrdb" {
+			data, err := os.ReadFile(args[len(args)-1])
+			if err != nil {
+				t.Error(err)
+			}
+			resources = string(data)
+		}
+		if name == "pkill" {
+			return runner.Result{ExitCode: 1}, &runner.ExitError{Name: name, ExitCode: 1}
+		}
+		return runner.Result{}, nil
+	})
+
+	setter := NewSetter(testLogger()).WithRunner(r).WithXSettingsd(xsettingsd)
+	if err := setter.Set(context.Background(), 144); err != nil {
+		t.Fatal(err)
+	}
+
+	if resources != "Xft.dpi: 144\n" {
+		t.Errorf("xrdb got %q", resources)
+	}
+	if len(calls) != 2 || calls[0][0] != "xrdb" || calls[0][1] != "-merge" ||
+		!reflect.DeepEqual(calls[1], []string{"pkill", "-HUP", "-x", "xsettingsd"}) {
+		t.Errorf("calls = %v", calls)
+	}
+	data, err := os.ReadFile(xsettingsd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Net/ThemeName \"Adwaita\"\nXft/DPI 147456\nXft/Antialias 1\n"; string(data) != want {
+		t.Errorf("xsettingsd config = %q, want %q", data, want)
+	}
+
+	// A missing config is created.
+	missing := filepath.Join(t.TempDir(), "new", "xsettingsd.conf")
+	if err := NewSetter(testLogger()).WithRunner(r).WithXSettingsd(missing).Set(context.Background(), 192); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(missing); string(data) != "Xft/DPI 196608\n" {
+		t.Errorf("new xsettingsd config = %q", data)
+	}
+}
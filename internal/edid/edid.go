@@ -0,0 +1,110 @@
+// Package edid decodes the identifying fields of a monitor's EDID base block.
+package edid
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var header = []byte{0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00}
+
+// Info identifies a monitor.
+type Info struct {
+	// Manufacturer is the three-letter PNP ID, e.g. "DEL".
+	Manufacturer string
+	Product      uint16
+	// Serial is the numeric serial; SerialString the serial descriptor, if any.
+	Serial       uint32
+	SerialString string
+	Name         string
+	Year         int
+	WidthCM      int
+	HeightCM     int
+}
+
+// Parse decodes an EDID. Only the 128-byte base block is read.
+func Parse(data []byte) (*Info, error) {
+	if len(data) < 128 {
+		return nil, fmt.Errorf("EDID too short: %d bytes", len(data))
+	}
+	if !bytes.Equal(data[:8], header) {
+		return nil, errors.New("EDID header not found")
+	}
+
+	var sum byte
+	for _, b := range data[:128] {
+		sum += b
+	}
+	if sum != 0 {
+		return nil, errors.New("EDID checksum mismatch")
+	}
+
+	id := uint16(data[8])<<8 | uint16(data[9])
+	info := &Info{
+		Manufacturer: string([]byte{
+			byte(id>>10&0x1f) + '@',
+			byte(id>>5&0x1f) + '@',
+			byte(id&0x1f) + '@',
+		}),
+		Product:  uint16(data[10]) | uint16(data[11])<<8,
+		Serial:   uint32(data[12]) | uint32(data[13])<<8 | uint32(data[14])<<16 | uint32(data[15])<<24,
+		Year:     int(data[17]) + 1990,
+		WidthCM:  int(data[21]),
+		HeightCM: int(data[22]),
+	}
+
+	// Display descriptors 0xFC (name) and 0xFF (serial) in the four 18-byte slots.
+	for offset := 54; offset < 126; offset += 18 {
+		d := data[offset : offset+18]
+		if d[0] != 0 || d[1] != 0 {
+			continue
+		}
+		switch d[3] {
+		case 0xfc:
+			info.Name = descriptorText(d[5:])
+		case 0xff:
+			info.SerialString = descriptorText(d[5:])
+		}
+	}
+
+	return info, nil
+}
+
+func descriptorText(b []byte) string {
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// Fingerprint identifies a physical monitor, stable across connectors and
+// docks: manufacturer, product code and serial, e.g. "DEL-A0EA-7RCRW83".
+func (i *Info) Fingerprint() string {
+	fp := fmt.Sprintf("%s-%04X", i.Manufacturer, i.Product)
+	switch {
+	case i.SerialString != "":
+		fp += "-" + i.SerialString
+	case i.Serial != 0:
+		fp += fmt.Sprintf("-%d", i.Serial)
+	}
+	return fp
+}
+
+// Fingerprint parses data and returns its fingerprint, or "" when it is not a valid EDID.
+func Fingerprint(data []byte) string {
+	info, err := Parse(data)
+	if err != nil {
+		return ""
+	}
+	return info.Fingerprint()
+}
+
+func (i *Info) String() string {
+	name := i.Name
+	if name == "" {
+		name = fmt.Sprintf("%s %04X", i.Manufacturer, i.Product)
+	}
+	return name
+}
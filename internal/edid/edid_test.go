@@ -0,0 +1,55 @@
+package edid
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+const (
+	dellU2720Q = "00ffffffffffff0010aceaa055334c4c0c200104a53c227802ee95a3544c99260f5054000000010101010101010101010101010101014dd000a0f0703e803020350055502100001a000000fc0044454c4c205532373230510a20000000ff00375243525738330a202020202000000010000000000000000000000000000000f9"
+	auoPanel   = "00ffffffffffff0006af3d5700000000011f0104a51f117802ee95a3544c99260f5054000000010101010101010101010101010101012e3680a070381f403020350058c21000001a000000fc004231343048414e30352e370a20000000ff000a2020202020202020202020200000001000000000000000000000000000000076"
+)
+
+func decode(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParse(t *testing.T) {
+	info, err := Parse(decode(t, dellU2720Q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Manufacturer != "DEL" || info.Product != 0xa0ea || info.Name != "DELL U2720Q" || info.SerialString != "7RCRW83" {
+		t.Errorf("unexpected info %+v", info)
+	}
+	if info.Year != 2022 || info.WidthCM != 60 || info.HeightCM != 34 {
+		t.Errorf("unexpected date or size %+v", info)
+	}
+	if got := info.Fingerprint(); got != "DEL-A0EA-7RCRW83" {
+		t.Errorf("fingerprint %s", got)
+	}
+
+	// Panels often have neither a serial descriptor nor a numeric serial.
+	if got := Fingerprint(decode(t, auoPanel)); got != "AUO-573D" {
+		t.Errorf("fingerprint %s", got)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	data := decode(t, dellU2720Q)
+	data[20] ^= 0xff
+	if _, err := Parse(data); err == nil {
+		t.Error("corrupted EDID should fail the checksum")
+	}
+	if _, err := Parse(data[:64]); err == nil {
+		t.Error("short EDID should fail")
+	}
+	if Fingerprint(nil) != "" {
+		t.Error("missing EDID should have no fingerprint")
+	}
+}
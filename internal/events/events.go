@@ -0,0 +1,262 @@
+// Package events describes changes of the display layout as events that
+// can be streamed to subscribers (status bars, window manager scripts) as
+// newline-delimited JSON.
+package events
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Type names an event.
+type Type string
+
+const (
+	Connected    Type = "output-connected"
+	Disconnected Type = "output-disconnected"
+	// ModeChanged covers an output's mode, refresh rate, position and
+	// rotation, including turning it on or off.
+	ModeChanged     Type = "mode-changed"
+	PrimaryChanged  Type = "primary-changed"
+	ProfileApplied  Type = "profile-applied"
+	ConfigureFailed Type = "configure-failed"
+)
+
+// Types lists every event type.
+var Types = []Type{Connected, Disconnected, ModeChanged, PrimaryChanged, ProfileApplied, ConfigureFailed}
+
+// Event is one layout change. Before and After are the layout on either
+// side of it, restricted to the outputs the event is about.
+type Event struct {
+	Type    Type           `json:"type"`
+	Time    time.Time      `json:"time"`
+	Output  string         `json:"output,omitempty"`
+	Profile string         `json:"profile,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	Before  *models.Layout `json:"before"`
+	After   *models.Layout `json:"after"`
+}
+
+// Diff returns the events that turn before into after: one per output that
+// was connected, disconnected or changed mode, then one if the primary
+// output changed.
+func Diff(before, after *models.Layout, now time.Time) []Event {
+	var events []Event
+	for _, id := range outputs(before, after) {
+		old, cur := find(before, id), find(after, id)
+		wasConnected := old != nil && old.Connected
+		isConnected := cur != nil && cur.Connected
+
+		var t Type
+		switch {
+		case !wasConnected && isConnected:
+			t = Connected
+		case wasConnected && !isConnected:
+			t = Disconnected
+		case isConnected && !sameGeometry(*old, *cur):
+			t = ModeChanged
+		default:
+			continue
+		}
+		events = append(events, Event{
+			Type:   t,
+			Time:   now,
+			Output: id,
+			Before: restrict(before, id),
+			After:  restrict(after, id),
+		})
+	}
+
+	if primary(before) != primary(after) {
+		events = append(events, Event{
+			Type:   PrimaryChanged,
+			Time:   now,
+			Output: primary(after),
+			Before: restrict(before, primary(before), primary(after)),
+			After:  restrict(after, primary(before), primary(after)),
+		})
+	}
+	return events
+}
+
+// Changed returns the outputs that differ between before and after, in
+// any way an event reports.
+func Changed(before, after *models.Layout) []string {
+	var ids []string
+	for _, id := range outputs(before, after) {
+		old, cur := find(before, id), find(after, id)
+		switch {
+		case old == nil || cur == nil:
+			ids = append(ids, id)
+		case old.Connected != cur.Connected || !sameGeometry(*old, *cur) || (id == primary(before)) != (id == primary(after)):
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Profile is the event of a profile being applied.
+func Profile(name string, before, after *models.Layout, now time.Time) Event {
+	changed := Changed(before, after)
+	return Event{
+		Type:    ProfileApplied,
+		Time:    now,
+		Profile: name,
+		Before:  restrict(before, changed...),
+		After:   restrict(after, changed...),
+	}
+}
+
+// Failure is the event of a layout change that failed. After shows what
+// the failed attempt left behind.
+func Failure(err error, before, after *models.Layout, now time.Time) Event {
+	changed := Changed(before, after)
+	return Event{
+		Type:   ConfigureFailed,
+		Time:   now,
+		Error:  err.Error(),
+		Before: restrict(before, changed...),
+		After:  restrict(after, changed...),
+	}
+}
+
+func sameGeometry(a, b models.Display) bool {
+	if (a.CurrentMode == nil) != (b.CurrentMode == nil) {
+		return false
+	}
+	if a.CurrentMode != nil {
+		m, n := a.CurrentMode, b.CurrentMode
+		if m.Width != n.Width || m.Height != n.Height || m.Name != n.Name || int(m.Rate*100+0.5) != int(n.Rate*100+0.5) {
+			return false
+		}
+	}
+	return a.X == b.X && a.Y == b.Y && a.Rotation == b.Rotation
+}
+
+// outputs returns the output names of both layouts, sorted.
+func outputs(layouts ...*models.Layout) []string {
+	seen := map[string]bool{}
+	var ids []string
+	for _, l := range layouts {
+		if l == nil {
+			continue
+		}
+		for _, d := range l.Displays {
+			if !seen[d.ID] {
+				seen[d.ID] = true
+				ids = append(ids, d.ID)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func find(l *models.Layout, id string) *models.Display {
+	if l == nil {
+		return nil
+	}
+	for i := range l.Displays {
+		if l.Displays[i].ID == id {
+			return &l.Displays[i]
+		}
+	}
+	return nil
+}
+
+func primary(l *models.Layout) string {
+	if l == nil {
+		return ""
+	}
+	return l.Primary
+}
+
+// restrict returns a copy of l with only the given outputs and their
+// logical monitors.
+func restrict(l *models.Layout, ids ...string) *models.Layout {
+	if l == nil {
+		return nil
+	}
+	keep := map[string]bool{}
+	for _, id := range ids {
+		keep[id] = true
+	}
+
+	result := &models.Layout{Primary: l.Primary, Displays: []models.Display{}}
+	for _, d := range l.Displays {
+		if keep[d.ID] {
+			result.Displays = append(result.Displays, d)
+		}
+	}
+	for _, m := range l.Monitors {
+		for _, o := range m.Outputs {
+			if keep[o] {
+				result.Monitors = append(result.Monitors, m)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before it misses some.
+const subscriberBuffer = 64
+
+// Bus fans events out to subscribers. Publishing never blocks: a
+// subscriber that stops reading loses events instead of stalling layout
+// changes.
+type Bus struct {
+	mu     sync.Mutex
+	subs   map[chan Event]struct{}
+	logger *logrus.Logger
+}
+
+func NewBus(logger *logrus.Logger) *Bus {
+	return &Bus{
+		subs:   map[chan Event]struct{}{},
+		logger: logger,
+	}
+}
+
+// Subscribe returns a channel of the events published from now on, and a
+// function that unsubscribes and closes it.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish sends an event to every subscriber.
+func (b *Bus) Publish(e Event) {
+	b.logger.WithFields(logrus.Fields{
+		"event":  e.Type,
+		"output": e.Output,
+	}).Debug("Publishing display event")
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			b.logger.WithField("event", e.Type).Warn("Event subscriber is not keeping up, dropping event")
+		}
+	}
+}
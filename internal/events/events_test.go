@@ -0,0 +1,151 @@
+package events
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+func active(id string, width, height, x int) models.Display {
+	mode := models.Mode{Width: width, Height: height, Rate: 60, Current: true}
+	return models.Display{ID: id, Connected: true, Modes: []models.Mode{mode}, CurrentMode: &mode, X: x}
+}
+
+func types(events []Event) []Type {
+	var result []Type
+	for _, e := range events {
+		result = append(result, e.Type)
+	}
+	return result
+}
+
+func TestDiff(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	laptop := &models.Layout{
+		Displays: []models.Display{
+			active("eDP-1", 1920, 1200, 0),
+			{ID: "HDMI-1"},
+		},
+		Primary: "eDP-1",
+	}
+	docked := &models.Layout{
+		Displays: []models.Display{
+			active("eDP-1", 1920, 1200, 2560),
+			active("HDMI-1", 2560, 1440, 0),
+		},
+		Primary: "HDMI-1",
+	}
+
+	if events := Diff(laptop, laptop, now); len(events) != 0 {
+		t.Errorf("no change: %v", types(events))
+	}
+
+	events := Diff(laptop, docked, now)
+	if got, want := types(events), []Type{Connected, ModeChanged, PrimaryChanged}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("docking: %v, want %v", got, want)
+	}
+
+	connected := events[0]
+	if connected.Output != "HDMI-1" || !connected.Time.Equal(now) {
+		t.Errorf("connected event: %+v", connected)
+	}
+	if len(connected.Before.Displays) != 1 || connected.Before.Displays[0].Connected ||
+		len(connected.After.Displays) != 1 || connected.After.Displays[0].CurrentMode.Width != 2560 {
+		t.Errorf("connected event carries %+v → %+v", connected.Before, connected.After)
+	}
+
+	moved := events[1]
+	if moved.Output != "eDP-1" || moved.Before.Displays[0].X != 0 || moved.After.Displays[0].X != 2560 {
+		t.Errorf("mode event: %+v", moved)
+	}
+
+	primary := events[2]
+	if primary.Output != "HDMI-1" || primary.Before.Primary != "eDP-1" || len(primary.After.Displays) != 2 {
+		t.Errorf("primary event: %+v", primary)
+	}
+
+	undocked := &models.Layout{
+		Displays: []models.Display{laptop.Displays[0]},
+		Primary:  "eDP-1",
+	}
+	if got, want := types(Diff(docked, undocked, now)), []Type{Disconnected, ModeChanged, PrimaryChanged}; !reflect.DeepEqual(got, want) {
+		t.Errorf("undocking: %v, want %v", got, want)
+	}
+
+	// A rate change is a mode change; rounding noise is not.
+	faster := &models.Layout{Displays: []models.Display{active("eDP-1", 1920, 1200, 0)}, Primary: "eDP-1"}
+	faster.Displays[0].CurrentMode.Rate = 120
+	if got := types(Diff(undocked, faster, now)); !reflect.DeepEqual(got, []Type{ModeChanged}) {
+		t.Errorf("rate change: %v", got)
+	}
+	noisy := &models.Layout{Displays: []models.Display{active("eDP-1", 1920, 1200, 0)}, Primary: "eDP-1"}
+	noisy.Displays[0].CurrentMode.Rate = 60.0001
+	if got := Diff(undocked, noisy, now); len(got) != 0 {
+		t.Errorf("rate noise: %v", types(got))
+	}
+}
+
+func TestProfileAndFailure(t *testing.T) {
+	now := time.Now()
+	before := &models.Layout{
+		Displays: []models.Display{active("eDP-1", 1920, 1200, 0), active("DP-1", 2560, 1440, 1920)},
+		Monitors: []models.Monitor{{Name: "eDP-1", Outputs: []string{"eDP-1"}}, {Name: "DP-1", Outputs: []string{"DP-1"}}},
+		Primary:  "DP-1",
+	}
+	after := &models.Layout{
+		Displays: []models.Display{{ID: "eDP-1", Connected: true}, active("DP-1", 2560, 1440, 1920)},
+		Monitors: []models.Monitor{{Name: "DP-1", Outputs: []string{"DP-1"}}},
+		Primary:  "DP-1",
+	}
+
+	e := Profile("desk", before, after, now)
+	if e.Type != ProfileApplied || e.Profile != "desk" {
+		t.Errorf("profile event: %+v", e)
+	}
+	if len(e.Before.Displays) != 1 || e.Before.Displays[0].ID != "eDP-1" || len(e.Before.Monitors) != 1 || len(e.After.Monitors) != 0 {
+		t.Errorf("profile event is not limited to eDP-1: %+v → %+v", e.Before, e.After)
+	}
+
+	e = Failure(io.ErrUnexpectedEOF, before, before, now)
+	if e.Type != ConfigureFailed || e.Error != io.ErrUnexpectedEOF.Error() || len(e.Before.Displays) != 0 || len(e.After.Displays) != 0 {
+		t.Errorf("failure event: %+v", e)
+	}
+}
+
+func TestBus(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	bus := NewBus(logger)
+
+	first, unsubscribeFirst := bus.Subscribe()
+	second, unsubscribeSecond := bus.Subscribe()
+	defer unsubscribeSecond()
+
+	bus.Publish(Event{Type: Connected, Output: "DP-1"})
+	unsubscribeFirst()
+	unsubscribeFirst()
+	bus.Publish(Event{Type: Disconnected, Output: "DP-1"})
+
+	var got []Type
+	for e := range first {
+		got = append(got, e.Type)
+	}
+	if !reflect.DeepEqual(got, []Type{Connected}) {
+		t.Errorf("unsubscribed subscriber got %v", got)
+	}
+	if e := <-second; e.Type != Connected {
+		t.Errorf("second subscriber got %v first", e.Type)
+	}
+	if e := <-second; e.Type != Disconnected {
+		t.Errorf("second subscriber got %v second", e.Type)
+	}
+
+	// A subscriber that never reads does not block publishing.
+	for i := 0; i < subscriberBuffer*2; i++ {
+		bus.Publish(Event{Type: ModeChanged})
+	}
+}
@@ -0,0 +1,791 @@
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/abhishek/dmon-cli/internal/modeline"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/planner"
+	"github.com/sirupsen/logrus"
+)
+
+// Hotplug connects or disconnects a display once DetectDisplays has been
+// called AfterDetects times.
+type Hotplug struct {
+	AfterDetects int
+	Display      string
+	Connected    bool
+}
+
+// Failure makes the Nth call (1-based) of an operation fail. A zero Call
+// fails every call. Operations: "detect", "configure", "layout", "brightness",
+// "color", "mode", "provider", "monitor".
+type Failure struct {
+	Operation string
+	Call      int
+	Error     string
+}
+
+// Call records one Configure or Apply invocation.
+type Call struct {
+	Config  models.DisplayConfig
+	Profile string               `json:",omitempty"`
+	Result  *models.ConfigResult `json:",omitempty"`
+	Error   string               `json:",omitempty"`
+}
+
+// State is the scriptable display inventory, stored as JSON.
+type State struct {
+	Displays []models.Display
+	Primary  string
+	Monitors []models.Monitor `json:",omitempty"`
+	// Brightness holds software brightness levels; missing outputs are at 1.0.
+	Brightness map[string]float64 `json:",omitempty"`
+	// Color holds the last colour correction applied to each output.
+	Color map[string]models.Color `json:",omitempty"`
+	// ICC holds the ICC profile attached to each output.
+	ICC map[string][]byte `json:",omitempty"`
+	// Providers lists the GPUs. Displays named in ProviderOutputs belong to
+	// the provider with that id and are not detected until it is an output
+	// sink; OutputSources maps linked sinks to their source.
+	Providers       []models.Provider `json:",omitempty"`
+	ProviderOutputs map[string]uint32 `json:",omitempty"`
+	OutputSources   map[uint32]uint32 `json:",omitempty"`
+	Hotplug         []Hotplug         `json:",omitempty"`
+	Failures        []Failure         `json:",omitempty"`
+	Calls           []Call            `json:",omitempty"`
+	Counts          map[string]int    `json:",omitempty"`
+}
+
+// Backend is an in-memory adapter.DisplayBackend. When created from a file
+// every operation writes the updated state back, so scripted hotplugs and
+// recorded Configure calls survive across dmon invocations.
+type Backend struct {
+	logger  *logrus.Logger
+	planner *planner.Planner
+	path    string
+
+	mu    sync.Mutex
+	state State
+}
+
+func NewBackend(logger *logrus.Logger, state State) *Backend {
+	if state.Counts == nil {
+		state.Counts = map[string]int{}
+	}
+	return &Backend{
+		logger:  logger,
+		planner: planner.New(logger),
+		state:   state,
+	}
+}
+
+// WithPlanner replaces the planner, e.g. one with custom resolvers.
+func (b *Backend) WithPlanner(p *planner.Planner) *Backend {
+	b.planner = p
+	return b
+}
+
+// Load creates a backend from a state file. A missing file starts from DefaultState.
+func Load(logger *logrus.Logger, path string) (*Backend, error) {
+	state := DefaultState()
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read fake state %s: %w", path, err)
+	default:
+		state = State{}
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("failed to parse fake state %s: %w", path, err)
+		}
+	}
+
+	b := NewBackend(logger, state)
+	b.path = path
+	return b, nil
+}
+
+// DefaultState is a laptop panel with one external monitor attached.
+func DefaultState() State {
+	internalModes := []models.Mode{
+		{Width: 2560, Height: 1600, Rate: 60, Preferred: true},
+		{Width: 1920, Height: 1200, Rate: 60, Current: true},
+		{Width: 1600, Height: 1000, Rate: 60},
+	}
+	externalModes := []models.Mode{
+		{Width: 3840, Height: 2160, Rate: 60, Preferred: true},
+		{Width: 1920, Height: 1080, Rate: 60},
+		{Width: 1920, Height: 1080, Rate: 50},
+		{Width: 1280, Height: 720, Rate: 60},
+	}
+
+	return State{
+		Displays: []models.Display{
+			{
+				ID:          "eDP-1",
+				Type:        models.Internal,
+				Connected:   true,
+				Modes:       internalModes,
+				CurrentMode: &internalModes[1],
+				WidthMM:     302,
+				HeightMM:    189,
+			},
+			{
+				ID:        "HDMI-1",
+				Type:      models.External,
+				Connected: true,
+				Modes:     externalModes,
+				WidthMM:   597,
+				HeightMM:  336,
+			},
+			{
+				ID:    "DP-1",
+				Type:  models.External,
+				Modes: []models.Mode{},
+			},
+		},
+		Primary: "eDP-1",
+	}
+}
+
+// State returns a copy of the current inventory and call log.
+func (b *Backend) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data, _ := json.Marshal(b.state)
+	var state State
+	_ = json.Unmarshal(data, &state)
+	return state
+}
+
+// Calls returns every recorded Configure call.
+func (b *Backend) Calls() []Call {
+	return b.State().Calls
+}
+
+// SetConnected simulates plugging or unplugging a display.
+func (b *Backend) SetConnected(displayID string, connected bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.setConnected(displayID, connected); err != nil {
+		return err
+	}
+	return b.save()
+}
+
+func (b *Backend) setConnected(displayID string, connected bool) error {
+	for i := range b.state.Displays {
+		d := &b.state.Displays[i]
+		if d.ID != displayID {
+			continue
+		}
+
+		b.logger.WithFields(logrus.Fields{
+			"id":        displayID,
+			"connected": connected,
+		}).Debug("Simulating hotplug")
+
+		d.Connected = connected
+		if !connected {
+			d.CurrentMode = nil
+			for j := range d.Modes {
+				d.Modes[j].Current = false
+			}
+			if b.state.Primary == displayID {
+				b.state.Primary = ""
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("display %s not found", displayID)
+}
+
+// FailNext makes the next call of operation return err.
+func (b *Backend) FailNext(operation string, err string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state.Failures = append(b.state.Failures, Failure{
+		Operation: operation,
+		Call:      b.state.Counts[operation] + 1,
+		Error:     err,
+	})
+}
+
+// call counts an operation and returns the scripted failure for it, if any.
+func (b *Backend) call(operation string) error {
+	b.state.Counts[operation]++
+	n := b.state.Counts[operation]
+
+	for _, f := range b.state.Failures {
+		if f.Operation == operation && (f.Call == 0 || f.Call == n) {
+			return errors.New(f.Error)
+		}
+	}
+	return nil
+}
+
+func (b *Backend) save() error {
+	if b.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(b.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(b.path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write fake state %s: %w", b.path, err)
+	}
+	return nil
+}
+
+func (b *Backend) DetectDisplays(ctx context.Context) ([]models.Display, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.logger.Debug("Detecting displays via fake backend")
+
+	if err := b.call("detect"); err != nil {
+		_ = b.save()
+		return nil, err
+	}
+
+	for _, h := range b.state.Hotplug {
+		if h.AfterDetects == b.state.Counts["detect"]-1 {
+			if err := b.setConnected(h.Display, h.Connected); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := b.save(); err != nil {
+		return nil, err
+	}
+
+	return b.copyDisplays(), nil
+}
+
+func (b *Backend) copyDisplays() []models.Display {
+	displays := make([]models.Display, 0, len(b.state.Displays))
+	for _, d := range b.state.Displays {
+		if b.unlinked(d.ID) {
+			continue
+		}
+		d.Modes = append([]models.Mode{}, d.Modes...)
+		d.Primary = d.ID == b.state.Primary
+		if d.CurrentMode != nil {
+			mode := *d.CurrentMode
+			d.CurrentMode = &mode
+		}
+		displays = append(displays, d)
+	}
+	return displays
+}
+
+// unlinked reports whether a display sits on a provider that is not an
+// output sink yet. Other associations, such as offload, do not count.
+func (b *Backend) unlinked(displayID string) bool {
+	id, ok := b.state.ProviderOutputs[displayID]
+	if !ok {
+		return false
+	}
+	_, linked := b.state.OutputSources[id]
+	return !linked
+}
+
+func (b *Backend) Configure(ctx context.Context, config models.DisplayConfig, displays []models.Display) (*models.ConfigResult, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.logger.WithFields(logrus.Fields{
+		"target":   config.Target,
+		"mode":     config.Mode,
+		"position": config.Position,
+	}).Info("Configuring displays")
+
+	return b.record(Call{Config: config}, func() (*models.ConfigResult, error) {
+		plan, err := b.planner.Plan(config, displays)
+		if err != nil {
+			return nil, err
+		}
+		return b.apply(plan)
+	})
+}
+
+func (b *Backend) Apply(ctx context.Context, plan *planner.Plan) (*models.ConfigResult, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.logger.WithField("profile", plan.Profile).Info("Applying display plan")
+
+	return b.record(Call{Config: plan.Config, Profile: plan.Profile}, func() (*models.ConfigResult, error) {
+		return b.apply(plan)
+	})
+}
+
+// record runs a configure operation and appends it to the call log.
+func (b *Backend) record(call Call, run func() (*models.ConfigResult, error)) (*models.ConfigResult, error) {
+	err := b.call("configure")
+	var result *models.ConfigResult
+	if err == nil {
+		result, err = run()
+	}
+
+	if err != nil {
+		call.Error = err.Error()
+	} else {
+		call.Result = result
+	}
+	b.state.Calls = append(b.state.Calls, call)
+
+	if saveErr := b.save(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b.logger.Info("Display configuration applied successfully")
+	return result, nil
+}
+
+func (b *Backend) apply(plan *planner.Plan) (*models.ConfigResult, error) {
+	// Check the whole plan first so a bad output leaves the state untouched,
+	// like the atomic commit of a real backend.
+	modes := make([]int, len(plan.Outputs))
+	for i, o := range plan.Outputs {
+		d := b.find(o.ID)
+		if d == nil {
+			return nil, fmt.Errorf("display %s not found", o.ID)
+		}
+		if !o.Enabled {
+			continue
+		}
+		if !d.Connected {
+			return nil, fmt.Errorf("display %s is not connected", o.ID)
+		}
+		if modes[i] = pickMode(d, o); modes[i] < 0 {
+			return nil, fmt.Errorf("mode %s not available for %s", o.Mode, o.ID)
+		}
+	}
+
+	for i, o := range plan.Outputs {
+		d := b.find(o.ID)
+		d.CurrentMode = nil
+		for j := range d.Modes {
+			d.Modes[j].Current = false
+		}
+
+		if !o.Enabled {
+			continue
+		}
+
+		d.Modes[modes[i]].Current = true
+		mode := d.Modes[modes[i]]
+		d.CurrentMode = &mode
+
+		if o.Primary {
+			b.state.Primary = o.ID
+		}
+	}
+
+	b.position(plan)
+	return plan.Result(), nil
+}
+
+// position places enabled outputs like a real backend would: absolute
+// outputs where the plan says, anchors at the origin, related outputs next
+// to their anchor, then shifted so no coordinate is negative.
+func (b *Backend) position(plan *planner.Plan) {
+	minX, minY := 0, 0
+	for _, o := range plan.Outputs {
+		d := b.find(o.ID)
+		if !o.Enabled || d.CurrentMode == nil {
+			continue
+		}
+
+		switch {
+		case o.Absolute:
+			d.X, d.Y = o.X, o.Y
+		case o.Relation == models.PositionNone:
+			d.X, d.Y = 0, 0
+		default:
+			ax, ay, aw, ah := 0, 0, 0, 0
+			if a := b.find(o.RelativeTo); a != nil && a.CurrentMode != nil {
+				ax, ay, aw, ah = a.X, a.Y, a.CurrentMode.Width, a.CurrentMode.Height
+			}
+			w, h := d.CurrentMode.Width, d.CurrentMode.Height
+			switch o.Relation {
+			case models.PositionLeft:
+				d.X, d.Y = ax-w, ay
+			case models.PositionRight:
+				d.X, d.Y = ax+aw, ay
+			case models.PositionAbove:
+				d.X, d.Y = ax, ay-h
+			case models.PositionBelow:
+				d.X, d.Y = ax, ay+ah
+			}
+		}
+		minX, minY = min(minX, d.X), min(minY, d.Y)
+	}
+
+	for _, o := range plan.Outputs {
+		if d := b.find(o.ID); o.Enabled && d.CurrentMode != nil {
+			d.X -= minX
+			d.Y -= minY
+		}
+	}
+}
+
+func (b *Backend) find(displayID string) *models.Display {
+	for i := range b.state.Displays {
+		if b.state.Displays[i].ID == displayID {
+			return &b.state.Displays[i]
+		}
+	}
+	return nil
+}
+
+// pickMode returns the index of the mode for a planned output, preferring the
+// preferred flag, then the highest rate.
+func pickMode(d *models.Display, o planner.OutputPlan) int {
+	best := -1
+	for i, m := range d.Modes {
+		if m.Name != "" && m.Name == o.Mode {
+			return i
+		}
+		if o.Mode != "auto" && (m.Width != o.Width || m.Height != o.Height) {
+			continue
+		}
+		if o.Rate > 0 && math.Abs(m.Rate-o.Rate) > 0.05 {
+			continue
+		}
+		if best < 0 || (m.Preferred && !d.Modes[best].Preferred) ||
+			(m.Preferred == d.Modes[best].Preferred && m.Rate > d.Modes[best].Rate) {
+			best = i
+		}
+	}
+	return best
+}
+
+func (b *Backend) GetCurrentLayout(ctx context.Context) (*models.Layout, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.logger.Debug("Getting current layout")
+
+	if err := b.call("layout"); err != nil {
+		_ = b.save()
+		return nil, err
+	}
+
+	layout := &models.Layout{
+		Displays: b.copyDisplays(),
+		Primary:  b.state.Primary,
+		Monitors: append([]models.Monitor{}, b.state.Monitors...),
+	}
+
+	return layout, b.save()
+}
+
+func (b *Backend) GetSupportedModes(ctx context.Context, displayID string) ([]models.Mode, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.logger.WithField("display", displayID).Debug("Getting supported modes")
+
+	if d := b.find(displayID); d != nil {
+		return append([]models.Mode{}, d.Modes...), nil
+	}
+
+	return nil, fmt.Errorf("display %s not found", displayID)
+}
+
+func (b *Backend) GetBrightness(ctx context.Context, displayID string) (float64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.find(displayID) == nil {
+		return 0, fmt.Errorf("display %s not found", displayID)
+	}
+	if level, ok := b.state.Brightness[displayID]; ok {
+		return level, nil
+	}
+	return 1, nil
+}
+
+func (b *Backend) SetBrightness(ctx context.Context, displayID string, level float64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.logger.WithFields(logrus.Fields{
+		"display": displayID,
+		"level":   level,
+	}).Debug("Setting software brightness via fake backend")
+
+	if err := b.call("brightness"); err != nil {
+		_ = b.save()
+		return err
+	}
+	if b.find(displayID) == nil {
+		return fmt.Errorf("display %s not found", displayID)
+	}
+
+	if b.state.Brightness == nil {
+		b.state.Brightness = map[string]float64{}
+	}
+	b.state.Brightness[displayID] = level
+	return b.save()
+}
+
+func (b *Backend) SetColor(ctx context.Context, displayID string, color models.Color) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.logger.WithFields(logrus.Fields{
+		"display": displayID,
+		"color":   color,
+	}).Debug("Setting colour via fake backend")
+
+	if err := b.call("color"); err != nil {
+		_ = b.save()
+		return err
+	}
+	if b.find(displayID) == nil {
+		return fmt.Errorf("display %s not found", displayID)
+	}
+
+	if b.state.Color == nil {
+		b.state.Color = map[string]models.Color{}
+	}
+	b.state.Color[displayID] = color
+	return b.save()
+}
+
+func (b *Backend) SetICCProfile(ctx context.Context, displayID string, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.logger.WithFields(logrus.Fields{
+		"display": displayID,
+		"bytes":   len(data),
+	}).Debug("Setting ICC profile via fake backend")
+
+	if b.find(displayID) == nil {
+		return fmt.Errorf("display %s not found", displayID)
+	}
+
+	if b.state.ICC == nil {
+		b.state.ICC = map[string][]byte{}
+	}
+	b.state.ICC[displayID] = append([]byte{}, data...)
+	return b.save()
+}
+
+func (b *Backend) GetICCProfile(ctx context.Context, displayID string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.find(displayID) == nil {
+		return nil, fmt.Errorf("display %s not found", displayID)
+	}
+	return b.state.ICC[displayID], nil
+}
+
+func (b *Backend) AddMode(ctx context.Context, displayID string, mode modeline.Modeline) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.logger.WithFields(logrus.Fields{
+		"display":  displayID,
+		"modeline": mode.String(),
+	}).Debug("Adding custom mode via fake backend")
+
+	if err := b.call("mode"); err != nil {
+		_ = b.save()
+		return err
+	}
+	d := b.find(displayID)
+	if d == nil {
+		return fmt.Errorf("display %s not found", displayID)
+	}
+
+	for _, m := range d.Modes {
+		if m.Name == mode.Name {
+			return b.save()
+		}
+	}
+	d.Modes = append(d.Modes, models.Mode{
+		Name:   mode.Name,
+		Width:  mode.HDisplay,
+		Height: mode.VDisplay,
+		Rate:   math.Round(mode.Rate()*100) / 100,
+	})
+	return b.save()
+}
+
+func (b *Backend) DeleteMode(ctx context.Context, displayID string, name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.call("mode"); err != nil {
+		_ = b.save()
+		return err
+	}
+	d := b.find(displayID)
+	if d == nil {
+		return fmt.Errorf("display %s not found", displayID)
+	}
+
+	for i, m := range d.Modes {
+		if m.Name != name {
+			continue
+		}
+		if m.Current {
+			return fmt.Errorf("mode %s is in use on %s", name, displayID)
+		}
+		d.Modes = append(d.Modes[:i], d.Modes[i+1:]...)
+		return b.save()
+	}
+	return fmt.Errorf("mode %s not found on %s", name, displayID)
+}
+
+// DestroyMode only checks that no output still has the mode, since the fake
+// inventory keeps modes per output.
+func (b *Backend) DestroyMode(ctx context.Context, name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.call("mode"); err != nil {
+		_ = b.save()
+		return err
+	}
+	for _, d := range b.state.Displays {
+		for _, m := range d.Modes {
+			if m.Name == name {
+				return fmt.Errorf("mode %s is still used by %s", name, d.ID)
+			}
+		}
+	}
+	return b.save()
+}
+
+func (b *Backend) ListProviders(ctx context.Context) ([]models.Provider, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.call("provider"); err != nil {
+		_ = b.save()
+		return nil, err
+	}
+	if err := b.save(); err != nil {
+		return nil, err
+	}
+	return append([]models.Provider{}, b.state.Providers...), nil
+}
+
+// LinkProvider makes sink an output sink of source, which makes the sink's
+// displays detectable. Linking again changes nothing, as with RandR.
+func (b *Backend) LinkProvider(ctx context.Context, sink, source models.Provider) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.logger.WithFields(logrus.Fields{
+		"sink":   sink.String(),
+		"source": source.String(),
+	}).Debug("Linking provider via fake backend")
+
+	if err := b.call("provider"); err != nil {
+		_ = b.save()
+		return err
+	}
+
+	var linked []*models.Provider
+	for i := range b.state.Providers {
+		if p := &b.state.Providers[i]; p.ID == sink.ID || p.ID == source.ID {
+			linked = append(linked, p)
+		}
+	}
+	if len(linked) != 2 || sink.ID == source.ID {
+		return fmt.Errorf("cannot link provider %s to %s", sink, source)
+	}
+	if b.state.OutputSources[sink.ID] == source.ID {
+		return b.save()
+	}
+	if b.state.OutputSources == nil {
+		b.state.OutputSources = map[uint32]uint32{}
+	}
+	b.state.OutputSources[sink.ID] = source.ID
+	for _, p := range linked {
+		p.Associated++
+	}
+	return b.save()
+}
+
+func (b *Backend) ListMonitors(ctx context.Context) ([]models.Monitor, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.call("monitor"); err != nil {
+		_ = b.save()
+		return nil, err
+	}
+	if err := b.save(); err != nil {
+		return nil, err
+	}
+	return append([]models.Monitor{}, b.state.Monitors...), nil
+}
+
+func (b *Backend) SetMonitor(ctx context.Context, monitor models.Monitor) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.call("monitor"); err != nil {
+		_ = b.save()
+		return err
+	}
+	for _, id := range monitor.Outputs {
+		if b.find(id) == nil {
+			return fmt.Errorf("display %s not found", id)
+		}
+	}
+
+	for i, m := range b.state.Monitors {
+		if m.Name == monitor.Name {
+			b.state.Monitors[i] = monitor
+			return b.save()
+		}
+	}
+	b.state.Monitors = append(b.state.Monitors, monitor)
+	return b.save()
+}
+
+func (b *Backend) DeleteMonitor(ctx context.Context, name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.call("monitor"); err != nil {
+		_ = b.save()
+		return err
+	}
+	for i, m := range b.state.Monitors {
+		if m.Name == name {
+			b.state.Monitors = append(b.state.Monitors[:i], b.state.Monitors[i+1:]...)
+			return b.save()
+		}
+	}
+	return fmt.Errorf("monitor %s not found", name)
+}
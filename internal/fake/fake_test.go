@@ -0,0 +1,296 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/planner"
+	"github.com/sirupsen/logrus"
+)
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// current returns the active mode and position of a display, or "off".
+func current(t *testing.T, state State, id string) string {
+	t.Helper()
+	for _, d := range state.Displays {
+		if d.ID != id {
+			continue
+		}
+		if d.CurrentMode == nil {
+			return "off"
+		}
+		return fmt.Sprintf("%dx%d+%d+%d", d.CurrentMode.Width, d.CurrentMode.Height, d.X, d.Y)
+	}
+	t.Fatalf("display %s not in state", id)
+	return ""
+}
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   models.DisplayConfig
+		internal string
+		external string
+		primary  string
+	}{
+		{
+			name:     "dual",
+			config:   models.DisplayConfig{Target: models.TargetBoth, Mode: models.ModeHighest, Position: models.PositionRight},
+			internal: "2560x1600+3840+0",
+			external: "3840x2160+0+0",
+			primary:  "HDMI-1",
+		},
+		{
+			name:     "dual left",
+			config:   models.DisplayConfig{Target: models.TargetBoth, Mode: models.ModePreset, Position: models.PositionLeft},
+			internal: "1920x1200+0+0",
+			external: "1920x1080+1920+0",
+			primary:  "HDMI-1",
+		},
+		{
+			name:     "internal only",
+			config:   models.DisplayConfig{Target: models.TargetInternal, Mode: models.ModeLow},
+			internal: "1600x1000+0+0",
+			external: "off",
+			primary:  "eDP-1",
+		},
+		{
+			name:     "external only",
+			config:   models.DisplayConfig{Target: models.TargetExternal, Mode: models.ModePreset},
+			internal: "off",
+			external: "1920x1080+0+0",
+			primary:  "HDMI-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			b := NewBackend(testLogger(), DefaultState())
+
+			displays, err := b.DetectDisplays(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := b.Configure(ctx, tt.config, displays); err != nil {
+				t.Fatal(err)
+			}
+
+			state := b.State()
+			if got := current(t, state, "eDP-1"); got != tt.internal {
+				t.Errorf("eDP-1 = %s, want %s", got, tt.internal)
+			}
+			if got := current(t, state, "HDMI-1"); got != tt.external {
+				t.Errorf("HDMI-1 = %s, want %s", got, tt.external)
+			}
+			if state.Primary != tt.primary {
+				t.Errorf("primary = %s, want %s", state.Primary, tt.primary)
+			}
+			if len(state.Calls) != 1 || state.Calls[0].Config != tt.config || state.Calls[0].Error != "" {
+				t.Errorf("calls = %+v", state.Calls)
+			}
+		})
+	}
+}
+
+func TestApplyLeavesStateOnError(t *testing.T) {
+	tests := []struct {
+		name    string
+		outputs []planner.OutputPlan
+		want    string
+	}{
+		{
+			name: "missing mode",
+			outputs: []planner.OutputPlan{
+				{ID: "eDP-1"},
+				{ID: "HDMI-1", Enabled: true, Mode: "5120x2880", Width: 5120, Height: 2880},
+			},
+			want: "mode 5120x2880 not available for HDMI-1",
+		},
+		{
+			name: "disconnected output",
+			outputs: []planner.OutputPlan{
+				{ID: "eDP-1", Enabled: true, Mode: "2560x1600", Width: 2560, Height: 1600},
+				{ID: "DP-1", Enabled: true, Mode: "1920x1080", Width: 1920, Height: 1080},
+			},
+			want: "display DP-1 is not connected",
+		},
+		{
+			name: "unknown output",
+			outputs: []planner.OutputPlan{
+				{ID: "eDP-1"},
+				{ID: "DP-9", Enabled: true, Mode: "1920x1080", Width: 1920, Height: 1080},
+			},
+			want: "display DP-9 not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBackend(testLogger(), DefaultState())
+			before := b.State().Displays
+
+			_, err := b.Apply(context.Background(), &planner.Plan{Outputs: tt.outputs, Profile: "broken"})
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+
+			state := b.State()
+			for i, d := range state.Displays {
+				if (d.CurrentMode == nil) != (before[i].CurrentMode == nil) || d.Primary != before[i].Primary {
+					t.Errorf("%s changed after a failed apply", d.ID)
+				}
+			}
+			if current(t, state, "eDP-1") != "1920x1200+0+0" || state.Primary != "eDP-1" {
+				t.Errorf("eDP-1 = %s, primary %s", current(t, state, "eDP-1"), state.Primary)
+			}
+			if len(state.Calls) != 1 || state.Calls[0].Error != tt.want || state.Calls[0].Profile != "broken" {
+				t.Errorf("calls = %+v", state.Calls)
+			}
+		})
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(testLogger(), DefaultState())
+
+	b.FailNext("detect", "xrandr crashed")
+	if _, err := b.DetectDisplays(ctx); err == nil || err.Error() != "xrandr crashed" {
+		t.Fatalf("first detect: err = %v", err)
+	}
+	displays, err := b.DetectDisplays(ctx)
+	if err != nil {
+		t.Fatalf("second detect: %v", err)
+	}
+
+	b.FailNext("configure", "BadMatch")
+	config := models.DisplayConfig{Target: models.TargetInternal, Mode: models.ModePreset}
+	if _, err := b.Configure(ctx, config, displays); err == nil || err.Error() != "BadMatch" {
+		t.Fatalf("configure: err = %v", err)
+	}
+	if _, err := b.Configure(ctx, config, displays); err != nil {
+		t.Fatalf("configure after the failure: %v", err)
+	}
+
+	state := b.State()
+	if state.Counts["detect"] != 2 || state.Counts["configure"] != 2 {
+		t.Errorf("counts = %v", state.Counts)
+	}
+	if len(state.Calls) != 2 || state.Calls[0].Error != "BadMatch" || state.Calls[1].Result == nil {
+		t.Errorf("calls = %+v", state.Calls)
+	}
+}
+
+func TestHotplug(t *testing.T) {
+	ctx := context.Background()
+	state := DefaultState()
+	state.Hotplug = []Hotplug{
+		{AfterDetects: 1, Display: "HDMI-1", Connected: false},
+		{AfterDetects: 2, Display: "HDMI-1", Connected: true},
+	}
+	b := NewBackend(testLogger(), state)
+
+	for i, want := range []bool{true, false, true} {
+		displays, err := b.DetectDisplays(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range displays {
+			if d.ID == "HDMI-1" && d.Connected != want {
+				t.Errorf("detect %d: HDMI-1 connected = %v, want %v", i+1, d.Connected, want)
+			}
+		}
+	}
+}
+
+func TestLoadSavesState(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	b, err := Load(testLogger(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetConnected("HDMI-1", false); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = Load(testLogger(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	displays, err := b.DetectDisplays(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range displays {
+		if d.ID == "HDMI-1" && d.Connected {
+			t.Error("HDMI-1 still connected after reloading")
+		}
+	}
+	if b.State().Counts["detect"] != 1 {
+		t.Errorf("counts = %v", b.State().Counts)
+	}
+
+	if _, err := Load(testLogger(), t.TempDir()); err == nil || !strings.Contains(err.Error(), "failed to read fake state") {
+		t.Errorf("loading a directory: err = %v", err)
+	}
+}
+
+func TestLinkProvider(t *testing.T) {
+	ctx := context.Background()
+	state := DefaultState()
+	state.Providers = []models.Provider{
+		{ID: 0x45, Name: "Intel", Capabilities: models.CapSourceOutput | models.CapSinkOutput, Outputs: 2},
+		// An offload association does not make the outputs usable.
+		{ID: 0x1f1, Name: "NVIDIA-G0", Capabilities: models.CapSinkOutput | models.CapSourceOffload, Outputs: 1, Associated: 1},
+	}
+	state.ProviderOutputs = map[string]uint32{"DP-1": 0x1f1}
+	state.Displays[2].Connected = true
+	b := NewBackend(testLogger(), state)
+
+	if hasDisplay(t, b, "DP-1") {
+		t.Fatal("DP-1 detected before its provider is linked")
+	}
+	if err := b.LinkProvider(ctx, state.Providers[1], state.Providers[0]); err != nil {
+		t.Fatal(err)
+	}
+	if !hasDisplay(t, b, "DP-1") {
+		t.Error("DP-1 not detected after linking")
+	}
+	if err := b.LinkProvider(ctx, state.Providers[1], state.Providers[0]); err != nil {
+		t.Fatalf("linking again: %v", err)
+	}
+	if got := b.State().Providers[1].Associated; got != 2 {
+		t.Errorf("NVIDIA-G0 has %d associations after linking twice, want 2", got)
+	}
+
+	if err := b.LinkProvider(ctx, state.Providers[0], state.Providers[0]); err == nil {
+		t.Error("linking a provider to itself succeeded")
+	}
+}
+
+func hasDisplay(t *testing.T, b *Backend, id string) bool {
+	t.Helper()
+	displays, err := b.DetectDisplays(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range displays {
+		if d.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+// Package gamma computes colour corrections: gamma curves, white points for
+// a colour temperature, and a day/night temperature schedule.
+package gamma
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+)
+
+const (
+	// Neutral is the colour temperature that leaves the picture unchanged.
+	Neutral = 6500
+	// MinTemperature and MaxTemperature bound the blackbody approximation.
+	MinTemperature = 1000
+	MaxTemperature = 25000
+)
+
+// ParseGamma accepts one value for all channels ("0.9") or one per channel ("1.0:0.9:0.8").
+func ParseGamma(s string) (models.RGB, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 1 && len(parts) != 3 {
+		return models.RGB{}, fmt.Errorf("invalid gamma: %s (valid: 0.9 or 1.0:0.9:0.8)", s)
+	}
+
+	values := make([]float64, len(parts))
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0.1 || v > 10 {
+			return models.RGB{}, fmt.Errorf("invalid gamma: %s (each value must be between 0.1 and 10)", s)
+		}
+		values[i] = v
+	}
+
+	if len(values) == 1 {
+		return models.RGB{Red: values[0], Green: values[0], Blue: values[0]}, nil
+	}
+	return models.RGB{Red: values[0], Green: values[1], Blue: values[2]}, nil
+}
+
+// ParseTemperature accepts "4500K" or "4500".
+func ParseTemperature(s string) (int, error) {
+	value := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "K")
+	kelvin, err := strconv.Atoi(value)
+	if err != nil || kelvin < MinTemperature || kelvin > MaxTemperature {
+		return 0, fmt.Errorf("invalid temperature: %s (must be between %dK and %dK)", s, MinTemperature, MaxTemperature)
+	}
+	return kelvin, nil
+}
+
+// Whitepoint returns channel multipliers for a blackbody colour temperature,
+// normalised so that 6500K is neutral and no channel exceeds 1.
+func Whitepoint(kelvin int) models.RGB {
+	r, g, b := blackbody(float64(kelvin))
+	nr, ng, nb := blackbody(Neutral)
+
+	return models.RGB{
+		Red:   math.Min(1, r/nr),
+		Green: math.Min(1, g/ng),
+		Blue:  math.Min(1, b/nb),
+	}
+}
+
+// blackbody is Tanner Helland's fit of the CIE 1964 blackbody colours,
+// returning 0-255 sRGB channel values.
+func blackbody(kelvin float64) (r, g, b float64) {
+	t := math.Max(MinTemperature, math.Min(MaxTemperature, kelvin)) / 100
+
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+
+	switch {
+	case t >= 66:
+		b = 255
+	case t <= 19:
+		b = 0
+	default:
+		b = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+
+	clamp := func(v float64) float64 { return math.Max(0, math.Min(255, v)) }
+	return clamp(r), clamp(g), clamp(b)
+}
+
+// Ramp builds a size-entry gamma ramp per channel, as RandR CRTC gamma takes it.
+func Ramp(size int, color models.Color) (red, green, blue []uint16) {
+	channel := func(gamma, whitepoint float64) []uint16 {
+		ramp := make([]uint16, size)
+		for i := range ramp {
+			x := float64(i) / float64(max(size-1, 1))
+			ramp[i] = uint16(math.Round(math.Pow(x, 1/gamma) * whitepoint * 0xffff))
+		}
+		return ramp
+	}
+
+	return channel(color.Gamma.Red, color.Whitepoint.Red),
+		channel(color.Gamma.Green, color.Whitepoint.Green),
+		channel(color.Gamma.Blue, color.Whitepoint.Blue)
+}
+
+// Effective folds the white point into a gamma exponent, for backends that
+// can only set gamma (xrandr --gamma). The curve is matched at mid-grey, so
+// the tint is right in the midtones and the white point is not reduced.
+func Effective(color models.Color) models.RGB {
+	fold := func(gamma, whitepoint float64) float64 {
+		if whitepoint <= 0 {
+			return 0.1
+		}
+		return math.Max(0.1, 1/(1/gamma-math.Log2(whitepoint)))
+	}
+
+	return models.RGB{
+		Red:   fold(color.Gamma.Red, color.Whitepoint.Red),
+		Green: fold(color.Gamma.Green, color.Whitepoint.Green),
+		Blue:  fold(color.Gamma.Blue, color.Whitepoint.Blue),
+	}
+}
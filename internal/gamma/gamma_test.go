@@ -0,0 +1,148 @@
+package gamma
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+)
+
+func TestWhitepoint(t *testing.T) {
+	if got := Whitepoint(Neutral); got != (models.RGB{Red: 1, Green: 1, Blue: 1}) {
+		t.Errorf("6500K should be neutral, got %+v", got)
+	}
+
+	warm := Whitepoint(3400)
+	if warm.Red != 1 || warm.Green >= 1 || warm.Blue >= warm.Green {
+		t.Errorf("3400K should keep red and cut blue more than green, got %+v", warm)
+	}
+
+	if cold := Whitepoint(10000); cold.Blue != 1 || cold.Red >= 1 {
+		t.Errorf("10000K should keep blue and cut red, got %+v", cold)
+	}
+}
+
+func TestRamp(t *testing.T) {
+	red, green, blue := Ramp(256, models.NeutralColor())
+	if len(red) != 256 || red[0] != 0 || red[255] != 0xffff || green[128] != blue[128] {
+		t.Errorf("neutral ramp is not linear: %d..%d", red[0], red[255])
+	}
+	if math.Abs(float64(red[128])-float64(128*0xffff/255)) > 1 {
+		t.Errorf("neutral ramp midpoint is %d", red[128])
+	}
+
+	color := models.NeutralColor()
+	color.Whitepoint = Whitepoint(3400)
+	_, _, blue = Ramp(256, color)
+	if want := uint16(math.Round(color.Whitepoint.Blue * 0xffff)); blue[255] != want {
+		t.Errorf("blue ramp should end at the white point, got %d want %d", blue[255], want)
+	}
+}
+
+func TestEffective(t *testing.T) {
+	if got := Effective(models.NeutralColor()); got != (models.RGB{Red: 1, Green: 1, Blue: 1}) {
+		t.Errorf("neutral colour should keep gamma 1, got %+v", got)
+	}
+
+	color := models.NeutralColor()
+	color.Whitepoint = Whitepoint(4500)
+	g := Effective(color)
+
+	// The folded curve matches the white point scaling at mid-grey.
+	if got, want := math.Pow(0.5, 1/g.Blue), 0.5*color.Whitepoint.Blue; math.Abs(got-want) > 1e-9 {
+		t.Errorf("mid-grey blue is %f, want %f", got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	if g, err := ParseGamma("1.0:0.9:0.8"); err != nil || g != (models.RGB{Red: 1, Green: 0.9, Blue: 0.8}) {
+		t.Errorf("ParseGamma per channel = %+v, %v", g, err)
+	}
+	if g, err := ParseGamma("0.9"); err != nil || g.Blue != 0.9 {
+		t.Errorf("ParseGamma single = %+v, %v", g, err)
+	}
+	for _, bad := range []string{"", "1:1", "0", "abc"} {
+		if _, err := ParseGamma(bad); err == nil {
+			t.Errorf("ParseGamma(%q) should fail", bad)
+		}
+	}
+
+	if k, err := ParseTemperature("4500K"); err != nil || k != 4500 {
+		t.Errorf("ParseTemperature = %d, %v", k, err)
+	}
+	for _, bad := range []string{"500", "warm", "30000k"} {
+		if _, err := ParseTemperature(bad); err == nil {
+			t.Errorf("ParseTemperature(%q) should fail", bad)
+		}
+	}
+}
+
+func TestSunTimes(t *testing.T) {
+	tests := []struct {
+		name          string
+		zone          string
+		date          time.Time
+		lat, lon      float64
+		sunrise       string
+		sunset        string
+		day, darkness bool
+	}{
+		{name: "new york solstice", zone: "America/New_York", date: time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC), lat: 40.7128, lon: -74.006, sunrise: "05:25", sunset: "20:30"},
+		{name: "tokyo winter", zone: "Asia/Tokyo", date: time.Date(2024, 12, 21, 12, 0, 0, 0, time.UTC), lat: 35.68, lon: 139.69, sunrise: "06:47", sunset: "16:31"},
+		{name: "svalbard summer", zone: "UTC", date: time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC), lat: 78.2, lon: 15.6, day: true},
+		{name: "svalbard winter", zone: "UTC", date: time.Date(2024, 12, 21, 12, 0, 0, 0, time.UTC), lat: 78.2, lon: 15.6, darkness: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := time.LoadLocation(tt.zone)
+			if err != nil {
+				t.Skipf("time zone data unavailable: %v", err)
+			}
+			date := time.Date(tt.date.Year(), tt.date.Month(), tt.date.Day(), 12, 0, 0, 0, loc)
+
+			sunrise, sunset, polarDay, polarNight := SunTimes(date, tt.lat, tt.lon)
+			if polarDay != tt.day || polarNight != tt.darkness {
+				t.Fatalf("polar day %v night %v, want %v %v", polarDay, polarNight, tt.day, tt.darkness)
+			}
+			if tt.day || tt.darkness {
+				return
+			}
+
+			// NOAA's simplified equation is accurate to about a minute.
+			for _, c := range []struct {
+				got  time.Time
+				want string
+			}{{sunrise, tt.sunrise}, {sunset, tt.sunset}} {
+				want, _ := time.ParseInLocation("2006-01-02 15:04", date.Format("2006-01-02 ")+c.want, loc)
+				if diff := c.got.Sub(want); diff < -2*time.Minute || diff > 2*time.Minute {
+					t.Errorf("got %s, want %s", c.got.Format("2006-01-02 15:04"), want.Format("2006-01-02 15:04"))
+				}
+			}
+		})
+	}
+}
+
+func TestScheduleTemperatureAt(t *testing.T) {
+	s := Schedule{Latitude: 0, Longitude: 0, Day: 6500, Night: 4500, Transition: time.Hour}
+
+	sunrise, sunset, _, _ := SunTimes(time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC), 0, 0)
+
+	tests := []struct {
+		at   time.Time
+		want int
+	}{
+		{at: sunrise.Add(-2 * time.Hour), want: 4500},
+		{at: sunrise, want: 5500},
+		{at: sunrise.Add(time.Hour), want: 6500},
+		{at: sunset.Add(-15 * time.Minute), want: 6000},
+		{at: sunset.Add(2 * time.Hour), want: 4500},
+	}
+
+	for _, tt := range tests {
+		if got := s.TemperatureAt(tt.at); got != tt.want {
+			t.Errorf("TemperatureAt(%s) = %d, want %d", tt.at.Format("15:04"), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package gamma
+
+import (
+	"math"
+	"time"
+)
+
+// zenith is the official sunrise/sunset zenith, allowing for refraction and
+// the solar disc.
+const zenith = 90.833
+
+// SunTimes computes sunrise and sunset for the day containing t at the given
+// position, with the NOAA sunrise equation. It needs no network access.
+// polarDay/polarNight report days when the sun never sets or never rises;
+// the returned times are then zero.
+func SunTimes(t time.Time, latitude, longitude float64) (sunrise, sunset time.Time, polarDay, polarNight bool) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	rise, riseState := sunEvent(day, latitude, longitude, true)
+	set, setState := sunEvent(day, latitude, longitude, false)
+	if riseState != 0 || setState != 0 {
+		return time.Time{}, time.Time{}, riseState < 0, riseState > 0
+	}
+
+	return rise, set, false, false
+}
+
+// sunEvent returns the time of sunrise (or sunset) on day. state is -1 when
+// the sun stays above the horizon and 1 when it stays below.
+func sunEvent(day time.Time, latitude, longitude float64, rising bool) (time.Time, int) {
+	rad := math.Pi / 180
+	lngHour := longitude / 15
+
+	approx := 18.0
+	if rising {
+		approx = 6
+	}
+	t := float64(day.YearDay()) + (approx-lngHour)/24
+
+	// Sun's mean anomaly and true longitude.
+	m := 0.9856*t - 3.289
+	l := normalize(m+1.916*math.Sin(m*rad)+0.020*math.Sin(2*m*rad)+282.634, 360)
+
+	// Right ascension, in the same quadrant as l, in hours.
+	ra := normalize(math.Atan(0.91764*math.Tan(l*rad))/rad, 360)
+	ra += math.Floor(l/90)*90 - math.Floor(ra/90)*90
+	ra /= 15
+
+	sinDec := 0.39782 * math.Sin(l*rad)
+	cosDec := math.Cos(math.Asin(sinDec))
+
+	cosH := (math.Cos(zenith*rad) - sinDec*math.Sin(latitude*rad)) / (cosDec * math.Cos(latitude*rad))
+	if cosH > 1 {
+		return time.Time{}, 1
+	}
+	if cosH < -1 {
+		return time.Time{}, -1
+	}
+
+	h := math.Acos(cosH) / rad
+	if rising {
+		h = 360 - h
+	}
+	h /= 15
+
+	local := h + ra - 0.06571*t - 6.622
+	ut := normalize(local-lngHour, 24)
+
+	utcDay := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	event := utcDay.Add(time.Duration(ut * float64(time.Hour)))
+
+	// Keep the event on the requested local day.
+	switch diff := event.Sub(day); {
+	case diff < 0:
+		event = event.Add(24 * time.Hour)
+	case diff >= 24*time.Hour:
+		event = event.Add(-24 * time.Hour)
+	}
+
+	return event.In(day.Location()), 0
+}
+
+func normalize(v, limit float64) float64 {
+	v = math.Mod(v, limit)
+	if v < 0 {
+		v += limit
+	}
+	return v
+}
+
+// Schedule picks a colour temperature from the position of the sun.
+type Schedule struct {
+	Latitude  float64
+	Longitude float64
+	Day       int
+	Night     int
+	// Transition is how long the change takes, centred on sunrise and sunset.
+	Transition time.Duration
+}
+
+// DefaultTransition matches redshift's gradual dusk and dawn.
+const DefaultTransition = time.Hour
+
+// TemperatureAt returns the colour temperature for t.
+func (s Schedule) TemperatureAt(t time.Time) int {
+	sunrise, sunset, polarDay, polarNight := SunTimes(t, s.Latitude, s.Longitude)
+	switch {
+	case polarDay:
+		return s.Day
+	case polarNight:
+		return s.Night
+	}
+
+	half := s.Transition / 2
+	// progress runs from 0 (night) to 1 (day).
+	progress := 0.0
+	switch {
+	case t.Before(sunrise.Add(-half)) || !t.Before(sunset.Add(half)):
+		progress = 0
+	case t.Before(sunrise.Add(half)):
+		progress = float64(t.Sub(sunrise.Add(-half))) / float64(s.Transition)
+	case t.Before(sunset.Add(-half)):
+		progress = 1
+	default:
+		progress = 1 - float64(t.Sub(sunset.Add(-half)))/float64(s.Transition)
+	}
+
+	return s.Night + int(math.Round(progress*float64(s.Day-s.Night)))
+}
@@ -0,0 +1,286 @@
+// Package hooks runs the user's scripts around layout changes, so the
+// desktop can follow them: restart the wallpaper, reload the status bar,
+// move window manager workspaces. Scripts live in one directory per stage
+// (pre-apply.d, post-apply.d, on-connect.d, on-disconnect.d) and run in
+// name order, like run-parts, with the layout described in the environment.
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/runner"
+	"github.com/abhishek/dmon-cli/internal/split"
+	"github.com/sirupsen/logrus"
+)
+
+// Stage names when hooks run.
+type Stage string
+
+const (
+	// PreApply runs before a layout change, with the layout it replaces.
+	PreApply Stage = "pre-apply"
+	// PostApply runs after a layout change succeeded, with the new layout.
+	PostApply Stage = "post-apply"
+	// OnConnect and OnDisconnect run when an output is plugged in or
+	// unplugged, which dmon only notices while it watches (dmon serve).
+	OnConnect    Stage = "on-connect"
+	OnDisconnect Stage = "on-disconnect"
+)
+
+// Stages lists every stage.
+var Stages = []Stage{PreApply, PostApply, OnConnect, OnDisconnect}
+
+// ParseStage parses a stage name, with or without the ".d" suffix.
+func ParseStage(s string) (Stage, error) {
+	s = strings.TrimSuffix(s, ".d")
+	for _, stage := range Stages {
+		if string(stage) == s {
+			return stage, nil
+		}
+	}
+	return "", fmt.Errorf("unknown hook stage %q", s)
+}
+
+// DefaultTimeout bounds one hook script when no timeout is configured.
+const DefaultTimeout = 10 * time.Second
+
+// maxOutput is how much of a hook's output is kept for the log.
+const maxOutput = 64 << 10
+
+// Hooks runs the scripts under one hooks directory.
+type Hooks struct {
+	dir     string
+	timeout time.Duration
+	runner  runner.Runner
+	logger  *logrus.Logger
+}
+
+// New runs the hooks under dir, each limited to DefaultTimeout.
+func New(dir string, logger *logrus.Logger) *Hooks {
+	return &Hooks{
+		dir:     dir,
+		timeout: DefaultTimeout,
+		runner:  runner.Exec{},
+		logger:  logger,
+	}
+}
+
+// WithRunner sets how scripts are run (recording, replay, fakes).
+func (h *Hooks) WithRunner(r runner.Runner) *Hooks {
+	h.runner = r
+	return h
+}
+
+// WithTimeout limits how long one script may run before it is killed.
+func (h *Hooks) WithTimeout(timeout time.Duration) *Hooks {
+	h.timeout = timeout
+	return h
+}
+
+// Dir returns the directory of a stage's scripts.
+func (h *Hooks) Dir(stage Stage) string {
+	return filepath.Join(h.dir, string(stage)+".d")
+}
+
+// Scripts returns the executable files of a stage in the order they run.
+// Hidden files and editor backups are skipped; a missing directory has no
+// scripts.
+func (h *Hooks) Scripts(stage Stage) ([]string, error) {
+	dir := h.Dir(stage)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hook directory %s: %w", dir, err)
+	}
+
+	var scripts []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+		scripts = append(scripts, path)
+	}
+	sort.Strings(scripts)
+	return scripts, nil
+}
+
+// Any reports whether any stage has scripts. A directory that cannot be
+// read counts, so running the stage reports why.
+func (h *Hooks) Any() bool {
+	for _, stage := range Stages {
+		if scripts, err := h.Scripts(stage); err != nil || len(scripts) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Result is the outcome of one hook script.
+type Result struct {
+	Script   string
+	Duration time.Duration
+	// Output is the script's stdout and stderr, interleaved and cut to
+	// its last 64 KiB.
+	Output []byte
+	Err    error
+}
+
+// Run runs a stage's scripts one after the other with env added to dmon's
+// own environment, logging each one's outcome and output. A script that
+// fails or times out does not stop the rest.
+func (h *Hooks) Run(ctx context.Context, stage Stage, env []string) []Result {
+	scripts, err := h.Scripts(stage)
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to list hooks")
+		return nil
+	}
+
+	results := make([]Result, 0, len(scripts))
+	for _, script := range scripts {
+		result := h.run(ctx, script, env)
+		results = append(results, result)
+
+		fields := logrus.Fields{
+			"hook":     filepath.Base(script),
+			"stage":    stage,
+			"duration": result.Duration.Round(time.Millisecond),
+		}
+		if output := strings.TrimSpace(string(result.Output)); output != "" {
+			fields["output"] = output
+		}
+		entry := h.logger.WithFields(fields)
+		if result.Err != nil {
+			entry.WithError(result.Err).Warn("Hook failed")
+		} else {
+			entry.Info("Ran hook")
+		}
+	}
+	return results
+}
+
+func (h *Hooks) run(ctx context.Context, script string, env []string) Result {
+	start := time.Now()
+	result := Result{Script: script}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	// Detached: a hook that starts a background program (a status bar, a
+	// wallpaper daemon) must not hold dmon up, and a timeout kills whatever
+	// the script started along with it.
+	out, err := runner.RunCommand(ctx, h.runner, runner.Command{
+		Name:     script,
+		Dir:      filepath.Dir(script),
+		Env:      env,
+		Detached: true,
+		Tail:     maxOutput,
+	})
+	result.Duration = time.Since(start)
+	result.Output = out.Stdout
+
+	var exitErr *runner.ExitError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result.Err = fmt.Errorf("timed out after %s", h.timeout)
+	case errors.As(err, &exitErr):
+		result.Err = fmt.Errorf("exited with status %d", exitErr.ExitCode)
+	case err != nil:
+		result.Err = err
+	}
+	return result
+}
+
+// Env describes a layout to hook scripts:
+//
+//	DMON_HOOK                the stage
+//	DMON_PROFILE             the profile being applied, if any
+//	DMON_OUTPUT              the output plugged in or unplugged (on-connect, on-disconnect)
+//	DMON_OUTPUTS             active outputs, left to right
+//	DMON_CONNECTED           connected outputs
+//	DMON_PRIMARY             the primary output
+//	DMON_OUTPUT_<NAME>       an active output's WIDTHxHEIGHT+X+Y
+//	DMON_OUTPUT_<NAME>_RATE  its refresh rate
+//
+// Lists are separated by spaces. <NAME> is the output name in upper case
+// with anything but letters and digits replaced by "_" (HDMI-1 is HDMI_1).
+func Env(stage Stage, layout *models.Layout, profileName, output string) []string {
+	env := []string{"DMON_HOOK=" + string(stage)}
+	if profileName != "" {
+		env = append(env, "DMON_PROFILE="+profileName)
+	}
+	if output != "" {
+		env = append(env, "DMON_OUTPUT="+output)
+	}
+	if layout == nil {
+		return env
+	}
+
+	var active []models.Display
+	var connected []string
+	for _, d := range layout.Displays {
+		if !d.Connected {
+			continue
+		}
+		connected = append(connected, d.ID)
+		if d.CurrentMode != nil {
+			active = append(active, d)
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool {
+		if active[i].X != active[j].X {
+			return active[i].X < active[j].X
+		}
+		return active[i].Y < active[j].Y
+	})
+
+	names := make([]string, len(active))
+	for i, d := range active {
+		names[i] = d.ID
+	}
+	env = append(env,
+		"DMON_OUTPUTS="+strings.Join(names, " "),
+		"DMON_CONNECTED="+strings.Join(connected, " "),
+		"DMON_PRIMARY="+layout.Primary,
+	)
+
+	for _, d := range active {
+		width, height, _ := split.Size(d)
+		key := "DMON_OUTPUT_" + VarName(d.ID)
+		env = append(env,
+			fmt.Sprintf("%s=%dx%d+%d+%d", key, width, height, d.X, d.Y),
+			fmt.Sprintf("%s_RATE=%.2f", key, d.CurrentMode.Rate),
+		)
+	}
+	return env
+}
+
+// VarName turns an output name into the part of a variable name that
+// stands for it.
+func VarName(output string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, output)
+}
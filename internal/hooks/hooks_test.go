@@ -0,0 +1,195 @@
+package hooks
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/runner"
+	"github.com/sirupsen/logrus"
+)
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func writeScript(t *testing.T, dir, name, body string, mode os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+body+"\n"), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScripts(t *testing.T) {
+	h := New(t.TempDir(), testLogger())
+	dir := h.Dir(PostApply)
+
+	writeScript(t, dir, "20-polybar", "true", 0o755)
+	writeScript(t, dir, "10-wallpaper", "true", 0o755)
+	writeScript(t, dir, "30-notes", "true", 0o644)
+	writeScript(t, dir, ".hidden", "true", 0o755)
+	writeScript(t, dir, "10-wallpaper~", "true", 0o755)
+	if err := os.Mkdir(filepath.Join(dir, "40-dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	scripts, err := h.Scripts(PostApply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "10-wallpaper"), filepath.Join(dir, "20-polybar")}
+	if !reflect.DeepEqual(scripts, want) {
+		t.Errorf("Scripts = %v, want %v", scripts, want)
+	}
+
+	if scripts, err := h.Scripts(OnConnect); err != nil || scripts != nil {
+		t.Errorf("missing directory: %v, %v", scripts, err)
+	}
+}
+
+func TestAny(t *testing.T) {
+	h := New(t.TempDir(), testLogger())
+	if h.Any() {
+		t.Error("Any without hook directories")
+	}
+
+	writeScript(t, h.Dir(PreApply), "README", "true", 0o644)
+	if h.Any() {
+		t.Error("Any with only a file that is not executable")
+	}
+
+	writeScript(t, h.Dir(OnDisconnect), "10-notify", "true", 0o755)
+	if !h.Any() {
+		t.Error("Any false with an on-disconnect script")
+	}
+}
+
+func TestRun(t *testing.T) {
+	h := New(t.TempDir(), testLogger()).WithTimeout(500 * time.Millisecond)
+	dir := h.Dir(PreApply)
+
+	writeScript(t, dir, "10-env", `echo "$DMON_HOOK $DMON_PRIMARY"; echo oops >&2`, 0o755)
+	writeScript(t, dir, "20-fail", "exit 3", 0o755)
+	writeScript(t, dir, "30-slow", "echo started; sleep 10", 0o755)
+	writeScript(t, dir, "40-after", "echo still ran", 0o755)
+
+	start := time.Now()
+	results := h.Run(context.Background(), PreApply, []string{"DMON_HOOK=pre-apply", "DMON_PRIMARY=HDMI-1"})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run took %s despite the timeout", elapsed)
+	}
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+
+	if r := results[0]; r.Err != nil || string(r.Output) != "pre-apply HDMI-1\noops\n" {
+		t.Errorf("env: %q, %v", r.Output, r.Err)
+	}
+	if r := results[1]; r.Err == nil || !strings.Contains(r.Err.Error(), "status 3") {
+		t.Errorf("fail: %v", r.Err)
+	}
+	if r := results[2]; r.Err == nil || !strings.Contains(r.Err.Error(), "timed out") || string(r.Output) != "started\n" {
+		t.Errorf("slow: %q, %v", r.Output, r.Err)
+	}
+	if r := results[3]; r.Err != nil || string(r.Output) != "still ran\n" {
+		t.Errorf("after: %q, %v", r.Output, r.Err)
+	}
+}
+
+func TestRunWithRunner(t *testing.T) {
+	h := New(t.TempDir(), testLogger())
+	dir := h.Dir(PostApply)
+	writeScript(t, dir, "10-bar", "exit 0", 0o755)
+
+	var got []runner.Command
+	h.WithRunner(commandFunc(func(ctx context.Context, cmd runner.Command) (runner.Result, error) {
+		got = append(got, cmd)
+		return runner.Result{Stdout: []byte("replayed\n"), ExitCode: 3}, &runner.ExitError{Name: cmd.Name, ExitCode: 3}
+	}))
+
+	results := h.Run(context.Background(), PostApply, []string{"DMON_HOOK=post-apply"})
+	if len(results) != 1 || string(results[0].Output) != "replayed\n" || results[0].Err == nil ||
+		results[0].Err.Error() != "exited with status 3" {
+		t.Fatalf("results = %+v", results)
+	}
+
+	want := runner.Command{
+		Name:     filepath.Join(dir, "10-bar"),
+		Dir:      dir,
+		Env:      []string{"DMON_HOOK=post-apply"},
+		Detached: true,
+		Tail:     maxOutput,
+	}
+	if len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+		t.Errorf("ran %+v, want %+v", got, want)
+	}
+}
+
+// commandFunc is a runner.CommandRunner made of a function.
+type commandFunc func(ctx context.Context, cmd runner.Command) (runner.Result, error)
+
+func (f commandFunc) Run(ctx context.Context, name string, args ...string) (runner.Result, error) {
+	return f(ctx, runner.Command{Name: name, Args: args})
+}
+
+func (f commandFunc) RunCommand(ctx context.Context, cmd runner.Command) (runner.Result, error) {
+	return f(ctx, cmd)
+}
+
+func TestEnv(t *testing.T) {
+	layout := &models.Layout{
+		Primary: "HDMI-1",
+		Displays: []models.Display{
+			{ID: "eDP-1", Connected: true, CurrentMode: &models.Mode{Width: 1920, Height: 1200, Rate: 60}, X: 2560},
+			{ID: "HDMI-1", Connected: true, CurrentMode: &models.Mode{Width: 2560, Height: 1440, Rate: 143.97}},
+			{ID: "DP-1", Connected: true, CurrentMode: &models.Mode{Width: 1920, Height: 1080, Rate: 60}, X: 4480, Rotation: "left"},
+			{ID: "DP-2", Connected: true},
+			{ID: "DP-3"},
+		},
+	}
+
+	got := Env(PostApply, layout, "desk", "")
+	want := []string{
+		"DMON_HOOK=post-apply",
+		"DMON_PROFILE=desk",
+		"DMON_OUTPUTS=HDMI-1 eDP-1 DP-1",
+		"DMON_CONNECTED=eDP-1 HDMI-1 DP-1 DP-2",
+		"DMON_PRIMARY=HDMI-1",
+		"DMON_OUTPUT_HDMI_1=2560x1440+0+0",
+		"DMON_OUTPUT_HDMI_1_RATE=143.97",
+		"DMON_OUTPUT_EDP_1=1920x1200+2560+0",
+		"DMON_OUTPUT_EDP_1_RATE=60.00",
+		"DMON_OUTPUT_DP_1=1080x1920+4480+0",
+		"DMON_OUTPUT_DP_1_RATE=60.00",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Env =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	got = Env(OnDisconnect, nil, "", "DP-1")
+	if want := []string{"DMON_HOOK=on-disconnect", "DMON_OUTPUT=DP-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Env without layout = %v, want %v", got, want)
+	}
+}
+
+func TestParseStage(t *testing.T) {
+	for _, s := range []string{"on-connect", "on-connect.d"} {
+		if stage, err := ParseStage(s); err != nil || stage != OnConnect {
+			t.Errorf("ParseStage(%q) = %q, %v", s, stage, err)
+		}
+	}
+	if _, err := ParseStage("post"); err == nil {
+		t.Error("ParseStage accepted an unknown stage")
+	}
+}
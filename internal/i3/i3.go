@@ -0,0 +1,181 @@
+// Package i3 keeps i3 (and sway) workspaces on the outputs they belong on
+// after a layout change. It talks to the window manager over its IPC
+// socket: it reads the workspaces and outputs, decides where each
+// workspace goes from a map of workspaces to display types or outputs, and
+// moves the ones that are elsewhere.
+package i3
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abhishek/dmon-cli/internal/runner"
+)
+
+// magic starts every IPC message, followed by the payload length and the
+// message type in native byte order.
+const magic = "i3-ipc"
+
+// Message types.
+const (
+	msgRunCommand    = 0
+	msgGetWorkspaces = 1
+	msgGetOutputs    = 3
+)
+
+// maxPayload bounds one reply; a tree of thousands of windows stays far
+// below it.
+const maxPayload = 16 << 20
+
+// Workspace is a workspace as GET_WORKSPACES reports it. Num is -1 for
+// workspaces whose name does not start with a number.
+type Workspace struct {
+	Num     int    `json:"num"`
+	Name    string `json:"name"`
+	Output  string `json:"output"`
+	Focused bool   `json:"focused"`
+	Visible bool   `json:"visible"`
+}
+
+// Output is an output as GET_OUTPUTS reports it.
+type Output struct {
+	Name    string `json:"name"`
+	Active  bool   `json:"active"`
+	Primary bool   `json:"primary"`
+}
+
+// Client is a connection to the IPC socket. Requests are answered in
+// order, so they are serialised.
+type Client struct {
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+// Dial connects to the IPC socket at path.
+func Dial(ctx context.Context, path string) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to i3 at %s: %w", path, err)
+	}
+	return &Client{conn: conn}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Workspaces returns the workspaces in i3's order.
+func (c *Client) Workspaces(ctx context.Context) ([]Workspace, error) {
+	var workspaces []Workspace
+	if err := c.request(ctx, msgGetWorkspaces, "", &workspaces); err != nil {
+		return nil, fmt.Errorf("failed to get i3 workspaces: %w", err)
+	}
+	return workspaces, nil
+}
+
+// Outputs returns the outputs i3 knows, including inactive ones.
+func (c *Client) Outputs(ctx context.Context) ([]Output, error) {
+	var outputs []Output
+	if err := c.request(ctx, msgGetOutputs, "", &outputs); err != nil {
+		return nil, fmt.Errorf("failed to get i3 outputs: %w", err)
+	}
+	return outputs, nil
+}
+
+// Command runs an i3 command (several may be separated by ";") and fails
+// if any of them did.
+func (c *Client) Command(ctx context.Context, command string) error {
+	var results []struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}
+	if err := c.request(ctx, msgRunCommand, command, &results); err != nil {
+		return fmt.Errorf("i3 command %q failed: %w", command, err)
+	}
+	for _, r := range results {
+		if !r.Success {
+			return fmt.Errorf("i3 command %q failed: %s", command, r.Error)
+		}
+	}
+	return nil
+}
+
+func (c *Client) request(ctx context.Context, typ uint32, payload string, v any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+		defer c.conn.SetDeadline(time.Time{})
+	}
+
+	if err := writeMessage(c.conn, typ, []byte(payload)); err != nil {
+		return err
+	}
+	replyType, reply, err := readMessage(c.conn)
+	if err != nil {
+		return err
+	}
+	if replyType != typ {
+		return fmt.Errorf("got a reply of type %d to a request of type %d", replyType, typ)
+	}
+	return json.Unmarshal(reply, v)
+}
+
+func writeMessage(w io.Writer, typ uint32, payload []byte) error {
+	msg := make([]byte, len(magic)+8, len(magic)+8+len(payload))
+	copy(msg, magic)
+	binary.NativeEndian.PutUint32(msg[len(magic):], uint32(len(payload)))
+	binary.NativeEndian.PutUint32(msg[len(magic)+4:], typ)
+	_, err := w.Write(append(msg, payload...))
+	return err
+}
+
+func readMessage(r io.Reader) (uint32, []byte, error) {
+	header := make([]byte, len(magic)+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	if string(header[:len(magic)]) != magic {
+		return 0, nil, errors.New("not an i3 IPC message")
+	}
+	length := binary.NativeEndian.Uint32(header[len(magic):])
+	typ := binary.NativeEndian.Uint32(header[len(magic)+4:])
+	if length > maxPayload {
+		return 0, nil, fmt.Errorf("i3 IPC message of %d bytes is too large", length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return typ, payload, nil
+}
+
+// SocketPath finds the IPC socket: I3SOCK, then SWAYSOCK, then what
+// 'i3 --get-socketpath' reports.
+func SocketPath(ctx context.Context, r runner.Runner) (string, error) {
+	for _, env := range []string{"I3SOCK", "SWAYSOCK"} {
+		if path := os.Getenv(env); path != "" {
+			return path, nil
+		}
+	}
+	result, err := r.Run(ctx, "i3", "--get-socketpath")
+	if err != nil {
+		return "", fmt.Errorf("i3 is not running (no I3SOCK, and i3 --get-socketpath failed: %w)", err)
+	}
+	path := strings.TrimSpace(string(result.Stdout))
+	if path == "" {
+		return "", errors.New("i3 is not running (i3 --get-socketpath printed nothing)")
+	}
+	return path, nil
+}
@@ -0,0 +1,304 @@
+package i3
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/runner"
+	"github.com/sirupsen/logrus"
+)
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// stub is an i3 IPC server that understands the commands Manager sends.
+type stub struct {
+	mu         sync.Mutex
+	workspaces []Workspace
+	outputs    []Output
+	commands   []string
+}
+
+func (s *stub) serve(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ipc.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	return path
+}
+
+func (s *stub) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		typ, payload, err := readMessage(conn)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		var reply any
+		switch typ {
+		case msgGetWorkspaces:
+			reply = s.workspaces
+		case msgGetOutputs:
+			reply = s.outputs
+		case msgRunCommand:
+			reply = s.run(string(payload))
+		}
+		data, _ := json.Marshal(reply)
+		s.mu.Unlock()
+
+		if err := writeMessage(conn, typ, data); err != nil {
+			return
+		}
+	}
+}
+
+type commandResult struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (s *stub) run(payload string) []commandResult {
+	var results []commandResult
+	for _, command := range strings.Split(payload, ";") {
+		command = strings.TrimSpace(command)
+		s.commands = append(s.commands, command)
+
+		switch {
+		case strings.HasPrefix(command, "workspace --no-auto-back-and-forth "):
+			name := unquote(strings.TrimPrefix(command, "workspace --no-auto-back-and-forth "))
+			for i := range s.workspaces {
+				s.workspaces[i].Focused = s.workspaces[i].Name == name
+			}
+			results = append(results, commandResult{Success: true})
+		case strings.HasPrefix(command, "move workspace to output "):
+			output := unquote(strings.TrimPrefix(command, "move workspace to output "))
+			for i := range s.workspaces {
+				if s.workspaces[i].Focused {
+					s.workspaces[i].Output = output
+				}
+			}
+			results = append(results, commandResult{Success: true})
+		default:
+			results = append(results, commandResult{Error: "unknown command"})
+		}
+	}
+	return results
+}
+
+func unquote(s string) string {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, `"`), `"`)
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(s)
+}
+
+func display(id string, typ models.DisplayType, x int, on bool) models.Display {
+	d := models.Display{ID: id, Type: typ, Connected: true, X: x}
+	if on {
+		d.CurrentMode = &models.Mode{Width: 1920, Height: 1080}
+	}
+	return d
+}
+
+func TestParseAssignments(t *testing.T) {
+	got, err := ParseAssignments(map[string]string{
+		"external": "1-3",
+		"internal": "4, mail,web-dev",
+		"DP-2":     "9",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Assignments{
+		"1": "external", "2": "external", "3": "external",
+		"4": "internal", "mail": "internal", "web-dev": "internal",
+		"9": "DP-2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAssignments = %v, want %v", got, want)
+	}
+
+	for _, bad := range []map[string]string{
+		{"external": "1-5", "internal": "5-10"},
+		{"external": "5-1"},
+		{"external": "1-1000"},
+		{"external": "1,,2"},
+		{"": "1"},
+	} {
+		if _, err := ParseAssignments(bad); err == nil {
+			t.Errorf("ParseAssignments(%v) succeeded", bad)
+		}
+	}
+}
+
+func TestArrange(t *testing.T) {
+	rules, err := ParseAssignments(map[string]string{"external": "1-5", "internal": "6-10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := []Output{{Name: "eDP-1", Active: true}, {Name: "HDMI-1", Active: true}, {Name: "DP-1"}}
+
+	tests := []struct {
+		name       string
+		layout     *models.Layout
+		workspaces []Workspace
+		want       []Move
+	}{
+		{
+			name: "dual",
+			layout: &models.Layout{Primary: "HDMI-1", Displays: []models.Display{
+				display("HDMI-1", models.External, 0, true),
+				display("eDP-1", models.Internal, 1920, true),
+			}},
+			workspaces: []Workspace{
+				{Num: 1, Name: "1: web", Output: "eDP-1"},
+				{Num: 2, Name: "2", Output: "HDMI-1"},
+				{Num: 7, Name: "7", Output: "HDMI-1"},
+				{Num: -1, Name: "scratch", Output: "HDMI-1"},
+			},
+			want: []Move{
+				{Workspace: "1: web", From: "eDP-1", To: "HDMI-1"},
+				{Workspace: "7", From: "HDMI-1", To: "eDP-1"},
+			},
+		},
+		{
+			name: "external gone",
+			layout: &models.Layout{Primary: "eDP-1", Displays: []models.Display{
+				display("HDMI-1", models.External, 0, false),
+				display("eDP-1", models.Internal, 0, true),
+			}},
+			workspaces: []Workspace{
+				{Num: 1, Name: "1", Output: "HDMI-1"},
+				{Num: 6, Name: "6", Output: "eDP-1"},
+				{Num: -1, Name: "scratch", Output: "HDMI-1"},
+			},
+			want: []Move{
+				{Workspace: "1", From: "HDMI-1", To: "eDP-1"},
+				{Workspace: "scratch", From: "HDMI-1", To: "eDP-1"},
+			},
+		},
+		{
+			name: "no primary, not yet active in i3",
+			layout: &models.Layout{Displays: []models.Display{
+				display("DP-1", models.External, 0, true),
+				display("HDMI-1", models.External, 2560, true),
+				display("eDP-1", models.Internal, 0, false),
+			}},
+			workspaces: []Workspace{
+				{Num: 1, Name: "1", Output: "eDP-1"},
+				{Num: 6, Name: "6", Output: "eDP-1"},
+			},
+			want: []Move{
+				{Workspace: "1", From: "eDP-1", To: "HDMI-1"},
+				{Workspace: "6", From: "eDP-1", To: "HDMI-1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Arrange(tt.workspaces, outputs, tt.layout, rules)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Arrange = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManagerApply(t *testing.T) {
+	s := &stub{
+		workspaces: []Workspace{
+			{Num: 1, Name: "1", Output: "eDP-1"},
+			{Num: 2, Name: `2: "code"`, Output: "eDP-1", Focused: true, Visible: true},
+			{Num: 6, Name: "6", Output: "eDP-1"},
+		},
+		outputs: []Output{{Name: "eDP-1", Active: true}, {Name: "HDMI-1", Active: true}},
+	}
+	path := s.serve(t)
+
+	layout := &models.Layout{Primary: "HDMI-1", Displays: []models.Display{
+		display("HDMI-1", models.External, 0, true),
+		display("eDP-1", models.Internal, 1920, true),
+	}}
+	rules := Assignments{"1": TargetExternal, "2": TargetExternal, "6": TargetInternal}
+	manager := NewManager(testLogger()).WithSocket(path)
+	ctx := context.Background()
+
+	moves, err := manager.Moves(ctx, layout, rules)
+	if err != nil || len(moves) != 2 || len(s.commands) != 0 {
+		t.Fatalf("Moves = %v, %v (commands %v)", moves, err, s.commands)
+	}
+
+	moves, err = manager.Apply(ctx, layout, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 2 {
+		t.Errorf("Apply moved %v", moves)
+	}
+	for _, ws := range s.workspaces {
+		want := map[string]string{"1": "HDMI-1", `2: "code"`: "HDMI-1", "6": "eDP-1"}[ws.Name]
+		if ws.Output != want {
+			t.Errorf("workspace %s on %s, want %s", ws.Name, ws.Output, want)
+		}
+		if ws.Focused != (ws.Name == `2: "code"`) {
+			t.Errorf("focus not restored: %+v", s.workspaces)
+		}
+	}
+
+	if moves, err := manager.Apply(ctx, layout, rules); err != nil || len(moves) != 0 {
+		t.Errorf("second Apply = %v, %v", moves, err)
+	}
+}
+
+func TestCommandFailure(t *testing.T) {
+	s := &stub{}
+	client, err := Dial(context.Background(), s.serve(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if err := client.Command(context.Background(), "nop"); err == nil || !strings.Contains(err.Error(), "unknown command") {
+		t.Errorf("Command = %v", err)
+	}
+}
+
+func TestSocketPath(t *testing.T) {
+	t.Setenv("I3SOCK", "")
+	t.Setenv("SWAYSOCK", "")
+	r := runner.Func(func(ctx context.Context, name string, args ...string) (runner.Result, error) {
+		return runner.Result{Stdout: []byte("/run/user/1000/i3/ipc-socket.42\n")}, nil
+	})
+	if path, err := SocketPath(context.Background(), r); err != nil || path != "/run/user/1000/i3/ipc-socket.42" {
+		t.Errorf("SocketPath = %q, %v", path, err)
+	}
+
+	t.Setenv("SWAYSOCK", "/run/user/1000/sway-ipc.sock")
+	if path, _ := SocketPath(context.Background(), r); path != "/run/user/1000/sway-ipc.sock" {
+		t.Errorf("SocketPath with SWAYSOCK = %q", path)
+	}
+}
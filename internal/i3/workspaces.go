@@ -0,0 +1,346 @@
+package i3
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/runner"
+	"github.com/sirupsen/logrus"
+)
+
+// Targets a workspace can be assigned to, besides an output name.
+const (
+	TargetInternal = "internal"
+	TargetExternal = "external"
+	TargetPrimary  = "primary"
+)
+
+// maxRange bounds a workspace range such as 1-10.
+const maxRange = 100
+
+// Assignments map workspaces to the target they belong on. A key is a
+// workspace number ("3", matching "3" and "3: mail") or a full workspace
+// name; a target is internal, external, primary or an output name.
+type Assignments map[string]string
+
+// ParseAssignments parses a map from targets to workspace lists, as
+// written in the config and in profiles:
+//
+//	external = "1-5"
+//	internal = "6-10,chat"
+//
+// Lists are comma-separated numbers, ranges of numbers and names.
+func ParseAssignments(targets map[string]string) (Assignments, error) {
+	names := make([]string, 0, len(targets))
+	for target := range targets {
+		names = append(names, target)
+	}
+	sort.Strings(names)
+
+	a := Assignments{}
+	for _, target := range names {
+		if strings.TrimSpace(target) == "" {
+			return nil, fmt.Errorf("workspaces: empty target")
+		}
+		workspaces, err := ParseWorkspaces(targets[target])
+		if err != nil {
+			return nil, fmt.Errorf("workspaces %s: %w", target, err)
+		}
+		for _, ws := range workspaces {
+			if other, ok := a[ws]; ok {
+				return nil, fmt.Errorf("workspace %s is assigned to both %s and %s", ws, other, target)
+			}
+			a[ws] = target
+		}
+	}
+	return a, nil
+}
+
+// ParseWorkspaces parses a workspace list such as "1-5,chat".
+func ParseWorkspaces(spec string) ([]string, error) {
+	var workspaces []string
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return nil, fmt.Errorf("empty workspace in %q", spec)
+		}
+
+		from, to, isRange := strings.Cut(item, "-")
+		first, err1 := strconv.Atoi(from)
+		last, err2 := strconv.Atoi(to)
+		if !isRange || err1 != nil || err2 != nil {
+			workspaces = append(workspaces, item)
+			continue
+		}
+		if first < 0 || last < first || last-first >= maxRange {
+			return nil, fmt.Errorf("invalid workspace range %q", item)
+		}
+		for n := first; n <= last; n++ {
+			workspaces = append(workspaces, strconv.Itoa(n))
+		}
+	}
+	return workspaces, nil
+}
+
+// target returns where a workspace is assigned, matching its full name
+// before its number.
+func (a Assignments) target(ws Workspace) (string, bool) {
+	if target, ok := a[ws.Name]; ok {
+		return target, true
+	}
+	if ws.Num >= 0 {
+		target, ok := a[strconv.Itoa(ws.Num)]
+		return target, ok
+	}
+	return "", false
+}
+
+// Move is a workspace that goes to another output.
+type Move struct {
+	Workspace string
+	From      string
+	To        string
+}
+
+// Arrange decides which workspaces move where. A workspace that has a
+// target goes to the output the target resolves to; one whose target has
+// no active output, or that has no target and sits on an output that is
+// gone, goes to the primary output, or the leftmost one without a primary.
+//
+// Outputs are usable when the layout has them on and i3 reports them
+// active; display types and the primary output come from the layout.
+func Arrange(workspaces []Workspace, outputs []Output, layout *models.Layout, rules Assignments) []Move {
+	inI3 := map[string]bool{}
+	for _, o := range outputs {
+		if o.Active {
+			inI3[o.Name] = true
+		}
+	}
+	var active []models.Display
+	for _, d := range layout.Displays {
+		if d.Connected && d.CurrentMode != nil && inI3[d.ID] {
+			active = append(active, d)
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+	sort.SliceStable(active, func(i, j int) bool {
+		if active[i].X != active[j].X {
+			return active[i].X < active[j].X
+		}
+		return active[i].Y < active[j].Y
+	})
+
+	fallback := active[0].ID
+	for _, d := range active {
+		if d.ID == layout.Primary {
+			fallback = d.ID
+		}
+	}
+	resolve := func(target string) string {
+		for _, d := range active {
+			switch {
+			case target == TargetInternal && d.Type == models.Internal,
+				target == TargetExternal && d.Type == models.External,
+				target == TargetPrimary && d.ID == layout.Primary,
+				target == d.ID:
+				return d.ID
+			}
+		}
+		return fallback
+	}
+
+	var moves []Move
+	for _, ws := range workspaces {
+		to := ""
+		if target, ok := rules.target(ws); ok {
+			to = resolve(target)
+		} else if !isActive(active, ws.Output) {
+			to = fallback
+		}
+		if to != "" && to != ws.Output {
+			moves = append(moves, Move{Workspace: ws.Name, From: ws.Output, To: to})
+		}
+	}
+	return moves
+}
+
+func isActive(active []models.Display, id string) bool {
+	for _, d := range active {
+		if d.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// settleTimeout is how long i3 gets to pick up a layout change before
+// workspaces are moved anyway.
+const settleTimeout = 2 * time.Second
+
+// Manager moves workspaces on the running i3.
+type Manager struct {
+	socket string
+	runner runner.Runner
+	settle time.Duration
+	logger *logrus.Logger
+}
+
+// NewManager finds i3 through its environment each time it is used.
+func NewManager(logger *logrus.Logger) *Manager {
+	return &Manager{
+		runner: runner.Exec{},
+		settle: settleTimeout,
+		logger: logger,
+	}
+}
+
+// WithSocket sets the IPC socket instead of looking it up.
+func (m *Manager) WithSocket(path string) *Manager {
+	m.socket = path
+	return m
+}
+
+// WithRunner sets how 'i3 --get-socketpath' is run.
+func (m *Manager) WithRunner(r runner.Runner) *Manager {
+	m.runner = r
+	return m
+}
+
+// Moves returns the moves Apply would make, without making them.
+func (m *Manager) Moves(ctx context.Context, layout *models.Layout, rules Assignments) ([]Move, error) {
+	client, err := m.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	moves, _, err := m.plan(ctx, client, layout, rules)
+	return moves, err
+}
+
+// Apply moves workspaces to where rules put them on layout, then returns
+// focus to the workspace that had it. It waits briefly for i3 to see the
+// layout's outputs, since i3 learns of a layout change on its own.
+func (m *Manager) Apply(ctx context.Context, layout *models.Layout, rules Assignments) ([]Move, error) {
+	client, err := m.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	moves, focused, err := m.plan(ctx, client, layout, rules)
+	if err != nil || len(moves) == 0 {
+		return nil, err
+	}
+
+	var done []Move
+	for _, move := range moves {
+		m.logger.WithFields(logrus.Fields{
+			"workspace": move.Workspace,
+			"from":      move.From,
+			"to":        move.To,
+		}).Debug("Moving i3 workspace")
+		command := fmt.Sprintf("workspace --no-auto-back-and-forth %s; move workspace to output %s", quote(move.Workspace), quote(move.To))
+		if err := client.Command(ctx, command); err != nil {
+			return done, err
+		}
+		done = append(done, move)
+	}
+
+	if focused != "" {
+		if err := client.Command(ctx, "workspace --no-auto-back-and-forth "+quote(focused)); err != nil {
+			return done, err
+		}
+	}
+	return done, nil
+}
+
+func (m *Manager) dial(ctx context.Context) (*Client, error) {
+	path := m.socket
+	if path == "" {
+		var err error
+		if path, err = SocketPath(ctx, m.runner); err != nil {
+			return nil, err
+		}
+	}
+	return Dial(ctx, path)
+}
+
+// plan waits for i3's outputs to match the layout, then arranges the
+// workspaces. It also returns the focused workspace.
+func (m *Manager) plan(ctx context.Context, client *Client, layout *models.Layout, rules Assignments) ([]Move, string, error) {
+	outputs, err := m.waitOutputs(ctx, client, layout)
+	if err != nil {
+		return nil, "", err
+	}
+	workspaces, err := client.Workspaces(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	focused := ""
+	for _, ws := range workspaces {
+		if ws.Focused {
+			focused = ws.Name
+		}
+	}
+	return Arrange(workspaces, outputs, layout, rules), focused, nil
+}
+
+// waitOutputs polls i3's outputs until the active ones are those the
+// layout has on, or the settle timeout passes.
+func (m *Manager) waitOutputs(ctx context.Context, client *Client, layout *models.Layout) ([]Output, error) {
+	want := map[string]bool{}
+	for _, d := range layout.Displays {
+		if d.Connected && d.CurrentMode != nil {
+			want[d.ID] = true
+		}
+	}
+
+	deadline := time.Now().Add(m.settle)
+	for {
+		outputs, err := client.Outputs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		got := map[string]bool{}
+		for _, o := range outputs {
+			if o.Active {
+				got[o.Name] = true
+			}
+		}
+		if sameSet(got, want) || time.Now().After(deadline) {
+			return outputs, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func sameSet(a, b map[string]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if !b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// quote makes s a double-quoted i3 command argument.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,137 @@
+// Package icc reads the header and description of ICC colour profiles.
+package icc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+const headerSize = 128
+
+// Profile is the part of an ICC profile dmon shows to users.
+type Profile struct {
+	Size        int
+	Version     string
+	Class       string
+	ColorSpace  string
+	Description string
+}
+
+// classes are the profile/device classes of the ICC specification.
+var classes = map[string]string{
+	"scnr": "input",
+	"mntr": "display",
+	"prtr": "output",
+	"link": "device link",
+	"spac": "colour space",
+	"abst": "abstract",
+	"nmcl": "named colour",
+}
+
+// Parse decodes the header and the 'desc' tag of an ICC profile.
+func Parse(data []byte) (*Profile, error) {
+	if len(data) < headerSize+4 {
+		return nil, errors.New("ICC profile too short")
+	}
+	if string(data[36:40]) != "acsp" {
+		return nil, errors.New("not an ICC profile (missing 'acsp' signature)")
+	}
+
+	size := int(binary.BigEndian.Uint32(data[0:4]))
+	if size < headerSize {
+		return nil, fmt.Errorf("invalid ICC profile size %d", size)
+	}
+	if size > len(data) {
+		return nil, fmt.Errorf("ICC profile truncated: header says %d bytes, have %d", size, len(data))
+	}
+
+	p := &Profile{
+		Size:       size,
+		Version:    fmt.Sprintf("%d.%d", data[8], data[9]>>4),
+		Class:      string(data[12:16]),
+		ColorSpace: strings.TrimSpace(string(data[16:20])),
+	}
+	if name, ok := classes[p.Class]; ok {
+		p.Class = name
+	}
+
+	count := int(binary.BigEndian.Uint32(data[headerSize : headerSize+4]))
+	for i := 0; i < count; i++ {
+		entry := headerSize + 4 + i*12
+		if entry+12 > len(data) {
+			break
+		}
+		if string(data[entry:entry+4]) != "desc" {
+			continue
+		}
+		offset := int(binary.BigEndian.Uint32(data[entry+4:]))
+		length := int(binary.BigEndian.Uint32(data[entry+8:]))
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return nil, errors.New("ICC 'desc' tag out of bounds")
+		}
+		p.Description = description(data[offset : offset+length])
+		break
+	}
+
+	return p, nil
+}
+
+// description decodes a v2 'desc' (textDescriptionType) or v4 'mluc'
+// (multiLocalizedUnicodeType) tag, taking the first record of the latter.
+func description(tag []byte) string {
+	if len(tag) < 12 {
+		return ""
+	}
+
+	switch string(tag[0:4]) {
+	case "desc":
+		n := int(binary.BigEndian.Uint32(tag[8:12]))
+		if 12+n > len(tag) {
+			n = len(tag) - 12
+		}
+		return strings.TrimRight(string(bytes.TrimRight(tag[12:12+n], "\x00")), " ")
+	case "mluc":
+		if len(tag) < 16+12 {
+			return ""
+		}
+		length := int(binary.BigEndian.Uint32(tag[20:24]))
+		offset := int(binary.BigEndian.Uint32(tag[24:28]))
+		if offset+length > len(tag) {
+			return ""
+		}
+		units := make([]uint16, length/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(tag[offset+2*i:])
+		}
+		return strings.TrimRight(string(utf16.Decode(units)), "\x00 ")
+	}
+
+	return ""
+}
+
+// Load reads and parses a profile file.
+func Load(path string) ([]byte, *Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read ICC profile: %w", err)
+	}
+
+	p, err := Parse(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid ICC profile %s: %w", path, err)
+	}
+
+	return data[:p.Size], p, nil
+}
+
+func (p *Profile) String() string {
+	if p.Description != "" {
+		return p.Description
+	}
+	return fmt.Sprintf("%s profile (%s, v%s)", p.Class, p.ColorSpace, p.Version)
+}
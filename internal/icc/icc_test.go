@@ -0,0 +1,209 @@
+package icc
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// build returns a display RGB profile of the given version whose only tag
+// is 'desc', holding tag.
+func build(major, minor byte, tag []byte) []byte {
+	data := make([]byte, headerSize+4+12)
+	data[8], data[9] = major, minor<<4
+	copy(data[12:16], "mntr")
+	copy(data[16:20], "RGB ")
+	copy(data[36:40], "acsp")
+
+	binary.BigEndian.PutUint32(data[headerSize:], 1)
+	entry := data[headerSize+4:]
+	copy(entry[0:4], "desc")
+	binary.BigEndian.PutUint32(entry[4:8], uint32(len(data)))
+	binary.BigEndian.PutUint32(entry[8:12], uint32(len(tag)))
+
+	data = append(data, tag...)
+	binary.BigEndian.PutUint32(data[0:4], uint32(len(data)))
+	return data
+}
+
+// textDescription is a v2 textDescriptionType tag.
+func textDescription(s string) []byte {
+	tag := make([]byte, 12)
+	copy(tag[0:4], "desc")
+	binary.BigEndian.PutUint32(tag[8:12], uint32(len(s)+1))
+	return append(append(tag, s...), 0, 0, 0, 0)
+}
+
+// multiLocalized is a v4 multiLocalizedUnicodeType tag with one record.
+func multiLocalized(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	tag := make([]byte, 28, 28+2*len(units))
+	copy(tag[0:4], "mluc")
+	binary.BigEndian.PutUint32(tag[8:12], 1)
+	binary.BigEndian.PutUint32(tag[12:16], 12)
+	copy(tag[16:20], "enUS")
+	binary.BigEndian.PutUint32(tag[20:24], uint32(2*len(units)))
+	binary.BigEndian.PutUint32(tag[24:28], 28)
+	for _, u := range units {
+		tag = binary.BigEndian.AppendUint16(tag, u)
+	}
+	return tag
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want Profile
+	}{
+		{
+			name: "v2 desc",
+			data: build(2, 1, textDescription("U2720Q calibrated")),
+			want: Profile{Version: "2.1", Class: "display", ColorSpace: "RGB", Description: "U2720Q calibrated"},
+		},
+		{
+			name: "v4 mluc",
+			data: build(4, 3, multiLocalized("Écran de bureau")),
+			want: Profile{Version: "4.3", Class: "display", ColorSpace: "RGB", Description: "Écran de bureau"},
+		},
+		{
+			name: "unknown description type",
+			data: build(4, 3, []byte("text\x00\x00\x00\x00Display P3")),
+			want: Profile{Version: "4.3", Class: "display", ColorSpace: "RGB"},
+		},
+		{
+			name: "desc length beyond the tag",
+			data: build(2, 0, append(textDescription("sRGB")[:12], "sRGB"...)),
+			want: Profile{Version: "2.0", Class: "display", ColorSpace: "RGB", Description: "sRGB"},
+		},
+		{
+			name: "mluc record beyond the tag",
+			data: build(4, 2, multiLocalized("sRGB")[:30]),
+			want: Profile{Version: "4.2", Class: "display", ColorSpace: "RGB"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want.Size = len(tt.data)
+			if *p != tt.want {
+				t.Errorf("profile = %+v, want %+v", *p, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	valid := build(4, 3, multiLocalized("sRGB"))
+
+	tests := []struct {
+		name   string
+		modify func(data []byte) []byte
+		want   string
+	}{
+		{
+			name:   "empty",
+			modify: func(data []byte) []byte { return nil },
+			want:   "ICC profile too short",
+		},
+		{
+			name:   "header only",
+			modify: func(data []byte) []byte { return data[:headerSize] },
+			want:   "ICC profile too short",
+		},
+		{
+			name: "missing signature",
+			modify: func(data []byte) []byte {
+				copy(data[36:40], "PNG\r")
+				return data
+			},
+			want: "not an ICC profile (missing 'acsp' signature)",
+		},
+		{
+			name: "size smaller than the header",
+			modify: func(data []byte) []byte {
+				binary.BigEndian.PutUint32(data[0:4], 64)
+				return data
+			},
+			want: "invalid ICC profile size 64",
+		},
+		{
+			name:   "truncated",
+			modify: func(data []byte) []byte { return data[:len(data)-8] },
+			want:   "ICC profile truncated: header says 180 bytes, have 172",
+		},
+		{
+			name: "desc tag out of bounds",
+			modify: func(data []byte) []byte {
+				binary.BigEndian.PutUint32(data[headerSize+4+8:], 4096)
+				return data
+			},
+			want: "ICC 'desc' tag out of bounds",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.modify(append([]byte{}, valid...))
+			if _, err := Parse(data); err == nil || err.Error() != tt.want {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTagTable(t *testing.T) {
+	// A tag count larger than the table stops at the end of the data.
+	data := build(2, 1, textDescription("sRGB"))
+	binary.BigEndian.PutUint32(data[headerSize:], 1000)
+	if p, err := Parse(data); err != nil || p.Description != "sRGB" {
+		t.Errorf("oversized tag count: %+v, %v", p, err)
+	}
+
+	// Without a 'desc' tag the profile describes itself by its header.
+	copy(data[headerSize+4:], "wtpt")
+	p, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Description != "" || p.String() != "display profile (RGB, v2.1)" {
+		t.Errorf("description %q, string %q", p.Description, p)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	profile := build(4, 3, multiLocalized("Studio"))
+
+	// Files may carry padding after the size the header declares.
+	path := filepath.Join(dir, "studio.icc")
+	if err := os.WriteFile(path, append(append([]byte{}, profile...), 0, 0, 0, 0), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, p, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != len(profile) || p.String() != "Studio" {
+		t.Errorf("loaded %d bytes, %q", len(data), p)
+	}
+
+	if _, _, err := Load(filepath.Join(dir, "missing.icc")); err == nil || !strings.Contains(err.Error(), "failed to read ICC profile") {
+		t.Errorf("missing file: %v", err)
+	}
+
+	broken := filepath.Join(dir, "broken.icc")
+	if err := os.WriteFile(broken, profile[:100], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Load(broken); err == nil || err.Error() != "invalid ICC profile "+broken+": ICC profile too short" {
+		t.Errorf("broken file: %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+// Package lid reads whether a laptop's lid is open or closed. Sources are
+// pluggable: the ACPI button files under /proc (with a configurable root,
+// so tests and bug reports can use fake files) and logind's LidClosed
+// property.
+package lid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// State is the lid state.
+type State int
+
+const (
+	// Unknown means there is no lid, or it could not be read.
+	Unknown State = iota
+	Open
+	Closed
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case Closed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// Source reads the lid state.
+type Source interface {
+	State(ctx context.Context) (State, error)
+}
+
+// Source names accepted by New.
+const (
+	SourceAuto   = "auto"
+	SourceProcfs = "procfs"
+	SourceLogind = "logind"
+	SourceNone   = "none"
+)
+
+// DefaultRoot is where ACPI exposes lid switches.
+const DefaultRoot = "/proc/acpi/button/lid"
+
+// Procfs reads <Root>/*/state, which holds a line like "state:      open".
+// With several lids (rare), the lid is closed when any of them is.
+type Procfs struct {
+	Root string
+}
+
+func NewProcfs(root string) Procfs {
+	if root == "" {
+		root = DefaultRoot
+	}
+	return Procfs{Root: root}
+}
+
+// Present reports whether any lid switch exists under the root.
+func (p Procfs) Present() bool {
+	files, _ := p.files()
+	return len(files) > 0
+}
+
+func (p Procfs) files() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(p.Root, "*", "state"))
+	sort.Strings(files)
+	return files, err
+}
+
+func (p Procfs) State(ctx context.Context) (State, error) {
+	files, err := p.files()
+	if err != nil {
+		return Unknown, err
+	}
+	if len(files) == 0 {
+		return Unknown, nil
+	}
+
+	state := Unknown
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return Unknown, fmt.Errorf("failed to read lid state: %w", err)
+		}
+		switch s := parse(string(data)); {
+		case s == Closed:
+			return Closed, nil
+		case s == Open:
+			state = Open
+		}
+	}
+	return state, nil
+}
+
+func parse(data string) State {
+	_, value, _ := strings.Cut(data, ":")
+	switch strings.TrimSpace(value) {
+	case "open":
+		return Open
+	case "closed":
+		return Closed
+	}
+	return Unknown
+}
+
+// Logind reads the LidClosed property of logind's manager. logind reports
+// the lid as open on machines without one.
+type Logind struct{}
+
+func (Logind) State(ctx context.Context) (State, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return Unknown, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	defer conn.Close()
+
+	manager := conn.Object("org.freedesktop.login1", "/org/freedesktop/login1")
+	var closed bool
+	call := manager.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.login1.Manager", "LidClosed")
+	if call.Err != nil {
+		return Unknown, fmt.Errorf("failed to read logind LidClosed: %w", call.Err)
+	}
+	var value dbus.Variant
+	if err := call.Store(&value); err != nil {
+		return Unknown, fmt.Errorf("failed to read logind LidClosed: %w", err)
+	}
+	if err := value.Store(&closed); err != nil {
+		return Unknown, fmt.Errorf("failed to read logind LidClosed: %w", err)
+	}
+	if closed {
+		return Closed, nil
+	}
+	return Open, nil
+}
+
+// None is the source of machines without a lid, or when lid handling is
+// turned off.
+type None struct{}
+
+func (None) State(ctx context.Context) (State, error) {
+	return Unknown, nil
+}
+
+// New returns the source called name. root overrides the procfs root. The
+// auto source uses procfs when a lid switch is there and logind otherwise.
+func New(name, root string) (Source, error) {
+	procfs := NewProcfs(root)
+	switch name {
+	case "", SourceAuto:
+		if procfs.Present() {
+			return procfs, nil
+		}
+		if _, err := os.Stat("/run/systemd/seats"); errors.Is(err, fs.ErrNotExist) {
+			return None{}, nil
+		}
+		return Logind{}, nil
+	case SourceProcfs:
+		return procfs, nil
+	case SourceLogind:
+		return Logind{}, nil
+	case SourceNone:
+		return None{}, nil
+	}
+	return nil, fmt.Errorf("unknown lid source %q (use auto, procfs, logind or none)", name)
+}
@@ -0,0 +1,64 @@
+package lid
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeLid(t *testing.T, root, name, state string) {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "state"), []byte("state:      "+state+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcfs(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	p := NewProcfs(root)
+
+	if p.Present() {
+		t.Error("empty root has a lid")
+	}
+	if state, err := p.State(ctx); err != nil || state != Unknown {
+		t.Errorf("no lid: %v, %v", state, err)
+	}
+
+	writeLid(t, root, "LID0", "open")
+	if state, err := p.State(ctx); err != nil || state != Open {
+		t.Errorf("open lid: %v, %v", state, err)
+	}
+
+	writeLid(t, root, "LID1", "closed")
+	if state, err := p.State(ctx); err != nil || state != Closed {
+		t.Errorf("one of two lids closed: %v, %v", state, err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	root := t.TempDir()
+	writeLid(t, root, "LID", "closed")
+
+	source, err := New(SourceAuto, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := source.(Procfs); !ok {
+		t.Errorf("auto with a lid file = %T, want Procfs", source)
+	}
+
+	for name, want := range map[string]Source{SourceLogind: Logind{}, SourceNone: None{}} {
+		if source, err := New(name, root); err != nil || source != want {
+			t.Errorf("New(%q) = %T, %v", name, source, err)
+		}
+	}
+	if _, err := New("acpi", root); err == nil {
+		t.Error("New accepted an unknown source")
+	}
+}
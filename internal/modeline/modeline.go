@@ -0,0 +1,222 @@
+package modeline
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultRate is used when a mode is requested without a refresh rate.
+const DefaultRate = 60.0
+
+// VESA CVT 1.2 constants, as used by the X server's cvt utility.
+const (
+	hGranularity = 8
+	minVPorch    = 3
+	minVBPorch   = 6
+	clockStep    = 250 // kHz
+
+	// Standard blanking.
+	minVSyncBP      = 550.0 // µs
+	hSyncPercentage = 8
+	cPrime          = 30.0 // (C-J)*K/256 + J with C=40, J=20, K=128
+	mPrime          = 300.0
+
+	// Reduced blanking.
+	rbMinVBlank = 460.0 // µs
+	rbHSync     = 32
+	rbHBlank    = 160
+)
+
+// Spec is a requested mode: "2560x1080" or "2560x1080@75".
+type Spec struct {
+	Width   int
+	Height  int
+	Rate    float64
+	Reduced bool
+}
+
+var specRegex = regexp.MustCompile(`^(\d+)x(\d+)(?:@(\d+(?:\.\d+)?)(?:Hz)?)?$`)
+
+func ParseSpec(s string) (Spec, error) {
+	matches := specRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if matches == nil {
+		return Spec{}, fmt.Errorf("invalid mode %q. Use format: WIDTHxHEIGHT[@RATE] (e.g., 2560x1080@60)", s)
+	}
+
+	width, _ := strconv.Atoi(matches[1])
+	height, _ := strconv.Atoi(matches[2])
+	rate := DefaultRate
+	if matches[3] != "" {
+		rate, _ = strconv.ParseFloat(matches[3], 64)
+	}
+
+	if width < 320 || height < 200 || width > 16384 || height > 16384 {
+		return Spec{}, fmt.Errorf("invalid mode %q: size out of range", s)
+	}
+	if rate < 20 || rate > 500 {
+		return Spec{}, fmt.Errorf("invalid mode %q: refresh rate must be between 20 and 500Hz", s)
+	}
+
+	return Spec{Width: width, Height: height, Rate: rate}, nil
+}
+
+func (s Spec) String() string {
+	return fmt.Sprintf("%dx%d@%g", s.Width, s.Height, s.Rate)
+}
+
+// Name is the mode name dmon gives generated modes, in the style of cvt:
+// "2560x1080_60.00", or "2560x1080R_60.00" with reduced blanking.
+func (s Spec) Name() string {
+	reduced := ""
+	if s.Reduced {
+		reduced = "R"
+	}
+	return fmt.Sprintf("%dx%d%s_%.2f", s.Width, s.Height, reduced, s.Rate)
+}
+
+var nameRegex = regexp.MustCompile(`^(\d+)x(\d+)(R?)_(\d+\.\d{2})$`)
+
+// ParseName recovers the spec from a generated mode name.
+func ParseName(name string) (Spec, bool) {
+	matches := nameRegex.FindStringSubmatch(name)
+	if matches == nil {
+		return Spec{}, false
+	}
+	width, _ := strconv.Atoi(matches[1])
+	height, _ := strconv.Atoi(matches[2])
+	rate, _ := strconv.ParseFloat(matches[4], 64)
+	return Spec{Width: width, Height: height, Rate: rate, Reduced: matches[3] == "R"}, true
+}
+
+// IsGenerated reports whether a mode name looks like one made by dmon or cvt.
+func IsGenerated(name string) bool {
+	_, ok := ParseName(name)
+	return ok
+}
+
+// Modeline holds X mode timings. Clock is in kHz.
+type Modeline struct {
+	Name       string
+	Clock      int
+	HDisplay   int
+	HSyncStart int
+	HSyncEnd   int
+	HTotal     int
+	VDisplay   int
+	VSyncStart int
+	VSyncEnd   int
+	VTotal     int
+	HSyncPos   bool
+	VSyncPos   bool
+}
+
+// Rate is the actual vertical refresh rate of the timings.
+func (m Modeline) Rate() float64 {
+	return float64(m.Clock) * 1000 / float64(m.HTotal*m.VTotal)
+}
+
+// Flags returns the sync polarities as xrandr expects them.
+func (m Modeline) Flags() []string {
+	hsync, vsync := "-hsync", "-vsync"
+	if m.HSyncPos {
+		hsync = "+hsync"
+	}
+	if m.VSyncPos {
+		vsync = "+vsync"
+	}
+	return []string{hsync, vsync}
+}
+
+// Args returns the arguments of `xrandr --newmode` after the mode name.
+func (m Modeline) Args() []string {
+	args := []string{strconv.FormatFloat(float64(m.Clock)/1000, 'f', 2, 64)}
+	for _, v := range []int{m.HDisplay, m.HSyncStart, m.HSyncEnd, m.HTotal, m.VDisplay, m.VSyncStart, m.VSyncEnd, m.VTotal} {
+		args = append(args, strconv.Itoa(v))
+	}
+	return append(args, m.Flags()...)
+}
+
+// String renders the timings as an xorg.conf Modeline.
+func (m Modeline) String() string {
+	return fmt.Sprintf("Modeline %q %s", m.Name, strings.Join(m.Args(), " "))
+}
+
+// CVT computes VESA Coordinated Video Timings for a spec, with reduced
+// blanking when spec.Reduced is set. Reduced blanking needs less bandwidth
+// and is what digital inputs (capture cards, KVMs, most LCDs) expect; the
+// standard timings also suit analogue inputs.
+func CVT(spec Spec) (Modeline, error) {
+	if spec.Width <= 0 || spec.Height <= 0 || spec.Rate <= 0 {
+		return Modeline{}, fmt.Errorf("invalid mode %dx%d@%g", spec.Width, spec.Height, spec.Rate)
+	}
+
+	hdisplay := spec.Width - spec.Width%hGranularity
+	vdisplay := spec.Height
+	vsync := vsyncWidth(hdisplay, vdisplay)
+
+	m := Modeline{
+		Name:       spec.Name(),
+		HDisplay:   hdisplay,
+		VDisplay:   vdisplay,
+		VSyncStart: vdisplay + minVPorch,
+	}
+	m.VSyncEnd = m.VSyncStart + vsync
+
+	if spec.Reduced {
+		hperiod := (1000000/spec.Rate - rbMinVBlank) / float64(vdisplay)
+		vblank := int(rbMinVBlank/hperiod) + 1
+		if vblank < minVPorch+vsync+minVBPorch {
+			vblank = minVPorch + vsync + minVBPorch
+		}
+
+		m.VTotal = vdisplay + vblank
+		m.HTotal = hdisplay + rbHBlank
+		m.HSyncEnd = hdisplay + rbHBlank/2
+		m.HSyncStart = m.HSyncEnd - rbHSync
+		m.Clock = int(spec.Rate * float64(m.VTotal) * float64(m.HTotal) / 1000)
+		m.HSyncPos = true
+	} else {
+		hperiod := (1000000/spec.Rate - minVSyncBP) / float64(vdisplay+minVPorch)
+		vsyncBP := int(minVSyncBP/hperiod) + 1
+		if vsyncBP < vsync+minVBPorch {
+			vsyncBP = vsync + minVBPorch
+		}
+
+		blankPercentage := math.Max(cPrime-mPrime*hperiod/1000, 20)
+		hblank := int(float64(hdisplay) * blankPercentage / (100 - blankPercentage))
+		hblank -= hblank % (2 * hGranularity)
+
+		m.VTotal = vdisplay + vsyncBP + minVPorch
+		m.HTotal = hdisplay + hblank
+		m.HSyncEnd = hdisplay + hblank/2
+		// Rounded the way the X server does it: always up to the next step.
+		m.HSyncStart = m.HSyncEnd - m.HTotal*hSyncPercentage/100
+		m.HSyncStart += hGranularity - m.HSyncStart%hGranularity
+		m.Clock = int(float64(m.HTotal) * 1000 / hperiod)
+		m.VSyncPos = true
+	}
+
+	m.Clock -= m.Clock % clockStep
+	return m, nil
+}
+
+// vsyncWidth encodes the aspect ratio in the vertical sync width, as CVT requires.
+func vsyncWidth(width, height int) int {
+	switch {
+	case height%3 == 0 && height*4/3 == width:
+		return 4
+	case height%9 == 0 && height*16/9 == width:
+		return 5
+	case height%10 == 0 && height*16/10 == width:
+		return 6
+	case height%4 == 0 && height*5/4 == width:
+		return 7
+	case height%9 == 0 && height*15/9 == width:
+		return 7
+	default:
+		return 10
+	}
+}
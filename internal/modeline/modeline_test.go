@@ -0,0 +1,83 @@
+package modeline
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// Expected values are the output of the X.Org cvt utility.
+func TestCVT(t *testing.T) {
+	tests := []struct {
+		spec Spec
+		want string
+	}{
+		{Spec{1920, 1080, 60, false}, `Modeline "1920x1080_60.00" 173.00 1920 2048 2248 2576 1080 1083 1088 1120 -hsync +vsync`},
+		{Spec{1920, 1080, 60, true}, `Modeline "1920x1080R_60.00" 138.50 1920 1968 2000 2080 1080 1083 1088 1111 +hsync -vsync`},
+		{Spec{2560, 1080, 60, false}, `Modeline "2560x1080_60.00" 230.00 2560 2720 2992 3424 1080 1083 1093 1120 -hsync +vsync`},
+		{Spec{2560, 1440, 60, true}, `Modeline "2560x1440R_60.00" 241.50 2560 2608 2640 2720 1440 1443 1448 1481 +hsync -vsync`},
+		{Spec{1280, 1024, 60, false}, `Modeline "1280x1024_60.00" 109.00 1280 1368 1496 1712 1024 1027 1034 1063 -hsync +vsync`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec.Name(), func(t *testing.T) {
+			m, err := CVT(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.String(); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+			if math.Abs(m.Rate()-tt.spec.Rate) > 0.5 {
+				t.Errorf("refresh rate %.2f too far from %g", m.Rate(), tt.spec.Rate)
+			}
+		})
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Spec
+		err   string
+	}{
+		{input: "2560x1080", want: Spec{Width: 2560, Height: 1080, Rate: 60}},
+		{input: "1920x1080@75", want: Spec{Width: 1920, Height: 1080, Rate: 75}},
+		{input: "1920x1080@59.94Hz", want: Spec{Width: 1920, Height: 1080, Rate: 59.94}},
+		{input: "1920x", err: "invalid mode"},
+		{input: "100x100", err: "size out of range"},
+		{input: "1920x1080@1000", err: "refresh rate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSpec(tt.input)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseName(t *testing.T) {
+	spec := Spec{Width: 2560, Height: 1080, Rate: 74.5, Reduced: true}
+	got, ok := ParseName(spec.Name())
+	if !ok || got != spec {
+		t.Errorf("round trip of %s gave %+v, %v", spec.Name(), got, ok)
+	}
+
+	for _, name := range []string{"1920x1080", "1920x1080i", "2560x1080_60", "custom"} {
+		if IsGenerated(name) {
+			t.Errorf("%s should not be treated as a generated mode", name)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package models
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type DisplayType int
 
@@ -20,12 +23,50 @@ func (dt DisplayType) String() string {
 	}
 }
 
+func (dt DisplayType) MarshalText() ([]byte, error) {
+	return []byte(dt.String()), nil
+}
+
+func (dt *DisplayType) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "internal":
+		*dt = Internal
+	case "external":
+		*dt = External
+	default:
+		return fmt.Errorf("invalid display type: %s (valid: internal, external)", text)
+	}
+	return nil
+}
+
+var internalPatterns = []string{"eDP", "LVDS", "DSI"}
+
+// InternalPrefix returns the panel connector prefix displayID starts with, or "".
+func InternalPrefix(displayID string) string {
+	for _, pattern := range internalPatterns {
+		if strings.HasPrefix(displayID, pattern) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// IdentifyDisplayType classifies an output by its connector name.
+func IdentifyDisplayType(displayID string) DisplayType {
+	if InternalPrefix(displayID) != "" {
+		return Internal
+	}
+	return External
+}
+
 type Mode struct {
-	Width     int
-	Height    int
-	Rate      float64
-	Current   bool
-	Preferred bool
+	Name       string `json:",omitempty"`
+	Width      int
+	Height     int
+	Rate       float64
+	Interlaced bool `json:",omitempty"`
+	Current    bool
+	Preferred  bool
 }
 
 func (m Mode) String() string {
@@ -36,7 +77,11 @@ func (m Mode) String() string {
 	if m.Preferred {
 		markers += "+"
 	}
-	return fmt.Sprintf("%dx%d@%.2fHz%s", m.Width, m.Height, m.Rate, markers)
+	scan := ""
+	if m.Interlaced {
+		scan = "i"
+	}
+	return fmt.Sprintf("%dx%d%s@%.2fHz%s", m.Width, m.Height, scan, m.Rate, markers)
 }
 
 type Display struct {
@@ -45,6 +90,24 @@ type Display struct {
 	Connected   bool
 	Modes       []Mode
 	CurrentMode *Mode
+	Primary     bool   `json:",omitempty"`
+	X           int    `json:",omitempty"`
+	Y           int    `json:",omitempty"`
+	Rotation    string `json:",omitempty"`
+	WidthMM     int
+	HeightMM    int
+	EDID        []byte            `json:",omitempty"`
+	Properties  map[string]string `json:",omitempty"`
+	// Classification says which rule decided Type; nil when only the
+	// backend's connector name guess was made.
+	Classification *Classification `json:",omitempty"`
+}
+
+// Classification records why a display is considered internal or external.
+type Classification struct {
+	// Rule names the rule that decided: config, sysfs, connector-type or name.
+	Rule   string
+	Reason string
 }
 
 func (d Display) String() string {
@@ -89,14 +152,18 @@ func (t Target) String() string {
 	}
 }
 
-type ResolutionMode int
+// ResolutionMode names how resolutions are chosen: one of the built-in
+// modes below, or a preset from the config the planner knows by name.
+type ResolutionMode string
 
 const (
-	ModePreset ResolutionMode = iota
-	ModeLow
-	ModeHighest
+	ModePreset  ResolutionMode = "preset"
+	ModeLow     ResolutionMode = "low"
+	ModeHighest ResolutionMode = "highest"
 )
 
+// ParseResolutionMode parses the built-in modes and their one-letter
+// aliases. Config presets are parsed by the planner, which knows them.
 func ParseResolutionMode(s string) (ResolutionMode, error) {
 	switch s {
 	case "preset", "p":
@@ -105,22 +172,12 @@ func ParseResolutionMode(s string) (ResolutionMode, error) {
 		return ModeLow, nil
 	case "highest", "h":
 		return ModeHighest, nil
-	default:
-		return 0, fmt.Errorf("invalid mode: %s (valid: preset/p, low/l, highest/h)", s)
 	}
+	return "", fmt.Errorf("invalid mode: %s (valid: preset/p, low/l, highest/h)", s)
 }
 
 func (rm ResolutionMode) String() string {
-	switch rm {
-	case ModePreset:
-		return "preset"
-	case ModeLow:
-		return "low"
-	case ModeHighest:
-		return "highest"
-	default:
-		return "unknown"
-	}
+	return string(rm)
 }
 
 type Position int
@@ -172,11 +229,82 @@ type DisplayConfig struct {
 	Mode             ResolutionMode
 	Position         Position
 	CustomResolution string
+	// MaxRate caps each output's refresh rate at its highest rate no
+	// higher than this; zero means no cap.
+	MaxRate float64
+	// HighestRate picks each output's highest refresh rate (under MaxRate)
+	// instead of leaving the rate to the backend.
+	HighestRate bool
+}
+
+// Monitor is a RandR logical monitor, which may span one or more outputs.
+type Monitor struct {
+	Name      string
+	Primary   bool
+	Automatic bool
+	X         int
+	Y         int
+	Width     int
+	Height    int
+	WidthMM   int
+	HeightMM  int
+	Outputs   []string
+}
+
+// ProviderCapability is a bit of a RandR provider's capability mask.
+type ProviderCapability uint32
+
+const (
+	CapSourceOutput ProviderCapability = 1 << iota
+	CapSinkOutput
+	CapSourceOffload
+	CapSinkOffload
+)
+
+var capabilityNames = []string{"Source Output", "Sink Output", "Source Offload", "Sink Offload"}
+
+// String lists the capabilities the way xrandr --listproviders does.
+func (c ProviderCapability) String() string {
+	var names []string
+	for i, name := range capabilityNames {
+		if c&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// Provider is a RandR provider, roughly one GPU. On hybrid laptops the
+// outputs of the discrete GPU only light up once it is linked as an output
+// sink of the GPU that renders the screen.
+type Provider struct {
+	Index        int
+	ID           uint32
+	Name         string
+	Capabilities ProviderCapability
+	CRTCs        int
+	Outputs      int
+	// Associated counts the providers this one is linked with, in either
+	// direction (output source or offload sink).
+	Associated int
+}
+
+// Can reports whether the provider has every capability in c.
+func (p Provider) Can(c ProviderCapability) bool {
+	return p.Capabilities&c == c
+}
+
+func (p Provider) String() string {
+	return fmt.Sprintf("%s (%#x)", p.Name, p.ID)
 }
 
 type Layout struct {
 	Displays []Display
 	Primary  string
+	Monitors []Monitor
 }
 
 type ConfiguredDisplay struct {
@@ -189,4 +317,45 @@ type ConfiguredDisplay struct {
 type ConfigResult struct {
 	Displays []ConfiguredDisplay
 	Config   DisplayConfig
+	Profile  string `json:",omitempty"`
+}
+
+// BrightnessMethod is how a display's brightness is controlled.
+type BrightnessMethod string
+
+const (
+	// BrightnessBacklight drives the panel's kernel backlight.
+	BrightnessBacklight BrightnessMethod = "backlight"
+	// BrightnessSoftware scales the output's gamma ramp.
+	BrightnessSoftware BrightnessMethod = "software"
+)
+
+type DisplayBrightness struct {
+	ID      string
+	Type    DisplayType
+	Method  BrightnessMethod
+	Device  string `json:",omitempty"`
+	Percent float64
+}
+
+// RGB holds one value per colour channel.
+type RGB struct {
+	Red   float64
+	Green float64
+	Blue  float64
+}
+
+// Color is a per-output colour correction: gamma exponents as xrandr
+// --gamma takes them, and white point multipliers from a colour temperature.
+type Color struct {
+	Gamma      RGB
+	Whitepoint RGB
+}
+
+// NeutralColor leaves the picture unchanged.
+func NeutralColor() Color {
+	return Color{
+		Gamma:      RGB{1, 1, 1},
+		Whitepoint: RGB{1, 1, 1},
+	}
 }
@@ -0,0 +1,411 @@
+package planner
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/abhishek/dmon-cli/internal/modeline"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// OutputPlan describes the desired state of a single output after a Configure call.
+type OutputPlan struct {
+	ID         string
+	Type       models.DisplayType
+	Enabled    bool
+	Primary    bool
+	Mode       string
+	Width      int
+	Height     int
+	Relation   models.Position
+	RelativeTo string
+	// Rate selects a refresh rate; zero lets the backend choose.
+	Rate float64
+	// Absolute places the output at X,Y instead of relative to another output.
+	Absolute bool
+	X        int
+	Y        int
+}
+
+// Plan is the backend-independent result of resolving a DisplayConfig against detected displays.
+type Plan struct {
+	Outputs []OutputPlan
+	Config  models.DisplayConfig
+	// Profile names the saved layout the plan was built from, if any.
+	Profile string
+}
+
+// Result converts the plan into the ConfigResult reported back to the CLI.
+func (p *Plan) Result() *models.ConfigResult {
+	configured := make([]models.ConfiguredDisplay, 0, len(p.Outputs))
+	for _, o := range p.Outputs {
+		res := ""
+		if o.Enabled {
+			res = o.Mode
+		}
+		configured = append(configured, models.ConfiguredDisplay{
+			ID:         o.ID,
+			Type:       o.Type,
+			Resolution: res,
+			Active:     o.Enabled,
+		})
+	}
+
+	return &models.ConfigResult{
+		Displays: configured,
+		Config:   p.Config,
+		Profile:  p.Profile,
+	}
+}
+
+type Planner struct {
+	logger    *logrus.Logger
+	resolvers map[models.ResolutionMode]Resolver
+}
+
+func New(logger *logrus.Logger) *Planner {
+	return &Planner{
+		logger: logger,
+		resolvers: map[models.ResolutionMode]Resolver{
+			models.ModePreset:  Closest{Internal: Size{1920, 1200}, External: Size{1920, 1080}},
+			models.ModeLow:     Closest{Internal: Size{1600, 1000}, External: Size{1280, 720}},
+			models.ModeHighest: Native{},
+		},
+	}
+}
+
+// WithResolver replaces how displays are resolved under a resolution mode,
+// or adds a mode named after a config preset.
+func (p *Planner) WithResolver(mode models.ResolutionMode, resolver Resolver) *Planner {
+	p.resolvers[mode] = resolver
+	return p
+}
+
+// ParseMode parses a built-in resolution mode, its one-letter alias, or the
+// name of a mode added with WithResolver.
+func (p *Planner) ParseMode(s string) (models.ResolutionMode, error) {
+	if mode, err := models.ParseResolutionMode(s); err == nil {
+		return mode, nil
+	}
+	if _, ok := p.resolvers[models.ResolutionMode(s)]; ok && s != "" {
+		return models.ResolutionMode(s), nil
+	}
+
+	valid := []string{"preset/p", "low/l", "highest/h"}
+	var named []string
+	for mode := range p.resolvers {
+		if _, err := models.ParseResolutionMode(string(mode)); err != nil {
+			named = append(named, string(mode))
+		}
+	}
+	sort.Strings(named)
+	return "", fmt.Errorf("invalid mode: %s (valid: %s)", s, strings.Join(append(valid, named...), ", "))
+}
+
+func (p *Planner) Plan(config models.DisplayConfig, displays []models.Display) (*Plan, error) {
+	internal, externals := CategorizeDisplays(displays)
+
+	// Without an internal display (a desktop, or a laptop whose panel is
+	// not connected) external layouts still apply; both means the external.
+	if internal == nil {
+		switch config.Target {
+		case models.TargetInternal:
+			return nil, fmt.Errorf("no internal display found")
+		case models.TargetBoth:
+			p.logger.Debug("No internal display, using the external display only")
+			config.Target = models.TargetExternal
+		}
+	}
+
+	plan := &Plan{Config: config}
+
+	switch config.Target {
+	case models.TargetInternal:
+		res := p.getResolution(internal, config.Mode, config.CustomResolution)
+		if res == "" {
+			if config.CustomResolution != "" {
+				return nil, fmt.Errorf("resolution %s not available for %s. Use 'dmon list' to see available resolutions", config.CustomResolution, internal.ID)
+			}
+			return nil, fmt.Errorf("failed to determine resolution for %s", internal.ID)
+		}
+		plan.Outputs = []OutputPlan{
+			enabled(internal, res, true),
+		}
+
+	case models.TargetExternal:
+		if len(externals) == 0 {
+			return nil, fmt.Errorf("no external displays found. Try 'dmon list' to see available displays")
+		}
+		res := p.getResolution(externals[0], config.Mode, config.CustomResolution)
+		if res == "" {
+			if config.CustomResolution != "" {
+				return nil, fmt.Errorf("resolution %s not available for %s. Use 'dmon list' to see available resolutions", config.CustomResolution, externals[0].ID)
+			}
+			return nil, fmt.Errorf("failed to determine resolution for %s", externals[0].ID)
+		}
+		plan.Outputs = []OutputPlan{
+			enabled(externals[0], res, true),
+		}
+		if internal != nil {
+			plan.Outputs = append(plan.Outputs, OutputPlan{
+				ID:   internal.ID,
+				Type: internal.Type,
+			})
+		}
+
+	case models.TargetBoth:
+		if len(externals) == 0 {
+			return nil, fmt.Errorf("no external displays found. Try 'dmon list' to see available displays")
+		}
+		internalRes := p.getResolution(internal, config.Mode, config.CustomResolution)
+		if internalRes == "" {
+			if config.CustomResolution != "" {
+				return nil, fmt.Errorf("resolution %s not available for %s. Use 'dmon list' to see available resolutions", config.CustomResolution, internal.ID)
+			}
+			return nil, fmt.Errorf("failed to determine resolution for %s", internal.ID)
+		}
+		externalRes := p.getResolution(externals[0], config.Mode, "")
+		if externalRes == "" {
+			return nil, fmt.Errorf("failed to determine resolution for %s", externals[0].ID)
+		}
+
+		pos := config.Position
+		if pos == models.PositionNone {
+			pos = models.PositionRight
+		}
+
+		internalPlan := enabled(internal, internalRes, false)
+		internalPlan.Relation = pos
+		internalPlan.RelativeTo = externals[0].ID
+
+		plan.Outputs = []OutputPlan{
+			enabled(externals[0], externalRes, true),
+			internalPlan,
+		}
+	}
+
+	if config.HighestRate || config.MaxRate > 0 {
+		for i := range plan.Outputs {
+			o := &plan.Outputs[i]
+			if !o.Enabled || modeline.IsGenerated(o.Mode) {
+				continue
+			}
+			for _, d := range displays {
+				if d.ID == o.ID {
+					o.Rate = PickRate(d.Modes, o.Width, o.Height, config.MaxRate)
+				}
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// PickRate returns the highest refresh rate of the width x height modes
+// that is no higher than maxRate (zero means any). When every rate is
+// higher, the lowest is the closest to the cap. Zero means no such modes.
+func PickRate(modes []models.Mode, width, height int, maxRate float64) float64 {
+	best, lowest := 0.0, 0.0
+	for _, m := range modes {
+		if m.Width != width || m.Height != height || m.Interlaced || m.Rate <= 0 {
+			continue
+		}
+		if lowest == 0 || m.Rate < lowest {
+			lowest = m.Rate
+		}
+		// Rates like 59.95 and 60.01 count as 60.
+		if maxRate > 0 && m.Rate > maxRate+0.5 {
+			continue
+		}
+		if m.Rate > best {
+			best = m.Rate
+		}
+	}
+	if best == 0 {
+		return lowest
+	}
+	return best
+}
+
+func enabled(display *models.Display, res string, primary bool) OutputPlan {
+	width, height, _ := ModeSize(res)
+	return OutputPlan{
+		ID:      display.ID,
+		Type:    display.Type,
+		Enabled: true,
+		Primary: primary,
+		Mode:    res,
+		Width:   width,
+		Height:  height,
+	}
+}
+
+// CategorizeDisplays splits connected displays into the internal panel and the external outputs.
+func CategorizeDisplays(displays []models.Display) (*models.Display, []*models.Display) {
+	var internal *models.Display
+	var externals []*models.Display
+
+	for i := range displays {
+		if !displays[i].Connected {
+			continue
+		}
+		if displays[i].Type == models.Internal {
+			internal = &displays[i]
+		} else {
+			externals = append(externals, &displays[i])
+		}
+	}
+
+	return internal, externals
+}
+
+func (p *Planner) getResolution(display *models.Display, mode models.ResolutionMode, customResolution string) string {
+	if customResolution != "" {
+		width, height, err := ParseResolution(customResolution)
+		if err != nil {
+			p.logger.WithError(err).Error("Invalid custom resolution format")
+			return ""
+		}
+		return p.findExactMode(display, width, height, customResolution)
+	}
+
+	resolver, ok := p.resolvers[mode]
+	if !ok {
+		resolver = Native{}
+	}
+
+	choice, err := resolver.Resolve(display)
+	if err != nil {
+		p.logger.WithError(err).WithField("display", display.ID).Error("Failed to resolve mode")
+		return ""
+	}
+	p.explain(display, mode, choice)
+
+	return choice.Mode
+}
+
+// maxExplained bounds how many ranked candidates -v prints per display.
+const maxExplained = 5
+
+// explain logs the decision, and at debug level (-v) the ranking behind it.
+func (p *Planner) explain(display *models.Display, mode models.ResolutionMode, choice Choice) {
+	fields := logrus.Fields{
+		"display": display.ID,
+		"mode":    mode,
+		"chosen":  choice.Mode,
+		"reason":  choice.Reason,
+	}
+	if choice.Target.Width > 0 {
+		fields["target"] = choice.Target.String()
+	}
+
+	if choice.Target.Width > 0 && !choice.Exact {
+		p.logger.WithFields(fields).Warn("Target resolution not available, using closest match")
+	} else {
+		p.logger.WithFields(fields).Debug("Resolved display mode")
+	}
+
+	for i, c := range choice.Ranked {
+		if i == maxExplained {
+			p.logger.WithField("display", display.ID).Debugf("... %d more candidate(s)", len(choice.Ranked)-i)
+			break
+		}
+		candidate := logrus.Fields{
+			"display":   display.ID,
+			"rank":      i + 1,
+			"mode":      c.Mode,
+			"aspect":    AspectName(c.Width, c.Height),
+			"rate":      fmt.Sprintf("%.2f", c.Rate),
+			"preferred": c.Preferred,
+		}
+		if choice.Target.Width > 0 {
+			candidate["same_aspect"] = c.SameAspect
+			candidate["pixels"] = fmt.Sprintf("%+.0f%%", c.PixelDelta*100)
+		}
+		p.logger.WithFields(candidate).Debug("Candidate mode")
+	}
+}
+
+// findExactMode returns the mode of exactly the requested size, or "" with
+// the available modes logged.
+func (p *Planner) findExactMode(display *models.Display, width, height int, customResolution string) string {
+	custom := ""
+	for _, mode := range display.Modes {
+		if mode.Width != width || mode.Height != height {
+			continue
+		}
+		// Generated modes are only reachable by name (xrandr --mode).
+		if modeline.IsGenerated(mode.Name) {
+			if custom == "" {
+				custom = mode.Name
+			}
+			continue
+		}
+		return fmt.Sprintf("%dx%d", mode.Width, mode.Height)
+	}
+	if custom != "" {
+		return custom
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"display": display.ID,
+		"target":  customResolution,
+	}).Error("Custom resolution not available")
+	p.logger.Error(FormatAvailableModes(display))
+	return ""
+}
+
+// ParseResolution parses a WIDTHxHEIGHT string. An empty string yields zero values.
+func ParseResolution(res string) (width, height int, err error) {
+	if res == "" {
+		return 0, 0, nil
+	}
+
+	parts := strings.Split(res, "x")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid resolution format '%s'. Use format: WIDTHxHEIGHT (e.g., 1920x1200)", res)
+	}
+
+	width, err = strconv.Atoi(parts[0])
+	if err != nil || width <= 0 {
+		return 0, 0, fmt.Errorf("invalid width in resolution '%s'", res)
+	}
+
+	height, err = strconv.Atoi(parts[1])
+	if err != nil || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid height in resolution '%s'", res)
+	}
+
+	return width, height, nil
+}
+
+// ModeSize returns the size of a planned mode: WIDTHxHEIGHT or the name of
+// a generated mode such as 2560x1080_60.00.
+func ModeSize(mode string) (width, height int, err error) {
+	if spec, ok := modeline.ParseName(mode); ok {
+		return spec.Width, spec.Height, nil
+	}
+	return ParseResolution(mode)
+}
+
+// FormatAvailableModes renders the mode list of a display for error and warning messages.
+func FormatAvailableModes(display *models.Display) string {
+	var lines []string
+	lines = append(lines, fmt.Sprintf("\nAvailable resolutions for %s:", display.ID))
+
+	for _, mode := range display.Modes {
+		marker := ""
+		if mode.Current {
+			marker = " (current) *"
+		} else if mode.Preferred {
+			marker = " +"
+		}
+		lines = append(lines, fmt.Sprintf("  %dx%d@%.2fHz%s",
+			mode.Width, mode.Height, mode.Rate, marker))
+	}
+
+	return strings.Join(lines, "\n")
+}
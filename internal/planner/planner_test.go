@@ -0,0 +1,111 @@
+package planner
+
+import (
+	"io"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+func TestPickRate(t *testing.T) {
+	list := []models.Mode{
+		{Width: 2560, Height: 1440, Rate: 165},
+		{Width: 2560, Height: 1440, Rate: 144},
+		{Width: 2560, Height: 1440, Rate: 59.95},
+		{Width: 1920, Height: 1080, Rate: 240},
+		{Width: 1920, Height: 1080, Rate: 120, Interlaced: true},
+	}
+	tests := []struct {
+		name          string
+		width, height int
+		maxRate       float64
+		want          float64
+	}{
+		{"highest", 2560, 1440, 0, 165},
+		{"capped", 2560, 1440, 150, 144},
+		{"near the cap", 2560, 1440, 60, 59.95},
+		{"all above the cap", 1920, 1080, 60, 240},
+		{"interlaced modes are skipped", 1920, 1080, 0, 240},
+		{"no such size", 1280, 720, 60, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PickRate(list, tt.width, tt.height, tt.maxRate); got != tt.want {
+				t.Errorf("PickRate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanRates(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	displays := []models.Display{
+		{ID: "eDP-1", Type: models.Internal, Connected: true, Modes: []models.Mode{
+			{Width: 2560, Height: 1600, Rate: 165, Preferred: true},
+			{Width: 2560, Height: 1600, Rate: 60},
+			{Width: 1920, Height: 1200, Rate: 165},
+			{Width: 1920, Height: 1200, Rate: 60},
+		}},
+		{ID: "DP-1", Type: models.External, Connected: true, Modes: []models.Mode{
+			{Width: 1920, Height: 1080, Rate: 144, Preferred: true},
+			{Width: 1920, Height: 1080, Rate: 60},
+		}},
+	}
+
+	rates := func(config models.DisplayConfig) []float64 {
+		t.Helper()
+		config.Target = models.TargetBoth
+		plan, err := New(logger).Plan(config, displays)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var list []float64
+		for _, o := range plan.Outputs {
+			list = append(list, o.Rate)
+		}
+		return list
+	}
+
+	if got := rates(models.DisplayConfig{}); got[0] != 0 || got[1] != 0 {
+		t.Errorf("rates without a policy = %v, want the backend's choice", got)
+	}
+	if got := rates(models.DisplayConfig{HighestRate: true}); got[0] != 144 || got[1] != 165 {
+		t.Errorf("highest rates = %v", got)
+	}
+	if got := rates(models.DisplayConfig{Mode: models.ModeLow, MaxRate: 60}); got[0] != 60 || got[1] != 60 {
+		t.Errorf("capped rates = %v", got)
+	}
+}
+
+func TestPlanWithoutInternal(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	displays := []models.Display{
+		{ID: "eDP-1", Type: models.Internal},
+		{ID: "DP-1", Type: models.External, Connected: true, Modes: []models.Mode{
+			{Width: 2560, Height: 1440, Rate: 60, Preferred: true},
+			{Width: 1920, Height: 1080, Rate: 60},
+		}},
+	}
+
+	for _, target := range []models.Target{models.TargetExternal, models.TargetBoth} {
+		plan, err := New(logger).Plan(models.DisplayConfig{Target: target, Mode: models.ModeHighest}, displays)
+		if err != nil {
+			t.Fatalf("%s: %v", target, err)
+		}
+		if len(plan.Outputs) != 1 || plan.Outputs[0].ID != "DP-1" || plan.Outputs[0].Mode != "2560x1440" || !plan.Outputs[0].Primary {
+			t.Errorf("%s: outputs = %+v", target, plan.Outputs)
+		}
+		if plan.Config.Target != models.TargetExternal {
+			t.Errorf("%s: planned target = %s", target, plan.Config.Target)
+		}
+	}
+
+	if _, err := New(logger).Plan(models.DisplayConfig{Target: models.TargetInternal}, displays); err == nil || err.Error() != "no internal display found" {
+		t.Errorf("internal target: %v", err)
+	}
+}
@@ -0,0 +1,288 @@
+package planner
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/abhishek/dmon-cli/internal/edid"
+	"github.com/abhishek/dmon-cli/internal/modeline"
+	"github.com/abhishek/dmon-cli/internal/models"
+)
+
+// aspectTolerance is how far apart (relatively) two aspect ratios may be and
+// still count as the same, so 1366x768 is 16:9.
+const aspectTolerance = 0.01
+
+// Size is a width and height in pixels.
+type Size struct {
+	Width  int
+	Height int
+}
+
+func (s Size) String() string {
+	return fmt.Sprintf("%dx%d", s.Width, s.Height)
+}
+
+// Resolver picks the mode a display uses under one resolution mode.
+type Resolver interface {
+	Resolve(display *models.Display) (Choice, error)
+}
+
+// Choice is a resolver's decision and why it was made.
+type Choice struct {
+	// Mode is what backends are asked to apply: WIDTHxHEIGHT, or the name of
+	// a generated mode.
+	Mode   string
+	Width  int
+	Height int
+	// Target is the size the resolver aimed for; zero when it had none.
+	Target Size
+	Exact  bool
+	Reason string
+	// Ranked lists the candidates considered, best first.
+	Ranked []Candidate
+}
+
+// Candidate is one distinct size a display offers, scored against a target.
+type Candidate struct {
+	Mode      string
+	Width     int
+	Height    int
+	Rate      float64
+	Preferred bool
+	// SameAspect and PixelDelta are relative to the target; PixelDelta is
+	// the difference in pixel count as a fraction of the target's.
+	SameAspect bool
+	PixelDelta float64
+}
+
+func (c Candidate) pixels() int {
+	return c.Width * c.Height
+}
+
+// Closest scores every mode against a target size for the display's type:
+// the same aspect ratio first, then the nearest pixel count, then the
+// preferred flag, then the highest refresh rate.
+type Closest struct {
+	Internal Size
+	External Size
+}
+
+func (c Closest) Resolve(display *models.Display) (Choice, error) {
+	target := c.External
+	if display.Type == models.Internal {
+		target = c.Internal
+	}
+	return ClosestTo(display, target)
+}
+
+// ClosestTo picks the mode of display closest to target.
+func ClosestTo(display *models.Display, target Size) (Choice, error) {
+	candidates := Candidates(display)
+	if len(candidates) == 0 {
+		return Choice{}, fmt.Errorf("%s has no modes", display.ID)
+	}
+
+	targetAspect := aspect(target.Width, target.Height)
+	targetPixels := float64(target.Width * target.Height)
+	for i := range candidates {
+		c := &candidates[i]
+		c.SameAspect = math.Abs(aspect(c.Width, c.Height)-targetAspect)/targetAspect <= aspectTolerance
+		c.PixelDelta = float64(c.pixels()-target.Width*target.Height) / targetPixels
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.SameAspect != b.SameAspect {
+			return a.SameAspect
+		}
+		if da, db := math.Abs(a.PixelDelta), math.Abs(b.PixelDelta); da != db {
+			return da < db
+		}
+		return better(a, b)
+	})
+
+	best := candidates[0]
+	choice := Choice{
+		Mode:   best.Mode,
+		Width:  best.Width,
+		Height: best.Height,
+		Target: target,
+		Exact:  best.Width == target.Width && best.Height == target.Height,
+		Ranked: candidates,
+	}
+
+	switch {
+	case choice.Exact:
+		choice.Reason = "exact match"
+	case best.SameAspect:
+		choice.Reason = fmt.Sprintf("closest %s mode, %s", AspectName(target.Width, target.Height), pixelDelta(best.PixelDelta))
+	default:
+		choice.Reason = fmt.Sprintf("no %s mode; closest pixel count, %s", AspectName(target.Width, target.Height), pixelDelta(best.PixelDelta))
+	}
+
+	return choice, nil
+}
+
+// Preset resolves each display against the target for its monitor (by EDID
+// fingerprint) or else for its type. A zero target means the largest mode.
+type Preset struct {
+	Internal Size
+	External Size
+	EDID     map[string]Size
+}
+
+func (p Preset) Resolve(display *models.Display) (Choice, error) {
+	target := p.External
+	if display.Type == models.Internal {
+		target = p.Internal
+	}
+
+	fingerprint := edid.Fingerprint(display.EDID)
+	size, byEDID := p.EDID[fingerprint]
+	if byEDID && fingerprint != "" {
+		target = size
+	}
+
+	var choice Choice
+	var err error
+	if target == (Size{}) {
+		choice, err = Native{}.Resolve(display)
+	} else {
+		choice, err = ClosestTo(display, target)
+	}
+	if err == nil && byEDID && fingerprint != "" {
+		choice.Reason += fmt.Sprintf(" (target for %s)", fingerprint)
+	}
+	return choice, err
+}
+
+// ParseTarget parses a preset target: WIDTHxHEIGHT, or "highest" for the
+// largest mode.
+func ParseTarget(s string) (Size, error) {
+	switch s {
+	case "":
+		return Size{}, fmt.Errorf("missing target (WIDTHxHEIGHT or highest)")
+	case "highest":
+		return Size{}, nil
+	}
+	width, height, err := ParseResolution(s)
+	if err != nil {
+		return Size{}, err
+	}
+	return Size{Width: width, Height: height}, nil
+}
+
+// Native picks the largest mode a display offers.
+type Native struct{}
+
+func (Native) Resolve(display *models.Display) (Choice, error) {
+	candidates := Candidates(display)
+	if len(candidates) == 0 {
+		return Choice{Mode: "auto", Reason: "no modes listed, letting the backend choose"}, nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.pixels() != b.pixels() {
+			return a.pixels() > b.pixels()
+		}
+		return better(a, b)
+	})
+
+	best := candidates[0]
+	return Choice{
+		Mode:   best.Mode,
+		Width:  best.Width,
+		Height: best.Height,
+		Reason: "largest mode",
+		Ranked: candidates,
+	}, nil
+}
+
+// better breaks ties between candidates of equal standing: preferred first,
+// then the higher refresh rate, then the larger size.
+func better(a, b Candidate) bool {
+	if a.Preferred != b.Preferred {
+		return a.Preferred
+	}
+	if a.Rate != b.Rate {
+		return a.Rate > b.Rate
+	}
+	return a.pixels() > b.pixels()
+}
+
+// Candidates groups a display's modes into one candidate per size, with the
+// best refresh rate of that size. Interlaced sizes are only offered when the
+// display has nothing else.
+func Candidates(display *models.Display) []Candidate {
+	build := func(interlaced bool) []Candidate {
+		var candidates []Candidate
+		index := map[string]int{}
+		for _, m := range display.Modes {
+			if m.Interlaced && !interlaced {
+				continue
+			}
+			name := fmt.Sprintf("%dx%d", m.Width, m.Height)
+			// Generated modes are only reachable by name (xrandr --mode).
+			if modeline.IsGenerated(m.Name) {
+				name = m.Name
+			}
+
+			i, ok := index[name]
+			if !ok {
+				i = len(candidates)
+				index[name] = i
+				candidates = append(candidates, Candidate{Mode: name, Width: m.Width, Height: m.Height})
+			}
+			candidates[i].Rate = math.Max(candidates[i].Rate, m.Rate)
+			candidates[i].Preferred = candidates[i].Preferred || m.Preferred
+		}
+		return candidates
+	}
+
+	if candidates := build(false); len(candidates) > 0 {
+		return candidates
+	}
+	return build(true)
+}
+
+func aspect(width, height int) float64 {
+	if height == 0 {
+		return 0
+	}
+	return float64(width) / float64(height)
+}
+
+var aspectNames = []struct {
+	name  string
+	ratio float64
+}{
+	{"4:3", 4.0 / 3}, {"5:4", 5.0 / 4}, {"3:2", 3.0 / 2}, {"16:10", 16.0 / 10},
+	{"16:9", 16.0 / 9}, {"21:9", 64.0 / 27}, {"32:9", 32.0 / 9},
+}
+
+// AspectName returns the common name of an aspect ratio ("16:9"), or the
+// ratio itself ("2.37:1") for unusual sizes.
+func AspectName(width, height int) string {
+	a := aspect(width, height)
+	for _, n := range aspectNames {
+		if math.Abs(a-n.ratio)/n.ratio <= aspectTolerance {
+			return n.name
+		}
+	}
+	return fmt.Sprintf("%.2f:1", a)
+}
+
+func pixelDelta(delta float64) string {
+	percent := math.Round(math.Abs(delta) * 100)
+	switch {
+	case percent == 0:
+		return "same pixel count"
+	case delta < 0:
+		return fmt.Sprintf("%g%% fewer pixels", percent)
+	default:
+		return fmt.Sprintf("%g%% more pixels", percent)
+	}
+}
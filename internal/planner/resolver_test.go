@@ -0,0 +1,279 @@
+package planner
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+func modes(specs ...string) []models.Mode {
+	var list []models.Mode
+	for _, spec := range specs {
+		m := models.Mode{Rate: 60}
+		name, flags, _ := strings.Cut(spec, " ")
+		m.Name = name
+		size := strings.TrimSuffix(name, "i")
+		if i := strings.Index(size, "_"); i >= 0 {
+			size = size[:i]
+		}
+		m.Width, m.Height, _ = ParseResolution(size)
+		m.Interlaced = strings.HasSuffix(name, "i")
+		m.Preferred = strings.Contains(flags, "+")
+		if strings.Contains(flags, "75") {
+			m.Rate = 75
+		}
+		list = append(list, m)
+	}
+	return list
+}
+
+func TestClosestTo(t *testing.T) {
+	tests := []struct {
+		name   string
+		modes  []models.Mode
+		target Size
+		want   string
+		reason string
+	}{
+		{
+			name:   "exact match",
+			modes:  modes("2560x1440 +", "1920x1080", "1280x1024"),
+			target: Size{1920, 1080},
+			want:   "1920x1080",
+			reason: "exact match",
+		},
+		{
+			name:   "same aspect ratio beats nearer pixel count",
+			modes:  modes("2560x1440 +", "1680x1050", "1600x900", "1280x1024"),
+			target: Size{1920, 1080},
+			want:   "1600x900",
+			reason: "closest 16:9 mode, 31% fewer pixels",
+		},
+		{
+			name:   "nearest pixel count without a matching aspect ratio",
+			modes:  modes("1024x768", "1280x1024 +", "800x600"),
+			target: Size{1920, 1200},
+			want:   "1280x1024",
+			reason: "no 16:10 mode; closest pixel count, 43% fewer pixels",
+		},
+		{
+			name:   "1366x768 counts as 16:9",
+			modes:  modes("1366x768 +", "1280x800", "1024x768"),
+			target: Size{1280, 720},
+			want:   "1366x768",
+		},
+		{
+			name:   "refresh rates of one size are merged",
+			modes:  modes("1280x720", "1280x720 +75", "1152x648"),
+			target: Size{1280, 720},
+			want:   "1280x720",
+		},
+		{
+			name:   "interlaced modes only as a last resort",
+			modes:  modes("1920x1080i +", "1280x720"),
+			target: Size{1920, 1080},
+			want:   "1280x720",
+		},
+		{
+			name:   "generated modes are chosen by name",
+			modes:  modes("1920x1080 +", "2560x1080_60.00"),
+			target: Size{2560, 1080},
+			want:   "2560x1080_60.00",
+			reason: "exact match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			display := &models.Display{ID: "HDMI-1", Modes: tt.modes}
+			choice, err := ClosestTo(display, tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if choice.Mode != tt.want {
+				t.Errorf("got %s, want %s (ranking %+v)", choice.Mode, tt.want, choice.Ranked)
+			}
+			if tt.reason != "" && choice.Reason != tt.reason {
+				t.Errorf("reason %q, want %q", choice.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestNative(t *testing.T) {
+	display := &models.Display{Modes: modes("1920x1080 +", "3840x2160", "2560x1440")}
+	choice, err := Native{}.Resolve(display)
+	if err != nil || choice.Mode != "3840x2160" {
+		t.Errorf("got %s, %v", choice.Mode, err)
+	}
+
+	choice, _ = Native{}.Resolve(&models.Display{})
+	if choice.Mode != "auto" {
+		t.Errorf("a display without modes should be left to the backend, got %s", choice.Mode)
+	}
+}
+
+type fixedResolver string
+
+func (f fixedResolver) Resolve(display *models.Display) (Choice, error) {
+	return Choice{Mode: string(f), Reason: "fixed"}, nil
+}
+
+func TestPlannerResolvers(t *testing.T) {
+	var logs bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&logs)
+	logger.SetLevel(logrus.DebugLevel)
+
+	displays := []models.Display{
+		{ID: "eDP-1", Type: models.Internal, Connected: true, Modes: modes("2560x1600 +", "1920x1200", "1680x1050")},
+		{ID: "HDMI-1", Type: models.External, Connected: true, Modes: modes("3440x1440 +", "2560x1080")},
+	}
+
+	plan, err := New(logger).Plan(models.DisplayConfig{Target: models.TargetBoth, Mode: models.ModePreset}, displays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Outputs[0].Mode != "2560x1080" || plan.Outputs[1].Mode != "1920x1200" {
+		t.Errorf("unexpected preset plan %+v", plan.Outputs)
+	}
+	for _, want := range []string{"Target resolution not available", "Candidate mode", "no 16:9 mode"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("verbose log should explain the choice with %q:\n%s", want, logs.String())
+		}
+	}
+
+	logger.SetOutput(io.Discard)
+	p := New(logger).WithResolver(models.ModeLow, fixedResolver("1680x1050"))
+	plan, err = p.Plan(models.DisplayConfig{Target: models.TargetInternal, Mode: models.ModeLow}, displays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Outputs[0].Mode != "1680x1050" || plan.Outputs[0].Width != 1680 {
+		t.Errorf("custom resolver was not used: %+v", plan.Outputs[0])
+	}
+}
+
+// testEDID builds a minimal valid EDID whose fingerprint is "DEL-A0EA-<serial>".
+func testEDID(serial byte) []byte {
+	e := make([]byte, 128)
+	copy(e, []byte{0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00})
+	e[8], e[9], e[10], e[11], e[12] = 0x10, 0xac, 0xea, 0xa0, serial
+	var sum byte
+	for _, b := range e[:127] {
+		sum += b
+	}
+	e[127] = -sum
+	return e
+}
+
+func TestPreset(t *testing.T) {
+	preset := Preset{
+		Internal: Size{2880, 1800},
+		EDID:     map[string]Size{"DEL-A0EA-7": {2560, 1080}},
+	}
+
+	tests := []struct {
+		name    string
+		display models.Display
+		want    string
+		reason  string
+	}{
+		{
+			name:    "type target",
+			display: models.Display{Type: models.Internal, Modes: modes("2880x1800 +", "1920x1200")},
+			want:    "2880x1800",
+		},
+		{
+			name:    "missing type target means highest",
+			display: models.Display{Type: models.External, Modes: modes("1920x1080 +", "3840x2160")},
+			want:    "3840x2160",
+			reason:  "largest mode",
+		},
+		{
+			name:    "EDID target wins",
+			display: models.Display{Type: models.External, EDID: testEDID(7), Modes: modes("3440x1440 +", "2560x1080")},
+			want:    "2560x1080",
+			reason:  "exact match (target for DEL-A0EA-7)",
+		},
+		{
+			name:    "other monitors fall back to the type target",
+			display: models.Display{Type: models.External, EDID: testEDID(8), Modes: modes("3440x1440 +", "2560x1080")},
+			want:    "3440x1440",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			choice, err := preset.Resolve(&tt.display)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if choice.Mode != tt.want {
+				t.Errorf("got %s, want %s", choice.Mode, tt.want)
+			}
+			if tt.reason != "" && choice.Reason != tt.reason {
+				t.Errorf("reason %q, want %q", choice.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestPresetModes(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	p := New(logger).
+		WithResolver("zoom", Preset{Internal: Size{1440, 900}}).
+		WithResolver(models.ModeLow, Preset{Internal: Size{1280, 800}})
+
+	for name, want := range map[string]models.ResolutionMode{
+		"zoom":    "zoom",
+		"low":     models.ModeLow,
+		"h":       models.ModeHighest,
+		"preset":  models.ModePreset,
+		"highest": models.ModeHighest,
+	} {
+		if got, err := p.ParseMode(name); err != nil || got != want {
+			t.Errorf("ParseMode(%s) = %s, %v; want %s", name, got, err, want)
+		}
+	}
+
+	// Presets belong to the planner that has them.
+	if _, err := New(logger).ParseMode("zoom"); err == nil {
+		t.Error("a planner without the preset parsed zoom")
+	}
+	_, err := p.ParseMode("4k")
+	if err == nil || err.Error() != "invalid mode: 4k (valid: preset/p, low/l, highest/h, zoom)" {
+		t.Errorf("ParseMode(4k) = %v", err)
+	}
+	if _, err := p.ParseMode(""); err == nil {
+		t.Error("empty mode parsed")
+	}
+
+	for mode, want := range map[models.ResolutionMode]string{"zoom": "1440x900", models.ModeLow: "1280x800"} {
+		plan, err := p.Plan(models.DisplayConfig{Target: models.TargetInternal, Mode: mode}, []models.Display{
+			{ID: "eDP-1", Type: models.Internal, Connected: true, Modes: modes("2880x1800 +", "1440x900", "1280x800")},
+		})
+		if err != nil || plan.Outputs[0].Mode != want {
+			t.Errorf("%s plan %+v, %v", mode, plan, err)
+		}
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	if size, err := ParseTarget("2560x1440"); err != nil || size != (Size{2560, 1440}) {
+		t.Errorf("2560x1440 = %v, %v", size, err)
+	}
+	if size, err := ParseTarget("highest"); err != nil || size != (Size{}) {
+		t.Errorf("highest = %v, %v", size, err)
+	}
+	for _, bad := range []string{"", "big", "2560"} {
+		if _, err := ParseTarget(bad); err == nil {
+			t.Errorf("ParseTarget(%q) succeeded", bad)
+		}
+	}
+}
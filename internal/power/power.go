@@ -0,0 +1,118 @@
+// Package power reads whether the machine runs on AC or on battery from
+// the kernel's power_supply class.
+package power
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// State is the power source.
+type State int
+
+const (
+	// Unknown means there is no battery (a desktop), or the supplies could
+	// not be read.
+	Unknown State = iota
+	AC
+	Battery
+)
+
+func (s State) String() string {
+	switch s {
+	case AC:
+		return "ac"
+	case Battery:
+		return "battery"
+	}
+	return "unknown"
+}
+
+// ParseState parses "ac" or "battery", as used in profile conditions.
+func ParseState(s string) (State, error) {
+	switch strings.ToLower(s) {
+	case "ac":
+		return AC, nil
+	case "battery":
+		return Battery, nil
+	}
+	return Unknown, fmt.Errorf("unknown power source %q (use ac or battery)", s)
+}
+
+// DefaultRoot is where the kernel exposes power supplies.
+const DefaultRoot = "/sys/class/power_supply"
+
+// Sysfs reads <Root>/*/type and the online or status file next to it.
+// Supplies scoped to a device, such as a wireless mouse's battery, do not
+// count.
+type Sysfs struct {
+	Root string
+}
+
+func NewSysfs(root string) Sysfs {
+	if root == "" {
+		root = DefaultRoot
+	}
+	return Sysfs{Root: root}
+}
+
+// State reports AC when any mains, USB or wireless supply is online, and
+// Battery when none is but a system battery is present. On machines whose
+// adapter the kernel does not list, a charging or full battery means AC.
+func (s Sysfs) State() (State, error) {
+	entries, err := os.ReadDir(s.Root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Unknown, nil
+	}
+	if err != nil {
+		return Unknown, fmt.Errorf("failed to read power supplies: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	adapter, battery, charging := false, false, false
+	for _, e := range entries {
+		dir := filepath.Join(s.Root, e.Name())
+		if read(dir, "scope") == "Device" {
+			continue
+		}
+		switch read(dir, "type") {
+		case "":
+			continue
+		case "Battery":
+			if read(dir, "present") == "0" {
+				continue
+			}
+			battery = true
+			switch read(dir, "status") {
+			case "Charging", "Full":
+				charging = true
+			}
+		default:
+			adapter = true
+			if read(dir, "online") == "1" {
+				return AC, nil
+			}
+		}
+	}
+
+	switch {
+	case charging && !adapter:
+		return AC, nil
+	case battery:
+		return Battery, nil
+	}
+	return Unknown, nil
+}
+
+func read(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,84 @@
+package power
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSupply(t *testing.T, root, name string, files map[string]string) {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for file, value := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(value+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSysfs(t *testing.T) {
+	tests := []struct {
+		name     string
+		supplies map[string]map[string]string
+		want     State
+	}{
+		{"no supplies", nil, Unknown},
+		{"desktop with a mouse battery", map[string]map[string]string{
+			"hidpp_battery_0": {"type": "Battery", "scope": "Device", "status": "Discharging"},
+		}, Unknown},
+		{"on AC", map[string]map[string]string{
+			"AC":   {"type": "Mains", "online": "1"},
+			"BAT0": {"type": "Battery", "present": "1", "status": "Charging"},
+		}, AC},
+		{"on battery", map[string]map[string]string{
+			"AC":   {"type": "Mains", "online": "0"},
+			"BAT0": {"type": "Battery", "present": "1", "status": "Full"},
+		}, Battery},
+		{"USB-C charger", map[string]map[string]string{
+			"ADP1":                 {"type": "Mains", "online": "0"},
+			"ucsi-source-psy-USBC": {"type": "USB", "online": "1"},
+			"BAT0":                 {"type": "Battery", "status": "Discharging"},
+		}, AC},
+		{"unlisted adapter, charging", map[string]map[string]string{
+			"BAT0": {"type": "Battery", "status": "Charging"},
+		}, AC},
+		{"unlisted adapter, discharging", map[string]map[string]string{
+			"BAT0": {"type": "Battery", "status": "Discharging"},
+		}, Battery},
+		{"battery removed", map[string]map[string]string{
+			"AC":   {"type": "Mains", "online": "0"},
+			"BAT0": {"type": "Battery", "present": "0"},
+		}, Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, files := range tt.supplies {
+				writeSupply(t, root, name, files)
+			}
+			state, err := NewSysfs(root).State()
+			if err != nil || state != tt.want {
+				t.Errorf("State = %v, %v; want %v", state, err, tt.want)
+			}
+		})
+	}
+
+	if state, err := NewSysfs(filepath.Join(t.TempDir(), "missing")).State(); err != nil || state != Unknown {
+		t.Errorf("missing root: %v, %v", state, err)
+	}
+}
+
+func TestParseState(t *testing.T) {
+	if s, err := ParseState("battery"); err != nil || s != Battery {
+		t.Errorf("battery: %v, %v", s, err)
+	}
+	if s, err := ParseState("AC"); err != nil || s != AC {
+		t.Errorf("AC: %v, %v", s, err)
+	}
+	if _, err := ParseState("solar"); err == nil {
+		t.Error("solar should be rejected")
+	}
+}
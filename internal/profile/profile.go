@@ -0,0 +1,495 @@
+// Package profile saves display layouts as TOML files and turns them back
+// into plans a backend can apply.
+package profile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/abhishek/dmon-cli/internal/dock"
+	"github.com/abhishek/dmon-cli/internal/edid"
+	"github.com/abhishek/dmon-cli/internal/i3"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/planner"
+	"github.com/abhishek/dmon-cli/internal/power"
+	"github.com/abhishek/dmon-cli/internal/split"
+)
+
+const ext = ".toml"
+
+// maxDPI bounds a profile's DPI override.
+const maxDPI = 1000
+
+// rateTolerance absorbs rounding between saved and reported refresh rates.
+const rateTolerance = 0.05
+
+var nameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Output is the saved state of one output. Outputs are matched to
+// connectors by EDID fingerprint first, so a profile survives a dock
+// renumbering DP-1-1 and DP-1-2; Name is the fallback.
+type Output struct {
+	Name    string  `toml:"name"`
+	EDID    string  `toml:"edid,omitempty"`
+	Enabled bool    `toml:"enabled"`
+	Mode    string  `toml:"mode,omitempty"`
+	Rate    float64 `toml:"rate,omitempty"`
+	X       int     `toml:"x"`
+	Y       int     `toml:"y"`
+	Primary bool    `toml:"primary,omitempty"`
+	// ICC is an ICC profile file, relative to the profile file or absolute.
+	ICC string `toml:"icc,omitempty"`
+	// Split carves the output into logical monitors, WIDTHxHEIGHT+X+Y
+	// relative to the output (see 'dmon split').
+	Split []string `toml:"split,omitempty"`
+}
+
+// Profile is a saved layout.
+type Profile struct {
+	Name    string   `toml:"-"`
+	Path    string   `toml:"-"`
+	Outputs []Output `toml:"output"`
+	// Workspaces maps internal, external, primary or an output name to the
+	// i3 workspaces that belong there ("1-5"), overriding the config's map.
+	Workspaces map[string]string `toml:"workspaces,omitempty"`
+	// DPI overrides the Xft.dpi computed from the monitors' sizes.
+	DPI int `toml:"dpi,omitzero"`
+	// When lets 'dmon auto' and 'dmon watch' pick the profile by itself.
+	When Conditions `toml:"when,omitempty"`
+}
+
+// Conditions say when a profile suits the machine's situation. Every
+// condition that is set must hold; a profile without any is only applied
+// by name.
+type Conditions struct {
+	// Power is "ac" or "battery".
+	Power string `toml:"power,omitempty"`
+	// Dock is a dock that must be attached, VENDOR:PRODUCT[:SERIAL] as
+	// 'dmon dock' prints it. With a serial it tells apart desks whose
+	// monitors are identical.
+	Dock string `toml:"dock,omitempty"`
+}
+
+// IsZero reports whether no condition is set.
+func (c Conditions) IsZero() bool {
+	return c == Conditions{}
+}
+
+// Count returns how many conditions are set; more conditions make a
+// profile more specific.
+func (c Conditions) Count() int {
+	n := 0
+	if c.Power != "" {
+		n++
+	}
+	if c.Dock != "" {
+		n++
+		// A particular dock is more specific than any dock of its model.
+		if spec, err := dock.ParseSpec(c.Dock); err == nil && spec.Serial != "" {
+			n++
+		}
+	}
+	return n
+}
+
+func (c Conditions) String() string {
+	var parts []string
+	if c.Power != "" {
+		parts = append(parts, "on "+c.Power)
+	}
+	if c.Dock != "" {
+		parts = append(parts, "with dock "+c.Dock)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Validate checks the conditions' values.
+func (c Conditions) Validate() error {
+	if c.Power != "" {
+		if _, err := power.ParseState(c.Power); err != nil {
+			return err
+		}
+	}
+	if c.Dock != "" {
+		if _, err := dock.ParseSpec(c.Dock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Store keeps profiles as <dir>/<name>.toml.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{
+		dir: dir,
+	}
+}
+
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// ValidateName rejects names that cannot be used as file names.
+func ValidateName(name string) error {
+	if !nameRegex.MatchString(name) {
+		return fmt.Errorf("invalid profile name: %q (use letters, digits, '.', '_' and '-')", name)
+	}
+	return nil
+}
+
+// List returns the names of all saved profiles.
+func (s *Store) List() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles: %w", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ext {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(e.Name(), ext))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// IsPath reports whether a profile reference is a file path rather than a
+// saved profile's name.
+func IsPath(ref string) bool {
+	return strings.ContainsRune(ref, os.PathSeparator) || filepath.Ext(ref) == ext
+}
+
+// Load reads a profile by name, or a layout file when ref is a path.
+func (s *Store) Load(ref string) (*Profile, error) {
+	path := ref
+	name := strings.TrimSuffix(filepath.Base(ref), ext)
+	if !IsPath(ref) {
+		if err := ValidateName(ref); err != nil {
+			return nil, err
+		}
+		path = filepath.Join(s.dir, ref+ext)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("profile %s not found (looked for %s)", ref, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile %s: %w", path, err)
+	}
+
+	p := &Profile{Name: name, Path: path}
+	meta, err := toml.Decode(string(data), p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse profile %s: %w", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("unknown key %q in profile %s", undecoded[0].String(), path)
+	}
+
+	return p, p.Validate()
+}
+
+// Save writes the profile to <dir>/<name>.toml.
+func (s *Store) Save(p *Profile) error {
+	if err := ValidateName(p.Name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", s.dir, err)
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(p); err != nil {
+		return fmt.Errorf("failed to encode profile %s: %w", p.Name, err)
+	}
+
+	path := filepath.Join(s.dir, p.Name+ext)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write profile %s: %w", path, err)
+	}
+	p.Path = path
+	return nil
+}
+
+// Delete removes a saved profile.
+func (s *Store) Delete(name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(s.dir, name+ext))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("profile %s not found", name)
+	}
+	return err
+}
+
+// Validate checks a profile for contradictions before it is applied.
+func (p *Profile) Validate() error {
+	if len(p.Outputs) == 0 {
+		return fmt.Errorf("profile %s has no outputs", p.Name)
+	}
+
+	enabled, primaries := 0, 0
+	seen := map[string]bool{}
+	for _, o := range p.Outputs {
+		if o.Name == "" && o.EDID == "" {
+			return fmt.Errorf("profile %s: every output needs a name or an edid", p.Name)
+		}
+		key := o.Name + "/" + o.EDID
+		if seen[key] {
+			return fmt.Errorf("profile %s: output %s is listed twice", p.Name, o.label())
+		}
+		seen[key] = true
+
+		if !o.Enabled {
+			continue
+		}
+		enabled++
+		if o.Primary {
+			primaries++
+		}
+		if o.Mode == "" {
+			return fmt.Errorf("profile %s: enabled output %s has no mode", p.Name, o.label())
+		}
+		if _, _, err := planner.ParseResolution(o.Mode); err != nil {
+			return fmt.Errorf("profile %s: output %s: %w", p.Name, o.label(), err)
+		}
+		if _, err := o.Rects(); err != nil {
+			return fmt.Errorf("profile %s: output %s: %w", p.Name, o.label(), err)
+		}
+	}
+
+	if enabled == 0 {
+		return fmt.Errorf("profile %s enables no outputs", p.Name)
+	}
+	if primaries > 1 {
+		return fmt.Errorf("profile %s has more than one primary output", p.Name)
+	}
+	if p.DPI < 0 || p.DPI > maxDPI {
+		return fmt.Errorf("profile %s: dpi %d is out of range (1-%d)", p.Name, p.DPI, maxDPI)
+	}
+	if _, err := i3.ParseAssignments(p.Workspaces); err != nil {
+		return fmt.Errorf("profile %s: %w", p.Name, err)
+	}
+	if err := p.When.Validate(); err != nil {
+		return fmt.Errorf("profile %s: when: %w", p.Name, err)
+	}
+	return nil
+}
+
+// Rects parses the output's split monitors.
+func (o Output) Rects() ([]split.Rect, error) {
+	rects := make([]split.Rect, 0, len(o.Split))
+	for _, s := range o.Split {
+		r, err := split.ParseRect(s)
+		if err != nil {
+			return nil, err
+		}
+		rects = append(rects, r)
+	}
+	return rects, nil
+}
+
+func (o Output) label() string {
+	if o.Name != "" {
+		return o.Name
+	}
+	return o.EDID
+}
+
+// Capture records the current layout of the connected displays. EDIDs are
+// only saved when the displays carry them.
+func Capture(name string, displays []models.Display) *Profile {
+	p := &Profile{Name: name}
+
+	for _, d := range displays {
+		if !d.Connected {
+			continue
+		}
+		o := Output{
+			Name: d.ID,
+			EDID: edid.Fingerprint(d.EDID),
+		}
+		if d.CurrentMode != nil {
+			o.Enabled = true
+			o.Mode = fmt.Sprintf("%dx%d", d.CurrentMode.Width, d.CurrentMode.Height)
+			o.Rate = math.Round(d.CurrentMode.Rate*100) / 100
+			o.X, o.Y = d.X, d.Y
+			o.Primary = d.Primary
+		}
+		p.Outputs = append(p.Outputs, o)
+	}
+
+	return p
+}
+
+// CaptureSplits records the split monitors of each captured output.
+func (p *Profile) CaptureSplits(displays []models.Display, monitors []models.Monitor) {
+	for i := range p.Outputs {
+		o := &p.Outputs[i]
+		for _, d := range displays {
+			if d.ID != o.Name || !o.Enabled {
+				continue
+			}
+			o.Split = nil
+			for _, r := range split.Rects(monitors, d) {
+				o.Split = append(o.Split, r.String())
+			}
+		}
+	}
+}
+
+// Match pairs each profile output with a connected display: by EDID
+// fingerprint when both sides have one, then by connector name. Outputs
+// without a display map to nil.
+func (p *Profile) Match(displays []models.Display) []*models.Display {
+	matched := make([]*models.Display, len(p.Outputs))
+	used := map[string]bool{}
+
+	fingerprints := make(map[string]string, len(displays))
+	for _, d := range displays {
+		fingerprints[d.ID] = edid.Fingerprint(d.EDID)
+	}
+
+	pass := func(match func(o Output, d models.Display) bool) {
+		for i, o := range p.Outputs {
+			if matched[i] != nil {
+				continue
+			}
+			for j := range displays {
+				d := &displays[j]
+				if !d.Connected || used[d.ID] || !match(o, *d) {
+					continue
+				}
+				matched[i] = d
+				used[d.ID] = true
+				break
+			}
+		}
+	}
+
+	pass(func(o Output, d models.Display) bool {
+		return o.EDID != "" && o.EDID == fingerprints[d.ID]
+	})
+	pass(func(o Output, d models.Display) bool {
+		// A name only matches a display that is not known to be a different monitor.
+		return o.Name == d.ID && (o.EDID == "" || fingerprints[d.ID] == "")
+	})
+
+	return matched
+}
+
+// Plan resolves the profile against detected displays. Connected displays
+// the profile does not mention are switched off, so the result is exactly
+// the saved layout.
+func (p *Profile) Plan(displays []models.Display) (*planner.Plan, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	plan := &planner.Plan{Profile: p.Name}
+	matched := p.Match(displays)
+	covered := map[string]bool{}
+
+	for i, o := range p.Outputs {
+		d := matched[i]
+		if d == nil {
+			if o.Enabled {
+				return nil, fmt.Errorf("profile %s needs %s, which is not connected", p.Name, o.label())
+			}
+			continue
+		}
+		covered[d.ID] = true
+
+		if !o.Enabled {
+			plan.Outputs = append(plan.Outputs, planner.OutputPlan{ID: d.ID, Type: d.Type})
+			continue
+		}
+
+		mode, err := findMode(d, o)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+
+		name := mode.Name
+		if name == "" {
+			name = fmt.Sprintf("%dx%d", mode.Width, mode.Height)
+		}
+
+		plan.Outputs = append(plan.Outputs, planner.OutputPlan{
+			ID:       d.ID,
+			Type:     d.Type,
+			Enabled:  true,
+			Primary:  o.Primary,
+			Mode:     name,
+			Width:    mode.Width,
+			Height:   mode.Height,
+			Rate:     o.Rate,
+			Absolute: true,
+			X:        o.X,
+			Y:        o.Y,
+		})
+	}
+
+	for _, d := range displays {
+		if d.Connected && !covered[d.ID] {
+			plan.Outputs = append(plan.Outputs, planner.OutputPlan{ID: d.ID, Type: d.Type})
+		}
+	}
+
+	return plan, nil
+}
+
+func findMode(d *models.Display, o Output) (models.Mode, error) {
+	width, height, _ := planner.ParseResolution(o.Mode)
+	for _, m := range d.Modes {
+		if m.Width != width || m.Height != height {
+			continue
+		}
+		if o.Rate > 0 && math.Abs(m.Rate-o.Rate) > rateTolerance {
+			continue
+		}
+		return m, nil
+	}
+
+	if o.Rate > 0 {
+		return models.Mode{}, fmt.Errorf("mode %s@%.2f not available for %s%s", o.Mode, o.Rate, d.ID, planner.FormatAvailableModes(d))
+	}
+	return models.Mode{}, fmt.Errorf("mode %s not available for %s%s", o.Mode, d.ID, planner.FormatAvailableModes(d))
+}
+
+// ICCPath resolves an output's ICC file relative to the profile file.
+func (p *Profile) ICCPath(o Output) string {
+	if o.ICC == "" {
+		return ""
+	}
+	path := o.ICC
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	if !filepath.IsAbs(path) && p.Path != "" {
+		path = filepath.Join(filepath.Dir(p.Path), path)
+	}
+	return path
+}
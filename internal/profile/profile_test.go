@@ -0,0 +1,275 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+)
+
+// testEDID builds a minimal valid EDID whose fingerprint is "DEL-<product>-<serial>".
+func testEDID(product uint16, serial uint32) []byte {
+	e := make([]byte, 128)
+	copy(e, []byte{0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00})
+	e[8], e[9] = 0x10, 0xac // "DEL"
+	e[10], e[11] = byte(product), byte(product>>8)
+	e[12], e[13], e[14], e[15] = byte(serial), byte(serial>>8), byte(serial>>16), byte(serial>>24)
+	var sum byte
+	for _, b := range e[:127] {
+		sum += b
+	}
+	e[127] = -sum
+	return e
+}
+
+func display(id string, edid []byte, active bool) models.Display {
+	modes := []models.Mode{
+		{Width: 2560, Height: 1440, Rate: 59.95, Preferred: true},
+		{Width: 2560, Height: 1440, Rate: 143.91},
+		{Width: 1920, Height: 1080, Rate: 60},
+	}
+	d := models.Display{
+		ID:        id,
+		Type:      models.IdentifyDisplayType(id),
+		Connected: true,
+		Modes:     modes,
+		EDID:      edid,
+	}
+	if active {
+		modes[0].Current = true
+		d.CurrentMode = &modes[0]
+	}
+	return d
+}
+
+func TestCaptureAndPlanFollowMonitorsAcrossConnectors(t *testing.T) {
+	left, right := testEDID(0xa0ea, 1), testEDID(0xa0ea, 2)
+
+	desk := []models.Display{
+		display("eDP-1", nil, false),
+		display("DP-1-1", left, true),
+		display("DP-1-2", right, true),
+	}
+	desk[1].Primary = true
+	desk[2].X = 2560
+
+	p := Capture("desk", desk)
+	if p.Outputs[0].Enabled || p.Outputs[1].EDID != "DEL-A0EA-1" || p.Outputs[2].X != 2560 {
+		t.Fatalf("unexpected capture %+v", p.Outputs)
+	}
+
+	// The dock swapped the connectors: the monitors must keep their places.
+	swapped := []models.Display{
+		display("eDP-1", nil, true),
+		display("DP-1-1", right, true),
+		display("DP-1-2", left, true),
+	}
+
+	plan, err := p.Plan(swapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	for _, o := range plan.Outputs {
+		state := "off"
+		if o.Enabled {
+			state = strings.TrimSpace(strings.Join([]string{o.Mode, map[bool]string{true: "primary"}[o.Primary]}, " "))
+		}
+		got[o.ID] = state
+		if o.ID == "DP-1-1" && o.X != 2560 {
+			t.Errorf("DP-1-1 now shows the right monitor and should be at x=2560, got %d", o.X)
+		}
+	}
+	want := map[string]string{"eDP-1": "off", "DP-1-1": "2560x1440", "DP-1-2": "2560x1440 primary"}
+	for id, w := range want {
+		if got[id] != w {
+			t.Errorf("%s: got %q, want %q", id, got[id], w)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	displays := []models.Display{
+		display("eDP-1", nil, true),
+		display("HDMI-1", nil, true),
+	}
+
+	tests := []struct {
+		name    string
+		outputs []Output
+		err     string
+		check   func(t *testing.T, p *Profile)
+	}{
+		{
+			name:    "unmentioned displays are switched off",
+			outputs: []Output{{Name: "HDMI-1", Enabled: true, Mode: "1920x1080", Primary: true}},
+			check: func(t *testing.T, p *Profile) {
+				plan, _ := p.Plan(displays)
+				if len(plan.Outputs) != 2 || plan.Outputs[1].ID != "eDP-1" || plan.Outputs[1].Enabled {
+					t.Errorf("eDP-1 should be planned off, got %+v", plan.Outputs)
+				}
+			},
+		},
+		{
+			name:    "rate picks among modes",
+			outputs: []Output{{Name: "eDP-1", Enabled: true, Mode: "2560x1440", Rate: 143.91}},
+			check: func(t *testing.T, p *Profile) {
+				plan, _ := p.Plan(displays)
+				if o := plan.Outputs[0]; o.Rate != 143.91 || !o.Absolute {
+					t.Errorf("unexpected plan %+v", o)
+				}
+			},
+		},
+		{
+			name:    "missing enabled output",
+			outputs: []Output{{Name: "DP-3", Enabled: true, Mode: "1920x1080"}},
+			err:     "needs DP-3, which is not connected",
+		},
+		{
+			name:    "missing disabled output is ignored",
+			outputs: []Output{{Name: "DP-3"}, {Name: "eDP-1", Enabled: true, Mode: "1920x1080"}},
+		},
+		{
+			name:    "unavailable rate",
+			outputs: []Output{{Name: "eDP-1", Enabled: true, Mode: "1920x1080", Rate: 144}},
+			err:     "mode 1920x1080@144.00 not available for eDP-1",
+		},
+		{
+			name:    "two primaries",
+			outputs: []Output{{Name: "eDP-1", Enabled: true, Mode: "1920x1080", Primary: true}, {Name: "HDMI-1", Enabled: true, Mode: "1920x1080", Primary: true}},
+			err:     "more than one primary",
+		},
+		{
+			name:    "nothing enabled",
+			outputs: []Output{{Name: "eDP-1"}},
+			err:     "enables no outputs",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Profile{Name: "test", Outputs: tt.outputs}
+			_, err := p.Plan(displays)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.check != nil {
+				tt.check(t, p)
+			}
+		})
+	}
+}
+
+func TestCaptureSplits(t *testing.T) {
+	displays := []models.Display{
+		display("eDP-1", nil, true),
+		display("DP-1", nil, true),
+	}
+	displays[1].X = 2560
+
+	monitors := []models.Monitor{
+		{Name: "eDP-1", Automatic: true, Width: 2560, Height: 1440, Outputs: []string{"eDP-1"}},
+		{Name: "DP-1~2", X: 3840, Width: 1280, Height: 1440},
+		{Name: "DP-1~1", X: 2560, Width: 1280, Height: 1440, Outputs: []string{"DP-1"}},
+	}
+
+	p := Capture("split", displays)
+	p.CaptureSplits(displays, monitors)
+
+	if len(p.Outputs[0].Split) != 0 {
+		t.Errorf("eDP-1 split: %v", p.Outputs[0].Split)
+	}
+	if want := []string{"1280x1440+0+0", "1280x1440+1280+0"}; !reflect.DeepEqual(p.Outputs[1].Split, want) {
+		t.Errorf("DP-1 split %v, want %v", p.Outputs[1].Split, want)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Outputs[1].Split = []string{"half"}
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), "invalid rectangle") {
+		t.Errorf("bad split accepted: %v", err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "profiles"))
+
+	if names, err := store.List(); err != nil || len(names) != 0 {
+		t.Fatalf("empty store should list nothing, got %v, %v", names, err)
+	}
+
+	p := &Profile{Name: "office", Outputs: []Output{
+		{Name: "DP-1", EDID: "DEL-A0EA-1", Enabled: true, Mode: "2560x1440", Rate: 59.95, X: 1920, Primary: true, ICC: "u2720q.icc",
+			Split: []string{"1280x1440+0+0", "1280x1440+1280+0"}},
+		{Name: "eDP-1"},
+	}, Workspaces: map[string]string{"external": "1-5", "internal": "6-10"}, DPI: 144, When: Conditions{Power: "ac", Dock: "17ef:30b4:1S40AY0090EU"}}
+	if err := store.Save(p); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := store.Load("office")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Outputs, p.Outputs) || !reflect.DeepEqual(loaded.Workspaces, p.Workspaces) || loaded.DPI != 144 || loaded.When != p.When {
+		t.Errorf("round trip changed the profile: %+v", loaded)
+	}
+	if got, want := loaded.ICCPath(loaded.Outputs[0]), filepath.Join(store.Dir(), "u2720q.icc"); got != want {
+		t.Errorf("ICC path %s, want %s", got, want)
+	}
+
+	// A layout file is loaded by path.
+	byPath, err := store.Load(loaded.Path)
+	if err != nil || byPath.Name != "office" {
+		t.Errorf("load by path: %v, %v", byPath, err)
+	}
+
+	if names, _ := store.List(); len(names) != 1 || names[0] != "office" {
+		t.Errorf("unexpected list %v", names)
+	}
+
+	bad := filepath.Join(store.Dir(), "bad.toml")
+	if err := os.WriteFile(bad, []byte("[[output]]\nname = \"DP-1\"\nenabled = true\nmode = \"1920x1080\"\nscale = 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load("bad"); err == nil || !strings.Contains(err.Error(), `unknown key "output.scale"`) {
+		t.Errorf("unknown keys should be rejected, got %v", err)
+	}
+
+	clash := filepath.Join(store.Dir(), "clash.toml")
+	if err := os.WriteFile(clash, []byte("[[output]]\nname = \"DP-1\"\nenabled = true\nmode = \"1920x1080\"\n[workspaces]\nexternal = \"1-5\"\nDP-1 = \"5\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load("clash"); err == nil || !strings.Contains(err.Error(), "workspace 5") {
+		t.Errorf("workspaces assigned twice should be rejected, got %v", err)
+	}
+
+	solar := filepath.Join(store.Dir(), "solar.toml")
+	if err := os.WriteFile(solar, []byte("[[output]]\nname = \"DP-1\"\nenabled = true\nmode = \"1920x1080\"\n[when]\npower = \"solar\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load("solar"); err == nil || !strings.Contains(err.Error(), "unknown power source") {
+		t.Errorf("bad conditions should be rejected, got %v", err)
+	}
+
+	if err := store.Delete("office"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load("office"); err == nil {
+		t.Error("deleted profile should not load")
+	}
+	if err := store.Save(&Profile{Name: "../escape"}); err == nil {
+		t.Error("names with path separators should be rejected")
+	}
+}
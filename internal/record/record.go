@@ -0,0 +1,362 @@
+package record
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abhishek/dmon-cli/internal/runner"
+	"github.com/abhishek/dmon-cli/internal/version"
+)
+
+const (
+	manifestFile = "manifest.json"
+	commandsFile = "commands.json"
+	// configFile and treesDir hold the config and the snapshot of each
+	// Tree, under its name.
+	configFile = "config.toml"
+	treesDir   = "trees"
+)
+
+// envKeys are the session facts that decide which backend and outputs dmon sees.
+var envKeys = []string{
+	"DISPLAY",
+	"XAUTHORITY",
+	"WAYLAND_DISPLAY",
+	"XDG_SESSION_TYPE",
+	"XDG_CURRENT_DESKTOP",
+	"DESKTOP_SESSION",
+}
+
+// Capture is one external command with everything needed to serve it back.
+type Capture struct {
+	Name string
+	Args []string
+	// Dir and Env are the working directory and added environment of
+	// commands that set them, like hook scripts.
+	Dir      string   `json:",omitempty"`
+	Env      []string `json:",omitempty"`
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Error    string `json:",omitempty"`
+	Duration time.Duration
+}
+
+// Manifest describes the environment a bundle was recorded in.
+type Manifest struct {
+	Version    map[string]string
+	Args       []string
+	Backend    string
+	RecordedAt time.Time
+	Env        map[string]string
+	Kernel     string `json:",omitempty"`
+	Error      string `json:",omitempty"`
+	// Config is the path of the config file, when there was one.
+	Config string `json:",omitempty"`
+	// Trees are the recorded directories' roots by name.
+	Trees map[string]string `json:",omitempty"`
+}
+
+// Bundle is the content of a --record tarball.
+type Bundle struct {
+	Manifest Manifest
+	Commands []Capture
+	// Files are the config file and the trees' files, by path in the
+	// bundle.
+	Files map[string][]byte
+}
+
+// Recorder is a runner.Runner that passes commands through and keeps a copy
+// of each invocation and its result.
+type Recorder struct {
+	next   runner.Runner
+	config string
+	trees  []Tree
+
+	mu       sync.Mutex
+	commands []Capture
+}
+
+func NewRecorder(next runner.Runner) *Recorder {
+	return &Recorder{
+		next: next,
+	}
+}
+
+// WithConfig records the config file at path in the bundle.
+func (r *Recorder) WithConfig(path string) *Recorder {
+	r.config = path
+	return r
+}
+
+// WithTrees records the files of trees in the bundle.
+func (r *Recorder) WithTrees(trees ...Tree) *Recorder {
+	r.trees = append(r.trees, trees...)
+	return r
+}
+
+func (r *Recorder) Run(ctx context.Context, name string, args ...string) (runner.Result, error) {
+	return r.RunCommand(ctx, runner.Command{Name: name, Args: args})
+}
+
+func (r *Recorder) RunCommand(ctx context.Context, cmd runner.Command) (runner.Result, error) {
+	start := time.Now()
+	result, err := runner.RunCommand(ctx, r.next, cmd)
+
+	capture := Capture{
+		Name:     cmd.Name,
+		Args:     append([]string{}, cmd.Args...),
+		Dir:      cmd.Dir,
+		Env:      append([]string(nil), cmd.Env...),
+		Stdout:   string(result.Stdout),
+		Stderr:   string(result.Stderr),
+		ExitCode: result.ExitCode,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		capture.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	r.commands = append(r.commands, capture)
+	r.mu.Unlock()
+
+	return result, err
+}
+
+// Bundle snapshots the recorded commands together with environment facts.
+func (r *Recorder) Bundle(backend string, runErr error) *Bundle {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	manifest := Manifest{
+		Version:    version.Map(),
+		Args:       os.Args,
+		Backend:    backend,
+		RecordedAt: time.Now().UTC(),
+		Env:        map[string]string{},
+	}
+	for _, key := range envKeys {
+		if value, ok := os.LookupEnv(key); ok {
+			manifest.Env[key] = value
+		}
+	}
+	if release, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		manifest.Kernel = fmt.Sprintf("%s %s/%s", strings.TrimSpace(string(release)), runtime.GOOS, runtime.GOARCH)
+	}
+	if runErr != nil {
+		manifest.Error = runErr.Error()
+	}
+
+	files := map[string][]byte{}
+	if data, err := os.ReadFile(r.config); err == nil {
+		manifest.Config = r.config
+		files[configFile] = data
+	}
+	if len(r.trees) > 0 {
+		manifest.Trees = map[string]string{}
+	}
+	for _, tree := range r.trees {
+		manifest.Trees[tree.Name] = tree.Root
+		tree.snapshot(files)
+	}
+
+	return &Bundle{
+		Manifest: manifest,
+		Commands: append([]Capture{}, r.commands...),
+		Files:    files,
+	}
+}
+
+// Write stores the bundle as a tar archive at path.
+func (b *Bundle) Write(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle %s: %w", path, err)
+	}
+	defer f.Close()
+
+	entries := []struct {
+		name  string
+		value any
+	}{
+		{manifestFile, b.Manifest},
+		{commandsFile, b.Commands},
+	}
+
+	tw := tar.NewWriter(f)
+	add := func(name string, data []byte) error {
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: b.Manifest.RecordedAt,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write bundle %s: %w", path, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("failed to write bundle %s: %w", path, err)
+		}
+		return nil
+	}
+
+	for _, entry := range entries {
+		data, err := json.MarshalIndent(entry.value, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := add(entry.name, data); err != nil {
+			return err
+		}
+	}
+
+	names := make([]string, 0, len(b.Files))
+	for name := range b.Files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if err := add(name, b.Files[name]); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// Read loads a bundle written by Write.
+func Read(path string) (*Bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle %s: %w", path, err)
+	}
+	defer f.Close()
+
+	bundle := &Bundle{Files: map[string][]byte{}}
+	found := map[string]bool{}
+
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle %s: %w", path, err)
+		}
+
+		var target any
+		switch header.Name {
+		case manifestFile:
+			target = &bundle.Manifest
+		case commandsFile:
+			target = &bundle.Commands
+		default:
+			if header.Name == configFile || strings.HasPrefix(header.Name, treesDir+"/") {
+				data, err := io.ReadAll(tr)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read bundle %s: %w", path, err)
+				}
+				bundle.Files[header.Name] = data
+			}
+			continue
+		}
+
+		if err := json.NewDecoder(tr).Decode(target); err != nil {
+			return nil, fmt.Errorf("failed to parse %s in bundle %s: %w", header.Name, path, err)
+		}
+		found[header.Name] = true
+	}
+
+	if !found[commandsFile] {
+		return nil, fmt.Errorf("bundle %s has no %s", path, commandsFile)
+	}
+
+	return bundle, nil
+}
+
+// Replayer is a runner.Runner that serves captured commands back instead of
+// running anything. Each capture is used at most once, in recorded order, so
+// a repeated `xrandr --query` sees the same sequence of states as the
+// original run.
+type Replayer struct {
+	mu       sync.Mutex
+	commands []Capture
+	used     []bool
+}
+
+func NewReplayer(bundle *Bundle) *Replayer {
+	return &Replayer{
+		commands: bundle.Commands,
+		used:     make([]bool, len(bundle.Commands)),
+	}
+}
+
+func (r *Replayer) Run(ctx context.Context, name string, args ...string) (runner.Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Fall back to reusing the last matching capture once all are consumed,
+	// so a replayed run may detect more often than the recorded one did.
+	last := -1
+	for i, c := range r.commands {
+		if c.Name != name || !slices.Equal(c.Args, args) {
+			continue
+		}
+		last = i
+		if r.used[i] {
+			continue
+		}
+		r.used[i] = true
+		return c.result()
+	}
+	if last >= 0 {
+		return r.commands[last].result()
+	}
+
+	return runner.Result{ExitCode: -1}, fmt.Errorf("command not in bundle: %s%s", runner.String(name, args...), r.closest(name))
+}
+
+// closest lists the recorded invocations of the same program, to show how a
+// replayed decision diverged from the original one.
+func (r *Replayer) closest(name string) string {
+	var recorded []string
+	for _, c := range r.commands {
+		if c.Name == name {
+			recorded = append(recorded, "  "+runner.String(c.Name, c.Args...))
+		}
+	}
+	if len(recorded) == 0 {
+		return ""
+	}
+	return "\nRecorded " + name + " commands:\n" + strings.Join(recorded, "\n")
+}
+
+func (c Capture) result() (runner.Result, error) {
+	result := runner.Result{
+		Stdout:   []byte(c.Stdout),
+		Stderr:   []byte(c.Stderr),
+		ExitCode: c.ExitCode,
+	}
+	if c.ExitCode != 0 {
+		return result, &runner.ExitError{Name: c.Name, ExitCode: c.ExitCode, Stderr: c.Stderr}
+	}
+	if c.Error != "" {
+		return result, errors.New(c.Error)
+	}
+	return result, nil
+}
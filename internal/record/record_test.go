@@ -0,0 +1,199 @@
+package record
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/runner"
+)
+
+// scripted answers each command line with the next of its outputs; a
+// command starting with "fail" exits 2.
+func scripted(outputs map[string][]string) runner.Runner {
+	return runner.Func(func(ctx context.Context, name string, args ...string) (runner.Result, error) {
+		line := runner.String(name, args...)
+		if strings.HasPrefix(line, "fail") {
+			return runner.Result{Stderr: []byte("boom\n"), ExitCode: 2}, &runner.ExitError{Name: name, ExitCode: 2, Stderr: "boom\n"}
+		}
+		queue := outputs[line]
+		if len(queue) == 0 {
+			return runner.Result{ExitCode: -1}, errors.New("not scripted: " + line)
+		}
+		outputs[line] = queue[1:]
+		return runner.Result{Stdout: []byte(queue[0])}, nil
+	})
+}
+
+func TestRecordReplay(t *testing.T) {
+	ctx := context.Background()
+	recorder := NewRecorder(scripted(map[string][]string{
+		"xrandr --query": {"first\n", "second\n"},
+		"xrdb -merge":    {""},
+	}))
+
+	for _, step := range []struct {
+		cmd runner.Command
+		ok  bool
+	}{
+		{runner.Command{Name: "xrandr", Args: []string{"--query"}}, true},
+		{runner.Command{Name: "xrdb", Args: []string{"-merge"}}, true},
+		{runner.Command{Name: "xrandr", Args: []string{"--query"}}, true},
+		{runner.Command{Name: "fail", Args: []string{"now"}}, false},
+		{runner.Command{Name: "/hooks/post-apply.d/10-bar", Dir: "/hooks/post-apply.d", Env: []string{"DMON_HOOK=post-apply"}}, false},
+	} {
+		if _, err := runner.RunCommand(ctx, recorder, step.cmd); (err == nil) != step.ok {
+			t.Fatalf("%s: err = %v", step.cmd.Name, err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "bundle.tar")
+	if err := recorder.Bundle("xrandr", errors.New("layout failed")).Write(path); err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bundle.Manifest.Backend != "xrandr" || bundle.Manifest.Error != "layout failed" || bundle.Manifest.RecordedAt.IsZero() {
+		t.Errorf("manifest = %+v", bundle.Manifest)
+	}
+	if len(bundle.Commands) != 5 {
+		t.Fatalf("recorded %d commands, want 5", len(bundle.Commands))
+	}
+	if hook := bundle.Commands[4]; hook.Dir != "/hooks/post-apply.d" || len(hook.Env) != 1 || hook.Error == "" {
+		t.Errorf("hook capture = %+v", hook)
+	}
+
+	replayer := NewReplayer(bundle)
+	for i, want := range []string{"first\n", "second\n", "second\n"} {
+		result, err := replayer.Run(ctx, "xrandr", "--query")
+		if err != nil || string(result.Stdout) != want {
+			t.Errorf("query %d = %q, %v; want %q", i+1, result.Stdout, err, want)
+		}
+	}
+
+	_, err = replayer.Run(ctx, "fail", "now")
+	var exitErr *runner.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode != 2 || exitErr.Stderr != "boom\n" {
+		t.Errorf("failed command replayed as %v", err)
+	}
+
+	_, err = replayer.Run(ctx, "xrandr", "--output", "HDMI-1", "--auto")
+	if err == nil || !strings.Contains(err.Error(), "command not in bundle: xrandr --output HDMI-1 --auto") ||
+		!strings.Contains(err.Error(), "  xrandr --query") {
+		t.Errorf("unrecorded command: %v", err)
+	}
+}
+
+func TestRecordTrees(t *testing.T) {
+	dir := t.TempDir()
+
+	// A sysfs class directory whose entry links to the device directory.
+	device := filepath.Join(dir, "devices", "card1-eDP-1")
+	if err := os.MkdirAll(filepath.Join(device, "power"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"status": "connected\n", "edid": "\x00\xff\xff\xff", "power/control": "auto\n"} {
+		if err := os.WriteFile(filepath.Join(device, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	drm := filepath.Join(dir, "drm")
+	if err := os.MkdirAll(drm, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(device, filepath.Join(drm, "card1-eDP-1")); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(config, []byte("backend = \"xrandr\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := NewRecorder(scripted(nil)).
+		WithConfig(config).
+		WithTrees(Tree{Name: "drm", Root: drm}, Tree{Name: "lid", Root: filepath.Join(dir, "no-lid")})
+	path := filepath.Join(dir, "bundle.tar")
+	if err := recorder.Bundle("xrandr", nil).Write(path); err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundle.Manifest.Config != config || bundle.Manifest.Trees["drm"] != drm || len(bundle.Manifest.Trees) != 2 {
+		t.Errorf("manifest = %+v", bundle.Manifest)
+	}
+
+	snapshot, err := bundle.Extract(filepath.Join(dir, "replay"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(snapshot.Config); err != nil || string(data) != "backend = \"xrandr\"\n" {
+		t.Errorf("config = %q, %v", data, err)
+	}
+	for name, want := range map[string]string{"status": "connected\n", "edid": "\x00\xff\xff\xff"} {
+		if data, err := os.ReadFile(filepath.Join(snapshot.Roots["drm"], "card1-eDP-1", name)); err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v", name, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(snapshot.Roots["drm"], "card1-eDP-1", "power")); err == nil {
+		t.Error("recorded a subdirectory")
+	}
+	if _, err := os.Stat(snapshot.Roots["lid"]); snapshot.Roots["lid"] == "" || err == nil {
+		t.Errorf("missing tree replayed as %q, %v", snapshot.Roots["lid"], err)
+	}
+
+	bundle.Files["../escape"] = nil
+	if _, err := bundle.Extract(filepath.Join(dir, "replay")); err == nil || !strings.Contains(err.Error(), "outside the bundle") {
+		t.Errorf("file outside the bundle: %v", err)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := Read(filepath.Join(dir, "missing.tar")); err == nil || !strings.Contains(err.Error(), "failed to open bundle") {
+		t.Errorf("missing bundle: %v", err)
+	}
+
+	empty := filepath.Join(dir, "empty.tar")
+	writeTar(t, empty, map[string]string{manifestFile: "{}"})
+	if _, err := Read(empty); err == nil || !strings.Contains(err.Error(), "has no commands.json") {
+		t.Errorf("bundle without commands: %v", err)
+	}
+
+	broken := filepath.Join(dir, "broken.tar")
+	writeTar(t, broken, map[string]string{commandsFile: "[{"})
+	if _, err := Read(broken); err == nil || !strings.Contains(err.Error(), "failed to parse commands.json") {
+		t.Errorf("broken commands: %v", err)
+	}
+}
+
+func writeTar(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,94 @@
+package record
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// maxFileSize bounds each recorded file; sysfs attributes and EDIDs are far
+// smaller.
+const maxFileSize = 64 << 10
+
+// Tree is a sysfs or procfs directory whose files decide layouts, such as
+// /sys/class/drm or /proc/acpi/button/lid. A bundle keeps the files directly
+// inside each of its entries, which is as deep as dmon reads, so a replay
+// can read the same state from the snapshot. Entries that are symlinks, as
+// most sysfs class entries are, are followed.
+type Tree struct {
+	// Name is the tree's directory in the bundle.
+	Name string
+	Root string
+}
+
+// snapshot adds the tree's files to files. Files that cannot be read, like
+// write-only attributes, are left out.
+func (t Tree) snapshot(files map[string][]byte) {
+	entries, err := os.ReadDir(t.Root)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		dir := filepath.Join(t.Root, e.Name())
+		children, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, c := range children {
+			if !c.Type().IsRegular() {
+				continue
+			}
+			if data, err := readFile(filepath.Join(dir, c.Name())); err == nil {
+				files[path.Join(treesDir, t.Name, e.Name(), c.Name())] = data
+			}
+		}
+	}
+}
+
+func readFile(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, maxFileSize))
+}
+
+// Snapshot is where Extract put a bundle's files.
+type Snapshot struct {
+	// Config is the recorded config file, or "" when there was none.
+	Config string
+	// Roots are the recorded trees by name. A tree that did not exist when
+	// recording has a root that does not exist either.
+	Roots map[string]string
+}
+
+// Extract writes the bundle's files below dir.
+func (b *Bundle) Extract(dir string) (*Snapshot, error) {
+	for name, data := range b.Files {
+		if !filepath.IsLocal(name) {
+			return nil, fmt.Errorf("bundle file %s is outside the bundle", name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, fmt.Errorf("failed to extract bundle: %w", err)
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to extract bundle: %w", err)
+		}
+	}
+
+	snapshot := &Snapshot{Roots: map[string]string{}}
+	if _, ok := b.Files[configFile]; ok {
+		snapshot.Config = filepath.Join(dir, configFile)
+	}
+	for name := range b.Manifest.Trees {
+		if !filepath.IsLocal(name) {
+			return nil, fmt.Errorf("bundle tree %s is outside the bundle", name)
+		}
+		snapshot.Roots[name] = filepath.Join(dir, treesDir, name)
+	}
+	return snapshot, nil
+}
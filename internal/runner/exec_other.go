@@ -0,0 +1,9 @@
+//go:build !unix
+
+package runner
+
+import "os/exec"
+
+// detach leaves the command as it is: without process groups only the
+// command itself is killed when it is cancelled.
+func detach(cmd *exec.Cmd) {}
@@ -0,0 +1,161 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Result is the captured outcome of an external command.
+type Result struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+}
+
+// Combined returns stdout followed by stderr, like exec.Cmd.CombinedOutput.
+func (r Result) Combined() []byte {
+	return append(append([]byte{}, r.Stdout...), r.Stderr...)
+}
+
+// Runner executes external commands. Backends take a Runner instead of
+// calling os/exec directly so commands can be faked, recorded or replayed.
+type Runner interface {
+	Run(ctx context.Context, name string, args ...string) (Result, error)
+}
+
+// ExitError reports a command that ran but exited non-zero.
+type ExitError struct {
+	Name     string
+	ExitCode int
+	Stderr   string
+}
+
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("%s exited with status %d", e.Name, e.ExitCode)
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+// Command is an external command with the settings Run leaves at their
+// defaults.
+type Command struct {
+	Name string
+	Args []string
+	// Dir is the working directory; empty means dmon's.
+	Dir string
+	// Env is added to dmon's environment.
+	Env []string
+	// Detached runs the command in its own process group, killed as a whole
+	// when ctx is done, and collects stdout and stderr interleaved in
+	// Result.Stdout, so programs it leaves running in the background neither
+	// hold it up nor survive a timeout.
+	Detached bool
+	// Tail keeps only the last Tail bytes of a detached command's output;
+	// zero keeps all of it.
+	Tail int64
+}
+
+// CommandRunner is a Runner that also takes the settings of a Command.
+type CommandRunner interface {
+	Runner
+	RunCommand(ctx context.Context, cmd Command) (Result, error)
+}
+
+// RunCommand runs cmd with r. Runners that only know Run, like fakes and
+// replays, get its name and arguments.
+func RunCommand(ctx context.Context, r Runner, cmd Command) (Result, error) {
+	if cr, ok := r.(CommandRunner); ok {
+		return cr.RunCommand(ctx, cmd)
+	}
+	return r.Run(ctx, cmd.Name, cmd.Args...)
+}
+
+// Exec runs commands with os/exec.
+type Exec struct{}
+
+func (e Exec) Run(ctx context.Context, name string, args ...string) (Result, error) {
+	return e.RunCommand(ctx, Command{Name: name, Args: args})
+}
+
+func (Exec) RunCommand(ctx context.Context, c Command) (Result, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, c.Name, c.Args...)
+	cmd.Dir = c.Dir
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	var out *os.File
+	if c.Detached {
+		// Output goes to a file rather than a pipe: a background program
+		// would keep a pipe open long after the command itself is done.
+		f, err := os.CreateTemp("", "dmon-run-*")
+		if err != nil {
+			return Result{ExitCode: -1}, fmt.Errorf("failed to capture output of %s: %w", c.Name, err)
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		out = f
+		cmd.Stdout = f
+		cmd.Stderr = f
+		detach(cmd)
+	}
+
+	err := cmd.Run()
+	result := Result{
+		Stdout: stdout.Bytes(),
+		Stderr: stderr.Bytes(),
+	}
+	if out != nil {
+		result.Stdout = tail(out, c.Tail)
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+		return result, &ExitError{Name: c.Name, ExitCode: result.ExitCode, Stderr: stderr.String()}
+	}
+	if err != nil {
+		result.ExitCode = -1
+		return result, err
+	}
+
+	return result, nil
+}
+
+// tail reads the last n bytes of f, or all of it when n is zero.
+func tail(f *os.File, n int64) []byte {
+	info, err := f.Stat()
+	if err != nil {
+		return nil
+	}
+	offset := int64(0)
+	if n > 0 {
+		offset = max(info.Size()-n, 0)
+	}
+	data, _ := io.ReadAll(io.NewSectionReader(f, offset, info.Size()-offset))
+	return data
+}
+
+// String renders a command line for logs and error messages.
+func String(name string, args ...string) string {
+	return strings.Join(append([]string{name}, args...), " ")
+}
+
+// Func adapts a plain function to the Runner interface.
+type Func func(ctx context.Context, name string, args ...string) (Result, error)
+
+func (f Func) Run(ctx context.Context, name string, args ...string) (Result, error) {
+	return f(ctx, name, args...)
+}
@@ -0,0 +1,121 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExec(t *testing.T) {
+	ctx := context.Background()
+
+	result, err := Exec{}.Run(ctx, "sh", "-c", "echo out; echo err >&2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result.Stdout) != "out\n" || string(result.Stderr) != "err\n" || result.ExitCode != 0 {
+		t.Errorf("result = %+v", result)
+	}
+	if got := string(result.Combined()); got != "out\nerr\n" {
+		t.Errorf("combined = %q", got)
+	}
+
+	result, err = Exec{}.Run(ctx, "sh", "-c", "echo bad >&2; exit 4")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode != 4 || result.ExitCode != 4 {
+		t.Fatalf("err = %v, result %+v", err, result)
+	}
+	if err.Error() != "sh exited with status 4: bad" {
+		t.Errorf("error = %q", err)
+	}
+
+	if _, err := (Exec{}).Run(ctx, "dmon-no-such-command"); err == nil || errors.As(err, &exitErr) {
+		t.Errorf("missing command: err = %v", err)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	result, err := RunCommand(ctx, Exec{}, Command{
+		Name: "sh",
+		Args: []string{"-c", `echo "$DMON_TEST $(pwd)"; echo err >&2`},
+		Dir:  dir,
+		Env:  []string{"DMON_TEST=set"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "set " + dir + "\n"
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil && resolved != dir {
+		want = "set " + resolved + "\n"
+	}
+	if string(result.Stdout) != want || string(result.Stderr) != "err\n" {
+		t.Errorf("result = %q, %q, want %q", result.Stdout, result.Stderr, want)
+	}
+
+	// Runners that only know Run get the name and arguments.
+	var got string
+	fake := Func(func(ctx context.Context, name string, args ...string) (Result, error) {
+		got = String(name, args...)
+		return Result{}, nil
+	})
+	if _, err := RunCommand(ctx, fake, Command{Name: "hook", Args: []string{"a"}, Env: []string{"X=1"}}); err != nil || got != "hook a" {
+		t.Errorf("fallback ran %q, err %v", got, err)
+	}
+}
+
+func TestRunCommandDetached(t *testing.T) {
+	ctx := context.Background()
+
+	result, err := RunCommand(ctx, Exec{}, Command{
+		Name:     "sh",
+		Args:     []string{"-c", "echo one; echo two >&2; echo three"},
+		Detached: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result.Stdout) != "one\ntwo\nthree\n" || len(result.Stderr) != 0 {
+		t.Errorf("interleaved output = %q, stderr %q", result.Stdout, result.Stderr)
+	}
+
+	result, err = RunCommand(ctx, Exec{}, Command{
+		Name:     "sh",
+		Args:     []string{"-c", "echo 0123456789"},
+		Detached: true,
+		Tail:     4,
+	})
+	if err != nil || string(result.Stdout) != "789\n" {
+		t.Errorf("tail = %q, err %v", result.Stdout, err)
+	}
+
+	// A background child keeps neither a pipe nor the command open, and
+	// cancelling kills the whole group.
+	start := time.Now()
+	result, err = RunCommand(ctx, Exec{}, Command{
+		Name:     "sh",
+		Args:     []string{"-c", "sleep 10 & echo started"},
+		Detached: true,
+	})
+	if err != nil || !strings.HasPrefix(string(result.Stdout), "started") {
+		t.Errorf("background: %q, %v", result.Stdout, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("waited %s for a background child", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if _, err := RunCommand(ctx, Exec{}, Command{Name: "sh", Args: []string{"-c", "sleep 10; sleep 10"}, Detached: true}); err == nil {
+		t.Error("cancelled command succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancel took %s", elapsed)
+	}
+}
@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WithTimeout bounds every command by d, so a wedged X server cannot hang dmon.
+func WithTimeout(next Runner, d time.Duration) Runner {
+	return Func(func(ctx context.Context, name string, args ...string) (Result, error) {
+		ctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		return next.Run(ctx, name, args...)
+	})
+}
+
+// RetryPolicy decides whether a failed command is worth running again.
+type RetryPolicy func(result Result, err error) bool
+
+// TransientCrtcFailure matches xrandr's "Configure crtc N failed", which
+// drivers report while a hotplug or modeset is still settling.
+func TransientCrtcFailure(result Result, err error) bool {
+	if err == nil {
+		return false
+	}
+	output := string(result.Combined())
+	return strings.Contains(output, "Configure crtc") && strings.Contains(output, "failed")
+}
+
+// WithRetry runs a command up to attempts times while policy reports a
+// transient failure, doubling the delay between attempts.
+func WithRetry(next Runner, attempts int, delay time.Duration, policy RetryPolicy, logger *logrus.Logger) Runner {
+	return Func(func(ctx context.Context, name string, args ...string) (Result, error) {
+		var result Result
+		var err error
+
+		for attempt := 1; attempt <= attempts; attempt++ {
+			result, err = next.Run(ctx, name, args...)
+			if attempt == attempts || !policy(result, err) {
+				return result, err
+			}
+
+			logger.WithFields(logrus.Fields{
+				"command": String(name, args...),
+				"attempt": attempt,
+				"delay":   delay,
+				"error":   err,
+			}).Warn("Transient command failure, retrying")
+
+			select {
+			case <-ctx.Done():
+				return result, err
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		return result, err
+	})
+}
+
+// WithLogging logs each command with its duration and exit code at debug level.
+func WithLogging(next Runner, logger *logrus.Logger) Runner {
+	return Func(func(ctx context.Context, name string, args ...string) (Result, error) {
+		start := time.Now()
+		result, err := next.Run(ctx, name, args...)
+
+		entry := logger.WithFields(logrus.Fields{
+			"command":  String(name, args...),
+			"duration": time.Since(start).Round(time.Millisecond),
+			"exitCode": result.ExitCode,
+			"stdout":   len(result.Stdout),
+		})
+		if err != nil {
+			entry.WithError(err).Debug("Command failed")
+		} else {
+			entry.Debug("Command finished")
+		}
+
+		return result, err
+	})
+}
@@ -0,0 +1,198 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+var errCrtc = &ExitError{Name: "xrandr", ExitCode: 1, Stderr: "xrandr: Configure crtc 0 failed"}
+
+func TestTransientCrtcFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		result Result
+		err    error
+		want   bool
+	}{
+		{
+			name:   "crtc failure on stderr",
+			result: Result{Stderr: []byte("xrandr: Configure crtc 2 failed\n"), ExitCode: 1},
+			err:    errCrtc,
+			want:   true,
+		},
+		{
+			name:   "crtc failure on stdout",
+			result: Result{Stdout: []byte("Configure crtc 0 failed"), ExitCode: 1},
+			err:    errCrtc,
+			want:   true,
+		},
+		{
+			name:   "success with matching text",
+			result: Result{Stdout: []byte("Configure crtc 0 failed")},
+		},
+		{
+			name:   "other xrandr error",
+			result: Result{Stderr: []byte("xrandr: cannot find mode 5120x2880\n"), ExitCode: 1},
+			err:    errors.New("exit status 1"),
+		},
+		{
+			name:   "crtc without failure",
+			result: Result{Stderr: []byte("Configure crtc 0 succeeded")},
+			err:    errors.New("exit status 1"),
+		},
+		{
+			name: "command not found",
+			err:  errors.New(`exec: "xrandr": executable file not found in $PATH`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TransientCrtcFailure(tt.result, tt.err); got != tt.want {
+				t.Errorf("TransientCrtcFailure = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// failing fails with a transient CRTC error until it has been called
+// failures times, then returns final.
+func failing(failures int, final error, calls *int) Runner {
+	return Func(func(ctx context.Context, name string, args ...string) (Result, error) {
+		*calls++
+		if *calls <= failures {
+			return Result{Stderr: []byte(errCrtc.Stderr), ExitCode: 1}, errCrtc
+		}
+		if final != nil {
+			return Result{ExitCode: 1}, final
+		}
+		return Result{Stdout: []byte("ok")}, nil
+	})
+}
+
+func TestWithRetry(t *testing.T) {
+	permanent := errors.New("cannot find mode")
+
+	tests := []struct {
+		name     string
+		failures int
+		final    error
+		attempts int
+		calls    int
+		wantErr  error
+	}{
+		{name: "success first time", attempts: 3, calls: 1},
+		{name: "transient then success", failures: 2, attempts: 3, calls: 3},
+		{name: "gives up after attempts", failures: 5, attempts: 3, calls: 3, wantErr: errCrtc},
+		{name: "single attempt", failures: 1, attempts: 1, calls: 1, wantErr: errCrtc},
+		{name: "stops on a permanent error", failures: 1, final: permanent, attempts: 5, calls: 2, wantErr: permanent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			r := WithRetry(failing(tt.failures, tt.final, &calls), tt.attempts, time.Millisecond, TransientCrtcFailure, testLogger())
+
+			result, err := r.Run(context.Background(), "xrandr", "--output", "HDMI-1", "--auto")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && string(result.Stdout) != "ok" {
+				t.Errorf("stdout = %q", result.Stdout)
+			}
+			if calls != tt.calls {
+				t.Errorf("ran %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestWithRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	r := WithRetry(Func(func(ctx context.Context, name string, args ...string) (Result, error) {
+		calls++
+		cancel()
+		return Result{ExitCode: 1}, errCrtc
+	}), 5, time.Hour, TransientCrtcFailure, testLogger())
+
+	if _, err := r.Run(ctx, "xrandr"); !errors.Is(err, errCrtc) {
+		t.Errorf("err = %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("ran %d times after cancel, want 1", calls)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		takes   time.Duration
+		wantErr error
+	}{
+		{name: "finishes in time", timeout: time.Second, takes: 0},
+		{name: "cancelled", timeout: 10 * time.Millisecond, takes: time.Minute, wantErr: context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deadline time.Time
+			r := WithTimeout(Func(func(ctx context.Context, name string, args ...string) (Result, error) {
+				deadline, _ = ctx.Deadline()
+				select {
+				case <-ctx.Done():
+					return Result{ExitCode: -1}, ctx.Err()
+				case <-time.After(tt.takes):
+					return Result{}, nil
+				}
+			}), tt.timeout)
+
+			start := time.Now()
+			_, err := r.Run(context.Background(), "xrandr", "--query")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if deadline.IsZero() || deadline.Before(start.Add(tt.timeout)) || deadline.After(time.Now().Add(tt.timeout)) {
+				t.Errorf("deadline %v not within %s", deadline, tt.timeout)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("took %s", elapsed)
+			}
+		})
+	}
+}
+
+// TestWrapped checks the order the xrandr backend stacks the wrappers in:
+// every retry gets its own timeout.
+func TestWrapped(t *testing.T) {
+	calls := 0
+	inner := Func(func(ctx context.Context, name string, args ...string) (Result, error) {
+		calls++
+		if calls == 1 {
+			<-ctx.Done()
+			return Result{Stderr: []byte(errCrtc.Stderr), ExitCode: 1}, errCrtc
+		}
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+		return Result{Stdout: []byte("ok")}, nil
+	})
+
+	r := WithRetry(WithLogging(WithTimeout(inner, 20*time.Millisecond), testLogger()), 2, time.Millisecond, TransientCrtcFailure, testLogger())
+	result, err := r.Run(context.Background(), "xrandr", "--auto")
+	if err != nil || string(result.Stdout) != "ok" || calls != 2 {
+		t.Errorf("result %q, err %v after %d calls", result.Stdout, err, calls)
+	}
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+
+	"github.com/abhishek/dmon-cli/internal/dpi"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/profile"
+	"github.com/sirupsen/logrus"
+)
+
+// WithDPI sets Xft.dpi with setter after every layout change the service
+// makes: the applied profile's dpi when it has one, otherwise the one
+// computed from the primary output's physical size.
+func (s *DisplayService) WithDPI(setter *dpi.Setter) *DisplayService {
+	s.dpiSetter = setter
+	return s
+}
+
+// updateDPI sets the font DPI for a new layout: the profile's own value, or
+// the one computed from the layout's primary output.
+func (s *DisplayService) updateDPI(ctx context.Context, layout *models.Layout, p *profile.Profile) {
+	value, output := dpi.Effective(layout)
+	fields := logrus.Fields{"dpi": value, "output": output}
+	if p != nil && p.DPI > 0 {
+		value = p.DPI
+		fields = logrus.Fields{"dpi": value, "profile": p.Name}
+	}
+
+	if err := s.dpiSetter.Set(ctx, value); err != nil {
+		s.logger.WithError(err).Warn("Failed to update the font DPI")
+		return
+	}
+	s.logger.WithFields(fields).Info("Updated font DPI")
+}
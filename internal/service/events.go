@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/abhishek/dmon-cli/internal/adapter"
+	"github.com/abhishek/dmon-cli/internal/events"
+	"github.com/abhishek/dmon-cli/internal/hooks"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/profile"
+)
+
+// settleDelay lets a burst of RandR notifications (one per CRTC and output
+// of a single change) finish before the layout is read.
+const settleDelay = 200 * time.Millisecond
+
+// WithEvents publishes layout events on bus: changes Watch sees, and those
+// the service makes itself, including failed ones.
+func (s *DisplayService) WithEvents(bus *events.Bus) *DisplayService {
+	s.events = bus
+	return s
+}
+
+// WithHooks runs the user's hook scripts around the layout changes the
+// service makes, and when Watch sees outputs plugged in or unplugged.
+func (s *DisplayService) WithHooks(h *hooks.Hooks) *DisplayService {
+	s.hooks = h
+	return s
+}
+
+// watching reports whether anything follows layout changes: an event bus
+// or hook scripts. Hooks are on by default, so without scripts they must not
+// cost every change two extra layout reads.
+func (s *DisplayService) watching() bool {
+	return s.events != nil || (s.hooks != nil && s.hooks.Any())
+}
+
+// currentLayout reads the current layout and classifies its displays the
+// way detect does, detecting properties when the classifier needs them.
+func (s *DisplayService) currentLayout(ctx context.Context) (*models.Layout, error) {
+	layout, err := s.backend.GetCurrentLayout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.needsProperties(layout.Displays) {
+		detected, err := s.detectWithProperties(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for i := range layout.Displays {
+			for _, d := range detected {
+				if d.ID == layout.Displays[i].ID {
+					layout.Displays[i].Type = d.Type
+					layout.Displays[i].Classification = d.Classification
+				}
+			}
+		}
+		return layout, nil
+	}
+	s.classifier.Classify(layout.Displays)
+	return layout, nil
+}
+
+// Watch publishes an event for every layout change, and runs the connect
+// and disconnect hooks, until ctx is done. It follows the backend's change
+// notifications when it has them and polls every interval otherwise.
+func (s *DisplayService) Watch(ctx context.Context, interval time.Duration) error {
+	if s.events == nil && s.hooks == nil {
+		return fmt.Errorf("no event bus or hooks to watch for")
+	}
+	if _, _, err := s.observe(ctx); err != nil {
+		return fmt.Errorf("failed to read the current layout: %w", err)
+	}
+
+	var changes <-chan struct{}
+	if notifier, ok := s.backend.(adapter.ChangeNotifier); ok {
+		var err error
+		if changes, err = notifier.Changes(ctx); err != nil {
+			s.logger.WithError(err).Warn("Change notifications unavailable, polling instead")
+			changes = nil
+		}
+	}
+
+	var ticks <-chan time.Time
+	if changes == nil {
+		s.logger.WithField("interval", interval).Info("Polling for display changes")
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	} else {
+		s.logger.Info("Watching for display changes")
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-changes:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("lost the display server connection")
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(settleDelay):
+			}
+		case <-ticks:
+		}
+
+		if _, _, err := s.observe(ctx); err != nil {
+			s.logger.WithError(err).Warn("Failed to read the current layout")
+		}
+	}
+}
+
+// observe reads the layout, publishes how it differs from the one seen
+// last, runs the hooks of outputs that came or went, and returns both
+// layouts. The first call only records.
+func (s *DisplayService) observe(ctx context.Context) (before, after *models.Layout, err error) {
+	after, err = s.currentLayout(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.eventsMu.Lock()
+	before, s.lastLayout = s.lastLayout, after
+	var changes []events.Event
+	if before != nil {
+		changes = events.Diff(before, after, time.Now())
+	}
+	if s.events != nil {
+		for _, e := range changes {
+			s.events.Publish(e)
+		}
+	}
+	s.eventsMu.Unlock()
+
+	if s.hooks != nil {
+		for _, e := range changes {
+			switch e.Type {
+			case events.Connected:
+				s.hooks.Run(ctx, hooks.OnConnect, hooks.Env(hooks.OnConnect, after, "", e.Output))
+			case events.Disconnected:
+				s.hooks.Run(ctx, hooks.OnDisconnect, hooks.Env(hooks.OnDisconnect, after, "", e.Output))
+			}
+		}
+	}
+	return before, after, nil
+}
+
+// beforeChange catches up with the layout before the service changes it,
+// so the change's events start from what was really there even when Watch
+// is polling or not running, then runs the pre-apply hooks with it.
+// Without an event bus or hooks it does nothing.
+func (s *DisplayService) beforeChange(ctx context.Context, profileName string) {
+	if !s.watching() {
+		return
+	}
+	_, current, err := s.observe(ctx)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to read the layout for events")
+	}
+	if s.hooks != nil {
+		s.hooks.Run(ctx, hooks.PreApply, hooks.Env(hooks.PreApply, current, profileName, ""))
+	}
+}
+
+// layoutChanged publishes the events of a layout change the service made:
+// what changed, then the change itself when it was a profile (p) or
+// failed. After a change that succeeded it updates the font DPI, moves i3
+// workspaces and runs the post-apply hooks with the new layout, in that
+// order so hooks that restart programs see the new DPI. Failures of these
+// steps are only logged since the layout itself has already been applied.
+// Without an event bus, hooks or post-apply steps it does nothing.
+func (s *DisplayService) layoutChanged(ctx context.Context, p *profile.Profile, changeErr error) {
+	if !s.watching() && s.workspaces == nil && s.dpiSetter == nil {
+		return
+	}
+
+	profileName := ""
+	if p != nil {
+		profileName = p.Name
+	}
+
+	before, after, err := s.observe(ctx)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to read the layout for events")
+	} else if s.events != nil {
+		switch {
+		case changeErr != nil:
+			s.events.Publish(events.Failure(changeErr, before, after, time.Now()))
+		case profileName != "":
+			s.events.Publish(events.Profile(profileName, before, after, time.Now()))
+		}
+	}
+	if changeErr != nil {
+		return
+	}
+
+	if s.dpiSetter != nil && after != nil {
+		s.updateDPI(ctx, after, p)
+	}
+	if s.workspaces != nil && after != nil {
+		s.moveWorkspaces(ctx, after, p)
+	}
+	if s.hooks != nil {
+		s.hooks.Run(ctx, hooks.PostApply, hooks.Env(hooks.PostApply, after, profileName, ""))
+	}
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/abhishek/dmon-cli/internal/adapter"
+	"github.com/abhishek/dmon-cli/internal/modeline"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/planner"
+	"github.com/sirupsen/logrus"
+)
+
+// AddMode generates CVT timings for spec, adds them to the output and, when
+// apply is set, switches the output to the new mode.
+func (s *DisplayService) AddMode(ctx context.Context, displayID string, spec modeline.Spec, apply bool) (modeline.Modeline, *models.ConfigResult, error) {
+	s.logger.WithFields(logrus.Fields{
+		"display": displayID,
+		"mode":    spec.String(),
+		"reduced": spec.Reduced,
+	}).Info("Adding custom mode")
+
+	manager, ok := s.backend.(adapter.ModeManager)
+	if !ok {
+		return modeline.Modeline{}, nil, fmt.Errorf("the display backend cannot create custom modes")
+	}
+
+	line, err := modeline.CVT(spec)
+	if err != nil {
+		return modeline.Modeline{}, nil, err
+	}
+
+	displays, err := s.detect(ctx)
+	if err != nil {
+		return line, nil, fmt.Errorf("failed to detect displays: %w", err)
+	}
+	d := findDisplay(displays, displayID)
+	if d == nil {
+		return line, nil, fmt.Errorf("display %s not found", displayID)
+	}
+	if !d.Connected {
+		return line, nil, fmt.Errorf("display %s is not connected", displayID)
+	}
+
+	if err := manager.AddMode(ctx, displayID, line); err != nil {
+		return line, nil, fmt.Errorf("failed to add mode %s to %s: %w", line.Name, displayID, err)
+	}
+	if !apply {
+		return line, nil, nil
+	}
+
+	applier, ok := s.backend.(adapter.PlanApplier)
+	if !ok {
+		return line, nil, fmt.Errorf("the display backend cannot switch %s to mode %s", displayID, line.Name)
+	}
+
+	output := planner.OutputPlan{
+		ID:       d.ID,
+		Type:     d.Type,
+		Enabled:  true,
+		Primary:  d.Primary,
+		Mode:     line.Name,
+		Width:    line.HDisplay,
+		Height:   line.VDisplay,
+		Absolute: true,
+		X:        d.X,
+		Y:        d.Y,
+	}
+	// An output that was off goes to the right of everything else.
+	if d.CurrentMode == nil {
+		output.X, output.Y = 0, 0
+		for _, other := range displays {
+			if other.CurrentMode != nil {
+				output.X = max(output.X, other.X+other.CurrentMode.Width)
+			}
+		}
+	}
+
+	result, err := applier.Apply(ctx, &planner.Plan{Outputs: []planner.OutputPlan{output}})
+	if err != nil {
+		return line, nil, err
+	}
+	return line, result, nil
+}
+
+// CleanModes removes generated modes (named like 2560x1080_60.00) that no
+// output is showing, from one output or from all of them. It returns the
+// names of the modes removed.
+func (s *DisplayService) CleanModes(ctx context.Context, displayID string) ([]string, error) {
+	s.logger.WithField("display", displayID).Info("Cleaning up custom modes")
+
+	manager, ok := s.backend.(adapter.ModeManager)
+	if !ok {
+		return nil, fmt.Errorf("the display backend cannot remove custom modes")
+	}
+
+	displays, err := s.detect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect displays: %w", err)
+	}
+	if displayID != "" && findDisplay(displays, displayID) == nil {
+		return nil, fmt.Errorf("display %s not found", displayID)
+	}
+
+	// A mode in use anywhere is kept everywhere: it may be shown again as
+	// soon as that output is reconfigured.
+	inUse := map[string]bool{}
+	attached := map[string][]string{}
+	var names []string
+	for _, d := range displays {
+		for _, m := range d.Modes {
+			if !modeline.IsGenerated(m.Name) {
+				continue
+			}
+			if m.Current {
+				inUse[m.Name] = true
+			}
+			if len(attached[m.Name]) == 0 {
+				names = append(names, m.Name)
+			}
+			if !slices.Contains(attached[m.Name], d.ID) {
+				attached[m.Name] = append(attached[m.Name], d.ID)
+			}
+		}
+	}
+
+	var removed []string
+	for _, name := range names {
+		if inUse[name] {
+			s.logger.WithField("mode", name).Debug("Keeping custom mode in use")
+			continue
+		}
+
+		remaining := len(attached[name])
+		for _, id := range attached[name] {
+			if displayID != "" && id != displayID {
+				continue
+			}
+			if err := manager.DeleteMode(ctx, id, name); err != nil {
+				return removed, fmt.Errorf("failed to delete mode %s from %s: %w", name, id, err)
+			}
+			remaining--
+		}
+
+		if remaining == 0 {
+			if err := manager.DestroyMode(ctx, name); err != nil {
+				return removed, fmt.Errorf("failed to remove mode %s: %w", name, err)
+			}
+		}
+		removed = append(removed, name)
+	}
+
+	return removed, nil
+}
+
+// ensureCustomMode creates a CVT mode for a --resolution the target display
+// does not list, so cheap capture cards and KVMs with sparse EDIDs can still
+// be driven at it. It returns the displays as detected afterwards.
+func (s *DisplayService) ensureCustomMode(ctx context.Context, displays []models.Display, target models.Target, resolution string) ([]models.Display, error) {
+	manager, ok := s.backend.(adapter.ModeManager)
+	if !ok {
+		return displays, nil
+	}
+
+	width, height, err := planner.ParseResolution(resolution)
+	if err != nil {
+		return displays, nil
+	}
+
+	// The planner applies --resolution to the internal display, except for the external target.
+	internal, externals := planner.CategorizeDisplays(displays)
+	d := internal
+	if target == models.TargetExternal && len(externals) > 0 {
+		d = externals[0]
+	}
+	if d == nil {
+		return displays, nil
+	}
+	for _, m := range d.Modes {
+		if m.Width == width && m.Height == height {
+			return displays, nil
+		}
+	}
+
+	line, err := modeline.CVT(modeline.Spec{Width: width, Height: height, Rate: modeline.DefaultRate})
+	if err != nil {
+		return displays, nil
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"display":  d.ID,
+		"modeline": line.String(),
+	}).Info("Resolution not advertised by the display, adding a CVT mode")
+
+	if err := manager.AddMode(ctx, d.ID, line); err != nil {
+		return nil, fmt.Errorf("failed to add mode %s to %s: %w", line.Name, d.ID, err)
+	}
+
+	displays, err = s.detect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect displays: %w", err)
+	}
+	return displays, nil
+}
+
+func findDisplay(displays []models.Display, displayID string) *models.Display {
+	for i := range displays {
+		if displays[i].ID == displayID {
+			return &displays[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/abhishek/dmon-cli/internal/adapter"
+	"github.com/abhishek/dmon-cli/internal/edid"
+	"github.com/abhishek/dmon-cli/internal/icc"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/profile"
+	"github.com/sirupsen/logrus"
+)
+
+// WithICC sets where ICC profiles are attached, for backends that cannot do it themselves.
+func (s *DisplayService) WithICC(assigner adapter.ICCAssigner) *DisplayService {
+	s.icc = assigner
+	return s
+}
+
+// WithICCFiles maps EDID fingerprints to ICC files that are attached after
+// every configuration, whatever connector the monitor ends up on.
+func (s *DisplayService) WithICCFiles(files map[string]string) *DisplayService {
+	s.iccFiles = files
+	return s
+}
+
+// detectWithProperties detects and classifies displays including their
+// EDID when the backend only reports it on request.
+func (s *DisplayService) detectWithProperties(ctx context.Context) ([]models.Display, error) {
+	var displays []models.Display
+	var err error
+	if detector, ok := s.backend.(adapter.PropertyDetector); ok {
+		displays, err = detector.DetectDisplayProperties(ctx)
+	} else {
+		displays, err = s.backend.DetectDisplays(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.classifier.Classify(displays)
+	return displays, nil
+}
+
+// CaptureProfile snapshots the current layout as a profile named name.
+func (s *DisplayService) CaptureProfile(ctx context.Context, name string) (*profile.Profile, error) {
+	s.logger.WithField("profile", name).Info("Capturing profile")
+
+	displays, err := s.detectWithProperties(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect displays: %w", err)
+	}
+
+	p := profile.Capture(name, displays)
+	s.captureSplits(ctx, p, displays)
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ApplyProfile puts every output where the profile says and attaches its ICC profiles.
+func (s *DisplayService) ApplyProfile(ctx context.Context, p *profile.Profile) (*models.ConfigResult, error) {
+	s.logger.WithField("profile", p.Name).Info("Applying profile")
+
+	applier, ok := s.backend.(adapter.PlanApplier)
+	if !ok {
+		return nil, fmt.Errorf("the display backend cannot apply profiles")
+	}
+
+	displays, err := s.detectWithProperties(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect displays: %w", err)
+	}
+
+	plan, err := p.Plan(displays)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, o := range plan.Outputs {
+		s.logger.WithFields(logrus.Fields{
+			"display": o.ID,
+			"enabled": o.Enabled,
+			"mode":    o.Mode,
+			"rate":    o.Rate,
+			"pos":     fmt.Sprintf("%d,%d", o.X, o.Y),
+			"primary": o.Primary,
+		}).Debug("Profile output")
+	}
+
+	s.beforeChange(ctx, p.Name)
+	result, err := applier.Apply(ctx, plan)
+	if err != nil {
+		s.layoutChanged(ctx, p, err)
+		return nil, err
+	}
+
+	s.attachICC(ctx, p, displays)
+	s.applySplits(ctx, p, displays)
+	s.layoutChanged(ctx, p, nil)
+	return result, nil
+}
+
+// attachICC attaches ICC profiles to the active displays: the profile's own
+// files first, then the fingerprint map from the config. It runs right
+// before layoutChanged and logs failures the same way.
+func (s *DisplayService) attachICC(ctx context.Context, p *profile.Profile, displays []models.Display) {
+	files := map[string]string{}
+
+	if p != nil {
+		for i, d := range p.Match(displays) {
+			if d != nil && p.Outputs[i].ICC != "" {
+				files[d.ID] = p.ICCPath(p.Outputs[i])
+			}
+		}
+	}
+
+	if len(s.iccFiles) > 0 {
+		if displays == nil {
+			var err error
+			if displays, err = s.detectWithProperties(ctx); err != nil {
+				s.logger.WithError(err).Warn("Failed to detect displays for ICC profiles")
+				return
+			}
+		}
+		for _, d := range displays {
+			if file, ok := s.iccFiles[edid.Fingerprint(d.EDID)]; ok && files[d.ID] == "" && d.Connected {
+				files[d.ID] = file
+			}
+		}
+	}
+
+	if len(files) == 0 {
+		return
+	}
+	if s.icc == nil {
+		s.logger.Warn("ICC profiles are configured but the display backend cannot attach them")
+		return
+	}
+
+	for id, file := range files {
+		data, info, err := icc.Load(file)
+		if err != nil {
+			s.logger.WithError(err).WithField("display", id).Warn("Skipping ICC profile")
+			continue
+		}
+		if err := s.icc.SetICCProfile(ctx, id, data); err != nil {
+			s.logger.WithError(err).WithField("display", id).Warn("Failed to attach ICC profile")
+			continue
+		}
+		s.logger.WithFields(logrus.Fields{
+			"display": id,
+			"file":    file,
+			"profile": info.String(),
+		}).Info("Attached ICC profile")
+	}
+}
+
+// ICCProfiles returns the parsed ICC profile attached to each display, for
+// listing. Displays without one, or whose profile cannot be read, are left out.
+func (s *DisplayService) ICCProfiles(ctx context.Context, displays []models.Display) map[string]*icc.Profile {
+	profiles := map[string]*icc.Profile{}
+	if s.icc == nil {
+		return profiles
+	}
+
+	for _, d := range displays {
+		if !d.Connected {
+			continue
+		}
+		data, err := s.icc.GetICCProfile(ctx, d.ID)
+		if err != nil {
+			s.logger.WithError(err).WithField("display", d.ID).Debug("Failed to read ICC profile")
+			continue
+		}
+		if len(data) == 0 {
+			continue
+		}
+		p, err := icc.Parse(data)
+		if err != nil {
+			s.logger.WithError(err).WithField("display", d.ID).Debug("Attached ICC profile is invalid")
+			continue
+		}
+		profiles[d.ID] = p
+	}
+
+	return profiles
+}
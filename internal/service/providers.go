@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/abhishek/dmon-cli/internal/adapter"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/planner"
+	"github.com/sirupsen/logrus"
+)
+
+// WithProviderLinking makes layout changes link GPUs first (see
+// LinkProviders), so outputs on a discrete GPU are detected.
+func (s *DisplayService) WithProviderLinking(link bool) *DisplayService {
+	s.linkProviders = link
+	return s
+}
+
+// Providers lists the RandR providers (GPUs).
+func (s *DisplayService) Providers(ctx context.Context) ([]models.Provider, error) {
+	s.logger.Info("Listing providers")
+
+	manager, ok := s.backend.(adapter.ProviderManager)
+	if !ok {
+		return nil, fmt.Errorf("the display backend does not support GPU providers")
+	}
+
+	providers, err := manager.ListProviders(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list providers: %w", err)
+	}
+	return providers, nil
+}
+
+// LinkProviders makes every GPU with outputs of its own an output sink of
+// the GPU rendering the screen, like
+// `xrandr --setprovideroutputsource <sink> <source>`. It returns the source
+// and the sinks it linked.
+func (s *DisplayService) LinkProviders(ctx context.Context) (models.Provider, []models.Provider, error) {
+	providers, err := s.Providers(ctx)
+	if err != nil {
+		return models.Provider{}, nil, err
+	}
+
+	source, sinks, ok := outputLinks(providers)
+	if !ok {
+		return source, nil, fmt.Errorf("no provider can act as an output source")
+	}
+
+	manager := s.backend.(adapter.ProviderManager)
+	var linked []models.Provider
+	for _, sink := range sinks {
+		s.logger.WithFields(logrus.Fields{
+			"sink":   sink.String(),
+			"source": source.String(),
+		}).Info("Linking GPU outputs")
+
+		if err := manager.LinkProvider(ctx, sink, source); err != nil {
+			return source, linked, fmt.Errorf("failed to link %s to %s: %w", sink, source, err)
+		}
+		linked = append(linked, sink)
+	}
+
+	return source, linked, nil
+}
+
+// outputLinks picks the provider the screen is rendered on (the first one
+// that can be an output source, normally provider 0) and the providers with
+// outputs that need it as their source. Sinks that are linked already are
+// included: Associated also counts offload links, which PRIME laptops have
+// from the start, so it cannot tell whether the outputs are linked, and
+// linking again changes nothing.
+func outputLinks(providers []models.Provider) (source models.Provider, sinks []models.Provider, ok bool) {
+	for _, p := range providers {
+		if p.Can(models.CapSourceOutput) {
+			source, ok = p, true
+			break
+		}
+	}
+	if !ok {
+		return source, nil, false
+	}
+
+	for _, p := range providers {
+		if p.ID == source.ID || !p.Can(models.CapSinkOutput) || p.Outputs == 0 {
+			continue
+		}
+		sinks = append(sinks, p)
+	}
+	return source, sinks, true
+}
+
+// autoLinkProviders links GPUs before a layout change when configured to.
+// Failures only warn: the layout may not need the discrete GPU at all.
+func (s *DisplayService) autoLinkProviders(ctx context.Context) {
+	if !s.linkProviders {
+		return
+	}
+	if _, ok := s.backend.(adapter.ProviderManager); !ok {
+		return
+	}
+	if _, _, err := s.LinkProviders(ctx); err != nil {
+		s.logger.WithError(err).Warn("Could not link GPU outputs")
+	}
+}
+
+// providerHint adds a pointer to 'dmon gpu link' to a layout error when an
+// external display was wanted, none was found and a GPU has outputs of its
+// own, unless auto-link has linked it already.
+func (s *DisplayService) providerHint(ctx context.Context, config models.DisplayConfig, displays []models.Display, err error) error {
+	if config.Target == models.TargetInternal || s.linkProviders {
+		return err
+	}
+	if _, externals := planner.CategorizeDisplays(displays); len(externals) > 0 {
+		return err
+	}
+	manager, ok := s.backend.(adapter.ProviderManager)
+	if !ok {
+		return err
+	}
+	providers, listErr := manager.ListProviders(ctx)
+	if listErr != nil {
+		return err
+	}
+
+	_, sinks, _ := outputLinks(providers)
+	if len(sinks) == 0 {
+		return err
+	}
+	names := make([]string, len(sinks))
+	for i, p := range sinks {
+		names[i] = p.Name
+	}
+	return fmt.Errorf("%w\nGPU %s has outputs of its own; if the display is connected there, run 'dmon gpu link' or set auto-link under [gpu] in the config",
+		err, strings.Join(names, ", "))
+}
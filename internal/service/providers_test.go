@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/fake"
+	"github.com/abhishek/dmon-cli/internal/models"
+)
+
+var (
+	intel  = models.Provider{ID: 0x45, Name: "Intel", Capabilities: models.CapSourceOutput | models.CapSinkOutput | models.CapSinkOffload, Outputs: 2, Associated: 1}
+	nvidia = models.Provider{ID: 0x1f1, Name: "NVIDIA-G0", Capabilities: models.CapSinkOutput | models.CapSourceOffload, Outputs: 1, Associated: 1}
+)
+
+func TestOutputLinks(t *testing.T) {
+	headless := models.Provider{ID: 0x2a0, Name: "modesetting", Capabilities: models.CapSinkOutput | models.CapSourceOffload}
+	renderOnly := models.Provider{ID: 0x2b0, Name: "AMD", Capabilities: models.CapSourceOffload, Outputs: 2}
+
+	tests := []struct {
+		name      string
+		providers []models.Provider
+		source    string
+		sinks     []string
+		ok        bool
+	}{
+		{name: "PRIME laptop with an offload association", providers: []models.Provider{intel, nvidia}, source: "Intel", sinks: []string{"NVIDIA-G0"}, ok: true},
+		{name: "sink listed first", providers: []models.Provider{nvidia, intel}, source: "Intel", sinks: []string{"NVIDIA-G0"}, ok: true},
+		{name: "single GPU", providers: []models.Provider{intel}, source: "Intel", ok: true},
+		{name: "sinks without outputs or the capability", providers: []models.Provider{intel, headless, renderOnly}, source: "Intel", ok: true},
+		{name: "no output source", providers: []models.Provider{nvidia}},
+		{name: "no providers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, sinks, ok := outputLinks(tt.providers)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && source.Name != tt.source {
+				t.Errorf("source = %s, want %s", source.Name, tt.source)
+			}
+			var names []string
+			for _, p := range sinks {
+				names = append(names, p.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.sinks, ",") {
+				t.Errorf("sinks = %v, want %v", names, tt.sinks)
+			}
+		})
+	}
+}
+
+// hybridState is the default inventory with HDMI-1 on a discrete GPU that
+// only has an offload association so far.
+func hybridState() fake.State {
+	state := fake.DefaultState()
+	state.Providers = []models.Provider{intel, nvidia}
+	state.ProviderOutputs = map[string]uint32{"HDMI-1": nvidia.ID}
+	return state
+}
+
+func TestLinkProviders(t *testing.T) {
+	ctx := context.Background()
+	s, backend := newTestService(hybridState())
+
+	if _, err := s.SetupDual(ctx, models.ModePreset); err == nil {
+		t.Fatal("dual succeeded before linking")
+	}
+
+	for i := 0; i < 2; i++ {
+		source, linked, err := s.LinkProviders(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if source.Name != "Intel" || len(linked) != 1 || linked[0].Name != "NVIDIA-G0" {
+			t.Errorf("link %d: source %s, linked %+v", i+1, source.Name, linked)
+		}
+	}
+	if sources := backend.State().OutputSources; sources[nvidia.ID] != intel.ID {
+		t.Errorf("output sources = %v", sources)
+	}
+
+	if _, err := s.SetupDual(ctx, models.ModePreset); err != nil {
+		t.Fatalf("dual after linking: %v", err)
+	}
+
+	s, _ = newTestService(fake.DefaultState())
+	if _, _, err := s.LinkProviders(ctx); err == nil || err.Error() != "no provider can act as an output source" {
+		t.Errorf("without providers: %v", err)
+	}
+}
+
+func TestAutoLinkProviders(t *testing.T) {
+	s, backend := newTestService(hybridState())
+	s.WithProviderLinking(true)
+
+	if _, err := s.SetupDual(context.Background(), models.ModePreset); err != nil {
+		t.Fatal(err)
+	}
+	if got := layout(backend); got != "eDP-1=1920x1200+1920+0 HDMI-1=1920x1080+0+0*" {
+		t.Errorf("layout = %s", got)
+	}
+}
+
+func TestProviderHint(t *testing.T) {
+	tests := []struct {
+		name  string
+		state func() fake.State
+		link  bool
+		hint  bool
+	}{
+		{name: "discrete GPU with outputs", state: hybridState, hint: true},
+		{name: "no providers", state: fake.DefaultState},
+		{name: "auto-link already tried", state: hybridState, link: true},
+		{
+			name: "no GPU with outputs",
+			state: func() fake.State {
+				state := fake.DefaultState()
+				state.Providers = []models.Provider{intel}
+				return state
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := tt.state()
+			state.Displays[1].Connected = false
+			s, _ := newTestService(state)
+			s.WithProviderLinking(tt.link)
+
+			_, err := s.SetupDual(context.Background(), models.ModePreset)
+			if err == nil || !strings.Contains(err.Error(), "no external displays found") {
+				t.Fatalf("err = %v", err)
+			}
+			hinted := strings.Contains(err.Error(), "GPU NVIDIA-G0 has outputs of its own") && strings.Contains(err.Error(), "dmon gpu link")
+			if hinted != tt.hint {
+				t.Errorf("hint = %v, want %v: %v", hinted, tt.hint, err)
+			}
+		})
+	}
+
+	// Layouts that only use the panel never get the hint.
+	s, _ := newTestService(hybridState())
+	err := s.providerHint(context.Background(), models.DisplayConfig{Target: models.TargetInternal}, nil, context.Canceled)
+	if err != context.Canceled {
+		t.Errorf("internal target: %v", err)
+	}
+}
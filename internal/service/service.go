@@ -3,44 +3,123 @@ package service
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/abhishek/dmon-cli/internal/adapter"
+	"github.com/abhishek/dmon-cli/internal/brightness"
+	"github.com/abhishek/dmon-cli/internal/classify"
+	"github.com/abhishek/dmon-cli/internal/ddc"
+	"github.com/abhishek/dmon-cli/internal/dpi"
+	"github.com/abhishek/dmon-cli/internal/events"
+	"github.com/abhishek/dmon-cli/internal/hooks"
+	"github.com/abhishek/dmon-cli/internal/i3"
 	"github.com/abhishek/dmon-cli/internal/models"
 	"github.com/sirupsen/logrus"
 )
 
 type DisplayService struct {
-	backend adapter.DisplayBackend
-	logger  *logrus.Logger
+	backend    adapter.DisplayBackend
+	backlight  *brightness.Backlight
+	ddc        *ddc.Client
+	classifier *classify.Classifier
+	icc        adapter.ICCAssigner
+	iccFiles   map[string]string
+	detailed   bool
+	// linkProviders links unlinked GPUs before layout changes.
+	linkProviders bool
+	events        *events.Bus
+	// lastLayout is the layout events were last computed against.
+	lastLayout *models.Layout
+	eventsMu   sync.Mutex
+	hooks      *hooks.Hooks
+	// dpiSetter publishes the layout's font DPI after layout changes.
+	dpiSetter *dpi.Setter
+	// workspaces moves i3 workspaces after layout changes, by
+	// workspaceRules unless a profile has its own.
+	workspaces     *i3.Manager
+	workspaceRules i3.Assignments
+	logger         *logrus.Logger
 }
 
 func New(backend adapter.DisplayBackend, logger *logrus.Logger) *DisplayService {
-	return &DisplayService{
-		backend: backend,
-		logger:  logger,
+	s := &DisplayService{
+		backend:    backend,
+		backlight:  brightness.NewBacklight(brightness.DefaultRoot),
+		ddc:        ddc.NewClient(ddc.DevOpener{}),
+		classifier: classify.Default(logger),
+		logger:     logger,
 	}
+	if assigner, ok := backend.(adapter.ICCAssigner); ok {
+		s.icc = assigner
+	}
+	return s
 }
 
-func (s *DisplayService) SetupDual(ctx context.Context, mode models.ResolutionMode) (*models.ConfigResult, error) {
-	s.logger.WithField("mode", mode).Info("Setting up dual display")
+// WithBacklight sets where internal panel brightness is read and written.
+func (s *DisplayService) WithBacklight(backlight *brightness.Backlight) *DisplayService {
+	s.backlight = backlight
+	return s
+}
+
+// WithDDC sets how external monitors are reached over DDC/CI.
+func (s *DisplayService) WithDDC(client *ddc.Client) *DisplayService {
+	s.ddc = client
+	return s
+}
+
+// WithClassifier sets the rules that decide which displays are internal.
+func (s *DisplayService) WithClassifier(classifier *classify.Classifier) *DisplayService {
+	s.classifier = classifier
+	return s
+}
 
+// WithDetailedDetection makes layout changes detect EDID and output
+// properties even when the backend only reports them on request, for
+// resolution presets that target specific monitors.
+func (s *DisplayService) WithDetailedDetection(detailed bool) *DisplayService {
+	s.detailed = detailed
+	return s
+}
+
+// detect detects and classifies displays, again with EDID and output
+// properties when the classifier cannot place a display without them.
+func (s *DisplayService) detect(ctx context.Context) ([]models.Display, error) {
 	displays, err := s.backend.DetectDisplays(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to detect displays: %w", err)
+		return nil, err
 	}
-
-	config := models.DisplayConfig{
-		Target:   models.TargetBoth,
-		Mode:     mode,
-		Position: models.PositionRight,
+	if s.needsProperties(displays) {
+		return s.detectWithProperties(ctx)
 	}
+	s.classifier.Classify(displays)
+	return displays, nil
+}
 
-	result, err := s.backend.Configure(ctx, config, displays)
-	if err != nil {
-		return nil, err
+// needsProperties reports whether displays lack properties the classifier
+// needs and the backend can detect on request.
+func (s *DisplayService) needsProperties(displays []models.Display) bool {
+	_, ok := s.backend.(adapter.PropertyDetector)
+	return ok && s.classifier.NeedsProperties(displays)
+}
+
+// detectForLayout detects displays for a layout change, after linking GPUs
+// when configured to.
+func (s *DisplayService) detectForLayout(ctx context.Context) ([]models.Display, error) {
+	s.autoLinkProviders(ctx)
+	if s.detailed {
+		return s.detectWithProperties(ctx)
 	}
+	return s.detect(ctx)
+}
 
-	return result, nil
+func (s *DisplayService) SetupDual(ctx context.Context, mode models.ResolutionMode) (*models.ConfigResult, error) {
+	s.logger.WithField("mode", mode).Info("Setting up dual display")
+
+	return s.ApplyConfig(ctx, models.DisplayConfig{
+		Target:   models.TargetBoth,
+		Mode:     mode,
+		Position: models.PositionRight,
+	})
 }
 
 func (s *DisplayService) SetDisplay(ctx context.Context, target models.Target, mode models.ResolutionMode, position models.Position, customResolution string) (*models.ConfigResult, error) {
@@ -51,52 +130,56 @@ func (s *DisplayService) SetDisplay(ctx context.Context, target models.Target, m
 		"customResolution": customResolution,
 	}).Info("Configuring display")
 
-	displays, err := s.backend.DetectDisplays(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to detect displays: %w", err)
-	}
-
-	config := models.DisplayConfig{
+	return s.ApplyConfig(ctx, models.DisplayConfig{
 		Target:           target,
 		Mode:             mode,
 		Position:         position,
 		CustomResolution: customResolution,
-	}
-
-	result, err := s.backend.Configure(ctx, config, displays)
-	if err != nil {
-		return nil, err
-	}
-
-	return result, nil
+	})
 }
 
 func (s *DisplayService) SetSingleDisplay(ctx context.Context) (*models.ConfigResult, error) {
 	s.logger.Info("Setting up single display (internal only)")
 
-	displays, err := s.backend.DetectDisplays(ctx)
+	return s.ApplyConfig(ctx, models.DisplayConfig{
+		Target:   models.TargetInternal,
+		Mode:     models.ModePreset,
+		Position: models.PositionNone,
+	})
+}
+
+// ApplyConfig detects the displays and lays them out as config says. The
+// Set* methods above are shorthands for it.
+func (s *DisplayService) ApplyConfig(ctx context.Context, config models.DisplayConfig) (*models.ConfigResult, error) {
+	displays, err := s.detectForLayout(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to detect displays: %w", err)
 	}
 
-	config := models.DisplayConfig{
-		Target:   models.TargetInternal,
-		Mode:     models.ModePreset,
-		Position: models.PositionNone,
+	if config.CustomResolution != "" {
+		displays, err = s.ensureCustomMode(ctx, displays, config.Target, config.CustomResolution)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	s.beforeChange(ctx, "")
 	result, err := s.backend.Configure(ctx, config, displays)
 	if err != nil {
-		return nil, err
+		s.layoutChanged(ctx, nil, err)
+		return nil, s.providerHint(ctx, config, displays, err)
 	}
 
+	// ICC profiles first, so post-apply hooks see the colours they are for.
+	s.attachICC(ctx, nil, nil)
+	s.layoutChanged(ctx, nil, nil)
 	return result, nil
 }
 
 func (s *DisplayService) ListDisplays(ctx context.Context) ([]models.Display, error) {
 	s.logger.Info("Listing displays")
 
-	displays, err := s.backend.DetectDisplays(ctx)
+	displays, err := s.detect(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to detect displays: %w", err)
 	}
@@ -107,7 +190,7 @@ func (s *DisplayService) ListDisplays(ctx context.Context) ([]models.Display, er
 func (s *DisplayService) DetectDisplays(ctx context.Context) ([]models.Display, error) {
 	s.logger.Info("Re-detecting displays")
 
-	displays, err := s.backend.DetectDisplays(ctx)
+	displays, err := s.detect(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to detect displays: %w", err)
 	}
@@ -119,10 +202,207 @@ func (s *DisplayService) DetectDisplays(ctx context.Context) ([]models.Display,
 func (s *DisplayService) CheckDisplays(ctx context.Context) (*models.Layout, error) {
 	s.logger.Info("Checking current display layout")
 
-	layout, err := s.backend.GetCurrentLayout(ctx)
+	layout, err := s.currentLayout(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get current layout: %w", err)
 	}
 
 	return layout, nil
 }
+
+// Brightness reports and optionally changes display brightness. Internal
+// panels use the kernel backlight; externals, and panels without one, are
+// dimmed in software by the backend. Without an explicit output a change
+// applies to the active internal panel, or to every active display when
+// there is none.
+func (s *DisplayService) Brightness(ctx context.Context, displayID string, change *brightness.Change) ([]models.DisplayBrightness, error) {
+	s.logger.WithFields(logrus.Fields{
+		"display": displayID,
+		"change":  change,
+	}).Info("Adjusting brightness")
+
+	displays, err := s.detect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect displays: %w", err)
+	}
+
+	targets, err := brightnessTargets(displays, displayID, change != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []models.DisplayBrightness
+	for _, d := range targets {
+		result, err := s.brightness(ctx, d, change)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func brightnessTargets(displays []models.Display, displayID string, changing bool) ([]models.Display, error) {
+	active, err := activeDisplays(displays, displayID)
+	if err != nil || displayID != "" || !changing {
+		return active, err
+	}
+
+	var internal []models.Display
+	for _, d := range active {
+		if d.Type == models.Internal {
+			internal = append(internal, d)
+		}
+	}
+	if len(internal) > 0 {
+		return internal, nil
+	}
+	return active, nil
+}
+
+// activeDisplays returns the named display, or every active one when displayID is empty.
+func activeDisplays(displays []models.Display, displayID string) ([]models.Display, error) {
+	var active []models.Display
+	for _, d := range displays {
+		if !d.Connected || d.CurrentMode == nil {
+			if d.ID == displayID {
+				return nil, fmt.Errorf("display %s is not active", displayID)
+			}
+			continue
+		}
+		if d.ID == displayID {
+			return []models.Display{d}, nil
+		}
+		active = append(active, d)
+	}
+
+	if displayID != "" {
+		return nil, fmt.Errorf("display %s not found", displayID)
+	}
+	if len(active) == 0 {
+		return nil, fmt.Errorf("no active displays found")
+	}
+	return active, nil
+}
+
+func (s *DisplayService) brightness(ctx context.Context, d models.Display, change *brightness.Change) (models.DisplayBrightness, error) {
+	result := models.DisplayBrightness{ID: d.ID, Type: d.Type}
+
+	if d.Type == models.Internal && s.backlight != nil {
+		devices, err := s.backlight.Devices()
+		if err != nil {
+			return result, err
+		}
+		if len(devices) > 0 {
+			device := devices[0]
+			if change != nil {
+				device, err = s.backlight.Set(ctx, device.Name, *change)
+				if err != nil {
+					return result, err
+				}
+			}
+			s.logger.WithFields(logrus.Fields{
+				"display": d.ID,
+				"device":  device.Name,
+				"value":   device.Current,
+				"max":     device.Max,
+			}).Debug("Using kernel backlight")
+
+			result.Method = models.BrightnessBacklight
+			result.Device = device.Name
+			result.Percent = device.Percent()
+			return result, nil
+		}
+		s.logger.WithField("display", d.ID).Debug("No kernel backlight found, falling back to software dimming")
+	}
+
+	controller, ok := s.backend.(adapter.BrightnessController)
+	if !ok {
+		return result, fmt.Errorf("cannot change brightness of %s: the display backend does not support software dimming", d.ID)
+	}
+
+	level, err := controller.GetBrightness(ctx, d.ID)
+	if err != nil {
+		return result, fmt.Errorf("failed to read brightness of %s: %w", d.ID, err)
+	}
+	if change != nil {
+		level = change.Apply(level*100) / 100
+		if err := controller.SetBrightness(ctx, d.ID, level); err != nil {
+			return result, fmt.Errorf("failed to set brightness of %s: %w", d.ID, err)
+		}
+	}
+
+	result.Method = models.BrightnessSoftware
+	result.Percent = level * 100
+	return result, nil
+}
+
+// Monitor opens the DDC/CI channel of an external display, found by matching
+// its EDID against the EDID each I2C bus returns. The caller closes it.
+func (s *DisplayService) Monitor(ctx context.Context, displayID string) (*ddc.Monitor, error) {
+	s.logger.WithField("display", displayID).Info("Opening DDC/CI channel")
+
+	displays, err := s.detectWithProperties(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect displays: %w", err)
+	}
+
+	for _, d := range displays {
+		if d.ID != displayID {
+			continue
+		}
+		if !d.Connected {
+			return nil, fmt.Errorf("display %s is not connected", displayID)
+		}
+		if d.Type == models.Internal {
+			return nil, fmt.Errorf("display %s is an internal panel; use 'dmon brightness' instead of DDC/CI", displayID)
+		}
+
+		monitor, err := s.ddc.Find(d.EDID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot reach %s over DDC/CI: %w", displayID, err)
+		}
+
+		s.logger.WithFields(logrus.Fields{
+			"display": displayID,
+			"bus":     monitor.Bus,
+		}).Debug("Matched display to I2C bus")
+		return monitor, nil
+	}
+
+	return nil, fmt.Errorf("display %s not found", displayID)
+}
+
+// SetColor applies a colour correction to one display, or to every active
+// display when displayID is empty, and returns the displays it changed.
+func (s *DisplayService) SetColor(ctx context.Context, displayID string, color models.Color) ([]models.Display, error) {
+	s.logger.WithFields(logrus.Fields{
+		"display":    displayID,
+		"gamma":      color.Gamma,
+		"whitepoint": color.Whitepoint,
+	}).Info("Setting colour correction")
+
+	controller, ok := s.backend.(adapter.ColorController)
+	if !ok {
+		return nil, fmt.Errorf("the display backend does not support gamma correction")
+	}
+
+	displays, err := s.detect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect displays: %w", err)
+	}
+
+	targets, err := activeDisplays(displays, displayID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, d := range targets {
+		if err := controller.SetColor(ctx, d.ID, color); err != nil {
+			return targets[:i], fmt.Errorf("failed to set colour of %s: %w", d.ID, err)
+		}
+	}
+
+	return targets, nil
+}
@@ -0,0 +1,508 @@
+package service
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/auto"
+	"github.com/abhishek/dmon-cli/internal/classify"
+	"github.com/abhishek/dmon-cli/internal/edid"
+	"github.com/abhishek/dmon-cli/internal/fake"
+	"github.com/abhishek/dmon-cli/internal/hooks"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/profile"
+	"github.com/abhishek/dmon-cli/internal/runner"
+	"github.com/abhishek/dmon-cli/internal/xrandr"
+	"github.com/sirupsen/logrus"
+)
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// newTestService runs the service against the fake backend in state.
+func newTestService(state fake.State) (*DisplayService, *fake.Backend) {
+	logger := testLogger()
+	backend := fake.NewBackend(logger, state)
+	return New(backend, logger), backend
+}
+
+// layout summarises the fake inventory as "ID=WxH+X+Y" (or "ID=off") per
+// connected display, with a * on the primary.
+func layout(backend *fake.Backend) string {
+	state := backend.State()
+	var parts []string
+	for _, d := range state.Displays {
+		if !d.Connected {
+			continue
+		}
+		part := d.ID + "=off"
+		if d.CurrentMode != nil {
+			part = fmt.Sprintf("%s=%dx%d+%d+%d", d.ID, d.CurrentMode.Width, d.CurrentMode.Height, d.X, d.Y)
+		}
+		if d.ID == state.Primary {
+			part += "*"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestApplyConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(ctx context.Context, s *DisplayService) (*models.ConfigResult, error)
+		want string
+	}{
+		{
+			name: "dual",
+			run: func(ctx context.Context, s *DisplayService) (*models.ConfigResult, error) {
+				return s.SetupDual(ctx, models.ModeHighest)
+			},
+			want: "eDP-1=2560x1600+3840+0 HDMI-1=3840x2160+0+0*",
+		},
+		{
+			name: "dual preset left",
+			run: func(ctx context.Context, s *DisplayService) (*models.ConfigResult, error) {
+				return s.SetDisplay(ctx, models.TargetBoth, models.ModePreset, models.PositionLeft, "")
+			},
+			want: "eDP-1=1920x1200+0+0 HDMI-1=1920x1080+1920+0*",
+		},
+		{
+			name: "single",
+			run: func(ctx context.Context, s *DisplayService) (*models.ConfigResult, error) {
+				return s.SetSingleDisplay(ctx)
+			},
+			want: "eDP-1=1920x1200+0+0* HDMI-1=off",
+		},
+		{
+			name: "external",
+			run: func(ctx context.Context, s *DisplayService) (*models.ConfigResult, error) {
+				return s.SetDisplay(ctx, models.TargetExternal, models.ModeLow, models.PositionNone, "")
+			},
+			want: "eDP-1=off HDMI-1=1280x720+0+0*",
+		},
+		{
+			name: "custom resolution",
+			run: func(ctx context.Context, s *DisplayService) (*models.ConfigResult, error) {
+				return s.SetDisplay(ctx, models.TargetInternal, models.ModePreset, models.PositionNone, "1680x1050")
+			},
+			want: "eDP-1=1680x1050+0+0* HDMI-1=off",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, backend := newTestService(fake.DefaultState())
+
+			result, err := tt.run(context.Background(), s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := layout(backend); got != tt.want {
+				t.Errorf("layout = %s, want %s", got, tt.want)
+			}
+			if calls := backend.Calls(); len(calls) != 1 || calls[0].Result == nil || result == nil {
+				t.Errorf("calls = %+v, result %+v", calls, result)
+			}
+		})
+	}
+}
+
+// TestApplyConfigWithoutInternal covers desktops and laptops whose panel is
+// not connected: what auto picks for them, and dual, use the external one.
+func TestApplyConfigWithoutInternal(t *testing.T) {
+	ctx := context.Background()
+	state := fake.DefaultState()
+	state.Displays[0].Connected = false
+
+	s, backend := newTestService(state)
+	decision, err := auto.Decide(auto.Situation{Displays: backend.State().Displays}, auto.Rules{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ApplyConfig(ctx, decision.Config); err != nil {
+		t.Fatalf("%s layout: %v", decision.Action, err)
+	}
+	if got := layout(backend); got != "HDMI-1=3840x2160+0+0*" {
+		t.Errorf("auto layout = %s", got)
+	}
+
+	s, backend = newTestService(state)
+	if _, err := s.SetupDual(ctx, models.ModeHighest); err != nil {
+		t.Fatal(err)
+	}
+	if got := layout(backend); got != "HDMI-1=3840x2160+0+0*" {
+		t.Errorf("dual layout = %s", got)
+	}
+
+	if _, err := s.SetSingleDisplay(ctx); err == nil || !strings.Contains(err.Error(), "no internal display found") {
+		t.Errorf("single: %v", err)
+	}
+}
+
+func TestApplyConfigFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(backend *fake.Backend)
+		want   string
+		layout string
+		calls  int
+	}{
+		{
+			name:   "detection fails",
+			setup:  func(backend *fake.Backend) { backend.FailNext("detect", "xrandr crashed") },
+			want:   "failed to detect displays: xrandr crashed",
+			layout: "eDP-1=1920x1200+0+0* HDMI-1=off",
+		},
+		{
+			name:   "backend rejects the layout",
+			setup:  func(backend *fake.Backend) { backend.FailNext("configure", "BadMatch") },
+			want:   "BadMatch",
+			layout: "eDP-1=1920x1200+0+0* HDMI-1=off",
+			calls:  1,
+		},
+		{
+			name:   "no external display",
+			setup:  func(backend *fake.Backend) { _ = backend.SetConnected("HDMI-1", false) },
+			want:   "no external displays found",
+			layout: "eDP-1=1920x1200+0+0*",
+			calls:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, backend := newTestService(fake.DefaultState())
+			tt.setup(backend)
+
+			_, err := s.SetupDual(context.Background(), models.ModePreset)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+			if got := layout(backend); got != tt.layout {
+				t.Errorf("layout = %s, want %s", got, tt.layout)
+			}
+			calls := backend.Calls()
+			if len(calls) != tt.calls {
+				t.Fatalf("calls = %+v, want %d", calls, tt.calls)
+			}
+			if tt.calls > 0 && calls[0].Error == "" {
+				t.Errorf("failed call recorded without its error: %+v", calls[0])
+			}
+		})
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	ctx := context.Background()
+	s, backend := newTestService(fake.DefaultState())
+
+	if _, err := s.SetupDual(ctx, models.ModePreset); err != nil {
+		t.Fatal(err)
+	}
+	desk := layout(backend)
+	p, err := s.CaptureProfile(ctx, "desk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.SetSingleDisplay(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if layout(backend) == desk {
+		t.Fatal("single layout looks like the captured one")
+	}
+
+	result, err := s.ApplyProfile(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := layout(backend); got != desk {
+		t.Errorf("layout = %s, want %s", got, desk)
+	}
+	if result.Profile != "desk" {
+		t.Errorf("result profile = %q", result.Profile)
+	}
+	calls := backend.Calls()
+	if last := calls[len(calls)-1]; last.Profile != "desk" || last.Error != "" {
+		t.Errorf("last call = %+v", last)
+	}
+}
+
+func TestApplyProfileFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		outputs []profile.Output
+		fail    string
+		want    string
+		calls   int
+	}{
+		{
+			name: "display not connected",
+			outputs: []profile.Output{
+				{Name: "eDP-1", Enabled: true, Mode: "2560x1600", Primary: true},
+				{Name: "DP-1", Enabled: true, Mode: "1920x1080", X: 2560},
+			},
+			want: "profile broken needs DP-1, which is not connected",
+		},
+		{
+			name: "mode not available",
+			outputs: []profile.Output{
+				{Name: "eDP-1", Enabled: true, Mode: "2560x1600", Primary: true},
+				{Name: "HDMI-1", Enabled: true, Mode: "1920x1080", Rate: 144, X: 2560},
+			},
+			want: "mode 1920x1080@144.00 not available for HDMI-1",
+		},
+		{
+			name: "backend rejects the layout",
+			outputs: []profile.Output{
+				{Name: "eDP-1", Enabled: true, Mode: "2560x1600", Primary: true},
+				{Name: "HDMI-1", Enabled: true, Mode: "3840x2160", X: 2560},
+			},
+			fail:  "screen size 6400x2160 exceeds maximum",
+			want:  "screen size 6400x2160 exceeds maximum",
+			calls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, backend := newTestService(fake.DefaultState())
+			if tt.fail != "" {
+				backend.FailNext("configure", tt.fail)
+			}
+			before := layout(backend)
+
+			_, err := s.ApplyProfile(context.Background(), &profile.Profile{Name: "broken", Outputs: tt.outputs})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+			if got := layout(backend); got != before {
+				t.Errorf("layout changed to %s", got)
+			}
+			if calls := backend.Calls(); len(calls) != tt.calls {
+				t.Errorf("calls = %+v", calls)
+			}
+		})
+	}
+}
+
+// hybridRunner answers xrandr like a hybrid laptop whose panel is DP-2:
+// only `xrandr --verbose` reports the ConnectorType and CONNECTOR_ID
+// properties. It records the commands run.
+func hybridRunner(t *testing.T, ran *[]string) runner.Runner {
+	t.Helper()
+	captures := map[string][]byte{}
+	for line, file := range map[string]string{"xrandr --query": "hybrid-panel-query.txt", "xrandr --verbose": "hybrid-panel.txt"} {
+		data, err := os.ReadFile(filepath.Join("..", "xrandr", "testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		captures[line] = data
+	}
+	return runner.Func(func(ctx context.Context, name string, args ...string) (runner.Result, error) {
+		line := runner.String(name, args...)
+		*ran = append(*ran, line)
+		if out, ok := captures[line]; ok {
+			return runner.Result{Stdout: out}, nil
+		}
+		if strings.HasPrefix(line, "xrandr --output") {
+			return runner.Result{}, nil
+		}
+		return runner.Result{ExitCode: 1}, errors.New("not scripted: " + line)
+	})
+}
+
+// drmTree writes /sys/class/drm connectors named after their kernel
+// connector ids.
+func drmTree(t *testing.T, connectors map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, id := range connectors {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "connector_id"), []byte(id+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// TestClassifyWithProperties runs the xrandr backend over the hybrid laptop.
+// Output properties are only read when sysfs cannot place a display by its
+// name.
+func TestClassifyWithProperties(t *testing.T) {
+	tests := []struct {
+		name     string
+		root     string
+		rule     string
+		internal string
+		verbose  bool
+	}{
+		{name: "connector type", root: t.TempDir(), rule: classify.RuleConnectorType, internal: "DP-2", verbose: true},
+		{name: "sysfs connector id", root: drmTree(t, map[string]string{"card1-eDP-1": "77", "card1-HDMI-A-1": "84"}), rule: classify.RuleSysfs, internal: "DP-2", verbose: true},
+		{name: "sysfs names", root: drmTree(t, map[string]string{"card1-DP-2": "77", "card1-HDMI-A-1": "84"}), rule: classify.RuleSysfs},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ran []string
+			logger := testLogger()
+			s := New(xrandr.NewBackend(logger).WithRunner(hybridRunner(t, &ran)), logger).
+				WithClassifier(classify.New(logger, classify.NewSysfs(tt.root), classify.ConnectorType{}))
+
+			displays, err := s.ListDisplays(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range displays {
+				want := models.External
+				if d.ID == tt.internal {
+					want = models.Internal
+				}
+				if !d.Connected {
+					continue
+				}
+				if d.Type != want || d.Classification == nil || d.Classification.Rule != tt.rule {
+					t.Errorf("%s: %s by %+v, want %s by %s", d.ID, d.Type, d.Classification, want, tt.rule)
+				}
+			}
+			if verbose := strings.Contains(strings.Join(ran, "\n"), "xrandr --verbose"); verbose != tt.verbose {
+				t.Errorf("ran xrandr --verbose = %v, want %v: %q", verbose, tt.verbose, ran)
+			}
+
+			if tt.internal == "" {
+				return
+			}
+			ran = nil
+			if _, err := s.SetSingleDisplay(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if last := ran[len(ran)-1]; last != "xrandr --output DP-2 --mode 1920x1200 --primary" {
+				t.Errorf("single display ran %q", last)
+			}
+		})
+	}
+}
+
+// TestCheckDisplaysWithProperties checks that the current layout, which
+// check, events and workspace moves use, is classified like detected
+// displays.
+func TestCheckDisplaysWithProperties(t *testing.T) {
+	var ran []string
+	logger := testLogger()
+	s := New(xrandr.NewBackend(logger).WithRunner(hybridRunner(t, &ran)), logger).
+		WithClassifier(classify.New(logger, classify.NewSysfs(t.TempDir()), classify.ConnectorType{}))
+
+	layout, err := s.CheckDisplays(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := map[string]string{}
+	for _, d := range layout.Displays {
+		if d.Connected && d.Classification != nil {
+			types[d.ID] = d.Type.String() + " by " + d.Classification.Rule
+		}
+	}
+	want := map[string]string{"DP-2": "Internal by connector-type", "HDMI-1": "External by connector-type"}
+	if fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Errorf("types = %v, want %v", types, want)
+	}
+	if layout.Primary != "DP-2" {
+		t.Errorf("primary = %s", layout.Primary)
+	}
+}
+
+// TestHooksReadLayoutOnlyWithScripts checks that hooks, which are on by
+// default, only cost layout reads when there are scripts to run.
+func TestHooksReadLayoutOnlyWithScripts(t *testing.T) {
+	dir := t.TempDir()
+	s, backend := newTestService(fake.DefaultState())
+	s.WithHooks(hooks.New(dir, testLogger()))
+
+	ctx := context.Background()
+	if _, err := s.SetupDual(ctx, models.ModePreset); err != nil {
+		t.Fatal(err)
+	}
+	if reads := backend.State().Counts["layout"]; reads != 0 {
+		t.Errorf("read the layout %d times without hook scripts", reads)
+	}
+
+	script := filepath.Join(dir, "post-apply.d", "10-log")
+	if err := os.MkdirAll(filepath.Dir(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "ran")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ntouch "+out+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SetSingleDisplay(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if reads := backend.State().Counts["layout"]; reads != 2 {
+		t.Errorf("read the layout %d times around a change with hooks, want 2", reads)
+	}
+	if _, err := os.Stat(out); err != nil {
+		t.Errorf("post-apply hook did not run: %v", err)
+	}
+}
+
+// u2720q is the EDID of the external monitor in the xrandr --verbose captures.
+const u2720q = "00ffffffffffff0010aceaa055334c4c0c200104a53c227802ee95a3544c99260f505400000001010101010101010101" +
+	"0101010101014dd000a0f0703e803020350055502100001a000000fc0044454c4c205532373230510a20000000ff0037" +
+	"5243525738330a202020202000000010000000000000000000000000000000f9"
+
+// TestICCBeforePostApplyHooks checks that post-apply hooks run once the
+// layout's ICC profiles are attached.
+func TestICCBeforePostApplyHooks(t *testing.T) {
+	monitor, err := hex.DecodeString(u2720q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := fake.DefaultState()
+	state.Displays[1].EDID = monitor
+
+	dir := t.TempDir()
+	profile := make([]byte, 132)
+	binary.BigEndian.PutUint32(profile, 132)
+	copy(profile[12:], "mntrRGB ")
+	copy(profile[36:], "acsp")
+	iccFile := filepath.Join(dir, "u2720q.icc")
+	if err := os.WriteFile(iccFile, profile, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "post-apply.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "post-apply.d", "10-redshift"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	s, backend := newTestService(state)
+	var attached []int
+	s.WithICCFiles(map[string]string{edid.Fingerprint(monitor): iccFile}).
+		WithHooks(hooks.New(dir, testLogger()).WithRunner(runner.Func(func(ctx context.Context, name string, args ...string) (runner.Result, error) {
+			attached = append(attached, len(backend.State().ICC["HDMI-1"]))
+			return runner.Result{}, nil
+		})))
+
+	if _, err := s.SetupDual(context.Background(), models.ModePreset); err != nil {
+		t.Fatal(err)
+	}
+	if len(attached) != 1 || attached[0] != len(profile) {
+		t.Errorf("post-apply hook saw ICC profiles of %v bytes, want [%d]", attached, len(profile))
+	}
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/abhishek/dmon-cli/internal/adapter"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/profile"
+	"github.com/abhishek/dmon-cli/internal/split"
+	"github.com/sirupsen/logrus"
+)
+
+func (s *DisplayService) monitorManager() (adapter.MonitorManager, error) {
+	manager, ok := s.backend.(adapter.MonitorManager)
+	if !ok {
+		return nil, fmt.Errorf("the display backend does not support logical monitors")
+	}
+	return manager, nil
+}
+
+// Monitors lists the logical monitors window managers see.
+func (s *DisplayService) Monitors(ctx context.Context) ([]models.Monitor, error) {
+	s.logger.Info("Listing monitors")
+
+	manager, err := s.monitorManager()
+	if err != nil {
+		return nil, err
+	}
+	monitors, err := manager.ListMonitors(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list monitors: %w", err)
+	}
+	return monitors, nil
+}
+
+// Split carves an active output into logical monitors, replacing any split
+// it already has.
+func (s *DisplayService) Split(ctx context.Context, displayID string, rects []split.Rect) ([]models.Monitor, error) {
+	return s.splitDisplay(ctx, displayID, func(models.Display) []split.Rect { return rects })
+}
+
+// SplitGrid splits an active output into columns x rows equal monitors.
+func (s *DisplayService) SplitGrid(ctx context.Context, displayID string, columns, rows int) ([]models.Monitor, error) {
+	return s.splitDisplay(ctx, displayID, func(d models.Display) []split.Rect {
+		width, height, ok := split.Size(d)
+		if !ok {
+			return nil
+		}
+		return split.Grid(width, height, columns, rows)
+	})
+}
+
+func (s *DisplayService) splitDisplay(ctx context.Context, displayID string, layout func(models.Display) []split.Rect) ([]models.Monitor, error) {
+	s.logger.WithField("display", displayID).Info("Splitting display")
+
+	manager, err := s.monitorManager()
+	if err != nil {
+		return nil, err
+	}
+
+	displays, err := s.detect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect displays: %w", err)
+	}
+	d := findDisplay(displays, displayID)
+	if d == nil {
+		return nil, fmt.Errorf("display %s not found", displayID)
+	}
+
+	existing, err := manager.ListMonitors(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list monitors: %w", err)
+	}
+	return s.split(ctx, manager, existing, *d, layout(*d))
+}
+
+// Unsplit removes an output's split monitors, which gives it back its
+// automatic monitor. It returns the names removed.
+func (s *DisplayService) Unsplit(ctx context.Context, displayID string) ([]string, error) {
+	s.logger.WithField("display", displayID).Info("Removing display split")
+
+	manager, err := s.monitorManager()
+	if err != nil {
+		return nil, err
+	}
+	existing, err := manager.ListMonitors(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list monitors: %w", err)
+	}
+	return s.unsplit(ctx, manager, existing, displayID, 0)
+}
+
+func (s *DisplayService) split(ctx context.Context, manager adapter.MonitorManager, existing []models.Monitor, d models.Display, rects []split.Rect) ([]models.Monitor, error) {
+	monitors, err := split.Monitors(d, rects)
+	if err != nil {
+		return nil, err
+	}
+
+	// Monitors of the same name are replaced; only surplus ones go.
+	if _, err := s.unsplit(ctx, manager, existing, d.ID, len(monitors)); err != nil {
+		return nil, err
+	}
+
+	for i, m := range monitors {
+		s.logger.WithFields(logrus.Fields{
+			"monitor":  m.Name,
+			"geometry": fmt.Sprintf("%dx%d+%d+%d", m.Width, m.Height, m.X, m.Y),
+		}).Debug("Setting split monitor")
+
+		if err := manager.SetMonitor(ctx, m); err != nil {
+			return monitors[:i], fmt.Errorf("failed to set monitor %s: %w", m.Name, err)
+		}
+	}
+	return monitors, nil
+}
+
+// unsplit deletes the split monitors of an output beyond the first keep.
+func (s *DisplayService) unsplit(ctx context.Context, manager adapter.MonitorManager, existing []models.Monitor, displayID string, keep int) ([]string, error) {
+	var removed []string
+	for i, m := range split.Of(existing, displayID) {
+		if i < keep {
+			continue
+		}
+		if err := manager.DeleteMonitor(ctx, m.Name); err != nil {
+			return removed, fmt.Errorf("failed to delete monitor %s: %w", m.Name, err)
+		}
+		removed = append(removed, m.Name)
+	}
+	return removed, nil
+}
+
+// captureSplits records the split monitors of each output in the profile.
+func (s *DisplayService) captureSplits(ctx context.Context, p *profile.Profile, displays []models.Display) {
+	manager, ok := s.backend.(adapter.MonitorManager)
+	if !ok {
+		return
+	}
+	monitors, err := manager.ListMonitors(ctx)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to list monitors, splits are not saved")
+		return
+	}
+	p.CaptureSplits(displays, monitors)
+}
+
+// applySplits makes the outputs of an applied profile split as it says:
+// outputs with splits are split at their new position, the others lose any
+// split they had. Failures are only logged, like ICC attachment.
+func (s *DisplayService) applySplits(ctx context.Context, p *profile.Profile, displays []models.Display) {
+	manager, ok := s.backend.(adapter.MonitorManager)
+	if !ok {
+		for _, o := range p.Outputs {
+			if len(o.Split) > 0 {
+				s.logger.Warn("The profile splits outputs but the display backend does not support logical monitors")
+				return
+			}
+		}
+		return
+	}
+
+	existing, err := manager.ListMonitors(ctx)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to list monitors")
+		return
+	}
+
+	var current []models.Display
+	for i, d := range p.Match(displays) {
+		if d == nil {
+			continue
+		}
+		o := p.Outputs[i]
+		if len(o.Split) == 0 || !o.Enabled {
+			if _, err := s.unsplit(ctx, manager, existing, d.ID, 0); err != nil {
+				s.logger.WithError(err).WithField("display", d.ID).Warn("Failed to remove split")
+			}
+			continue
+		}
+
+		// Splits follow the output to where the profile put it.
+		if current == nil {
+			if current, err = s.detect(ctx); err != nil {
+				s.logger.WithError(err).Warn("Failed to detect displays for splits")
+				return
+			}
+		}
+		placed := findDisplay(current, d.ID)
+		if placed == nil {
+			continue
+		}
+		rects, err := o.Rects()
+		if err == nil {
+			_, err = s.split(ctx, manager, existing, *placed, rects)
+		}
+		if err != nil {
+			s.logger.WithError(err).WithField("display", d.ID).Warn("Failed to split display")
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/abhishek/dmon-cli/internal/i3"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/profile"
+	"github.com/sirupsen/logrus"
+)
+
+// WithWorkspaces moves i3 workspaces with manager after every layout
+// change the service makes: to where rules put them, or a profile's own
+// map when it has one, and off outputs that were turned off.
+func (s *DisplayService) WithWorkspaces(manager *i3.Manager, rules i3.Assignments) *DisplayService {
+	s.workspaces = manager
+	s.workspaceRules = rules
+	return s
+}
+
+// ArrangeWorkspaces moves i3 workspaces on the current layout as a layout
+// change would, with p's map if given. With dryRun it only returns the
+// moves.
+func (s *DisplayService) ArrangeWorkspaces(ctx context.Context, p *profile.Profile, dryRun bool) ([]i3.Move, error) {
+	if s.workspaces == nil {
+		return nil, fmt.Errorf("i3 workspace moves are not enabled")
+	}
+	rules, err := s.workspaceRulesFor(p)
+	if err != nil {
+		return nil, err
+	}
+	layout, err := s.currentLayout(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the current layout: %w", err)
+	}
+
+	if dryRun {
+		return s.workspaces.Moves(ctx, layout, rules)
+	}
+	return s.workspaces.Apply(ctx, layout, rules)
+}
+
+// moveWorkspaces moves i3 workspaces to their outputs in a new layout, by
+// the profile's assignments when it has them.
+func (s *DisplayService) moveWorkspaces(ctx context.Context, layout *models.Layout, p *profile.Profile) {
+	rules, err := s.workspaceRulesFor(p)
+	if err != nil {
+		s.logger.WithError(err).Warn("Not moving i3 workspaces")
+		return
+	}
+
+	moves, err := s.workspaces.Apply(ctx, layout, rules)
+	for _, move := range moves {
+		s.logger.WithFields(logrus.Fields{
+			"workspace": move.Workspace,
+			"from":      move.From,
+			"to":        move.To,
+		}).Info("Moved i3 workspace")
+	}
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to move i3 workspaces")
+	}
+}
+
+func (s *DisplayService) workspaceRulesFor(p *profile.Profile) (i3.Assignments, error) {
+	if p == nil || len(p.Workspaces) == 0 {
+		return s.workspaceRules, nil
+	}
+	rules, err := i3.ParseAssignments(p.Workspaces)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", p.Name, err)
+	}
+	return rules, nil
+}
@@ -0,0 +1,202 @@
+// Package split carves one output into several RandR logical monitors
+// (xrandr --setmonitor), so window managers treat an ultrawide as
+// side-by-side screens. Split monitors are named <output>~<n>, which is how
+// they are found again.
+package split
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+)
+
+// Separator joins an output name and a monitor number: DP-1~1, DP-1~2.
+const Separator = "~"
+
+// maxCells bounds a grid; more monitors than this on one output is a typo.
+const maxCells = 16
+
+var (
+	rectRegex = regexp.MustCompile(`^(\d+)x(\d+)\+(\d+)\+(\d+)$`)
+	gridRegex = regexp.MustCompile(`^(\d+)x(\d+)$`)
+)
+
+// Rect is a region of an output, in pixels relative to its top left corner.
+type Rect struct {
+	Width  int
+	Height int
+	X      int
+	Y      int
+}
+
+func (r Rect) String() string {
+	return fmt.Sprintf("%dx%d+%d+%d", r.Width, r.Height, r.X, r.Y)
+}
+
+func (r Rect) overlaps(o Rect) bool {
+	return r.X < o.X+o.Width && o.X < r.X+r.Width && r.Y < o.Y+o.Height && o.Y < r.Y+r.Height
+}
+
+// ParseRect parses WIDTHxHEIGHT+X+Y.
+func ParseRect(s string) (Rect, error) {
+	m := rectRegex.FindStringSubmatch(s)
+	if m == nil {
+		return Rect{}, fmt.Errorf("invalid rectangle %q (use WIDTHxHEIGHT+X+Y, e.g. 1720x1440+0+0)", s)
+	}
+	r := Rect{}
+	r.Width, _ = strconv.Atoi(m[1])
+	r.Height, _ = strconv.Atoi(m[2])
+	r.X, _ = strconv.Atoi(m[3])
+	r.Y, _ = strconv.Atoi(m[4])
+	if r.Width == 0 || r.Height == 0 {
+		return Rect{}, fmt.Errorf("invalid rectangle %q: empty", s)
+	}
+	return r, nil
+}
+
+// ParseGrid parses COLUMNSxROWS, e.g. 2x1 for side-by-side halves.
+func ParseGrid(s string) (columns, rows int, err error) {
+	m := gridRegex.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, fmt.Errorf("invalid grid %q (use COLUMNSxROWS, e.g. 2x1)", s)
+	}
+	columns, _ = strconv.Atoi(m[1])
+	rows, _ = strconv.Atoi(m[2])
+	if columns == 0 || rows == 0 || columns*rows < 2 || columns*rows > maxCells {
+		return 0, 0, fmt.Errorf("invalid grid %q: between 2 and %d monitors", s, maxCells)
+	}
+	return columns, rows, nil
+}
+
+// Grid divides width x height into equal cells, row by row. Leftover pixels
+// go to the last column and row.
+func Grid(width, height, columns, rows int) []Rect {
+	cellWidth, cellHeight := width/columns, height/rows
+	rects := make([]Rect, 0, columns*rows)
+	for row := 0; row < rows; row++ {
+		for col := 0; col < columns; col++ {
+			r := Rect{Width: cellWidth, Height: cellHeight, X: col * cellWidth, Y: row * cellHeight}
+			if col == columns-1 {
+				r.Width = width - r.X
+			}
+			if row == rows-1 {
+				r.Height = height - r.Y
+			}
+			rects = append(rects, r)
+		}
+	}
+	return rects
+}
+
+// Size returns the area an active output covers on the screen, with its
+// rotation applied.
+func Size(d models.Display) (width, height int, ok bool) {
+	if d.CurrentMode == nil {
+		return 0, 0, false
+	}
+	width, height = d.CurrentMode.Width, d.CurrentMode.Height
+	if d.Rotation == "left" || d.Rotation == "right" {
+		width, height = height, width
+	}
+	return width, height, true
+}
+
+// Name returns the name of the nth (1-based) monitor of an output.
+func Name(output string, n int) string {
+	return fmt.Sprintf("%s%s%d", output, Separator, n)
+}
+
+// parseName returns the output and number of a split monitor name.
+func parseName(name string) (output string, n int, ok bool) {
+	i := strings.LastIndex(name, Separator)
+	if i <= 0 {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(name[i+len(Separator):])
+	if err != nil || n < 1 {
+		return "", 0, false
+	}
+	return name[:i], n, true
+}
+
+// Monitors lays rects out as logical monitors on an active display. The
+// first monitor carries the output; the others have none, since RandR only
+// lets an output belong to one monitor.
+func Monitors(d models.Display, rects []Rect) ([]models.Monitor, error) {
+	width, height, ok := Size(d)
+	if !ok {
+		return nil, fmt.Errorf("display %s is not active", d.ID)
+	}
+	if len(rects) == 0 {
+		return nil, fmt.Errorf("no monitors to split %s into", d.ID)
+	}
+
+	monitors := make([]models.Monitor, 0, len(rects))
+	for i, r := range rects {
+		if r.X+r.Width > width || r.Y+r.Height > height {
+			return nil, fmt.Errorf("monitor %s does not fit on %s (%dx%d)", r, d.ID, width, height)
+		}
+		for _, other := range rects[:i] {
+			if r.overlaps(other) {
+				return nil, fmt.Errorf("monitors %s and %s overlap", other, r)
+			}
+		}
+
+		m := models.Monitor{
+			Name:   Name(d.ID, i+1),
+			X:      d.X + r.X,
+			Y:      d.Y + r.Y,
+			Width:  r.Width,
+			Height: r.Height,
+		}
+		if d.WidthMM > 0 && d.HeightMM > 0 {
+			widthMM, heightMM := d.WidthMM, d.HeightMM
+			if d.Rotation == "left" || d.Rotation == "right" {
+				widthMM, heightMM = heightMM, widthMM
+			}
+			m.WidthMM = widthMM * r.Width / width
+			m.HeightMM = heightMM * r.Height / height
+		}
+		if i == 0 {
+			m.Outputs = []string{d.ID}
+			m.Primary = d.Primary
+		}
+		monitors = append(monitors, m)
+	}
+	return monitors, nil
+}
+
+// Of returns the split monitors of an output, in order.
+func Of(monitors []models.Monitor, output string) []models.Monitor {
+	type numbered struct {
+		n int
+		m models.Monitor
+	}
+	var found []numbered
+	for _, m := range monitors {
+		if name, n, ok := parseName(m.Name); ok && name == output && !m.Automatic {
+			found = append(found, numbered{n, m})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].n < found[j].n })
+
+	result := make([]models.Monitor, len(found))
+	for i, f := range found {
+		result[i] = f.m
+	}
+	return result
+}
+
+// Rects returns the regions of an output's split monitors relative to the
+// output, the inverse of Monitors.
+func Rects(monitors []models.Monitor, d models.Display) []Rect {
+	var rects []Rect
+	for _, m := range Of(monitors, d.ID) {
+		rects = append(rects, Rect{Width: m.Width, Height: m.Height, X: m.X - d.X, Y: m.Y - d.Y})
+	}
+	return rects
+}
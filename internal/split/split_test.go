@@ -0,0 +1,123 @@
+package split
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+)
+
+func ultrawide() models.Display {
+	mode := models.Mode{Width: 5120, Height: 1440, Rate: 120, Current: true}
+	return models.Display{
+		ID:          "DP-1",
+		Connected:   true,
+		Modes:       []models.Mode{mode},
+		CurrentMode: &mode,
+		Primary:     true,
+		X:           1920,
+		WidthMM:     1190,
+		HeightMM:    340,
+	}
+}
+
+func TestParse(t *testing.T) {
+	if r, err := ParseRect("1720x1440+1720+0"); err != nil || r != (Rect{1720, 1440, 1720, 0}) {
+		t.Errorf("ParseRect: %+v, %v", r, err)
+	}
+	for _, bad := range []string{"1720x1440", "0x1440+0+0", "1720x1440-5+0", "a"} {
+		if _, err := ParseRect(bad); err == nil {
+			t.Errorf("ParseRect(%q) accepted", bad)
+		}
+	}
+
+	if c, r, err := ParseGrid("3x1"); err != nil || c != 3 || r != 1 {
+		t.Errorf("ParseGrid: %d, %d, %v", c, r, err)
+	}
+	for _, bad := range []string{"1x1", "0x2", "5x5", "2", "2x"} {
+		if _, _, err := ParseGrid(bad); err == nil {
+			t.Errorf("ParseGrid(%q) accepted", bad)
+		}
+	}
+}
+
+func TestGrid(t *testing.T) {
+	got := Grid(5120, 1440, 3, 1)
+	want := []Rect{{1706, 1440, 0, 0}, {1706, 1440, 1706, 0}, {1708, 1440, 3412, 0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("3x1: %v, want %v", got, want)
+	}
+
+	got = Grid(3840, 2160, 2, 2)
+	want = []Rect{{1920, 1080, 0, 0}, {1920, 1080, 1920, 0}, {1920, 1080, 0, 1080}, {1920, 1080, 1920, 1080}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("2x2: %v, want %v", got, want)
+	}
+}
+
+func TestMonitors(t *testing.T) {
+	d := ultrawide()
+	monitors, err := Monitors(d, Grid(5120, 1440, 2, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []models.Monitor{
+		{Name: "DP-1~1", Primary: true, X: 1920, Width: 2560, Height: 1440, WidthMM: 595, HeightMM: 340, Outputs: []string{"DP-1"}},
+		{Name: "DP-1~2", X: 4480, Width: 2560, Height: 1440, WidthMM: 595, HeightMM: 340},
+	}
+	if !reflect.DeepEqual(monitors, want) {
+		t.Errorf("got %+v\nwant %+v", monitors, want)
+	}
+
+	// Rects undoes Monitors, whatever order the server lists them in.
+	listed := append([]models.Monitor{{Name: "eDP-1", Automatic: true}}, monitors[1], monitors[0])
+	if got := Rects(listed, d); !reflect.DeepEqual(got, Grid(5120, 1440, 2, 1)) {
+		t.Errorf("Rects: %v", got)
+	}
+
+	rotated := ultrawide()
+	rotated.Rotation = "left"
+	if _, err := Monitors(rotated, Grid(1440, 5120, 1, 2)); err != nil {
+		t.Errorf("rotated output: %v", err)
+	}
+
+	errors := []struct {
+		rects []Rect
+		err   string
+	}{
+		{[]Rect{{2560, 1440, 0, 0}, {2600, 1440, 2560, 0}}, "does not fit"},
+		{[]Rect{{2560, 1440, 0, 0}, {2560, 1440, 2000, 0}}, "overlap"},
+		{nil, "no monitors"},
+	}
+	for _, tt := range errors {
+		if _, err := Monitors(d, tt.rects); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: got %v, want %q", tt.rects, err, tt.err)
+		}
+	}
+
+	inactive := ultrawide()
+	inactive.CurrentMode = nil
+	if _, err := Monitors(inactive, Grid(5120, 1440, 2, 1)); err == nil {
+		t.Error("split an inactive output")
+	}
+}
+
+func TestOf(t *testing.T) {
+	monitors := []models.Monitor{
+		{Name: "DP-1~10"},
+		{Name: "DP-1-1~1"},
+		{Name: "DP-1~2"},
+		{Name: "DP-1"},
+		{Name: "DP-1~x"},
+		{Name: "DP-1~1"},
+	}
+	var names []string
+	for _, m := range Of(monitors, "DP-1") {
+		names = append(names, m.Name)
+	}
+	if want := []string{"DP-1~1", "DP-1~2", "DP-1~10"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+}
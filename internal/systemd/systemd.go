@@ -0,0 +1,295 @@
+// Package systemd generates the systemd user units and the udev rule that
+// run dmon in the background, and drives the user's service manager
+// through systemctl to install, inspect and remove them.
+package systemd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/abhishek/dmon-cli/internal/runner"
+	"github.com/sirupsen/logrus"
+)
+
+// Unit names.
+const (
+	// WatchUnit runs 'dmon watch' for the whole graphical session.
+	WatchUnit = "dmon-watch.service"
+	// AutoUnit runs 'dmon auto' once; the udev rule starts it.
+	AutoUnit = "dmon-auto.service"
+)
+
+// DefaultTarget is the target the watch unit is tied to. Desktop
+// environments start it once the session's environment is imported.
+const DefaultTarget = "graphical-session.target"
+
+// SessionVars are imported from the graphical session into the service
+// manager: an X client needs the display and its cookie, and i3 workspace
+// moves need the IPC socket.
+var SessionVars = []string{"DISPLAY", "XAUTHORITY", "I3SOCK"}
+
+// header marks generated files so that uninstalling only removes ours.
+const header = "# Generated by 'dmon install-service'; 'dmon install-service --uninstall' removes it."
+
+// UnitDir returns where user units live, honouring XDG_CONFIG_HOME.
+func UnitDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "systemd", "user")
+	}
+	return filepath.Join(os.Getenv("HOME"), ".config", "systemd", "user")
+}
+
+// UdevRulePath returns the rule file for user in /etc/udev/rules.d.
+func UdevRulePath(user string) string {
+	return filepath.Join("/etc/udev/rules.d", "90-dmon-"+user+".rules")
+}
+
+// Options describe the generated units.
+type Options struct {
+	// Command is the dmon executable and the global flags to run it with.
+	Command []string
+	// Target is what the watch unit is part of and wanted by.
+	Target string
+	// Systemctl is the absolute path of systemctl, for the udev rule.
+	Systemctl string
+}
+
+// WatchUnitFile renders the unit that runs 'dmon watch'. It restarts on
+// failure, so a session whose environment is imported late (no DISPLAY
+// yet) still ends up watched.
+func WatchUnitFile(o Options) string {
+	target := o.Target
+	if target == "" {
+		target = DefaultTarget
+	}
+	return fmt.Sprintf(`%s
+[Unit]
+Description=dmon: apply display layouts on hotplug, lid, power and dock changes
+PartOf=%s
+After=%s
+
+[Service]
+Type=simple
+ExecStart=%s
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=%s
+`, header, target, target, commandLine(append(o.Command, "watch")), target)
+}
+
+// AutoUnitFile renders the unit that runs 'dmon auto' once. It does
+// nothing while the watch unit runs, which follows hotplug itself.
+func AutoUnitFile(o Options) string {
+	return fmt.Sprintf(`%s
+[Unit]
+Description=dmon: apply the display layout for the connected displays
+
+[Service]
+Type=oneshot
+ExecCondition=/bin/sh -c '! systemctl --user --quiet is-active %s'
+ExecStart=%s
+`, header, WatchUnit, commandLine(append(o.Command, "auto")))
+}
+
+// MachineVersion is the first systemd whose systemctl reaches a user's
+// service manager with --machine=USER@.host, as the udev rule does.
+const MachineVersion = 248
+
+// UdevRule renders a rule that starts the auto unit in user's service
+// manager on DRM change events, which the kernel sends on hotplug. It
+// needs systemd MachineVersion or later.
+func UdevRule(o Options, user string) string {
+	systemctl := o.Systemctl
+	if systemctl == "" {
+		systemctl = "/usr/bin/systemctl"
+	}
+	return fmt.Sprintf(`%s
+ACTION=="change", SUBSYSTEM=="drm", RUN+="%s --user --machine=%s@.host --no-block start %s"
+`, header, systemctl, user, AutoUnit)
+}
+
+// IsGenerated reports whether the file at path was written by dmon.
+func IsGenerated(path string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && strings.HasPrefix(string(data), header)
+}
+
+// commandLine joins arguments with systemd's quoting: arguments with
+// spaces or quotes are double-quoted, and % and $ are escaped so systemd
+// does not expand them.
+func commandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		arg = strings.NewReplacer("%", "%%", "$", "$$").Replace(arg)
+		if arg == "" || strings.ContainsAny(arg, " \t\"'\\;") {
+			arg = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Manager runs systemctl --user.
+type Manager struct {
+	runner runner.Runner
+	logger *logrus.Logger
+}
+
+func NewManager(logger *logrus.Logger) *Manager {
+	return &Manager{
+		runner: runner.Exec{},
+		logger: logger,
+	}
+}
+
+// WithRunner sets how systemctl is run.
+func (m *Manager) WithRunner(r runner.Runner) *Manager {
+	m.runner = r
+	return m
+}
+
+func (m *Manager) systemctl(ctx context.Context, args ...string) (string, error) {
+	args = append([]string{"--user"}, args...)
+	m.logger.WithField("command", runner.String("systemctl", args...)).Debug("Running systemctl")
+	result, err := m.runner.Run(ctx, "systemctl", args...)
+	return string(result.Stdout), err
+}
+
+// DaemonReload makes the manager re-read unit files.
+func (m *Manager) DaemonReload(ctx context.Context) error {
+	_, err := m.systemctl(ctx, "daemon-reload")
+	return err
+}
+
+// Enable enables a unit and starts it, or restarts it when it runs.
+func (m *Manager) Enable(ctx context.Context, unit string) error {
+	if _, err := m.systemctl(ctx, "enable", unit); err != nil {
+		return err
+	}
+	_, err := m.systemctl(ctx, "restart", unit)
+	return err
+}
+
+// Restart restarts a unit.
+func (m *Manager) Restart(ctx context.Context, unit string) error {
+	_, err := m.systemctl(ctx, "restart", unit)
+	return err
+}
+
+// Disable stops and disables units. Units the manager does not know are
+// not an error.
+func (m *Manager) Disable(ctx context.Context, units ...string) error {
+	_, err := m.systemctl(ctx, append([]string{"disable", "--now"}, units...)...)
+	var exitErr *runner.ExitError
+	if errors.As(err, &exitErr) && strings.Contains(exitErr.Stderr, "does not exist") {
+		return nil
+	}
+	return err
+}
+
+// ResetFailed forgets failed states of units.
+func (m *Manager) ResetFailed(ctx context.Context, units ...string) error {
+	_, err := m.systemctl(ctx, append([]string{"reset-failed"}, units...)...)
+	return err
+}
+
+// ImportEnvironment copies the named variables that are set in dmon's
+// environment into the manager's, returning those that were imported.
+func (m *Manager) ImportEnvironment(ctx context.Context, vars []string) ([]string, error) {
+	var set []string
+	for _, v := range vars {
+		if os.Getenv(v) != "" {
+			set = append(set, v)
+		}
+	}
+	if len(set) == 0 {
+		return nil, nil
+	}
+	_, err := m.systemctl(ctx, append([]string{"import-environment"}, set...)...)
+	return set, err
+}
+
+// Environment returns the manager's environment.
+func (m *Manager) Environment(ctx context.Context) (map[string]string, error) {
+	out, err := m.systemctl(ctx, "show-environment")
+	if err != nil {
+		return nil, err
+	}
+	return parseProperties(out), nil
+}
+
+// Status is what systemctl show reports about a unit.
+type Status struct {
+	// LoadState is loaded or not-found.
+	LoadState string
+	// ActiveState and SubState are like active and running.
+	ActiveState string
+	SubState    string
+	// UnitFileState is enabled or disabled.
+	UnitFileState string
+	// Since is when the unit entered its active state, if it did.
+	Since string
+	// Result is success or why the unit last failed.
+	Result string
+}
+
+// Status reads a unit's state.
+func (m *Manager) Status(ctx context.Context, unit string) (Status, error) {
+	out, err := m.systemctl(ctx, "show", "--property=LoadState,ActiveState,SubState,UnitFileState,ActiveEnterTimestamp,Result", unit)
+	if err != nil {
+		return Status{}, err
+	}
+	p := parseProperties(out)
+	return Status{
+		LoadState:     p["LoadState"],
+		ActiveState:   p["ActiveState"],
+		SubState:      p["SubState"],
+		UnitFileState: p["UnitFileState"],
+		Since:         p["ActiveEnterTimestamp"],
+		Result:        p["Result"],
+	}, nil
+}
+
+// Version returns the version of systemd, e.g. 255.
+func (m *Manager) Version(ctx context.Context) (int, error) {
+	out, err := m.systemctl(ctx, "--version")
+	if err != nil {
+		return 0, err
+	}
+	// The first line is like "systemd 255 (255.4-1ubuntu8)".
+	line, _, _ := strings.Cut(out, "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "systemd" {
+		return 0, fmt.Errorf("unexpected systemctl --version output %q", line)
+	}
+	version, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, fmt.Errorf("unexpected systemd version %q", fields[1])
+	}
+	return version, nil
+}
+
+// IsActive reports whether a unit, such as a target, is active.
+func (m *Manager) IsActive(ctx context.Context, unit string) bool {
+	status, err := m.Status(ctx, unit)
+	return err == nil && status.ActiveState == "active"
+}
+
+func parseProperties(out string) map[string]string {
+	props := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), "="); ok {
+			props[key] = value
+		}
+	}
+	return props
+}
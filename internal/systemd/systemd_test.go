@@ -0,0 +1,116 @@
+package systemd
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/runner"
+	"github.com/sirupsen/logrus"
+)
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestCommandLine(t *testing.T) {
+	got := commandLine([]string{"/opt/my tools/dmon", "--config", `/home/u/"odd".toml`, "50%", "$HOME", "watch"})
+	want := `"/opt/my tools/dmon" --config "/home/u/\"odd\".toml" 50%% $$HOME watch`
+	if got != want {
+		t.Errorf("commandLine = %s, want %s", got, want)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	o := Options{Command: []string{"/usr/bin/dmon", "--i3"}, Systemctl: "/usr/bin/systemctl"}
+
+	watch := WatchUnitFile(o)
+	for _, want := range []string{"ExecStart=/usr/bin/dmon --i3 watch\n", "Restart=on-failure", "PartOf=graphical-session.target", "WantedBy=graphical-session.target"} {
+		if !strings.Contains(watch, want) {
+			t.Errorf("watch unit lacks %q:\n%s", want, watch)
+		}
+	}
+	if watch := WatchUnitFile(Options{Command: o.Command, Target: "default.target"}); !strings.Contains(watch, "WantedBy=default.target") {
+		t.Errorf("target not used:\n%s", watch)
+	}
+
+	auto := AutoUnitFile(o)
+	for _, want := range []string{"ExecStart=/usr/bin/dmon --i3 auto\n", "Type=oneshot", "is-active " + WatchUnit} {
+		if !strings.Contains(auto, want) {
+			t.Errorf("auto unit lacks %q:\n%s", want, auto)
+		}
+	}
+
+	rule := UdevRule(o, "alice")
+	if want := `ACTION=="change", SUBSYSTEM=="drm", RUN+="/usr/bin/systemctl --user --machine=alice@.host --no-block start dmon-auto.service"`; !strings.Contains(rule, want) {
+		t.Errorf("udev rule = %s", rule)
+	}
+
+	dir := t.TempDir()
+	ours, theirs := filepath.Join(dir, "ours"), filepath.Join(dir, "theirs")
+	if err := os.WriteFile(ours, []byte(watch), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(theirs, []byte("[Unit]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !IsGenerated(ours) || IsGenerated(theirs) || IsGenerated(filepath.Join(dir, "missing")) {
+		t.Error("IsGenerated is wrong")
+	}
+}
+
+func TestManager(t *testing.T) {
+	var calls [][]string
+	r := runner.Func(func(ctx context.Context, name string, args ...string) (runner.Result, error) {
+		calls = append(calls, append([]string{name}, args...))
+		switch args[1] {
+		case "disable":
+			return runner.Result{ExitCode: 1}, &runner.ExitError{Name: name, ExitCode: 1, Stderr: "Failed to disable unit: Unit file dmon-auto.service does not exist."}
+		case "--version":
+			return runner.Result{Stdout: []byte("systemd 255 (255.4-1ubuntu8)\n+PAM +AUDIT +SELINUX\n")}, nil
+		case "show":
+			return runner.Result{Stdout: []byte("LoadState=loaded\nActiveState=active\nSubState=running\nUnitFileState=enabled\nActiveEnterTimestamp=Sun 2026-10-18 09:12:03 CEST\nResult=success\n")}, nil
+		}
+		return runner.Result{}, nil
+	})
+	m := NewManager(testLogger()).WithRunner(r)
+	ctx := context.Background()
+
+	if err := m.Disable(ctx, WatchUnit, AutoUnit); err != nil {
+		t.Errorf("disabling a missing unit failed: %v", err)
+	}
+
+	t.Setenv("DISPLAY", ":0")
+	t.Setenv("XAUTHORITY", "")
+	t.Setenv("I3SOCK", "/run/user/1000/i3/ipc-socket.1")
+	imported, err := m.ImportEnvironment(ctx, SessionVars)
+	if err != nil || !reflect.DeepEqual(imported, []string{"DISPLAY", "I3SOCK"}) {
+		t.Errorf("ImportEnvironment = %v, %v", imported, err)
+	}
+
+	status, err := m.Status(ctx, WatchUnit)
+	want := Status{LoadState: "loaded", ActiveState: "active", SubState: "running", UnitFileState: "enabled", Since: "Sun 2026-10-18 09:12:03 CEST", Result: "success"}
+	if err != nil || status != want {
+		t.Errorf("Status = %+v, %v", status, err)
+	}
+
+	if version, err := m.Version(ctx); err != nil || version != 255 {
+		t.Errorf("Version = %d, %v", version, err)
+	}
+
+	wantCalls := [][]string{
+		{"systemctl", "--user", "disable", "--now", WatchUnit, AutoUnit},
+		{"systemctl", "--user", "import-environment", "DISPLAY", "I3SOCK"},
+		{"systemctl", "--user", "show", "--property=LoadState,ActiveState,SubState,UnitFileState,ActiveEnterTimestamp,Result", WatchUnit},
+		{"systemctl", "--user", "--version"},
+	}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("calls = %v", calls)
+	}
+}
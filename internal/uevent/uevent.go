@@ -0,0 +1,50 @@
+// Package uevent follows the kernel's device events, such as a charger
+// being plugged in or a dock appearing on USB, so 'dmon watch' can react
+// without polling sysfs.
+package uevent
+
+import "strings"
+
+// Event is one kernel uevent.
+type Event struct {
+	// Action is add, remove, change, bind, unbind and the like.
+	Action    string
+	DevPath   string
+	Subsystem string
+	// Env holds the KEY=VALUE fields.
+	Env map[string]string
+}
+
+// Parse decodes a kernel uevent message: a header like
+// "change@/devices/..." followed by NUL-separated KEY=VALUE fields. Other
+// messages, such as udev's own broadcasts, are rejected.
+func Parse(msg []byte) (Event, bool) {
+	fields := strings.Split(strings.TrimRight(string(msg), "\x00"), "\x00")
+	action, devPath, ok := strings.Cut(fields[0], "@")
+	if !ok || action == "" || devPath == "" {
+		return Event{}, false
+	}
+
+	e := Event{Action: action, DevPath: devPath, Env: make(map[string]string, len(fields)-1)}
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		e.Env[key] = value
+	}
+	e.Subsystem = e.Env["SUBSYSTEM"]
+	return e, true
+}
+
+func wanted(e Event, subsystems []string) bool {
+	if len(subsystems) == 0 {
+		return true
+	}
+	for _, s := range subsystems {
+		if e.Subsystem == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package uevent
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// bufferSize is how many events wait for a slow reader before newer ones
+// are dropped. Readers re-read the state they care about, so losing some
+// of a burst is harmless.
+const bufferSize = 16
+
+// Listen sends the uevents of the given subsystems (all when none are
+// given) until ctx is done, then closes the channel.
+func Listen(ctx context.Context, subsystems ...string) (<-chan Event, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open uevent socket: %w", err)
+	}
+	// Group 1 carries the kernel's own events.
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: 1}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to listen for uevents: %w", err)
+	}
+
+	// A non-blocking descriptor goes through the runtime poller, so closing
+	// the file ends a pending Read.
+	socket := os.NewFile(uintptr(fd), "uevent")
+	go func() {
+		<-ctx.Done()
+		socket.Close()
+	}()
+
+	events := make(chan Event, bufferSize)
+	go func() {
+		defer close(events)
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := socket.Read(buf)
+			if err != nil {
+				return
+			}
+			e, ok := Parse(buf[:n])
+			if !ok || !wanted(e, subsystems) {
+				continue
+			}
+			select {
+			case events <- e:
+			default:
+			}
+		}
+	}()
+	return events, nil
+}
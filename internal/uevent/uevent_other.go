@@ -0,0 +1,13 @@
+//go:build !linux
+
+package uevent
+
+import (
+	"context"
+	"errors"
+)
+
+// Listen is only implemented on Linux; callers fall back to polling.
+func Listen(ctx context.Context, subsystems ...string) (<-chan Event, error) {
+	return nil, errors.New("uevents are only supported on Linux")
+}
@@ -0,0 +1,23 @@
+package uevent
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	msg := []byte("change@/devices/LNXSYSTM:00/ACPI0003:00/power_supply/AC\x00ACTION=change\x00DEVPATH=/devices/LNXSYSTM:00/ACPI0003:00/power_supply/AC\x00SUBSYSTEM=power_supply\x00POWER_SUPPLY_ONLINE=0\x00")
+	e, ok := Parse(msg)
+	if !ok {
+		t.Fatal("kernel event rejected")
+	}
+	if e.Action != "change" || e.DevPath != "/devices/LNXSYSTM:00/ACPI0003:00/power_supply/AC" ||
+		e.Subsystem != "power_supply" || e.Env["POWER_SUPPLY_ONLINE"] != "0" {
+		t.Errorf("Parse = %+v", e)
+	}
+	if !wanted(e, []string{"usb", "power_supply"}) || wanted(e, []string{"drm"}) || !wanted(e, nil) {
+		t.Error("subsystem filter is wrong")
+	}
+
+	// udev's rebroadcasts start with "libudev" and a binary header.
+	if _, ok := Parse([]byte("libudev\x00\xfe\xed\xca\xfe")); ok {
+		t.Error("udev message accepted")
+	}
+}
@@ -0,0 +1,48 @@
+package x11
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/abhishek/dmon-cli/internal/gamma"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/jezek/xgb/randr"
+	"github.com/sirupsen/logrus"
+)
+
+// SetColor loads a gamma ramp into the CRTC driving the output.
+func (b *Backend) SetColor(ctx context.Context, displayID string, color models.Color) error {
+	s, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	for _, o := range s.outputs {
+		if o.name != displayID {
+			continue
+		}
+		if o.info.Crtc == 0 {
+			return fmt.Errorf("display %s is not active", displayID)
+		}
+
+		size, err := randr.GetCrtcGammaSize(s.conn, o.info.Crtc).Reply()
+		if err != nil {
+			return fmt.Errorf("failed to get gamma size of %s: %w", displayID, err)
+		}
+
+		b.logger.WithFields(logrus.Fields{
+			"display": displayID,
+			"crtc":    o.info.Crtc,
+			"size":    size.Size,
+		}).Debug("Setting CRTC gamma ramp")
+
+		red, green, blue := gamma.Ramp(int(size.Size), color)
+		if err := randr.SetCrtcGammaChecked(s.conn, o.info.Crtc, size.Size, red, green, blue).Check(); err != nil {
+			return fmt.Errorf("failed to set gamma of %s: %w", displayID, err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("display %s not found", displayID)
+}
@@ -0,0 +1,431 @@
+package x11
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/planner"
+	"github.com/jezek/xgb/randr"
+	"github.com/jezek/xgb/xproto"
+	"github.com/sirupsen/logrus"
+)
+
+// rateTolerance absorbs rounding between printed and computed refresh rates.
+const rateTolerance = 0.05
+
+// dpi is used to derive the screen's physical size when it has to be resized.
+const dpi = 96.0
+
+// crtcConfig is the desired state of one CRTC. A zero mode disables it.
+type crtcConfig struct {
+	crtc     randr.Crtc
+	x        int
+	y        int
+	mode     randr.Mode
+	rotation uint16
+	outputs  []randr.Output
+}
+
+func (c crtcConfig) size(s *session) (int, int) {
+	if c.mode == 0 {
+		return 0, 0
+	}
+	mi := s.modes[c.mode]
+	w, h := int(mi.info.Width), int(mi.info.Height)
+	if c.rotation&(randr.RotationRotate90|randr.RotationRotate270) != 0 {
+		w, h = h, w
+	}
+	return w, h
+}
+
+func (b *Backend) Configure(ctx context.Context, config models.DisplayConfig, displays []models.Display) (*models.ConfigResult, error) {
+	b.logger.WithFields(logrus.Fields{
+		"target":   config.Target,
+		"mode":     config.Mode,
+		"position": config.Position,
+	}).Info("Configuring displays")
+
+	plan, err := b.planner.Plan(config, displays)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Apply(ctx, plan)
+}
+
+// Apply maps the plan onto CRTCs and commits it atomically.
+func (b *Backend) Apply(ctx context.Context, plan *planner.Plan) (*models.ConfigResult, error) {
+	s, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+
+	changes, primary, err := s.resolve(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range changes {
+		w, h := c.size(s)
+		b.logger.WithFields(logrus.Fields{
+			"crtc": c.crtc,
+			"mode": s.modes[c.mode].name,
+			"pos":  fmt.Sprintf("%dx%d+%d+%d", w, h, c.x, c.y),
+		}).Debug("Planned CRTC configuration")
+	}
+
+	if err := s.apply(changes, primary); err != nil {
+		b.logger.WithError(err).Error("RandR configuration failed")
+		return nil, err
+	}
+
+	b.logger.Info("Display configuration applied successfully")
+
+	return plan.Result(), nil
+}
+
+// resolve maps the backend-independent plan onto CRTCs, modes and positions.
+func (s *session) resolve(plan *planner.Plan) ([]crtcConfig, randr.Output, error) {
+	byName := make(map[string]output, len(s.outputs))
+	for _, o := range s.outputs {
+		byName[o.name] = o
+	}
+
+	used := make(map[randr.Crtc]bool)
+	for id, info := range s.crtcs {
+		if len(info.Outputs) > 0 {
+			used[id] = true
+		}
+	}
+
+	var changes []crtcConfig
+	var primary randr.Output
+	index := make(map[string]int)
+
+	// Release CRTCs of outputs being switched off first so they can be reused.
+	for _, op := range plan.Outputs {
+		o, ok := byName[op.ID]
+		if !ok {
+			return nil, 0, fmt.Errorf("display %s not found", op.ID)
+		}
+		if !op.Enabled && o.info.Crtc != 0 {
+			changes = append(changes, crtcConfig{crtc: o.info.Crtc, rotation: randr.RotationRotate0})
+			used[o.info.Crtc] = false
+		}
+	}
+
+	for _, op := range plan.Outputs {
+		if !op.Enabled {
+			continue
+		}
+		o := byName[op.ID]
+
+		mode, err := s.pickMode(o, op)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		crtc := o.info.Crtc
+		rotation := uint16(randr.RotationRotate0)
+		if crtc != 0 {
+			rotation = s.crtcs[crtc].Rotation
+		} else {
+			for _, candidate := range o.info.Crtcs {
+				if !used[candidate] {
+					crtc = candidate
+					break
+				}
+			}
+			if crtc == 0 {
+				return nil, 0, fmt.Errorf("no free CRTC available for %s", op.ID)
+			}
+		}
+		used[crtc] = true
+
+		if op.Primary {
+			primary = o.id
+		}
+
+		index[op.ID] = len(changes)
+		changes = append(changes, crtcConfig{
+			crtc:     crtc,
+			mode:     mode,
+			rotation: rotation,
+			outputs:  []randr.Output{o.id},
+		})
+	}
+
+	changes = s.position(plan, changes, index)
+
+	return changes, primary, nil
+}
+
+// pickMode selects the output mode matching the planned size (and rate, when
+// the plan has one), preferring the current mode, then the preferred mode,
+// then the highest rate.
+func (s *session) pickMode(o output, op planner.OutputPlan) (randr.Mode, error) {
+	var current randr.Mode
+	if crtc, ok := s.crtcs[o.info.Crtc]; ok && o.info.Crtc != 0 {
+		current = crtc.Mode
+	}
+
+	var best randr.Mode
+	bestScore := -1.0
+	for i, id := range o.info.Modes {
+		mi, ok := s.modes[id]
+		if !ok {
+			continue
+		}
+		// Every rate of a size shares its name, so the rate is checked first.
+		if op.Rate > 0 && math.Abs(mi.rate-op.Rate) > rateTolerance {
+			continue
+		}
+		// A custom mode is requested by name, which picks it over EDID modes of the same size.
+		if mi.name == op.Mode {
+			return id, nil
+		}
+		if op.Mode != "auto" && (int(mi.info.Width) != op.Width || int(mi.info.Height) != op.Height) {
+			continue
+		}
+
+		score := mi.rate
+		if i < int(o.info.NumPreferred) {
+			score += 1000
+		}
+		if id == current {
+			score += 10000
+		}
+		if score > bestScore {
+			best = id
+			bestScore = score
+		}
+	}
+
+	if best == 0 {
+		return 0, fmt.Errorf("mode %s not available for %s", op.Mode, op.ID)
+	}
+
+	return best, nil
+}
+
+// position lays out enabled outputs: absolute outputs where the plan puts
+// them, anchors at the origin, related outputs next to their anchor, then
+// everything shifted so no coordinate is negative. Outputs the plan does not
+// mention keep their CRTC; they are only moved along with the shift.
+func (s *session) position(plan *planner.Plan, changes []crtcConfig, index map[string]int) []crtcConfig {
+	minX, minY := 0, 0
+
+	for _, op := range plan.Outputs {
+		i, ok := index[op.ID]
+		if !ok {
+			continue
+		}
+		if op.Absolute {
+			changes[i].x, changes[i].y = op.X, op.Y
+			minX = min(minX, op.X)
+			minY = min(minY, op.Y)
+			continue
+		}
+		if op.Relation == models.PositionNone {
+			continue
+		}
+
+		c := &changes[i]
+		w, h := c.size(s)
+
+		var anchor crtcConfig
+		if j, ok := index[op.RelativeTo]; ok {
+			anchor = changes[j]
+		} else if current, ok := s.current(op.RelativeTo); ok {
+			anchor = current
+		}
+		ax, ay := anchor.x, anchor.y
+		aw, ah := anchor.size(s)
+
+		switch op.Relation {
+		case models.PositionLeft:
+			c.x, c.y = ax-w, ay
+		case models.PositionRight:
+			c.x, c.y = ax+aw, ay
+		case models.PositionAbove:
+			c.x, c.y = ax, ay-h
+		case models.PositionBelow:
+			c.x, c.y = ax, ay+ah
+		}
+
+		minX = min(minX, c.x)
+		minY = min(minY, c.y)
+	}
+
+	if minX == 0 && minY == 0 {
+		return changes
+	}
+
+	// The shift would slide planned outputs under untouched ones, so those
+	// are reconfigured at their current mode and moved by the same amount.
+	changed := make(map[randr.Crtc]bool, len(changes))
+	for _, c := range changes {
+		changed[c.crtc] = true
+	}
+	for _, id := range slices.Sorted(maps.Keys(s.crtcs)) {
+		info := s.crtcs[id]
+		if changed[id] || info.Mode == 0 {
+			continue
+		}
+		changes = append(changes, crtcConfig{
+			crtc:     id,
+			x:        int(info.X),
+			y:        int(info.Y),
+			mode:     info.Mode,
+			rotation: info.Rotation,
+			outputs:  info.Outputs,
+		})
+	}
+
+	for i := range changes {
+		if changes[i].mode != 0 {
+			changes[i].x -= minX
+			changes[i].y -= minY
+		}
+	}
+
+	return changes
+}
+
+// current returns the CRTC state of an enabled output by name.
+func (s *session) current(name string) (crtcConfig, bool) {
+	for _, o := range s.outputs {
+		if o.name != name || o.info.Crtc == 0 {
+			continue
+		}
+		info, ok := s.crtcs[o.info.Crtc]
+		if !ok || info.Mode == 0 {
+			return crtcConfig{}, false
+		}
+		return crtcConfig{
+			crtc:     o.info.Crtc,
+			x:        int(info.X),
+			y:        int(info.Y),
+			mode:     info.Mode,
+			rotation: info.Rotation,
+			outputs:  info.Outputs,
+		}, true
+	}
+	return crtcConfig{}, false
+}
+
+// screenSize returns the bounding box of every CRTC that stays enabled.
+func (s *session) screenSize(changes []crtcConfig) (int, int) {
+	changed := make(map[randr.Crtc]bool, len(changes))
+	width, height := 0, 0
+
+	for _, c := range changes {
+		changed[c.crtc] = true
+		w, h := c.size(s)
+		width = max(width, c.x+w)
+		height = max(height, c.y+h)
+	}
+
+	for id, info := range s.crtcs {
+		if changed[id] || info.Mode == 0 {
+			continue
+		}
+		width = max(width, int(info.X)+int(info.Width))
+		height = max(height, int(info.Y)+int(info.Height))
+	}
+
+	return width, height
+}
+
+// apply performs the whole change under a server grab and rolls back to the
+// original CRTC state if any step fails.
+func (s *session) apply(changes []crtcConfig, primary randr.Output) error {
+	width, height := s.screenSize(changes)
+
+	limits, err := randr.GetScreenSizeRange(s.conn, s.root).Reply()
+	if err != nil {
+		return fmt.Errorf("failed to get screen size range: %w", err)
+	}
+	if width > int(limits.MaxWidth) || height > int(limits.MaxHeight) {
+		return fmt.Errorf("screen size %dx%d exceeds maximum %dx%d", width, height, limits.MaxWidth, limits.MaxHeight)
+	}
+	width = max(width, int(limits.MinWidth))
+	height = max(height, int(limits.MinHeight))
+
+	if err := xproto.GrabServerChecked(s.conn).Check(); err != nil {
+		return fmt.Errorf("failed to grab X server: %w", err)
+	}
+	defer xproto.UngrabServer(s.conn)
+
+	original := make([]crtcConfig, 0, len(changes))
+	for _, c := range changes {
+		info := s.crtcs[c.crtc]
+		original = append(original, crtcConfig{
+			crtc:     c.crtc,
+			x:        int(info.X),
+			y:        int(info.Y),
+			mode:     info.Mode,
+			rotation: info.Rotation,
+			outputs:  info.Outputs,
+		})
+	}
+
+	if err := s.commit(changes, width, height, primary); err != nil {
+		origWidth, origHeight := int(s.screen.WidthInPixels), int(s.screen.HeightInPixels)
+		if rbErr := s.commit(original, origWidth, origHeight, s.primary); rbErr != nil {
+			return fmt.Errorf("%w (rollback also failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *session) commit(changes []crtcConfig, width, height int, primary randr.Output) error {
+	// Disable every CRTC being changed so the screen can be resized safely.
+	for _, c := range changes {
+		if err := s.setCrtc(crtcConfig{crtc: c.crtc, rotation: randr.RotationRotate0}); err != nil {
+			return err
+		}
+	}
+
+	mmWidth := uint32(float64(width) * 25.4 / dpi)
+	mmHeight := uint32(float64(height) * 25.4 / dpi)
+	if err := randr.SetScreenSizeChecked(s.conn, s.root, uint16(width), uint16(height), mmWidth, mmHeight).Check(); err != nil {
+		return fmt.Errorf("failed to set screen size %dx%d: %w", width, height, err)
+	}
+
+	for _, c := range changes {
+		if c.mode == 0 {
+			continue
+		}
+		if err := s.setCrtc(c); err != nil {
+			return err
+		}
+	}
+
+	if primary != 0 {
+		if err := randr.SetOutputPrimaryChecked(s.conn, s.root, primary).Check(); err != nil {
+			return fmt.Errorf("failed to set primary output: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *session) setCrtc(c crtcConfig) error {
+	reply, err := randr.SetCrtcConfig(s.conn, c.crtc, xproto.TimeCurrentTime, s.res.ConfigTimestamp,
+		int16(c.x), int16(c.y), c.mode, c.rotation, c.outputs).Reply()
+	if err != nil {
+		return fmt.Errorf("failed to configure CRTC %d: %w", c.crtc, err)
+	}
+	if reply.Status != randr.SetConfigSuccess {
+		return fmt.Errorf("failed to configure CRTC %d: status %d", c.crtc, reply.Status)
+	}
+	return nil
+}
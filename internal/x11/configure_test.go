@@ -0,0 +1,252 @@
+package x11
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/planner"
+	"github.com/jezek/xgb/randr"
+)
+
+const (
+	eDP  randr.Output = 1
+	hdmi randr.Output = 2
+	dp   randr.Output = 3
+)
+
+const rot0 = uint16(randr.RotationRotate0)
+
+// testMode builds a mode whose timings give the requested refresh rate.
+func testMode(id randr.Mode, width, height int, rate float64) modeInfo {
+	info := randr.ModeInfo{
+		Id:       uint32(id),
+		Width:    uint16(width),
+		Height:   uint16(height),
+		Htotal:   1000,
+		Vtotal:   1000,
+		DotClock: uint32(rate * 1e6),
+	}
+	return modeInfo{info: info, name: fmt.Sprintf("%dx%d", width, height), rate: modeRate(info)}
+}
+
+// testSession stubs the X connection with a snapshot of screen resources:
+// eDP-1 lit on CRTC 10, HDMI-1 connected but dark and, when dpOn is set,
+// DP-1 lit on CRTC 12 right of the panel.
+func testSession(dpOn bool) *session {
+	s := &session{
+		modes: map[randr.Mode]modeInfo{
+			100: testMode(100, 2560, 1600, 60),
+			101: testMode(101, 1920, 1200, 60),
+			200: testMode(200, 3840, 2160, 60),
+			201: testMode(201, 1920, 1080, 60),
+			202: testMode(202, 1920, 1080, 50),
+		},
+		crtcs: map[randr.Crtc]*randr.GetCrtcInfoReply{
+			10: {Mode: 100, Width: 2560, Height: 1600, Rotation: rot0, Outputs: []randr.Output{eDP}},
+			11: {Rotation: rot0},
+			12: {Rotation: rot0},
+		},
+		outputs: []output{
+			{id: eDP, name: "eDP-1", info: &randr.GetOutputInfoReply{
+				Crtc: 10, Crtcs: []randr.Crtc{10, 11}, Modes: []randr.Mode{100, 101}, NumPreferred: 1,
+			}},
+			{id: hdmi, name: "HDMI-1", info: &randr.GetOutputInfoReply{
+				Crtcs: []randr.Crtc{10, 11}, Modes: []randr.Mode{200, 201, 202}, NumPreferred: 1,
+			}},
+			{id: dp, name: "DP-1", info: &randr.GetOutputInfoReply{
+				Crtcs: []randr.Crtc{12}, Modes: []randr.Mode{201},
+			}},
+		},
+	}
+	if dpOn {
+		s.crtcs[12] = &randr.GetCrtcInfoReply{X: 2560, Mode: 201, Width: 1920, Height: 1080, Rotation: rot0, Outputs: []randr.Output{dp}}
+		s.outputs[2].info.Crtc = 12
+	}
+	return s
+}
+
+func on(id, mode string, primary bool) planner.OutputPlan {
+	w, h, _ := planner.ModeSize(mode)
+	return planner.OutputPlan{ID: id, Enabled: true, Primary: primary, Mode: mode, Width: w, Height: h}
+}
+
+func next(op planner.OutputPlan, rel models.Position, to string) planner.OutputPlan {
+	op.Relation, op.RelativeTo = rel, to
+	return op
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		dpOn    bool
+		outputs []planner.OutputPlan
+		want    []crtcConfig
+		primary randr.Output
+	}{
+		{
+			name: "dual",
+			outputs: []planner.OutputPlan{
+				on("HDMI-1", "3840x2160", true),
+				next(on("eDP-1", "2560x1600", false), models.PositionRight, "HDMI-1"),
+			},
+			want: []crtcConfig{
+				{crtc: 11, mode: 200, rotation: rot0, outputs: []randr.Output{hdmi}},
+				{crtc: 10, x: 3840, mode: 100, rotation: rot0, outputs: []randr.Output{eDP}},
+			},
+			primary: hdmi,
+		},
+		{
+			name: "single external reuses the panel's CRTC",
+			outputs: []planner.OutputPlan{
+				on("HDMI-1", "1920x1080", true),
+				{ID: "eDP-1"},
+			},
+			want: []crtcConfig{
+				{crtc: 10, rotation: rot0},
+				{crtc: 10, mode: 201, rotation: rot0, outputs: []randr.Output{hdmi}},
+			},
+			primary: hdmi,
+		},
+		{
+			name: "rate picks among same-size modes",
+			outputs: []planner.OutputPlan{
+				func() planner.OutputPlan { op := on("HDMI-1", "1920x1080", true); op.Rate = 50; return op }(),
+				{ID: "eDP-1"},
+			},
+			want: []crtcConfig{
+				{crtc: 10, rotation: rot0},
+				{crtc: 10, mode: 202, rotation: rot0, outputs: []randr.Output{hdmi}},
+			},
+			primary: hdmi,
+		},
+		{
+			name: "absolute",
+			outputs: []planner.OutputPlan{
+				func() planner.OutputPlan {
+					op := on("eDP-1", "1920x1200", true)
+					op.Absolute, op.X, op.Y = true, 0, 2160
+					return op
+				}(),
+				func() planner.OutputPlan { op := on("HDMI-1", "3840x2160", false); op.Absolute = true; return op }(),
+			},
+			want: []crtcConfig{
+				{crtc: 10, y: 2160, mode: 101, rotation: rot0, outputs: []randr.Output{eDP}},
+				{crtc: 11, mode: 200, rotation: rot0, outputs: []randr.Output{hdmi}},
+			},
+			primary: eDP,
+		},
+		{
+			name: "untouched output is left alone",
+			dpOn: true,
+			outputs: []planner.OutputPlan{
+				on("eDP-1", "2560x1600", true),
+				next(on("HDMI-1", "1920x1080", false), models.PositionBelow, "eDP-1"),
+			},
+			want: []crtcConfig{
+				{crtc: 10, mode: 100, rotation: rot0, outputs: []randr.Output{eDP}},
+				{crtc: 11, y: 1600, mode: 201, rotation: rot0, outputs: []randr.Output{hdmi}},
+			},
+			primary: eDP,
+		},
+		{
+			name: "untouched output moves with the shift",
+			dpOn: true,
+			outputs: []planner.OutputPlan{
+				on("eDP-1", "2560x1600", true),
+				next(on("HDMI-1", "3840x2160", false), models.PositionLeft, "eDP-1"),
+			},
+			want: []crtcConfig{
+				{crtc: 10, x: 3840, mode: 100, rotation: rot0, outputs: []randr.Output{eDP}},
+				{crtc: 11, mode: 200, rotation: rot0, outputs: []randr.Output{hdmi}},
+				{crtc: 12, x: 6400, mode: 201, rotation: rot0, outputs: []randr.Output{dp}},
+			},
+			primary: eDP,
+		},
+		{
+			name: "relative to an output outside the plan",
+			dpOn: true,
+			outputs: []planner.OutputPlan{
+				next(on("HDMI-1", "1920x1080", false), models.PositionRight, "DP-1"),
+			},
+			want: []crtcConfig{
+				{crtc: 11, x: 4480, mode: 201, rotation: rot0, outputs: []randr.Output{hdmi}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testSession(tt.dpOn)
+			changes, primary, err := s.resolve(&planner.Plan{Outputs: tt.outputs})
+			if err != nil {
+				t.Fatalf("resolve: %v", err)
+			}
+			if !reflect.DeepEqual(changes, tt.want) {
+				t.Errorf("changes:\n got %+v\nwant %+v", changes, tt.want)
+			}
+			if primary != tt.primary {
+				t.Errorf("primary = %d, want %d", primary, tt.primary)
+			}
+		})
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		outputs []planner.OutputPlan
+		want    string
+	}{
+		{
+			name:    "unknown output",
+			outputs: []planner.OutputPlan{on("HDMI-9", "1920x1080", true)},
+			want:    "display HDMI-9 not found",
+		},
+		{
+			name:    "missing mode",
+			outputs: []planner.OutputPlan{on("HDMI-1", "1280x720", true)},
+			want:    "mode 1280x720 not available for HDMI-1",
+		},
+		{
+			name: "no free CRTC",
+			outputs: []planner.OutputPlan{
+				on("eDP-1", "2560x1600", true),
+				on("HDMI-1", "1920x1080", false),
+				{ID: "DP-1", Enabled: true, Mode: "1920x1080", Width: 1920, Height: 1080},
+			},
+			want: "no free CRTC available for DP-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testSession(false)
+			if tt.name == "no free CRTC" {
+				// DP-1 can only drive CRTC 12, which another output holds.
+				s.crtcs[12].Mode = 201
+				s.crtcs[12].Outputs = []randr.Output{99}
+			}
+			_, _, err := s.resolve(&planner.Plan{Outputs: tt.outputs})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestScreenSize(t *testing.T) {
+	s := testSession(true)
+	changes := []crtcConfig{
+		{crtc: 10, rotation: rot0},
+		{crtc: 11, mode: 200, rotation: uint16(randr.RotationRotate90), outputs: []randr.Output{hdmi}},
+	}
+
+	// HDMI-1 is rotated to 2160x3840; DP-1 keeps its 1920x1080 at 2560,0.
+	w, h := s.screenSize(changes)
+	if w != 4480 || h != 3840 {
+		t.Errorf("screenSize = %dx%d, want 4480x3840", w, h)
+	}
+}
@@ -0,0 +1,54 @@
+package x11
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jezek/xgb/randr"
+)
+
+// changeMask selects the RandR events that follow hotplug and mode changes.
+const changeMask = randr.NotifyMaskScreenChange | randr.NotifyMaskCrtcChange | randr.NotifyMaskOutputChange
+
+// Changes implements adapter.ChangeNotifier with RandR notify events on a
+// connection of its own, kept open until ctx is done.
+func (b *Backend) Changes(ctx context.Context) (<-chan struct{}, error) {
+	s, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+	if err := randr.SelectInputChecked(s.conn, s.root, changeMask).Check(); err != nil {
+		s.close()
+		return nil, fmt.Errorf("failed to select RandR events: %w", err)
+	}
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		<-ctx.Done()
+		s.close()
+	}()
+	go func() {
+		defer close(changes)
+		for {
+			ev, xerr := s.conn.WaitForEvent()
+			if ev == nil && xerr == nil {
+				return
+			}
+			if xerr != nil {
+				b.logger.WithError(xerr).Debug("X error while waiting for RandR events")
+				continue
+			}
+
+			switch ev.(type) {
+			case randr.ScreenChangeNotifyEvent, randr.NotifyEvent:
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	b.logger.Debug("Watching RandR change notifications")
+	return changes, nil
+}
@@ -0,0 +1,149 @@
+package x11
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jezek/xgb/randr"
+	"github.com/jezek/xgb/xproto"
+	"github.com/sirupsen/logrus"
+)
+
+// maxICCSize bounds how much of an _ICC_PROFILE property is read back.
+const maxICCSize = 16 << 20
+
+// SetICCProfile publishes a profile the two ways the "ICC Profiles in X"
+// specification describes: the _ICC_PROFILE property of the RandR output,
+// and the root window atom _ICC_PROFILE(_n) for the n-th monitor.
+func (b *Backend) SetICCProfile(ctx context.Context, displayID string, data []byte) error {
+	s, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	o, ok := s.output(displayID)
+	if !ok {
+		return fmt.Errorf("display %s not found", displayID)
+	}
+
+	atom, err := s.internAtom("_ICC_PROFILE")
+	if err != nil {
+		return err
+	}
+	if err := randr.ChangeOutputPropertyChecked(s.conn, o.id, atom, xproto.AtomCardinal, 8,
+		xproto.PropModeReplace, uint32(len(data)), data).Check(); err != nil {
+		return fmt.Errorf("failed to set _ICC_PROFILE on %s: %w", displayID, err)
+	}
+
+	index, err := s.monitorIndex(o.id)
+	if err != nil {
+		return err
+	}
+	if index < 0 {
+		b.logger.WithField("display", displayID).Debug("Output is not part of an active monitor, skipping root window atom")
+		return nil
+	}
+
+	name := "_ICC_PROFILE"
+	if index > 0 {
+		name = fmt.Sprintf("_ICC_PROFILE_%d", index)
+	}
+	rootAtom, err := s.internAtom(name)
+	if err != nil {
+		return err
+	}
+	if err := xproto.ChangePropertyChecked(s.conn, xproto.PropModeReplace, s.root, rootAtom,
+		xproto.AtomCardinal, 8, uint32(len(data)), data).Check(); err != nil {
+		return fmt.Errorf("failed to set %s: %w", name, err)
+	}
+
+	b.logger.WithFields(logrus.Fields{
+		"display": displayID,
+		"atom":    name,
+		"bytes":   len(data),
+	}).Debug("Set ICC profile")
+
+	return nil
+}
+
+// GetICCProfile returns the output's _ICC_PROFILE property, or nil when unset.
+func (b *Backend) GetICCProfile(ctx context.Context, displayID string) ([]byte, error) {
+	s, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+
+	o, ok := s.output(displayID)
+	if !ok {
+		return nil, fmt.Errorf("display %s not found", displayID)
+	}
+
+	atom, err := s.atom("_ICC_PROFILE")
+	if err != nil || atom == xproto.AtomNone {
+		return nil, err
+	}
+
+	prop, err := randr.GetOutputProperty(s.conn, o.id, atom, xproto.AtomAny, 0, maxICCSize/4, false, false).Reply()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read _ICC_PROFILE of %s: %w", displayID, err)
+	}
+	if prop.Format != 8 || len(prop.Data) == 0 {
+		return nil, nil
+	}
+	return prop.Data, nil
+}
+
+func (s *session) output(name string) (output, bool) {
+	for _, o := range s.outputs {
+		if o.name == name {
+			return o, true
+		}
+	}
+	return output{}, false
+}
+
+// internAtom returns the atom for name, creating it if needed.
+func (s *session) internAtom(name string) (xproto.Atom, error) {
+	reply, err := xproto.InternAtom(s.conn, false, uint16(len(name)), name).Reply()
+	if err != nil {
+		return xproto.AtomNone, fmt.Errorf("failed to intern atom %s: %w", name, err)
+	}
+	return reply.Atom, nil
+}
+
+// monitorIndex returns the position of the active monitor showing output o,
+// which is the Xinerama screen number clients use, or -1.
+func (s *session) monitorIndex(o randr.Output) (int, error) {
+	if s.minor < 5 {
+		// Without monitors, Xinerama screens follow the order of active CRTCs.
+		index := 0
+		for _, c := range s.res.Crtcs {
+			info := s.crtcs[c]
+			if info.Mode == 0 {
+				continue
+			}
+			for _, out := range info.Outputs {
+				if out == o {
+					return index, nil
+				}
+			}
+			index++
+		}
+		return -1, nil
+	}
+
+	reply, err := randr.GetMonitors(s.conn, s.root, true).Reply()
+	if err != nil {
+		return -1, fmt.Errorf("failed to get monitors: %w", err)
+	}
+	for i, m := range reply.Monitors {
+		for _, out := range m.Outputs {
+			if out == o {
+				return i, nil
+			}
+		}
+	}
+	return -1, nil
+}
@@ -0,0 +1,127 @@
+package x11
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/abhishek/dmon-cli/internal/modeline"
+	"github.com/jezek/xgb/randr"
+	"github.com/sirupsen/logrus"
+)
+
+// AddMode creates the mode on the server unless one of the same name exists,
+// then adds it to the output.
+func (b *Backend) AddMode(ctx context.Context, displayID string, mode modeline.Modeline) error {
+	s, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	o, ok := s.output(displayID)
+	if !ok {
+		return fmt.Errorf("display %s not found", displayID)
+	}
+
+	id, ok := s.modeByName(mode.Name)
+	if !ok {
+		reply, err := randr.CreateMode(s.conn, s.root, randrMode(mode), mode.Name).Reply()
+		if err != nil {
+			return fmt.Errorf("failed to create mode %s: %w", mode.Name, err)
+		}
+		id = reply.Mode
+	}
+
+	for _, existing := range o.info.Modes {
+		if existing == id {
+			return nil
+		}
+	}
+
+	b.logger.WithFields(logrus.Fields{
+		"display":  displayID,
+		"modeline": mode.String(),
+	}).Debug("Adding custom mode")
+
+	if err := randr.AddOutputModeChecked(s.conn, o.id, id).Check(); err != nil {
+		return fmt.Errorf("failed to add mode %s to %s: %w", mode.Name, displayID, err)
+	}
+	return nil
+}
+
+// DeleteMode removes a mode from an output's mode list.
+func (b *Backend) DeleteMode(ctx context.Context, displayID string, name string) error {
+	s, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	o, ok := s.output(displayID)
+	if !ok {
+		return fmt.Errorf("display %s not found", displayID)
+	}
+
+	for _, id := range o.info.Modes {
+		if s.modes[id].name != name {
+			continue
+		}
+		if err := randr.DeleteOutputModeChecked(s.conn, o.id, id).Check(); err != nil {
+			return fmt.Errorf("failed to delete mode %s from %s: %w", name, displayID, err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("mode %s not found on %s", name, displayID)
+}
+
+// DestroyMode removes a mode from the server.
+func (b *Backend) DestroyMode(ctx context.Context, name string) error {
+	s, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	id, ok := s.modeByName(name)
+	if !ok {
+		return fmt.Errorf("mode %s not found", name)
+	}
+	if err := randr.DestroyModeChecked(s.conn, id).Check(); err != nil {
+		return fmt.Errorf("failed to destroy mode %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *session) modeByName(name string) (randr.Mode, bool) {
+	for id, mi := range s.modes {
+		if mi.name == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+func randrMode(m modeline.Modeline) randr.ModeInfo {
+	var flags uint32 = randr.ModeFlagHsyncNegative | randr.ModeFlagVsyncNegative
+	if m.HSyncPos {
+		flags ^= randr.ModeFlagHsyncNegative | randr.ModeFlagHsyncPositive
+	}
+	if m.VSyncPos {
+		flags ^= randr.ModeFlagVsyncNegative | randr.ModeFlagVsyncPositive
+	}
+
+	return randr.ModeInfo{
+		Width:      uint16(m.HDisplay),
+		Height:     uint16(m.VDisplay),
+		DotClock:   uint32(m.Clock) * 1000,
+		HsyncStart: uint16(m.HSyncStart),
+		HsyncEnd:   uint16(m.HSyncEnd),
+		Htotal:     uint16(m.HTotal),
+		VsyncStart: uint16(m.VSyncStart),
+		VsyncEnd:   uint16(m.VSyncEnd),
+		Vtotal:     uint16(m.VTotal),
+		NameLen:    uint16(len(m.Name)),
+		ModeFlags:  flags,
+	}
+}
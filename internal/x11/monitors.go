@@ -0,0 +1,87 @@
+package x11
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/jezek/xgb/randr"
+	"github.com/sirupsen/logrus"
+)
+
+// monitorMinor is the RandR minor version that introduced monitors.
+const monitorMinor = 5
+
+func (b *Backend) ListMonitors(ctx context.Context) ([]models.Monitor, error) {
+	s, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+
+	return s.monitors()
+}
+
+// SetMonitor defines a monitor, replacing one of the same name.
+func (b *Backend) SetMonitor(ctx context.Context, monitor models.Monitor) error {
+	s, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	if s.minor < monitorMinor {
+		return fmt.Errorf("RandR 1.%d or newer is needed for monitors (server has 1.%d)", monitorMinor, s.minor)
+	}
+
+	name, err := s.internAtom(monitor.Name)
+	if err != nil {
+		return err
+	}
+
+	info := randr.MonitorInfo{
+		Name:                name,
+		Primary:             monitor.Primary,
+		X:                   int16(monitor.X),
+		Y:                   int16(monitor.Y),
+		Width:               uint16(monitor.Width),
+		Height:              uint16(monitor.Height),
+		WidthInMillimeters:  uint32(monitor.WidthMM),
+		HeightInMillimeters: uint32(monitor.HeightMM),
+	}
+	for _, outputName := range monitor.Outputs {
+		o, ok := s.output(outputName)
+		if !ok {
+			return fmt.Errorf("display %s not found", outputName)
+		}
+		info.Outputs = append(info.Outputs, o.id)
+	}
+	info.NOutput = uint16(len(info.Outputs))
+
+	b.logger.WithFields(logrus.Fields{
+		"monitor": monitor.Name,
+		"outputs": monitor.Outputs,
+	}).Debug("Setting monitor")
+
+	if err := randr.SetMonitorChecked(s.conn, s.root, info).Check(); err != nil {
+		return fmt.Errorf("failed to set monitor %s: %w", monitor.Name, err)
+	}
+	return nil
+}
+
+func (b *Backend) DeleteMonitor(ctx context.Context, name string) error {
+	s, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	atom, err := s.atom(name)
+	if err != nil {
+		return err
+	}
+	if err := randr.DeleteMonitorChecked(s.conn, s.root, atom).Check(); err != nil {
+		return fmt.Errorf("failed to delete monitor %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package x11
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/jezek/xgb/randr"
+	"github.com/sirupsen/logrus"
+)
+
+// providerMinor is the RandR minor version that introduced providers.
+const providerMinor = 4
+
+// ListProviders returns the RandR providers, or none before RandR 1.4.
+func (b *Backend) ListProviders(ctx context.Context) ([]models.Provider, error) {
+	s, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+
+	if s.minor < providerMinor {
+		return nil, nil
+	}
+
+	reply, err := randr.GetProviders(s.conn, s.root).Reply()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get providers: %w", err)
+	}
+
+	providers := make([]models.Provider, 0, len(reply.Providers))
+	for i, id := range reply.Providers {
+		info, err := randr.GetProviderInfo(s.conn, id, s.res.ConfigTimestamp).Reply()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get provider %#x: %w", uint32(id), err)
+		}
+		providers = append(providers, models.Provider{
+			Index:        i,
+			ID:           uint32(id),
+			Name:         info.Name,
+			Capabilities: models.ProviderCapability(info.Capabilities),
+			CRTCs:        int(info.NumCrtcs),
+			Outputs:      int(info.NumOutputs),
+			Associated:   int(info.NumAssociatedProviders),
+		})
+	}
+
+	return providers, nil
+}
+
+// LinkProvider sets the output source of sink, like xrandr --setprovideroutputsource.
+func (b *Backend) LinkProvider(ctx context.Context, sink, source models.Provider) error {
+	s, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	b.logger.WithFields(logrus.Fields{
+		"sink":   sink.String(),
+		"source": source.String(),
+	}).Debug("Linking provider output source")
+
+	err = randr.SetProviderOutputSourceChecked(s.conn, randr.Provider(sink.ID), randr.Provider(source.ID), s.res.ConfigTimestamp).Check()
+	if err != nil {
+		return fmt.Errorf("failed to link %s to %s: %w", sink, source, err)
+	}
+	return nil
+}
@@ -0,0 +1,484 @@
+package x11
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/planner"
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/randr"
+	"github.com/jezek/xgb/xproto"
+	"github.com/sirupsen/logrus"
+)
+
+// Backend talks RandR directly over the X socket instead of forking xrandr.
+// Every call opens its own connection so the backend stays as stateless as
+// the xrandr one.
+type Backend struct {
+	logger  *logrus.Logger
+	planner *planner.Planner
+	display string
+}
+
+func NewBackend(logger *logrus.Logger) *Backend {
+	return &Backend{
+		logger:  logger,
+		planner: planner.New(logger),
+	}
+}
+
+// WithPlanner replaces the planner, e.g. one with custom resolvers.
+func (b *Backend) WithPlanner(p *planner.Planner) *Backend {
+	b.planner = p
+	return b
+}
+
+// WithDisplay targets an explicit X display (e.g. ":99" for Xvfb) instead of $DISPLAY.
+func (b *Backend) WithDisplay(display string) *Backend {
+	b.display = display
+	return b
+}
+
+const (
+	minMajor = 1
+	minMinor = 2
+	edidLen  = 256
+)
+
+type modeInfo struct {
+	info randr.ModeInfo
+	name string
+	rate float64
+}
+
+type output struct {
+	id   randr.Output
+	name string
+	info *randr.GetOutputInfoReply
+}
+
+// session holds a connection and a snapshot of the screen resources.
+type session struct {
+	conn    *xgb.Conn
+	root    xproto.Window
+	screen  *xproto.ScreenInfo
+	minor   uint32
+	res     *randr.GetScreenResourcesCurrentReply
+	modes   map[randr.Mode]modeInfo
+	outputs []output
+	crtcs   map[randr.Crtc]*randr.GetCrtcInfoReply
+	primary randr.Output
+}
+
+func (b *Backend) open() (*session, error) {
+	conn, err := xgb.NewConnDisplay(b.display)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to X server: %w", err)
+	}
+
+	if err := randr.Init(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("RandR extension not available: %w", err)
+	}
+
+	version, err := randr.QueryVersion(conn, 1, 5).Reply()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("RandR version query failed: %w", err)
+	}
+	if version.MajorVersion < minMajor || (version.MajorVersion == minMajor && version.MinorVersion < minMinor) {
+		conn.Close()
+		return nil, fmt.Errorf("RandR %d.%d or newer required (server has %d.%d)",
+			minMajor, minMinor, version.MajorVersion, version.MinorVersion)
+	}
+
+	screen := xproto.Setup(conn).DefaultScreen(conn)
+	s := &session{
+		conn:   conn,
+		root:   screen.Root,
+		screen: screen,
+		minor:  version.MinorVersion,
+	}
+
+	if err := s.refresh(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	b.logger.WithFields(logrus.Fields{
+		"randr":   fmt.Sprintf("%d.%d", version.MajorVersion, version.MinorVersion),
+		"outputs": len(s.outputs),
+		"crtcs":   len(s.crtcs),
+	}).Debug("Connected to X server")
+
+	return s, nil
+}
+
+// Probe connects to the display and reports the RandR version it offers.
+func Probe(display string) (string, error) {
+	b := &Backend{logger: discardLogger(), display: display}
+	s, err := b.open()
+	if err != nil {
+		return "", err
+	}
+	defer s.close()
+
+	return fmt.Sprintf("RandR 1.%d", s.minor), nil
+}
+
+func discardLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func (s *session) close() {
+	s.conn.Close()
+}
+
+func (s *session) refresh() error {
+	res, err := randr.GetScreenResourcesCurrent(s.conn, s.root).Reply()
+	if err != nil {
+		return fmt.Errorf("failed to get screen resources: %w", err)
+	}
+	s.res = res
+
+	s.modes = make(map[randr.Mode]modeInfo, len(res.Modes))
+	offset := 0
+	for _, m := range res.Modes {
+		end := offset + int(m.NameLen)
+		if end > len(res.Names) {
+			end = len(res.Names)
+		}
+		s.modes[randr.Mode(m.Id)] = modeInfo{
+			info: m,
+			name: string(res.Names[offset:end]),
+			rate: modeRate(m),
+		}
+		offset = end
+	}
+
+	s.crtcs = make(map[randr.Crtc]*randr.GetCrtcInfoReply, len(res.Crtcs))
+	for _, c := range res.Crtcs {
+		info, err := randr.GetCrtcInfo(s.conn, c, res.ConfigTimestamp).Reply()
+		if err != nil {
+			return fmt.Errorf("failed to get CRTC %d info: %w", c, err)
+		}
+		s.crtcs[c] = info
+	}
+
+	s.outputs = s.outputs[:0]
+	for _, o := range res.Outputs {
+		info, err := randr.GetOutputInfo(s.conn, o, res.ConfigTimestamp).Reply()
+		if err != nil {
+			return fmt.Errorf("failed to get output %d info: %w", o, err)
+		}
+		s.outputs = append(s.outputs, output{
+			id:   o,
+			name: string(info.Name),
+			info: info,
+		})
+	}
+
+	primary, err := randr.GetOutputPrimary(s.conn, s.root).Reply()
+	if err != nil {
+		return fmt.Errorf("failed to get primary output: %w", err)
+	}
+	s.primary = primary.Output
+
+	return nil
+}
+
+func modeRate(m randr.ModeInfo) float64 {
+	if m.Htotal == 0 || m.Vtotal == 0 {
+		return 0
+	}
+
+	vtotal := float64(m.Vtotal)
+	if m.ModeFlags&randr.ModeFlagDoubleScan != 0 {
+		vtotal *= 2
+	}
+	if m.ModeFlags&randr.ModeFlagInterlace != 0 {
+		vtotal /= 2
+	}
+
+	return float64(m.DotClock) / (float64(m.Htotal) * vtotal)
+}
+
+func (s *session) display(o output) models.Display {
+	d := models.Display{
+		ID:        o.name,
+		Type:      models.IdentifyDisplayType(o.name),
+		Connected: o.info.Connection == randr.ConnectionConnected,
+		Modes:     []models.Mode{},
+	}
+
+	if !d.Connected {
+		return d
+	}
+
+	d.WidthMM = int(o.info.MmWidth)
+	d.HeightMM = int(o.info.MmHeight)
+
+	d.Primary = o.id == s.primary
+
+	var current randr.Mode
+	if crtc, ok := s.crtcs[o.info.Crtc]; ok && o.info.Crtc != 0 {
+		current = crtc.Mode
+		d.X = int(crtc.X)
+		d.Y = int(crtc.Y)
+		d.Rotation = rotationName(crtc.Rotation)
+	}
+
+	for i, id := range o.info.Modes {
+		mi, ok := s.modes[id]
+		if !ok {
+			continue
+		}
+		mode := models.Mode{
+			Name:       mi.name,
+			Width:      int(mi.info.Width),
+			Height:     int(mi.info.Height),
+			Rate:       mi.rate,
+			Interlaced: mi.info.ModeFlags&randr.ModeFlagInterlace != 0,
+			Current:    id == current,
+			Preferred:  i < int(o.info.NumPreferred),
+		}
+		d.Modes = append(d.Modes, mode)
+
+		if mode.Current {
+			modeCopy := mode
+			d.CurrentMode = &modeCopy
+		}
+	}
+
+	d.EDID = s.edid(o.id)
+	d.Properties = s.properties(o.id, "ConnectorType", "CONNECTOR_ID")
+
+	return d
+}
+
+// rotationName uses the same names xrandr prints.
+func rotationName(rotation uint16) string {
+	switch {
+	case rotation&randr.RotationRotate90 != 0:
+		return "left"
+	case rotation&randr.RotationRotate180 != 0:
+		return "inverted"
+	case rotation&randr.RotationRotate270 != 0:
+		return "right"
+	default:
+		return "normal"
+	}
+}
+
+func (s *session) edid(o randr.Output) []byte {
+	atom, err := s.atom("EDID")
+	if err != nil || atom == xproto.AtomNone {
+		return nil
+	}
+
+	prop, err := randr.GetOutputProperty(s.conn, o, atom, xproto.AtomAny, 0, edidLen/4, false, false).Reply()
+	if err != nil || prop.Format != 8 || len(prop.Data) == 0 {
+		return nil
+	}
+
+	return prop.Data
+}
+
+// properties reads scalar output properties (atoms and integers), the ones
+// display classification uses. Missing properties are left out.
+func (s *session) properties(o randr.Output, names ...string) map[string]string {
+	var props map[string]string
+	for _, name := range names {
+		atom, err := s.atom(name)
+		if err != nil || atom == xproto.AtomNone {
+			continue
+		}
+
+		prop, err := randr.GetOutputProperty(s.conn, o, atom, xproto.AtomAny, 0, 1, false, false).Reply()
+		if err != nil || prop.Format != 32 || len(prop.Data) < 4 {
+			continue
+		}
+
+		var value string
+		switch prop.Type {
+		case xproto.AtomAtom:
+			value = s.atomName(xproto.Atom(xgb.Get32(prop.Data)))
+		case xproto.AtomInteger, xproto.AtomCardinal:
+			value = fmt.Sprint(int32(xgb.Get32(prop.Data)))
+		default:
+			continue
+		}
+
+		if props == nil {
+			props = map[string]string{}
+		}
+		props[name] = value
+	}
+	return props
+}
+
+func (s *session) atom(name string) (xproto.Atom, error) {
+	reply, err := xproto.InternAtom(s.conn, true, uint16(len(name)), name).Reply()
+	if err != nil {
+		return xproto.AtomNone, err
+	}
+	return reply.Atom, nil
+}
+
+func (s *session) atomName(atom xproto.Atom) string {
+	reply, err := xproto.GetAtomName(s.conn, atom).Reply()
+	if err != nil {
+		return fmt.Sprintf("atom-%d", atom)
+	}
+	return reply.Name
+}
+
+func (s *session) monitors() ([]models.Monitor, error) {
+	if s.minor < monitorMinor {
+		return nil, nil
+	}
+
+	reply, err := randr.GetMonitors(s.conn, s.root, true).Reply()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get monitors: %w", err)
+	}
+
+	names := make(map[randr.Output]string, len(s.outputs))
+	for _, o := range s.outputs {
+		names[o.id] = o.name
+	}
+
+	monitors := make([]models.Monitor, 0, len(reply.Monitors))
+	for _, m := range reply.Monitors {
+		monitor := models.Monitor{
+			Name:      s.atomName(m.Name),
+			Primary:   m.Primary,
+			Automatic: m.Automatic,
+			X:         int(m.X),
+			Y:         int(m.Y),
+			Width:     int(m.Width),
+			Height:    int(m.Height),
+			WidthMM:   int(m.WidthInMillimeters),
+			HeightMM:  int(m.HeightInMillimeters),
+		}
+		for _, o := range m.Outputs {
+			if name, ok := names[o]; ok {
+				monitor.Outputs = append(monitor.Outputs, name)
+			}
+		}
+		monitors = append(monitors, monitor)
+	}
+
+	return monitors, nil
+}
+
+func (b *Backend) DetectDisplays(ctx context.Context) ([]models.Display, error) {
+	b.logger.Debug("Detecting displays via RandR")
+
+	s, err := b.open()
+	if err != nil {
+		b.logger.WithError(err).Error("Failed to query RandR")
+		return nil, err
+	}
+	defer s.close()
+
+	displays := s.displays()
+	b.logDisplays(displays)
+
+	return displays, nil
+}
+
+func (s *session) displays() []models.Display {
+	displays := make([]models.Display, 0, len(s.outputs))
+	for _, o := range s.outputs {
+		displays = append(displays, s.display(o))
+	}
+	return displays
+}
+
+func (b *Backend) logDisplays(displays []models.Display) {
+	connected := 0
+	for _, d := range displays {
+		if d.Connected {
+			connected++
+		}
+	}
+
+	b.logger.WithFields(logrus.Fields{
+		"total":     len(displays),
+		"connected": connected,
+	}).Info("Displays detected")
+
+	for _, d := range displays {
+		b.logger.WithFields(logrus.Fields{
+			"id":        d.ID,
+			"type":      d.Type,
+			"connected": d.Connected,
+			"modes":     len(d.Modes),
+			"edid":      len(d.EDID) > 0,
+		}).Debug("Display details")
+	}
+}
+
+func (b *Backend) GetCurrentLayout(ctx context.Context) (*models.Layout, error) {
+	b.logger.Debug("Getting current layout")
+
+	s, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+
+	displays := s.displays()
+	b.logDisplays(displays)
+
+	layout := &models.Layout{
+		Displays: displays,
+	}
+
+	for _, o := range s.outputs {
+		if o.id == s.primary {
+			layout.Primary = o.name
+			break
+		}
+	}
+
+	// Without an explicit primary, fall back to the first active display like the xrandr backend.
+	if layout.Primary == "" {
+		for _, d := range displays {
+			if d.Connected && d.CurrentMode != nil {
+				layout.Primary = d.ID
+				break
+			}
+		}
+	}
+
+	layout.Monitors, err = s.monitors()
+	if err != nil {
+		return nil, err
+	}
+
+	return layout, nil
+}
+
+func (b *Backend) GetSupportedModes(ctx context.Context, displayID string) ([]models.Mode, error) {
+	b.logger.WithField("display", displayID).Debug("Getting supported modes")
+
+	s, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+
+	for _, o := range s.outputs {
+		if o.name == displayID {
+			return s.display(o).Modes, nil
+		}
+	}
+
+	return nil, fmt.Errorf("display %s not found", displayID)
+}
@@ -0,0 +1,50 @@
+package xrandr
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// GetBrightness reads the software brightness xrandr reports in --verbose
+// output. Outputs without the property are at full brightness.
+func (b *Backend) GetBrightness(ctx context.Context, displayID string) (float64, error) {
+	displays, err := b.DetectDisplayProperties(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, d := range displays {
+		if d.ID != displayID {
+			continue
+		}
+		value, ok := d.Properties["Brightness"]
+		if !ok {
+			return 1, nil
+		}
+		level, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid brightness %q for %s", value, displayID)
+		}
+		return level, nil
+	}
+
+	return 0, fmt.Errorf("display %s not found", displayID)
+}
+
+// SetBrightness scales the output's gamma ramp with `xrandr --brightness`.
+// This dims the picture, not the panel's backlight.
+func (b *Backend) SetBrightness(ctx context.Context, displayID string, level float64) error {
+	b.logger.WithFields(logrus.Fields{
+		"display": displayID,
+		"level":   level,
+	}).Debug("Setting software brightness")
+
+	result, err := b.runner.Run(ctx, "xrandr", "--output", displayID, "--brightness", strconv.FormatFloat(level, 'f', 2, 64))
+	if err != nil {
+		return fmt.Errorf("xrandr failed: %w\nOutput: %s", err, string(result.Combined()))
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package xrandr
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/abhishek/dmon-cli/internal/gamma"
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// SetColor applies a colour correction with `xrandr --gamma`. xrandr only
+// takes gamma exponents, so the white point is folded into them.
+func (b *Backend) SetColor(ctx context.Context, displayID string, color models.Color) error {
+	g := gamma.Effective(color)
+	value := fmt.Sprintf("%.3f:%.3f:%.3f", g.Red, g.Green, g.Blue)
+
+	b.logger.WithFields(logrus.Fields{
+		"display": displayID,
+		"gamma":   value,
+	}).Debug("Setting gamma")
+
+	result, err := b.runner.Run(ctx, "xrandr", "--output", displayID, "--gamma", value)
+	if err != nil {
+		return fmt.Errorf("xrandr failed: %w\nOutput: %s", err, string(result.Combined()))
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package xrandr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/abhishek/dmon-cli/internal/modeline"
+	"github.com/sirupsen/logrus"
+)
+
+// AddMode runs `xrandr --newmode` and `xrandr --addmode`. A mode of the same
+// name left over from an earlier run is reused: names encode the timings.
+func (b *Backend) AddMode(ctx context.Context, displayID string, mode modeline.Modeline) error {
+	b.logger.WithFields(logrus.Fields{
+		"display":  displayID,
+		"modeline": mode.String(),
+	}).Debug("Adding custom mode")
+
+	args := append([]string{"--newmode", mode.Name}, mode.Args()...)
+	result, err := b.runner.Run(ctx, "xrandr", args...)
+	if err != nil && !strings.Contains(string(result.Combined()), "BadName") {
+		return fmt.Errorf("xrandr failed: %w\nOutput: %s", err, string(result.Combined()))
+	}
+
+	result, err = b.runner.Run(ctx, "xrandr", "--addmode", displayID, mode.Name)
+	if err != nil {
+		return fmt.Errorf("xrandr failed: %w\nOutput: %s", err, string(result.Combined()))
+	}
+	return nil
+}
+
+// DeleteMode runs `xrandr --delmode`.
+func (b *Backend) DeleteMode(ctx context.Context, displayID string, name string) error {
+	result, err := b.runner.Run(ctx, "xrandr", "--delmode", displayID, name)
+	if err != nil {
+		return fmt.Errorf("xrandr failed: %w\nOutput: %s", err, string(result.Combined()))
+	}
+	return nil
+}
+
+// DestroyMode runs `xrandr --rmmode`.
+func (b *Backend) DestroyMode(ctx context.Context, name string) error {
+	result, err := b.runner.Run(ctx, "xrandr", "--rmmode", name)
+	if err != nil {
+		return fmt.Errorf("xrandr failed: %w\nOutput: %s", err, string(result.Combined()))
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package xrandr
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/modeline"
+	"github.com/abhishek/dmon-cli/internal/runner"
+)
+
+func TestAddMode(t *testing.T) {
+	line, err := modeline.CVT(modeline.Spec{Width: 2560, Height: 1080, Rate: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		newmode string // stderr of a failing --newmode, empty for success
+		err     string
+	}{
+		{name: "new mode"},
+		{name: "mode left over from an earlier run", newmode: "X Error of failed request:  BadName (named color or font does not exist)"},
+		{name: "other newmode failure", newmode: "X Error of failed request:  BadValue", err: "BadValue"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			b := newTestBackend().WithRunner(runner.Func(func(ctx context.Context, name string, args ...string) (runner.Result, error) {
+				calls = append(calls, strings.Join(args, " "))
+				if args[0] == "--newmode" && tt.newmode != "" {
+					return runner.Result{Stderr: []byte(tt.newmode), ExitCode: 1}, &runner.ExitError{Name: name, ExitCode: 1, Stderr: tt.newmode}
+				}
+				return runner.Result{}, nil
+			}))
+
+			err := b.AddMode(context.Background(), "HDMI-1", line)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := []string{
+				"--newmode 2560x1080_60.00 230.00 2560 2720 2992 3424 1080 1083 1093 1120 -hsync +vsync",
+				"--addmode HDMI-1 2560x1080_60.00",
+			}
+			if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+				t.Errorf("got calls:\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package xrandr
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// monitorRegex matches a line of `xrandr --listmonitors`, e.g.
+// " 1: +*DP-1 5120/1190x1440/340+0+0  DP-1"; + marks automatic monitors and
+// * the primary one.
+var monitorRegex = regexp.MustCompile(`^\s*\d+: (\+?)(\*?)(\S+) (\d+)/(\d+)x(\d+)/(\d+)([+-]\d+)([+-]\d+)\s*(.*)$`)
+
+// ListMonitors runs `xrandr --listmonitors`.
+func (b *Backend) ListMonitors(ctx context.Context) ([]models.Monitor, error) {
+	result, err := b.runner.Run(ctx, "xrandr", "--listmonitors")
+	if err != nil {
+		return nil, fmt.Errorf("xrandr command failed: %w", err)
+	}
+
+	return parseMonitors(string(result.Stdout)), nil
+}
+
+// SetMonitor runs `xrandr --setmonitor`. A monitor without outputs is given
+// "none".
+func (b *Backend) SetMonitor(ctx context.Context, monitor models.Monitor) error {
+	name := monitor.Name
+	if monitor.Primary {
+		name = "*" + name
+	}
+	geometry := fmt.Sprintf("%d/%dx%d/%d+%d+%d", monitor.Width, monitor.WidthMM, monitor.Height, monitor.HeightMM, monitor.X, monitor.Y)
+	outputs := "none"
+	if len(monitor.Outputs) > 0 {
+		outputs = strings.Join(monitor.Outputs, ",")
+	}
+
+	b.logger.WithFields(logrus.Fields{
+		"monitor":  monitor.Name,
+		"geometry": geometry,
+		"outputs":  outputs,
+	}).Debug("Setting monitor")
+
+	result, err := b.runner.Run(ctx, "xrandr", "--setmonitor", name, geometry, outputs)
+	if err != nil {
+		return fmt.Errorf("xrandr failed: %w\nOutput: %s", err, string(result.Combined()))
+	}
+	return nil
+}
+
+// DeleteMonitor runs `xrandr --delmonitor`.
+func (b *Backend) DeleteMonitor(ctx context.Context, name string) error {
+	result, err := b.runner.Run(ctx, "xrandr", "--delmonitor", name)
+	if err != nil {
+		return fmt.Errorf("xrandr failed: %w\nOutput: %s", err, string(result.Combined()))
+	}
+	return nil
+}
+
+func parseMonitors(output string) []models.Monitor {
+	var monitors []models.Monitor
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		m := monitorRegex.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+
+		monitor := models.Monitor{
+			Name:      m[3],
+			Automatic: m[1] == "+",
+			Primary:   m[2] == "*",
+		}
+		monitor.Width, _ = strconv.Atoi(m[4])
+		monitor.WidthMM, _ = strconv.Atoi(m[5])
+		monitor.Height, _ = strconv.Atoi(m[6])
+		monitor.HeightMM, _ = strconv.Atoi(m[7])
+		monitor.X, _ = strconv.Atoi(m[8])
+		monitor.Y, _ = strconv.Atoi(m[9])
+		monitor.Outputs = strings.Fields(m[10])
+		monitors = append(monitors, monitor)
+	}
+
+	return monitors
+}
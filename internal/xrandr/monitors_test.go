@@ -0,0 +1,59 @@
+package xrandr
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/runner"
+)
+
+func TestParseMonitors(t *testing.T) {
+	output := `Monitors: 3
+ 0: +*eDP-1 1920/344x1080/194+0+0  eDP-1
+ 1: DP-1~1 2560/595x1440/340+1920+0  DP-1
+ 2: DP-1~2 2560/595x1440/340+4480+0
+`
+	want := []models.Monitor{
+		{Name: "eDP-1", Primary: true, Automatic: true, Width: 1920, Height: 1080, WidthMM: 344, HeightMM: 194, Outputs: []string{"eDP-1"}},
+		{Name: "DP-1~1", X: 1920, Width: 2560, Height: 1440, WidthMM: 595, HeightMM: 340, Outputs: []string{"DP-1"}},
+		{Name: "DP-1~2", X: 4480, Width: 2560, Height: 1440, WidthMM: 595, HeightMM: 340, Outputs: []string{}},
+	}
+
+	if got := parseMonitors(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestSetMonitor(t *testing.T) {
+	var calls []string
+	b := newTestBackend().WithRunner(runner.Func(func(ctx context.Context, name string, args ...string) (runner.Result, error) {
+		calls = append(calls, strings.Join(args, " "))
+		return runner.Result{}, nil
+	}))
+
+	ctx := context.Background()
+	monitors := []models.Monitor{
+		{Name: "DP-1~1", Primary: true, X: 1920, Width: 2560, Height: 1440, WidthMM: 595, HeightMM: 340, Outputs: []string{"DP-1"}},
+		{Name: "DP-1~2", X: 4480, Width: 2560, Height: 1440, WidthMM: 595, HeightMM: 340},
+	}
+	for _, m := range monitors {
+		if err := b.SetMonitor(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.DeleteMonitor(ctx, "DP-1~2"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"--setmonitor *DP-1~1 2560/595x1440/340+1920+0 DP-1",
+		"--setmonitor DP-1~2 2560/595x1440/340+4480+0 none",
+		"--delmonitor DP-1~2",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("got calls:\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+}
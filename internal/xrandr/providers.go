@@ -0,0 +1,81 @@
+package xrandr
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// providerRegex matches a line of `xrandr --listproviders`, e.g.
+// Provider 1: id: 0x1f4 cap: 0x2, Sink Output crtcs: 4 outputs: 5 associated providers: 0 name:NVIDIA-G0
+var providerRegex = regexp.MustCompile(`^Provider (\d+): id: (0x[0-9a-fA-F]+) cap: (0x[0-9a-fA-F]+).*? crtcs: (\d+) outputs: (\d+) associated providers: (\d+) name:(.*)$`)
+
+// ListProviders runs `xrandr --listproviders`.
+func (b *Backend) ListProviders(ctx context.Context) ([]models.Provider, error) {
+	result, err := b.runner.Run(ctx, "xrandr", "--listproviders")
+	if err != nil {
+		return nil, fmt.Errorf("xrandr command failed: %w", err)
+	}
+
+	return parseProviders(string(result.Stdout))
+}
+
+// LinkProvider runs `xrandr --setprovideroutputsource`. Providers are given
+// by id, since names need not be unique.
+func (b *Backend) LinkProvider(ctx context.Context, sink, source models.Provider) error {
+	b.logger.WithFields(logrus.Fields{
+		"sink":   sink.String(),
+		"source": source.String(),
+	}).Debug("Linking provider output source")
+
+	result, err := b.runner.Run(ctx, "xrandr", "--setprovideroutputsource",
+		fmt.Sprintf("%#x", sink.ID), fmt.Sprintf("%#x", source.ID))
+	if err != nil {
+		return fmt.Errorf("xrandr failed: %w\nOutput: %s", err, string(result.Combined()))
+	}
+	return nil
+}
+
+func parseProviders(output string) ([]models.Provider, error) {
+	var providers []models.Provider
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		matches := providerRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		index, _ := strconv.Atoi(matches[1])
+		id, err := strconv.ParseUint(matches[2], 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid provider id in %q", line)
+		}
+		caps, err := strconv.ParseUint(matches[3], 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid provider capabilities in %q", line)
+		}
+		crtcs, _ := strconv.Atoi(matches[4])
+		outputs, _ := strconv.Atoi(matches[5])
+		associated, _ := strconv.Atoi(matches[6])
+
+		providers = append(providers, models.Provider{
+			Index:        index,
+			ID:           uint32(id),
+			Name:         matches[7],
+			Capabilities: models.ProviderCapability(caps),
+			CRTCs:        crtcs,
+			Outputs:      outputs,
+			Associated:   associated,
+		})
+	}
+
+	return providers, scanner.Err()
+}
@@ -0,0 +1,81 @@
+package xrandr
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/runner"
+)
+
+func TestParseProviders(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []models.Provider
+	}{
+		{
+			name: "nvidia reverse prime, not linked",
+			output: `Providers: number : 2
+Provider 0: id: 0x48 cap: 0xf, Source Output, Sink Output, Source Offload, Sink Offload crtcs: 3 outputs: 1 associated providers: 0 name:modesetting
+Provider 1: id: 0x1f4 cap: 0x2, Sink Output crtcs: 4 outputs: 5 associated providers: 0 name:NVIDIA-G0
+`,
+			want: []models.Provider{
+				{Index: 0, ID: 0x48, Name: "modesetting", Capabilities: 0xf, CRTCs: 3, Outputs: 1},
+				{Index: 1, ID: 0x1f4, Name: "NVIDIA-G0", Capabilities: models.CapSinkOutput, CRTCs: 4, Outputs: 5},
+			},
+		},
+		{
+			name: "names with spaces, linked",
+			output: `Providers: number : 2
+Provider 0: id: 0x7d cap: 0xb, Source Output, Sink Output, Sink Offload crtcs: 3 outputs: 4 associated providers: 1 name:Intel
+Provider 1: id: 0x56 cap: 0xf, Source Output, Sink Output, Source Offload, Sink Offload crtcs: 6 outputs: 1 associated providers: 1 name:AMD Radeon HD 8750M @ pci:0000:01:00.0
+`,
+			want: []models.Provider{
+				{Index: 0, ID: 0x7d, Name: "Intel", Capabilities: 0xb, CRTCs: 3, Outputs: 4, Associated: 1},
+				{Index: 1, ID: 0x56, Name: "AMD Radeon HD 8750M @ pci:0000:01:00.0", Capabilities: 0xf, CRTCs: 6, Outputs: 1, Associated: 1},
+			},
+		},
+		{
+			name:   "no capabilities",
+			output: "Providers: number : 1\nProvider 0: id: 0x45 cap: 0x0 crtcs: 1 outputs: 1 associated providers: 0 name:VMware\n",
+			want:   []models.Provider{{Index: 0, ID: 0x45, Name: "VMware", CRTCs: 1, Outputs: 1}},
+		},
+		{
+			name:   "none",
+			output: "Providers: number : 0\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProviders(tt.output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkProvider(t *testing.T) {
+	var calls []string
+	b := newTestBackend().WithRunner(runner.Func(func(ctx context.Context, name string, args ...string) (runner.Result, error) {
+		calls = append(calls, strings.Join(args, " "))
+		return runner.Result{}, nil
+	}))
+
+	sink := models.Provider{Index: 1, ID: 0x1f4, Name: "NVIDIA-G0"}
+	source := models.Provider{ID: 0x48, Name: "modesetting"}
+	if err := b.LinkProvider(context.Background(), sink, source); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "--setprovideroutputsource 0x1f4 0x48"; len(calls) != 1 || calls[0] != want {
+		t.Errorf("got calls %q, want %q", calls, want)
+	}
+}
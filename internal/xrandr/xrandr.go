@@ -3,43 +3,78 @@ package xrandr
 import (
 	"bufio"
 	"context"
+	"encoding/hex"
 	"fmt"
-	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/abhishek/dmon-cli/internal/planner"
+	"github.com/abhishek/dmon-cli/internal/runner"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	commandTimeout = 10 * time.Second
+	retryAttempts  = 3
+	retryDelay     = 250 * time.Millisecond
+)
+
 type Backend struct {
-	logger *logrus.Logger
+	logger  *logrus.Logger
+	planner *planner.Planner
+	runner  runner.Runner
 }
 
 func NewBackend(logger *logrus.Logger) *Backend {
-	return &Backend{
-		logger: logger,
+	b := &Backend{
+		logger:  logger,
+		planner: planner.New(logger),
 	}
+	return b.WithRunner(runner.Exec{})
+}
+
+// WithPlanner replaces the planner, e.g. one with custom resolvers.
+func (b *Backend) WithPlanner(p *planner.Planner) *Backend {
+	b.planner = p
+	return b
+}
+
+// WithRunner replaces how xrandr is executed (recording, replay, fakes). The
+// timeout, logging and retry wrappers are applied on top of r, so every
+// attempt is bounded and logged separately.
+func (b *Backend) WithRunner(r runner.Runner) *Backend {
+	r = runner.WithTimeout(r, commandTimeout)
+	r = runner.WithLogging(r, b.logger)
+	b.runner = runner.WithRetry(r, retryAttempts, retryDelay, runner.TransientCrtcFailure, b.logger)
+	return b
 }
 
 var (
-	displayLineRegex = regexp.MustCompile(`^(\S+)\s+(connected|disconnected)`)
-	modeLineRegex    = regexp.MustCompile(`^\s+(\d+)x(\d+)\s+([0-9.]+)([*+\s]*)`)
-	internalPatterns = []string{"eDP", "LVDS"}
+	displayLineRegex = regexp.MustCompile(`^(\S+)\s+(connected|disconnected|unknown connection)(\s+primary)?` +
+		`(?:\s+(\d+)x(\d+)\+(-?\d+)\+(-?\d+))?(?:\s+\(0x[0-9a-f]+\))?(?:\s+(normal|left|inverted|right))?` +
+		`(?:\s+(?:X axis|Y axis|X and Y axis))?(?:\s+\([^)]*\))?(?:\s+(\d+)mm x (\d+)mm)?`)
+	modeLineRegex    = regexp.MustCompile(`^\s+((\d+)x(\d+)(i?)\S*)\s+(\d+\.\d+.*)$`)
+	rateRegex        = regexp.MustCompile(`(\d+\.\d+)(\*?)( ?\+)?`)
+	verboseModeRegex = regexp.MustCompile(`^\s+(\S+)\s+\(0x[0-9a-f]+\)\s+[0-9.]+MHz(.*)$`)
+	verboseSizeRegex = regexp.MustCompile(`^\s+h:\s+width\s+(\d+)`)
+	verboseRateRegex = regexp.MustCompile(`^\s+v:\s+height\s+(\d+).*clock\s+([0-9.]+)Hz`)
+	propertyRegex    = regexp.MustCompile(`^\t([^\s:][^:]*):\s*(.*?)\s*$`)
+	hexLineRegex     = regexp.MustCompile(`^\t\t([0-9a-f]+)$`)
 )
 
 func (b *Backend) DetectDisplays(ctx context.Context) ([]models.Display, error) {
 	b.logger.Debug("Detecting displays via xrandr")
 
-	cmd := exec.CommandContext(ctx, "xrandr", "--query")
-	output, err := cmd.Output()
+	result, err := b.runner.Run(ctx, "xrandr", "--query")
 	if err != nil {
 		b.logger.WithError(err).Error("Failed to execute xrandr")
 		return nil, fmt.Errorf("xrandr command failed: %w", err)
 	}
 
-	displays, err := b.parseXrandrOutput(string(output))
+	displays, err := b.parseXrandrOutput(string(result.Stdout))
 	if err != nil {
 		b.logger.WithError(err).Error("Failed to parse xrandr output")
 		return nil, err
@@ -69,60 +104,148 @@ func (b *Backend) DetectDisplays(ctx context.Context) ([]models.Display, error)
 	return displays, nil
 }
 
+// DetectDisplayProperties runs `xrandr --verbose`, which also reports EDID
+// and output properties but can take noticeably longer than --query.
+func (b *Backend) DetectDisplayProperties(ctx context.Context) ([]models.Display, error) {
+	result, err := b.runner.Run(ctx, "xrandr", "--verbose")
+	if err != nil {
+		return nil, fmt.Errorf("xrandr command failed: %w", err)
+	}
+
+	return b.parseXrandrOutput(string(result.Stdout))
+}
+
+// parseXrandrOutput understands both `xrandr --query` and `xrandr --verbose`
+// (or `--props`) output.
 func (b *Backend) parseXrandrOutput(output string) ([]models.Display, error) {
 	var displays []models.Display
 	var currentDisplay *models.Display
+	var verboseMode *models.Mode
+	var property string
+	var edid strings.Builder
+
+	// flushMode completes a --verbose mode block once its v: line has been seen.
+	flushMode := func() {
+		if verboseMode == nil {
+			return
+		}
+		b.addMode(currentDisplay, *verboseMode)
+		verboseMode = nil
+	}
+	flushDisplay := func() {
+		flushMode()
+		if currentDisplay == nil {
+			return
+		}
+		if edid.Len() > 0 {
+			currentDisplay.EDID, _ = hex.DecodeString(edid.String())
+			edid.Reset()
+		}
+		displays = append(displays, *currentDisplay)
+	}
 
 	scanner := bufio.NewScanner(strings.NewReader(output))
 	for scanner.Scan() {
 		line := scanner.Text()
 
 		if matches := displayLineRegex.FindStringSubmatch(line); matches != nil {
-			if currentDisplay != nil {
-				displays = append(displays, *currentDisplay)
-			}
+			flushDisplay()
 
 			displayID := matches[1]
 			connected := matches[2] == "connected"
 
 			currentDisplay = &models.Display{
 				ID:        displayID,
-				Type:      b.identifyDisplayType(displayID),
+				Type:      models.IdentifyDisplayType(displayID),
 				Connected: connected,
+				Primary:   matches[3] != "",
 				Modes:     []models.Mode{},
 			}
+			if matches[4] != "" {
+				currentDisplay.X, _ = strconv.Atoi(matches[6])
+				currentDisplay.Y, _ = strconv.Atoi(matches[7])
+				currentDisplay.Rotation = "normal"
+			}
+			if matches[8] != "" {
+				currentDisplay.Rotation = matches[8]
+			}
+			if matches[9] != "" {
+				currentDisplay.WidthMM, _ = strconv.Atoi(matches[9])
+				currentDisplay.HeightMM, _ = strconv.Atoi(matches[10])
+			}
+			property = ""
+			continue
+		}
+
+		if currentDisplay == nil {
+			continue
+		}
+
+		if matches := hexLineRegex.FindStringSubmatch(line); matches != nil {
+			if property == "EDID" {
+				edid.WriteString(matches[1])
+			}
 			continue
 		}
 
-		if currentDisplay != nil && currentDisplay.Connected {
-			if matches := modeLineRegex.FindStringSubmatch(line); matches != nil {
-				width, _ := strconv.Atoi(matches[1])
-				height, _ := strconv.Atoi(matches[2])
-				rate, _ := strconv.ParseFloat(matches[3], 64)
-				flags := matches[4]
-
-				mode := models.Mode{
-					Width:     width,
-					Height:    height,
-					Rate:      rate,
-					Current:   strings.Contains(flags, "*"),
-					Preferred: strings.Contains(flags, "+"),
-				}
-
-				currentDisplay.Modes = append(currentDisplay.Modes, mode)
-
-				if mode.Current {
-					modeCopy := mode
-					currentDisplay.CurrentMode = &modeCopy
-				}
+		if matches := propertyRegex.FindStringSubmatch(line); matches != nil {
+			property = matches[1]
+			if property == "EDID" {
+				continue
 			}
+			if currentDisplay.Properties == nil {
+				currentDisplay.Properties = map[string]string{}
+			}
+			currentDisplay.Properties[property] = matches[2]
+			continue
 		}
-	}
 
-	if currentDisplay != nil {
-		displays = append(displays, *currentDisplay)
+		if matches := verboseModeRegex.FindStringSubmatch(line); matches != nil {
+			flushMode()
+			flags := matches[2]
+			verboseMode = &models.Mode{
+				Name:       matches[1],
+				Interlaced: strings.Contains(flags, "Interlace"),
+				Current:    strings.Contains(flags, "*current"),
+				Preferred:  strings.Contains(flags, "+preferred"),
+			}
+			continue
+		}
+
+		if verboseMode != nil {
+			if matches := verboseSizeRegex.FindStringSubmatch(line); matches != nil {
+				verboseMode.Width, _ = strconv.Atoi(matches[1])
+				continue
+			}
+			if matches := verboseRateRegex.FindStringSubmatch(line); matches != nil {
+				verboseMode.Height, _ = strconv.Atoi(matches[1])
+				verboseMode.Rate, _ = strconv.ParseFloat(matches[2], 64)
+				flushMode()
+				continue
+			}
+		}
+
+		if matches := modeLineRegex.FindStringSubmatch(line); matches != nil {
+			width, _ := strconv.Atoi(matches[2])
+			height, _ := strconv.Atoi(matches[3])
+
+			for _, rate := range rateRegex.FindAllStringSubmatch(matches[5], -1) {
+				value, _ := strconv.ParseFloat(rate[1], 64)
+				b.addMode(currentDisplay, models.Mode{
+					Name:       matches[1],
+					Width:      width,
+					Height:     height,
+					Rate:       value,
+					Interlaced: matches[4] == "i",
+					Current:    rate[2] == "*",
+					Preferred:  rate[3] != "",
+				})
+			}
+		}
 	}
 
+	flushDisplay()
+
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("scanner error: %w", err)
 	}
@@ -130,13 +253,13 @@ func (b *Backend) parseXrandrOutput(output string) ([]models.Display, error) {
 	return displays, nil
 }
 
-func (b *Backend) identifyDisplayType(displayID string) models.DisplayType {
-	for _, pattern := range internalPatterns {
-		if strings.HasPrefix(displayID, pattern) {
-			return models.Internal
-		}
+func (b *Backend) addMode(display *models.Display, mode models.Mode) {
+	display.Modes = append(display.Modes, mode)
+
+	if mode.Current {
+		modeCopy := mode
+		display.CurrentMode = &modeCopy
 	}
-	return models.External
 }
 
 func (b *Backend) Configure(ctx context.Context, config models.DisplayConfig, displays []models.Display) (*models.ConfigResult, error) {
@@ -146,97 +269,22 @@ func (b *Backend) Configure(ctx context.Context, config models.DisplayConfig, di
 		"position": config.Position,
 	}).Info("Configuring displays")
 
-	internal, externals := b.categorizeDisplays(displays)
-
-	if internal == nil {
-		return nil, fmt.Errorf("no internal display found")
+	plan, err := b.planner.Plan(config, displays)
+	if err != nil {
+		return nil, err
 	}
 
-	var args []string
-	var configuredDisplays []models.ConfiguredDisplay
-
-	switch config.Target {
-	case models.TargetInternal:
-		res := b.getResolution(internal, config.Mode, config.CustomResolution)
-		if res == "" {
-			if config.CustomResolution != "" {
-				return nil, fmt.Errorf("resolution %s not available for %s. Use 'dmon list' to see available resolutions", config.CustomResolution, internal.ID)
-			}
-			return nil, fmt.Errorf("failed to determine resolution for %s", internal.ID)
-		}
-		args = b.buildInternalOnlyConfig(internal, res)
-		configuredDisplays = []models.ConfiguredDisplay{
-			{
-				ID:         internal.ID,
-				Type:       internal.Type,
-				Resolution: res,
-				Active:     true,
-			},
-		}
-
-	case models.TargetExternal:
-		if len(externals) == 0 {
-			return nil, fmt.Errorf("no external displays found. Try 'dmon list' to see available displays")
-		}
-		res := b.getResolution(externals[0], config.Mode, config.CustomResolution)
-		if res == "" {
-			if config.CustomResolution != "" {
-				return nil, fmt.Errorf("resolution %s not available for %s. Use 'dmon list' to see available resolutions", config.CustomResolution, externals[0].ID)
-			}
-			return nil, fmt.Errorf("failed to determine resolution for %s", externals[0].ID)
-		}
-		args = b.buildExternalOnlyConfig(internal, externals[0], res)
-		configuredDisplays = []models.ConfiguredDisplay{
-			{
-				ID:         externals[0].ID,
-				Type:       externals[0].Type,
-				Resolution: res,
-				Active:     true,
-			},
-			{
-				ID:         internal.ID,
-				Type:       internal.Type,
-				Resolution: "",
-				Active:     false,
-			},
-		}
+	return b.Apply(ctx, plan)
+}
 
-	case models.TargetBoth:
-		if len(externals) == 0 {
-			return nil, fmt.Errorf("no external displays found. Try 'dmon list' to see available displays")
-		}
-		internalRes := b.getResolution(internal, config.Mode, config.CustomResolution)
-		if internalRes == "" {
-			if config.CustomResolution != "" {
-				return nil, fmt.Errorf("resolution %s not available for %s. Use 'dmon list' to see available resolutions", config.CustomResolution, internal.ID)
-			}
-			return nil, fmt.Errorf("failed to determine resolution for %s", internal.ID)
-		}
-		externalRes := b.getResolution(externals[0], config.Mode, "")
-		if externalRes == "" {
-			return nil, fmt.Errorf("failed to determine resolution for %s", externals[0].ID)
-		}
-		args = b.buildDualConfig(internal, externals[0], internalRes, externalRes, config.Position)
-		configuredDisplays = []models.ConfiguredDisplay{
-			{
-				ID:         externals[0].ID,
-				Type:       externals[0].Type,
-				Resolution: externalRes,
-				Active:     true,
-			},
-			{
-				ID:         internal.ID,
-				Type:       internal.Type,
-				Resolution: internalRes,
-				Active:     true,
-			},
-		}
-	}
+// Apply runs a single xrandr command that puts every output of the plan in place.
+func (b *Backend) Apply(ctx context.Context, plan *planner.Plan) (*models.ConfigResult, error) {
+	args := buildArgs(plan)
 
 	b.logger.WithField("args", strings.Join(args, " ")).Debug("Executing xrandr command")
 
-	cmd := exec.CommandContext(ctx, "xrandr", args...)
-	output, err := cmd.CombinedOutput()
+	result, err := b.runner.Run(ctx, "xrandr", args...)
+	output := result.Combined()
 	if err != nil {
 		b.logger.WithFields(logrus.Fields{
 			"error":  err,
@@ -247,195 +295,43 @@ func (b *Backend) Configure(ctx context.Context, config models.DisplayConfig, di
 
 	b.logger.Info("Display configuration applied successfully")
 
-	result := &models.ConfigResult{
-		Displays: configuredDisplays,
-		Config:   config,
-	}
-
-	return result, nil
+	return plan.Result(), nil
 }
 
-func (b *Backend) categorizeDisplays(displays []models.Display) (*models.Display, []*models.Display) {
-	var internal *models.Display
-	var externals []*models.Display
+func buildArgs(plan *planner.Plan) []string {
+	var args []string
 
-	for i := range displays {
-		if !displays[i].Connected {
+	for _, o := range plan.Outputs {
+		args = append(args, "--output", o.ID)
+		if !o.Enabled {
+			args = append(args, "--off")
 			continue
 		}
-		if displays[i].Type == models.Internal {
-			internal = &displays[i]
-		} else {
-			externals = append(externals, &displays[i])
-		}
-	}
-
-	return internal, externals
-}
-
-func (b *Backend) buildInternalOnlyConfig(internal *models.Display, resolution string) []string {
-	return []string{
-		"--output", internal.ID,
-		"--mode", resolution,
-		"--primary",
-	}
-}
-
-func (b *Backend) buildExternalOnlyConfig(internal *models.Display, external *models.Display, resolution string) []string {
-	return []string{
-		"--output", external.ID,
-		"--mode", resolution,
-		"--primary",
-		"--output", internal.ID,
-		"--off",
-	}
-}
-
-func (b *Backend) buildDualConfig(internal *models.Display, external *models.Display, internalRes string, externalRes string, pos models.Position) []string {
-	args := []string{
-		"--output", external.ID,
-		"--mode", externalRes,
-		"--primary",
-		"--output", internal.ID,
-		"--mode", internalRes,
-	}
-
-	switch pos {
-	case models.PositionLeft:
-		args = append(args, "--left-of", external.ID)
-	case models.PositionRight:
-		args = append(args, "--right-of", external.ID)
-	case models.PositionAbove:
-		args = append(args, "--above", external.ID)
-	case models.PositionBelow:
-		args = append(args, "--below", external.ID)
-	default:
-		args = append(args, "--right-of", external.ID)
-	}
-
-	return args
-}
-
-func (b *Backend) getResolution(display *models.Display, mode models.ResolutionMode, customResolution string) string {
-	if customResolution != "" {
-		width, height, err := parseCustomResolution(customResolution)
-		if err != nil {
-			b.logger.WithError(err).Error("Invalid custom resolution format")
-			return ""
-		}
-		return b.findClosestMode(display, width, height, customResolution)
-	}
-
-	switch mode {
-	case models.ModePreset:
-		if display.Type == models.Internal {
-			return b.findClosestMode(display, 1920, 1200, "")
-		}
-		return b.findClosestMode(display, 1920, 1080, "")
 
-	case models.ModeLow:
-		if display.Type == models.Internal {
-			return b.findClosestMode(display, 1600, 1000, "")
+		args = append(args, "--mode", o.Mode)
+		if o.Rate > 0 {
+			args = append(args, "--rate", strconv.FormatFloat(o.Rate, 'f', 2, 64))
 		}
-		return b.findClosestMode(display, 1280, 720, "")
-
-	case models.ModeHighest:
-		return b.findNativeMode(display)
-
-	default:
-		return b.findNativeMode(display)
-	}
-}
-
-func (b *Backend) findClosestMode(display *models.Display, targetWidth, targetHeight int, customResolution string) string {
-	for _, mode := range display.Modes {
-		if mode.Width == targetWidth && mode.Height == targetHeight {
-			return fmt.Sprintf("%dx%d", mode.Width, mode.Height)
+		if o.Absolute {
+			args = append(args, "--pos", fmt.Sprintf("%dx%d", o.X, o.Y))
 		}
-	}
-
-	availableModes := b.formatAvailableModes(display)
-
-	if customResolution != "" {
-		b.logger.WithFields(logrus.Fields{
-			"display": display.ID,
-			"target":  customResolution,
-		}).Error("Custom resolution not available")
-		b.logger.Error(availableModes)
-		return ""
-	}
-
-	if display.CurrentMode != nil {
-		b.logger.WithFields(logrus.Fields{
-			"display": display.ID,
-			"target":  fmt.Sprintf("%dx%d", targetWidth, targetHeight),
-		}).Warn("Target resolution not available")
-		b.logger.Warn(availableModes)
-		b.logger.WithField("resolution", fmt.Sprintf("%dx%d", display.CurrentMode.Width, display.CurrentMode.Height)).Info("Using current mode as fallback")
-		return fmt.Sprintf("%dx%d", display.CurrentMode.Width, display.CurrentMode.Height)
-	}
-
-	return b.findNativeMode(display)
-}
-
-func (b *Backend) findNativeMode(display *models.Display) string {
-	var best models.Mode
-	maxPixels := 0
-
-	for _, mode := range display.Modes {
-		pixels := mode.Width * mode.Height
-		if pixels > maxPixels {
-			maxPixels = pixels
-			best = mode
+		if o.Primary {
+			args = append(args, "--primary")
 		}
-	}
-
-	if maxPixels > 0 {
-		return fmt.Sprintf("%dx%d", best.Width, best.Height)
-	}
-
-	return "auto"
-}
-
-func parseCustomResolution(res string) (width, height int, err error) {
-	if res == "" {
-		return 0, 0, nil
-	}
-
-	parts := strings.Split(res, "x")
-	if len(parts) != 2 {
-		return 0, 0, fmt.Errorf("invalid resolution format '%s'. Use format: WIDTHxHEIGHT (e.g., 1920x1200)", res)
-	}
-
-	width, err = strconv.Atoi(parts[0])
-	if err != nil || width <= 0 {
-		return 0, 0, fmt.Errorf("invalid width in resolution '%s'", res)
-	}
-
-	height, err = strconv.Atoi(parts[1])
-	if err != nil || height <= 0 {
-		return 0, 0, fmt.Errorf("invalid height in resolution '%s'", res)
-	}
-
-	return width, height, nil
-}
-
-func (b *Backend) formatAvailableModes(display *models.Display) string {
-	var lines []string
-	lines = append(lines, fmt.Sprintf("\nAvailable resolutions for %s:", display.ID))
 
-	for _, mode := range display.Modes {
-		marker := ""
-		if mode.Current {
-			marker = " (current) *"
-		} else if mode.Preferred {
-			marker = " +"
+		switch o.Relation {
+		case models.PositionLeft:
+			args = append(args, "--left-of", o.RelativeTo)
+		case models.PositionRight:
+			args = append(args, "--right-of", o.RelativeTo)
+		case models.PositionAbove:
+			args = append(args, "--above", o.RelativeTo)
+		case models.PositionBelow:
+			args = append(args, "--below", o.RelativeTo)
 		}
-		lines = append(lines, fmt.Sprintf("  %dx%d@%.2fHz%s",
-			mode.Width, mode.Height, mode.Rate, marker))
 	}
 
-	return strings.Join(lines, "\n")
+	return args
 }
 
 func (b *Backend) GetCurrentLayout(ctx context.Context) (*models.Layout, error) {
@@ -450,6 +346,13 @@ func (b *Backend) GetCurrentLayout(ctx context.Context) (*models.Layout, error)
 		Displays: displays,
 	}
 
+	for _, d := range displays {
+		if d.Primary {
+			layout.Primary = d.ID
+			return layout, nil
+		}
+	}
+
 	for _, d := range displays {
 		if d.Connected && d.CurrentMode != nil {
 			layout.Primary = d.ID
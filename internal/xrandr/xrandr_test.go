@@ -0,0 +1,275 @@
+package xrandr
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abhishek/dmon-cli/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/*.golden from the current parser")
+
+func newTestBackend() *Backend {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewBackend(logger)
+}
+
+// TestParseGolden parses every capture in testdata and compares the result
+// with its .golden file. Run `go test ./internal/xrandr -update` after an
+// intentional parser change and review the diff.
+func TestParseGolden(t *testing.T) {
+	captures, err := filepath.Glob(filepath.Join("testdata", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(captures) == 0 {
+		t.Fatal("no captures found in testdata")
+	}
+
+	b := newTestBackend()
+
+	for _, capture := range captures {
+		name := strings.TrimSuffix(filepath.Base(capture), ".txt")
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(capture)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			displays, err := b.parseXrandrOutput(string(input))
+			if err != nil {
+				t.Fatalf("parse failed: %v", err)
+			}
+
+			got, err := json.MarshalIndent(displays, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := strings.TrimSuffix(capture, ".txt") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("missing golden file (run with -update): %v", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("parsed output differs from %s; rerun with -update and review the diff\n%s", golden, firstDiff(string(want), string(got)))
+			}
+		})
+	}
+}
+
+func firstDiff(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d:\n  want: %s\n  got:  %s", i+1, w, g)
+		}
+	}
+	return ""
+}
+
+// TestParseExpectations pins the properties each capture exists to cover, so a
+// blindly regenerated golden file cannot hide a regression.
+func TestParseExpectations(t *testing.T) {
+	tests := []struct {
+		capture string
+		check   func(t *testing.T, displays []models.Display)
+	}{
+		{
+			capture: "laptop-edp",
+			check: func(t *testing.T, displays []models.Display) {
+				d := find(t, displays, "eDP-1")
+				if d.Type != models.Internal || !d.Primary {
+					t.Errorf("eDP-1 should be internal and primary, got %s primary=%v", d.Type, d.Primary)
+				}
+				if d.CurrentMode == nil || d.CurrentMode.Width != 1920 || d.CurrentMode.Rate != 60.02 {
+					t.Errorf("unexpected current mode %v", d.CurrentMode)
+				}
+				// Every rate on a mode line is its own mode.
+				if got := countModes(d, 1920, 1080); got != 6 {
+					t.Errorf("expected 6 1920x1080 modes, got %d", got)
+				}
+				if find(t, displays, "HDMI-1").Connected {
+					t.Error("HDMI-1 should be disconnected")
+				}
+			},
+		},
+		{
+			capture: "laptop-lvds",
+			check: func(t *testing.T, displays []models.Display) {
+				if d := find(t, displays, "LVDS1"); d.Type != models.Internal || d.Primary {
+					t.Errorf("LVDS1 should be internal and not primary, got %s primary=%v", d.Type, d.Primary)
+				}
+				if d := find(t, displays, "VGA1"); d.Type != models.External || !d.Primary || d.X != 1366 {
+					t.Errorf("VGA1 should be external primary at x=1366, got %s primary=%v x=%d", d.Type, d.Primary, d.X)
+				}
+				find(t, displays, "VIRTUAL1")
+			},
+		},
+		{
+			capture: "dock-mst",
+			check: func(t *testing.T, displays []models.Display) {
+				for _, id := range []string{"DP-1-1", "DP-1-2"} {
+					if d := find(t, displays, id); !d.Connected || d.Type != models.External || d.CurrentMode == nil {
+						t.Errorf("%s should be a connected, active external display", id)
+					}
+				}
+				if !find(t, displays, "DP-1-1").Primary {
+					t.Error("DP-1-1 should be primary")
+				}
+				if find(t, displays, "DP-1-3").Connected {
+					t.Error("DP-1-3 should be disconnected")
+				}
+			},
+		},
+		{
+			capture: "disconnected-with-modes",
+			check: func(t *testing.T, displays []models.Display) {
+				d := find(t, displays, "HDMI-1")
+				if d.Connected || len(d.Modes) != 1 || d.Modes[0].Rate != 60 || d.X != 1920 {
+					t.Errorf("HDMI-1 should be disconnected at x=1920 with one 60Hz mode, got %+v", d)
+				}
+				if d := find(t, displays, "DP-1"); len(d.Modes) != 1 || d.Modes[0].Width != 2560 {
+					t.Errorf("DP-1 should keep its 2560x1440 mode, got %+v", d.Modes)
+				}
+				// Stale modes must not leak into the next output.
+				if got := len(find(t, displays, "DP-2").Modes); got != 0 {
+					t.Errorf("DP-2 should have no modes, got %d", got)
+				}
+			},
+		},
+		{
+			capture: "interlaced",
+			check: func(t *testing.T, displays []models.Display) {
+				d := find(t, displays, "HDMI-1")
+				interlaced := 0
+				for _, m := range d.Modes {
+					if m.Interlaced {
+						interlaced++
+						if !strings.HasSuffix(m.Name, "i") {
+							t.Errorf("interlaced mode has name %q", m.Name)
+						}
+					}
+				}
+				if interlaced != 6 {
+					t.Errorf("expected 6 interlaced modes, got %d", interlaced)
+				}
+				if d.CurrentMode == nil || d.CurrentMode.Interlaced {
+					t.Errorf("current mode should be progressive, got %v", d.CurrentMode)
+				}
+			},
+		},
+		{
+			capture: "rotated",
+			check: func(t *testing.T, displays []models.Display) {
+				if d := find(t, displays, "DP-2"); d.Rotation != "left" || !d.Primary || d.WidthMM != 527 {
+					t.Errorf("DP-2 should be primary, rotated left, 527mm wide, got %+v", d)
+				}
+				if d := find(t, displays, "HDMI-1"); d.Rotation != "inverted" || d.Y != 1920 {
+					t.Errorf("HDMI-1 should be inverted at y=1920, got rotation=%q y=%d", d.Rotation, d.Y)
+				}
+				if d := find(t, displays, "eDP-1"); d.Rotation != "normal" {
+					t.Errorf("eDP-1 should be normal, got %q", d.Rotation)
+				}
+			},
+		},
+		{
+			capture: "custom-mode",
+			check: func(t *testing.T, displays []models.Display) {
+				d := find(t, displays, "HDMI-1")
+				if d.CurrentMode == nil || d.CurrentMode.Name != "2560x1080_60.00" || d.CurrentMode.Width != 2560 {
+					t.Errorf("current mode should be the custom 2560x1080 mode, got %v", d.CurrentMode)
+				}
+				// "60.00 +" marks preferred without current.
+				if m := d.Modes[0]; !m.Preferred || m.Current {
+					t.Errorf("first mode should be preferred only, got %+v", m)
+				}
+			},
+		},
+		{
+			capture: "verbose-dual",
+			check: func(t *testing.T, displays []models.Display) {
+				d := find(t, displays, "DP-1")
+				if len(d.EDID) != 128 || d.EDID[0] != 0x00 || d.EDID[1] != 0xff {
+					t.Errorf("DP-1 EDID not decoded, got %d bytes", len(d.EDID))
+				}
+				if d.Properties["Brightness"] != "0.80" || d.Properties["CONNECTOR_ID"] != "103" {
+					t.Errorf("unexpected properties %v", d.Properties)
+				}
+				if d.CurrentMode == nil || d.CurrentMode.Rate != 59.99 || d.CurrentMode.Height != 2160 {
+					t.Errorf("unexpected current mode %v", d.CurrentMode)
+				}
+				if got := len(d.Modes); got != 5 {
+					t.Errorf("expected 5 modes, got %d", got)
+				}
+				if !d.Modes[4].Interlaced {
+					t.Error("1920x1080i should be interlaced")
+				}
+				if e := find(t, displays, "eDP-1"); e.Properties["panel orientation"] != "Normal" || len(e.EDID) != 128 {
+					t.Errorf("eDP-1 properties not parsed: %v", e.Properties)
+				}
+			},
+		},
+	}
+
+	b := newTestBackend()
+
+	for _, tt := range tests {
+		t.Run(tt.capture, func(t *testing.T) {
+			input, err := os.ReadFile(filepath.Join("testdata", tt.capture+".txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			displays, err := b.parseXrandrOutput(string(input))
+			if err != nil {
+				t.Fatalf("parse failed: %v", err)
+			}
+			tt.check(t, displays)
+		})
+	}
+}
+
+func find(t *testing.T, displays []models.Display, id string) models.Display {
+	t.Helper()
+	for _, d := range displays {
+		if d.ID == id {
+			return d
+		}
+	}
+	t.Fatalf("display %s not found", id)
+	return models.Display{}
+}
+
+func countModes(d models.Display, width, height int) int {
+	n := 0
+	for _, m := range d.Modes {
+		if m.Width == width && m.Height == height {
+			n++
+		}
+	}
+	return n
+}